@@ -9,6 +9,8 @@ import (
 	"castafiore-backend/internal/auth"
 	"castafiore-backend/internal/config"
 	"castafiore-backend/internal/database"
+	"castafiore-backend/internal/subsonic"
+	"castafiore-backend/internal/web"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,6 +28,13 @@ func main() {
 
 	// Initialize services
 	authService := auth.NewService(cfg.JWTSecret)
+	subsonicService := subsonic.NewService(db.DB, authService, cfg)
+	webController := web.NewWebController(db.DB, authService, cfg)
+
+	// Start background jobs: podcast downloads, scrobble queues, recommendations,
+	// search index and dashboard analytics
+	subsonicService.StartBackgroundJobs()
+	webController.StartBackgroundJobs()
 
 	// Setup router
 	router := gin.Default()
@@ -45,7 +54,7 @@ func main() {
 	})
 
 	// Setup API routes
-	api.SetupRoutes(router, authService, db.DB, cfg)
+	api.SetupRoutes(router, subsonicService, webController)
 
 	// Start server
 	address := cfg.Host + ":" + cfg.Port
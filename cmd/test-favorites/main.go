@@ -31,7 +31,7 @@ func main() {
 
 	// Initialize services
 	authService := auth.NewService(cfg.JWTSecret)
-	subsonicService := subsonic.NewService(db.DB, authService, cfg.LastFMAPIKey)
+	subsonicService := subsonic.NewService(db.DB, authService, cfg)
 
 	// Set Gin to test mode
 	gin.SetMode(gin.TestMode)
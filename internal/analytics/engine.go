@@ -0,0 +1,145 @@
+package analytics
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+// refreshOverlap is how many days before the last aggregated day each refresh
+// recomputes, so scrobbles submitted late by offline clients are still counted
+const refreshOverlap = 7
+
+// playBytes estimates the bytes served for a play from the bitrate (kbps) and the time
+// played, capped at the size of the file. Downloads count the whole file.
+const playBytes = `CASE
+	WHEN COALESCE(s.bitrate, 0) > 0 THEN LEAST(COALESCE(s.file_size, 0),
+		COALESCE(ph.duration_played, s.duration, 0)::bigint * s.bitrate * 125)
+	ELSE COALESCE(s.file_size, 0)
+END`
+
+// Engine aggregates streams, downloads and library growth into the tables the admin
+// dashboard reads, so its charts don't scan the raw history on every load
+type Engine struct {
+	db *sql.DB
+}
+
+func NewEngine(db *sql.DB) *Engine {
+	return &Engine{db: db}
+}
+
+// Start refreshes the aggregates now and then every interval
+func (e *Engine) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := e.Refresh(); err != nil {
+				log.Printf("[Analytics] Error refreshing analytics: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+	log.Printf("[Analytics] Analytics refresh started (interval: %s)", interval)
+}
+
+// Refresh updates the usage of the days since the last refresh, records today's library
+// size and rebuilds the storage table
+func (e *Engine) Refresh() error {
+	start := time.Now()
+
+	var last sql.NullTime
+	if err := e.db.QueryRow("SELECT MAX(day) FROM usage_daily").Scan(&last); err != nil {
+		return err
+	}
+	// The first refresh aggregates the whole history
+	var from time.Time
+	if last.Valid {
+		from = last.Time.AddDate(0, 0, -refreshOverlap)
+	}
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"DELETE FROM usage_daily WHERE day >= $1::date", []interface{}{from}},
+		{`
+			INSERT INTO usage_daily (day, user_id, streams, downloads, bytes_served)
+			SELECT day, user_id, SUM(streams), SUM(downloads), SUM(bytes)
+			FROM (
+				SELECT ph.played_at::date AS day, ph.user_id, 1 AS streams, 0 AS downloads, ` + playBytes + ` AS bytes
+				FROM play_history ph
+				JOIN songs s ON s.id = ph.song_id
+				WHERE ph.played_at >= $1::date AND ph.user_id IS NOT NULL
+				UNION ALL
+				SELECT d.downloaded_at::date, d.user_id, 0, 1, COALESCE(s.file_size, 0)
+				FROM downloads d
+				JOIN songs s ON s.id = d.song_id
+				WHERE d.downloaded_at >= $1::date AND d.user_id IS NOT NULL
+			) u
+			GROUP BY day, user_id
+		`, []interface{}{from}},
+		{"DELETE FROM song_streams_daily WHERE day >= $1::date", []interface{}{from}},
+		{`
+			INSERT INTO song_streams_daily (day, song_id, streams)
+			SELECT ph.played_at::date, ph.song_id, COUNT(*)
+			FROM play_history ph
+			JOIN songs s ON s.id = ph.song_id
+			WHERE ph.played_at >= $1::date
+			GROUP BY 1, 2
+		`, []interface{}{from}},
+		// The first refresh has no snapshots yet: the days before today are estimated
+		// from when the current artists, albums and songs were added
+		{`
+			INSERT INTO library_growth_daily (day, artists, albums, songs, bytes)
+			SELECT day,
+			       SUM(SUM(artists)) OVER (ORDER BY day), SUM(SUM(albums)) OVER (ORDER BY day),
+			       SUM(SUM(songs)) OVER (ORDER BY day), SUM(SUM(bytes)) OVER (ORDER BY day)
+			FROM (
+				SELECT created_at::date AS day, 1 AS artists, 0 AS albums, 0 AS songs, 0::bigint AS bytes FROM artists
+				UNION ALL
+				SELECT created_at::date, 0, 1, 0, 0 FROM albums
+				UNION ALL
+				SELECT created_at::date, 0, 0, 1, COALESCE(file_size, 0) FROM songs
+			) l
+			WHERE day < CURRENT_DATE AND NOT EXISTS (SELECT 1 FROM library_growth_daily)
+			GROUP BY day
+		`, nil},
+		{`
+			INSERT INTO library_growth_daily (day, artists, albums, songs, bytes)
+			SELECT CURRENT_DATE,
+			       (SELECT COUNT(*) FROM artists), (SELECT COUNT(*) FROM albums),
+			       COUNT(*), COALESCE(SUM(file_size), 0)
+			FROM songs
+			ON CONFLICT (day) DO UPDATE SET
+				artists = EXCLUDED.artists, albums = EXCLUDED.albums,
+				songs = EXCLUDED.songs, bytes = EXCLUDED.bytes
+		`, nil},
+		{"DELETE FROM storage_by_format", nil},
+		{`
+			INSERT INTO storage_by_format (format, songs, bytes)
+			SELECT LOWER(COALESCE(NULLIF(format, ''), 'unknown')), COUNT(*), COALESCE(SUM(file_size), 0)
+			FROM songs
+			GROUP BY 1
+		`, nil},
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement.query, statement.args...); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("[Analytics] Aggregated usage since %s (%s)",
+		from.Format("2006-01-02"), time.Since(start).Round(time.Millisecond))
+	return nil
+}
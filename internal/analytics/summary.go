@@ -0,0 +1,251 @@
+package analytics
+
+import (
+	"time"
+)
+
+const (
+	// DefaultDays and MaxDays bound the window of the dashboard
+	DefaultDays = 30
+	MaxDays     = 365
+	// topLimit is the length of the lists of users and songs
+	topLimit = 10
+)
+
+const dayLayout = "2006-01-02"
+
+// Day is the usage of the server on a day
+type Day struct {
+	Day         string `json:"day"`
+	Streams     int    `json:"streams"`
+	Downloads   int    `json:"downloads"`
+	Bytes       int64  `json:"bytes"`
+	ActiveUsers int    `json:"activeUsers"`
+}
+
+// UserUsage is the usage of a user in the window
+type UserUsage struct {
+	Username  string `json:"username"`
+	Streams   int    `json:"streams"`
+	Downloads int    `json:"downloads"`
+	Bytes     int64  `json:"bytes"`
+}
+
+// Song is one of the most streamed songs of the window
+type Song struct {
+	ID      int    `json:"id"`
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+	Streams int    `json:"streams"`
+}
+
+// Growth is the size of the library at the end of a day
+type Growth struct {
+	Day     string `json:"day"`
+	Artists int    `json:"artists"`
+	Albums  int    `json:"albums"`
+	Songs   int    `json:"songs"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Storage is the space used by the songs of a format
+type Storage struct {
+	Format string `json:"format"`
+	Songs  int    `json:"songs"`
+	Bytes  int64  `json:"bytes"`
+}
+
+// Summary is what the dashboard shows for a window of days ending today
+type Summary struct {
+	From        string      `json:"from"`
+	To          string      `json:"to"`
+	Streams     int         `json:"streams"`
+	Downloads   int         `json:"downloads"`
+	Bytes       int64       `json:"bytes"`
+	ActiveUsers int         `json:"activeUsers"`
+	Days        []Day       `json:"days"`
+	Users       []UserUsage `json:"users"`
+	TopSongs    []Song      `json:"topSongs"`
+	Growth      []Growth    `json:"growth"`
+	Storage     []Storage   `json:"storage"`
+}
+
+// Summary reads the aggregates of the last days, today included
+func (e *Engine) Summary(days int, now time.Time) (*Summary, error) {
+	if days <= 0 {
+		days = DefaultDays
+	}
+	if days > MaxDays {
+		days = MaxDays
+	}
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	from := to.AddDate(0, 0, -(days - 1))
+
+	s := &Summary{From: from.Format(dayLayout), To: to.Format(dayLayout)}
+	var err error
+	if s.Days, err = e.days(from, to); err != nil {
+		return nil, err
+	}
+	for _, day := range s.Days {
+		s.Streams += day.Streams
+		s.Downloads += day.Downloads
+		s.Bytes += day.Bytes
+	}
+
+	err = e.db.QueryRow(`
+		SELECT COUNT(DISTINCT user_id) FROM usage_daily WHERE day BETWEEN $1::date AND $2::date
+	`, from, to).Scan(&s.ActiveUsers)
+	if err != nil {
+		return nil, err
+	}
+	if s.Users, err = e.users(from, to); err != nil {
+		return nil, err
+	}
+	if s.TopSongs, err = e.topSongs(from, to); err != nil {
+		return nil, err
+	}
+	if s.Growth, err = e.growth(); err != nil {
+		return nil, err
+	}
+	if s.Storage, err = e.storage(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// days returns the usage of every day from from to to, days without usage included
+func (e *Engine) days(from, to time.Time) ([]Day, error) {
+	rows, err := e.db.Query(`
+		SELECT day, SUM(streams), SUM(downloads), SUM(bytes_served), COUNT(*)
+		FROM usage_daily
+		WHERE day BETWEEN $1::date AND $2::date
+		GROUP BY day
+	`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string]Day)
+	for rows.Next() {
+		var day Day
+		var date time.Time
+		if err := rows.Scan(&date, &day.Streams, &day.Downloads, &day.Bytes, &day.ActiveUsers); err != nil {
+			return nil, err
+		}
+		day.Day = date.Format(dayLayout)
+		usage[day.Day] = day
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return fillDays(from, to, usage), nil
+}
+
+// fillDays lists the days from from to to, with their usage when there is any
+func fillDays(from, to time.Time, usage map[string]Day) []Day {
+	var days []Day
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(dayLayout)
+		entry, ok := usage[key]
+		if !ok {
+			entry = Day{Day: key}
+		}
+		days = append(days, entry)
+	}
+	return days
+}
+
+func (e *Engine) users(from, to time.Time) ([]UserUsage, error) {
+	rows, err := e.db.Query(`
+		SELECT u.username, SUM(d.streams), SUM(d.downloads), SUM(d.bytes_served)
+		FROM usage_daily d
+		JOIN users u ON u.id = d.user_id
+		WHERE d.day BETWEEN $1::date AND $2::date
+		GROUP BY u.username
+		ORDER BY SUM(d.bytes_served) DESC, u.username
+		LIMIT $3
+	`, from, to, topLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserUsage{}
+	for rows.Next() {
+		var user UserUsage
+		if err := rows.Scan(&user.Username, &user.Streams, &user.Downloads, &user.Bytes); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (e *Engine) topSongs(from, to time.Time) ([]Song, error) {
+	rows, err := e.db.Query(`
+		SELECT s.id, s.title, ar.name, SUM(d.streams)
+		FROM song_streams_daily d
+		JOIN songs s ON s.id = d.song_id
+		JOIN artists ar ON ar.id = s.artist_id
+		WHERE d.day BETWEEN $1::date AND $2::date
+		GROUP BY s.id, s.title, ar.name
+		ORDER BY SUM(d.streams) DESC, s.title
+		LIMIT $3
+	`, from, to, topLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	songs := []Song{}
+	for rows.Next() {
+		var song Song
+		if err := rows.Scan(&song.ID, &song.Title, &song.Artist, &song.Streams); err != nil {
+			return nil, err
+		}
+		songs = append(songs, song)
+	}
+	return songs, rows.Err()
+}
+
+// growth returns the size of the library at the end of each day a snapshot was taken
+func (e *Engine) growth() ([]Growth, error) {
+	rows, err := e.db.Query(`
+		SELECT day, artists, albums, songs, bytes FROM library_growth_daily ORDER BY day
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []Growth
+	for rows.Next() {
+		var day Growth
+		var date time.Time
+		if err := rows.Scan(&date, &day.Artists, &day.Albums, &day.Songs, &day.Bytes); err != nil {
+			return nil, err
+		}
+		day.Day = date.Format(dayLayout)
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+func (e *Engine) storage() ([]Storage, error) {
+	rows, err := e.db.Query("SELECT format, songs, bytes FROM storage_by_format ORDER BY bytes DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	formats := []Storage{}
+	for rows.Next() {
+		var format Storage
+		if err := rows.Scan(&format.Format, &format.Songs, &format.Bytes); err != nil {
+			return nil, err
+		}
+		formats = append(formats, format)
+	}
+	return formats, rows.Err()
+}
@@ -0,0 +1,26 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFillDays(t *testing.T) {
+	from := time.Date(2024, 2, 28, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	usage := map[string]Day{
+		"2024-02-29": {Day: "2024-02-29", Streams: 4, Downloads: 1, Bytes: 1024, ActiveUsers: 2},
+		// Days outside the window are left out
+		"2024-03-02": {Day: "2024-03-02", Streams: 9},
+	}
+
+	want := []Day{
+		{Day: "2024-02-28"},
+		{Day: "2024-02-29", Streams: 4, Downloads: 1, Bytes: 1024, ActiveUsers: 2},
+		{Day: "2024-03-01"},
+	}
+	if got := fillDays(from, to, usage); !reflect.DeepEqual(got, want) {
+		t.Errorf("fillDays() = %+v, want %+v", got, want)
+	}
+}
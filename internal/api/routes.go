@@ -1,19 +1,16 @@
 package api
 
 import (
-	"database/sql"
 	"html/template"
 	"net/http"
 
-	"castafiore-backend/internal/auth"
-	"castafiore-backend/internal/config"
 	"castafiore-backend/internal/subsonic"
 	"castafiore-backend/internal/web"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg *config.Config) {
+func SetupRoutes(router *gin.Engine, subsonicService *subsonic.Service, webController *web.WebController) {
 	// Configure HTML templates with custom functions
 	funcMap := template.FuncMap{
 		"add": func(a, b int) int { return a + b },
@@ -21,17 +18,25 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		"mul": func(a, b int) int { return a * b },
 		"div": func(a, b int) int { return a / b },
 		"mod": func(a, b int) int { return a % b },
+		"dict": func(pairs ...interface{}) map[string]interface{} {
+			m := make(map[string]interface{}, len(pairs)/2)
+			for i := 0; i+1 < len(pairs); i += 2 {
+				m[pairs[i].(string)] = pairs[i+1]
+			}
+			return m
+		},
+		"weekday": func(d int) string {
+			return [...]string{"Domingo", "Lunes", "Martes", "Miércoles", "Jueves", "Viernes", "Sábado"}[d%7]
+		},
+		"month": func(m int) string {
+			return [...]string{"Enero", "Febrero", "Marzo", "Abril", "Mayo", "Junio", "Julio",
+				"Agosto", "Septiembre", "Octubre", "Noviembre", "Diciembre"}[(m-1)%12]
+		},
 	}
 
 	tmpl := template.Must(template.New("").Funcs(funcMap).ParseGlob("web/templates/*"))
 	router.SetHTMLTemplate(tmpl)
 
-	// Create subsonic service
-	subsonicService := subsonic.NewService(db, authService, cfg)
-
-	// Create web controller
-	webController := web.NewWebController(db, authService, cfg)
-
 	// Authentication routes (no middleware)
 	router.GET("/login", webController.LoginForm)
 	router.POST("/login", webController.Login)
@@ -45,6 +50,10 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 	// Static files
 	router.Static("/static", "./static")
 
+	// Public share links (no authentication)
+	router.GET("/share/:token", subsonicService.SharePage)
+	router.GET("/share/:token/stream", subsonicService.ShareStream)
+
 	// Web Admin Interface (with authentication middleware)
 	admin := router.Group("/admin")
 	admin.Use(webController.AuthMiddleware())
@@ -59,6 +68,15 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		admin.POST("/users/:id/delete", webController.DeleteUser)
 		admin.GET("/music", webController.MusicBrowser)
 		admin.GET("/music/search", webController.SearchMusic)
+		admin.GET("/radio", webController.RadioStations)
+		admin.POST("/radio/create", webController.CreateRadioStation)
+		admin.POST("/radio/:id/edit", webController.EditRadioStation)
+		admin.POST("/radio/:id/delete", webController.DeleteRadioStation)
+		admin.GET("/playlists", webController.Playlists)
+		admin.POST("/playlists/import", webController.ImportPlaylistFiles)
+		admin.GET("/playlists/:id/export", webController.ExportPlaylist)
+		admin.GET("/stats", webController.ListeningStats)
+		admin.GET("/stats/yearly", webController.ExportYearlyReport)
 		admin.GET("/settings", webController.Settings)
 		admin.POST("/settings/update-music-path", webController.UpdateMusicPath)
 
@@ -68,6 +86,8 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		admin.POST("/api/scan-library", webController.ScanLibrary)
 		admin.GET("/api/scan-progress", webController.GetScanProgress)
 		admin.GET("/api/library-stats", webController.GetLibraryStats)
+		admin.GET("/api/analytics", webController.APIAnalytics)
+		admin.POST("/api/lastfm-cache/purge", webController.PurgeLastFMCache)
 	}
 
 	// Subsonic API endpoints
@@ -95,6 +115,10 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		rest.GET("/getArtist.view", subsonicService.AuthMiddleware(), subsonicService.GetArtist)
 		rest.GET("/getArtistInfo2", subsonicService.AuthMiddleware(), subsonicService.GetArtistInfo2)
 		rest.GET("/getArtistInfo2.view", subsonicService.AuthMiddleware(), subsonicService.GetArtistInfo2)
+		rest.GET("/getAlbumInfo", subsonicService.AuthMiddleware(), subsonicService.GetAlbumInfo)
+		rest.GET("/getAlbumInfo.view", subsonicService.AuthMiddleware(), subsonicService.GetAlbumInfo)
+		rest.GET("/getAlbumInfo2", subsonicService.AuthMiddleware(), subsonicService.GetAlbumInfo)
+		rest.GET("/getAlbumInfo2.view", subsonicService.AuthMiddleware(), subsonicService.GetAlbumInfo)
 		rest.GET("/getAlbum", subsonicService.AuthMiddleware(), subsonicService.GetAlbum)
 		rest.GET("/getAlbum.view", subsonicService.AuthMiddleware(), subsonicService.GetAlbum)
 		rest.GET("/getSong", subsonicService.AuthMiddleware(), subsonicService.GetSong)
@@ -105,12 +129,22 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		rest.GET("/getAlbumList.view", subsonicService.AuthMiddleware(), subsonicService.GetAlbumList)
 		rest.GET("/getAlbumList2", subsonicService.AuthMiddleware(), subsonicService.GetAlbumList2)
 		rest.GET("/getAlbumList2.view", subsonicService.AuthMiddleware(), subsonicService.GetAlbumList2)
+		rest.GET("/getDecades", subsonicService.AuthMiddleware(), subsonicService.GetDecades)
+		rest.GET("/getDecades.view", subsonicService.AuthMiddleware(), subsonicService.GetDecades)
+		rest.GET("/getOnThisDay", subsonicService.AuthMiddleware(), subsonicService.GetOnThisDay)
+		rest.GET("/getOnThisDay.view", subsonicService.AuthMiddleware(), subsonicService.GetOnThisDay)
+		rest.GET("/getListeningStats", subsonicService.AuthMiddleware(), subsonicService.GetListeningStats)
+		rest.GET("/getListeningStats.view", subsonicService.AuthMiddleware(), subsonicService.GetListeningStats)
+		rest.GET("/getYearlyReport", subsonicService.AuthMiddleware(), subsonicService.GetYearlyReport)
+		rest.GET("/getYearlyReport.view", subsonicService.AuthMiddleware(), subsonicService.GetYearlyReport)
 		rest.GET("/getRandomSongs", subsonicService.AuthMiddleware(), subsonicService.GetRandomSongs)
 		rest.GET("/getRandomSongs.view", subsonicService.AuthMiddleware(), subsonicService.GetRandomSongs)
 		rest.GET("/getTopSongs", subsonicService.AuthMiddleware(), subsonicService.GetTopSongs)
 		rest.GET("/getTopSongs.view", subsonicService.AuthMiddleware(), subsonicService.GetTopSongs)
 		rest.GET("/getSongsByGenre", subsonicService.AuthMiddleware(), subsonicService.GetSongsByGenre)
 		rest.GET("/getSongsByGenre.view", subsonicService.AuthMiddleware(), subsonicService.GetSongsByGenre)
+		rest.GET("/getSimilarSongs", subsonicService.AuthMiddleware(), subsonicService.GetSimilarSongs)
+		rest.GET("/getSimilarSongs.view", subsonicService.AuthMiddleware(), subsonicService.GetSimilarSongs)
 		rest.GET("/getSimilarSongs2", subsonicService.AuthMiddleware(), subsonicService.GetSimilarSongs2)
 		rest.GET("/getSimilarSongs2.view", subsonicService.AuthMiddleware(), subsonicService.GetSimilarSongs2)
 		rest.GET("/getNowPlaying", subsonicService.AuthMiddleware(), subsonicService.GetNowPlaying)
@@ -138,6 +172,24 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		rest.GET("/updatePlaylist.view", subsonicService.AuthMiddleware(), subsonicService.UpdatePlaylist)
 		rest.GET("/deletePlaylist", subsonicService.AuthMiddleware(), subsonicService.DeletePlaylist)
 		rest.GET("/deletePlaylist.view", subsonicService.AuthMiddleware(), subsonicService.DeletePlaylist)
+		rest.GET("/movePlaylistEntry", subsonicService.AuthMiddleware(), subsonicService.MovePlaylistEntry)
+		rest.GET("/movePlaylistEntry.view", subsonicService.AuthMiddleware(), subsonicService.MovePlaylistEntry)
+		rest.GET("/getPlaylistCollaborators", subsonicService.AuthMiddleware(), subsonicService.GetPlaylistCollaborators)
+		rest.GET("/getPlaylistCollaborators.view", subsonicService.AuthMiddleware(), subsonicService.GetPlaylistCollaborators)
+		rest.GET("/setPlaylistCollaborator", subsonicService.AuthMiddleware(), subsonicService.SetPlaylistCollaborator)
+		rest.GET("/setPlaylistCollaborator.view", subsonicService.AuthMiddleware(), subsonicService.SetPlaylistCollaborator)
+		rest.POST("/setPlaylistCover", subsonicService.AuthMiddleware(), subsonicService.SetPlaylistCover)
+		rest.POST("/setPlaylistCover.view", subsonicService.AuthMiddleware(), subsonicService.SetPlaylistCover)
+		rest.GET("/deletePlaylistCover", subsonicService.AuthMiddleware(), subsonicService.DeletePlaylistCover)
+		rest.GET("/deletePlaylistCover.view", subsonicService.AuthMiddleware(), subsonicService.DeletePlaylistCover)
+		rest.GET("/exportPlaylist", subsonicService.AuthMiddleware(), subsonicService.ExportPlaylist)
+		rest.GET("/exportPlaylist.view", subsonicService.AuthMiddleware(), subsonicService.ExportPlaylist)
+		rest.GET("/createSmartPlaylist", subsonicService.AuthMiddleware(), subsonicService.CreateSmartPlaylist)
+		rest.GET("/createSmartPlaylist.view", subsonicService.AuthMiddleware(), subsonicService.CreateSmartPlaylist)
+		rest.POST("/createSmartPlaylist", subsonicService.AuthMiddleware(), subsonicService.CreateSmartPlaylist)
+		rest.POST("/createSmartPlaylist.view", subsonicService.AuthMiddleware(), subsonicService.CreateSmartPlaylist)
+		rest.POST("/importSmartPlaylist", subsonicService.AuthMiddleware(), subsonicService.ImportSmartPlaylist)
+		rest.POST("/importSmartPlaylist.view", subsonicService.AuthMiddleware(), subsonicService.ImportSmartPlaylist)
 
 		// Media retrieval (both with and without .view suffix)
 		rest.GET("/stream", subsonicService.AuthMiddleware(), subsonicService.Stream)
@@ -176,6 +228,76 @@ func SetupRoutes(router *gin.Engine, authService *auth.Service, db *sql.DB, cfg
 		rest.GET("/scrobble.view", subsonicService.AuthMiddleware(), subsonicService.Scrobble)
 		rest.GET("/setNowPlaying", subsonicService.AuthMiddleware(), subsonicService.SetNowPlaying)
 		rest.GET("/setNowPlaying.view", subsonicService.AuthMiddleware(), subsonicService.SetNowPlaying)
+
+		// Bookmarks (both with and without .view suffix)
+		rest.GET("/getBookmarks", subsonicService.AuthMiddleware(), subsonicService.GetBookmarks)
+		rest.GET("/getBookmarks.view", subsonicService.AuthMiddleware(), subsonicService.GetBookmarks)
+		rest.GET("/createBookmark", subsonicService.AuthMiddleware(), subsonicService.CreateBookmark)
+		rest.GET("/createBookmark.view", subsonicService.AuthMiddleware(), subsonicService.CreateBookmark)
+		rest.GET("/deleteBookmark", subsonicService.AuthMiddleware(), subsonicService.DeleteBookmark)
+		rest.GET("/deleteBookmark.view", subsonicService.AuthMiddleware(), subsonicService.DeleteBookmark)
+
+		// Play queue (both with and without .view suffix)
+		rest.GET("/getPlayQueue", subsonicService.AuthMiddleware(), subsonicService.GetPlayQueue)
+		rest.GET("/getPlayQueue.view", subsonicService.AuthMiddleware(), subsonicService.GetPlayQueue)
+		rest.GET("/savePlayQueue", subsonicService.AuthMiddleware(), subsonicService.SavePlayQueue)
+		rest.GET("/savePlayQueue.view", subsonicService.AuthMiddleware(), subsonicService.SavePlayQueue)
+		rest.GET("/getPlayQueueByIndex", subsonicService.AuthMiddleware(), subsonicService.GetPlayQueueByIndex)
+		rest.GET("/getPlayQueueByIndex.view", subsonicService.AuthMiddleware(), subsonicService.GetPlayQueueByIndex)
+		rest.GET("/savePlayQueueByIndex", subsonicService.AuthMiddleware(), subsonicService.SavePlayQueueByIndex)
+		rest.GET("/savePlayQueueByIndex.view", subsonicService.AuthMiddleware(), subsonicService.SavePlayQueueByIndex)
+
+		// Shares (both with and without .view suffix)
+		rest.GET("/getShares", subsonicService.AuthMiddleware(), subsonicService.GetShares)
+		rest.GET("/getShares.view", subsonicService.AuthMiddleware(), subsonicService.GetShares)
+		rest.GET("/createShare", subsonicService.AuthMiddleware(), subsonicService.CreateShare)
+		rest.GET("/createShare.view", subsonicService.AuthMiddleware(), subsonicService.CreateShare)
+		rest.GET("/updateShare", subsonicService.AuthMiddleware(), subsonicService.UpdateShare)
+		rest.GET("/updateShare.view", subsonicService.AuthMiddleware(), subsonicService.UpdateShare)
+		rest.GET("/deleteShare", subsonicService.AuthMiddleware(), subsonicService.DeleteShare)
+		rest.GET("/deleteShare.view", subsonicService.AuthMiddleware(), subsonicService.DeleteShare)
+
+		// Internet radio (both with and without .view suffix)
+		rest.GET("/getInternetRadioStations", subsonicService.AuthMiddleware(), subsonicService.GetInternetRadioStations)
+		rest.GET("/getInternetRadioStations.view", subsonicService.AuthMiddleware(), subsonicService.GetInternetRadioStations)
+		rest.GET("/createInternetRadioStation", subsonicService.AuthMiddleware(), subsonicService.CreateInternetRadioStation)
+		rest.GET("/createInternetRadioStation.view", subsonicService.AuthMiddleware(), subsonicService.CreateInternetRadioStation)
+		rest.GET("/updateInternetRadioStation", subsonicService.AuthMiddleware(), subsonicService.UpdateInternetRadioStation)
+		rest.GET("/updateInternetRadioStation.view", subsonicService.AuthMiddleware(), subsonicService.UpdateInternetRadioStation)
+		rest.GET("/deleteInternetRadioStation", subsonicService.AuthMiddleware(), subsonicService.DeleteInternetRadioStation)
+		rest.GET("/deleteInternetRadioStation.view", subsonicService.AuthMiddleware(), subsonicService.DeleteInternetRadioStation)
+
+		// Podcasts (both with and without .view suffix)
+		rest.GET("/getPodcasts", subsonicService.AuthMiddleware(), subsonicService.GetPodcasts)
+		rest.GET("/getPodcasts.view", subsonicService.AuthMiddleware(), subsonicService.GetPodcasts)
+		rest.GET("/getNewestPodcasts", subsonicService.AuthMiddleware(), subsonicService.GetNewestPodcasts)
+		rest.GET("/getNewestPodcasts.view", subsonicService.AuthMiddleware(), subsonicService.GetNewestPodcasts)
+		rest.GET("/refreshPodcasts", subsonicService.AuthMiddleware(), subsonicService.RefreshPodcasts)
+		rest.GET("/refreshPodcasts.view", subsonicService.AuthMiddleware(), subsonicService.RefreshPodcasts)
+		rest.GET("/createPodcastChannel", subsonicService.AuthMiddleware(), subsonicService.CreatePodcastChannel)
+		rest.GET("/createPodcastChannel.view", subsonicService.AuthMiddleware(), subsonicService.CreatePodcastChannel)
+		rest.GET("/deletePodcastChannel", subsonicService.AuthMiddleware(), subsonicService.DeletePodcastChannel)
+		rest.GET("/deletePodcastChannel.view", subsonicService.AuthMiddleware(), subsonicService.DeletePodcastChannel)
+		rest.GET("/downloadPodcastEpisode", subsonicService.AuthMiddleware(), subsonicService.DownloadPodcastEpisode)
+		rest.GET("/downloadPodcastEpisode.view", subsonicService.AuthMiddleware(), subsonicService.DownloadPodcastEpisode)
+		rest.GET("/deletePodcastEpisode", subsonicService.AuthMiddleware(), subsonicService.DeletePodcastEpisode)
+		rest.GET("/deletePodcastEpisode.view", subsonicService.AuthMiddleware(), subsonicService.DeletePodcastEpisode)
+
+		// Last.fm scrobbling account link (Castafiore extension)
+		rest.GET("/getLastfmLink", subsonicService.AuthMiddleware(), subsonicService.GetLastFMLink)
+		rest.GET("/getLastfmLink.view", subsonicService.AuthMiddleware(), subsonicService.GetLastFMLink)
+		rest.GET("/startLastfmLink", subsonicService.AuthMiddleware(), subsonicService.StartLastFMLink)
+		rest.GET("/startLastfmLink.view", subsonicService.AuthMiddleware(), subsonicService.StartLastFMLink)
+		rest.GET("/completeLastfmLink", subsonicService.AuthMiddleware(), subsonicService.CompleteLastFMLink)
+		rest.GET("/completeLastfmLink.view", subsonicService.AuthMiddleware(), subsonicService.CompleteLastFMLink)
+		rest.GET("/unlinkLastfm", subsonicService.AuthMiddleware(), subsonicService.UnlinkLastFM)
+		rest.GET("/unlinkLastfm.view", subsonicService.AuthMiddleware(), subsonicService.UnlinkLastFM)
+
+		// ListenBrainz token (Castafiore extension)
+		rest.GET("/getListenBrainzLink", subsonicService.AuthMiddleware(), subsonicService.GetListenBrainzLink)
+		rest.GET("/getListenBrainzLink.view", subsonicService.AuthMiddleware(), subsonicService.GetListenBrainzLink)
+		rest.GET("/setListenBrainzToken", subsonicService.AuthMiddleware(), subsonicService.SetListenBrainzToken)
+		rest.GET("/setListenBrainzToken.view", subsonicService.AuthMiddleware(), subsonicService.SetListenBrainzToken)
 	}
 
 	// Health check endpoint
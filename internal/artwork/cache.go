@@ -0,0 +1,119 @@
+package artwork
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// jpegQuality is the quality of generated images
+const jpegQuality = 90
+
+// MosaicCache stores generated mosaics as JPEG files. The file name includes the size and
+// a hash of the source covers and their modification times, so a mosaic is rebuilt as
+// soon as the covers it is made of change, and older versions are removed at that moment.
+type MosaicCache struct {
+	dir string
+	mu  sync.Mutex // serializes generation so a mosaic is not removed while written
+}
+
+func NewMosaicCache(dir string) *MosaicCache {
+	return &MosaicCache{dir: dir}
+}
+
+// Get returns the path of the mosaic named name built from the cover files, creating
+// it if needed. Covers that cannot be decoded are skipped.
+func (m *MosaicCache) Get(name string, covers []string, size int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	prefix := fmt.Sprintf("%s-%d-", name, size)
+	path := filepath.Join(m.dir, prefix+mosaicKey(covers)+".jpg")
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	var images []image.Image
+	for _, cover := range covers {
+		img, err := Load(cover)
+		if err != nil {
+			log.Printf("Artwork: Skipping cover %s: %v", cover, err)
+			continue
+		}
+		images = append(images, img)
+		if len(images) == MosaicTiles {
+			break
+		}
+	}
+
+	mosaic, err := Mosaic(images, size)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return "", err
+	}
+	// Older versions of this size are stale now
+	m.removeMatching(prefix + "*.jpg")
+	if err := writeJPEG(path, mosaic); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Remove deletes every cached mosaic named name, in all sizes
+func (m *MosaicCache) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeMatching(name + "-*.jpg")
+}
+
+func (m *MosaicCache) removeMatching(pattern string) {
+	matches, err := filepath.Glob(filepath.Join(m.dir, pattern))
+	if err != nil {
+		return
+	}
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil && !os.IsNotExist(err) {
+			log.Printf("Artwork: Error removing %s: %v", match, err)
+		}
+	}
+}
+
+// mosaicKey identifies the inputs of a mosaic
+func mosaicKey(covers []string) string {
+	h := sha1.New()
+	for _, cover := range covers {
+		var modified int64
+		if info, err := os.Stat(cover); err == nil {
+			modified = info.ModTime().UnixNano()
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", cover, modified)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// writeJPEG encodes img to a temporary file first so readers never see a partial image
+func writeJPEG(path string, img image.Image) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".mosaic-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := jpeg.Encode(tmp, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,148 @@
+// Package artwork generates images that do not exist in the library, such as the
+// cover mosaics of playlists, using only the standard image packages
+package artwork
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif" // registered for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+)
+
+const (
+	// DefaultSize is the side in pixels of generated images
+	DefaultSize = 600
+	// MinSize and MaxSize bound the size requested by clients
+	MinSize = 64
+	MaxSize = 1200
+	// MosaicTiles is how many distinct covers a mosaic uses
+	MosaicTiles = 4
+)
+
+// ErrNoImages means there is nothing to build a mosaic from
+var ErrNoImages = errors.New("no images to build a mosaic from")
+
+// ClampSize returns size within MinSize and MaxSize, or DefaultSize when it is not set
+func ClampSize(size int) int {
+	switch {
+	case size <= 0:
+		return DefaultSize
+	case size < MinSize:
+		return MinSize
+	case size > MaxSize:
+		return MaxSize
+	}
+	return size
+}
+
+// Load decodes an image file in any of the registered formats (JPEG, PNG, GIF)
+func Load(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// Mosaic lays out up to four images as a size x size square. A single image fills the
+// square; with two or three the grid repeats them on the diagonal so the tiles stay
+// balanced: [a b / b a] and [a b / c a].
+func Mosaic(images []image.Image, size int) (image.Image, error) {
+	if len(images) == 0 {
+		return nil, ErrNoImages
+	}
+	if len(images) > MosaicTiles {
+		images = images[:MosaicTiles]
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.Black), image.Point{}, draw.Src)
+
+	if len(images) == 1 {
+		draw.Draw(dst, dst.Bounds(), Square(images[0], size), image.Point{}, draw.Src)
+		return dst, nil
+	}
+
+	var tiles [4]image.Image
+	switch len(images) {
+	case 2:
+		tiles = [4]image.Image{images[0], images[1], images[1], images[0]}
+	case 3:
+		tiles = [4]image.Image{images[0], images[1], images[2], images[0]}
+	default:
+		tiles = [4]image.Image{images[0], images[1], images[2], images[3]}
+	}
+
+	// The second column and row take the odd pixel of sizes that do not split evenly;
+	// tiles are drawn square and clipped to their cell
+	edges := [3]int{0, size / 2, size}
+	for i, tile := range tiles {
+		cell := image.Rect(edges[i%2], edges[i/2], edges[i%2+1], edges[i/2+1])
+		side := cell.Dx()
+		if cell.Dy() > side {
+			side = cell.Dy()
+		}
+		draw.Draw(dst, cell, Square(tile, side), image.Point{}, draw.Src)
+	}
+	return dst, nil
+}
+
+// Square crops the centre square of src and scales it to size x size, averaging the
+// source pixels that fall into each destination pixel
+func Square(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(b.Min.X+(b.Dx()-side)/2, b.Min.Y+(b.Dy()-side)/2))
+	return scale(src, crop, size)
+}
+
+// scale resizes the crop rectangle of src to size x size. Downscaling uses a box filter,
+// upscaling falls back to the nearest source pixel.
+func scale(src image.Image, crop image.Rectangle, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if crop.Empty() || size <= 0 {
+		return dst
+	}
+
+	side := crop.Dx()
+	for y := 0; y < size; y++ {
+		sy0 := crop.Min.Y + y*side/size
+		sy1 := crop.Min.Y + (y+1)*side/size
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < size; x++ {
+			sx0 := crop.Min.X + x*side/size
+			sx1 := crop.Min.X + (x+1)*side/size
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,197 @@
+package artwork
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+	white = color.RGBA{255, 255, 255, 255}
+)
+
+func solid(c color.Color, w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func assertColor(t *testing.T, img image.Image, x, y int, want color.RGBA) {
+	t.Helper()
+	got := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	if got != want {
+		t.Errorf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+	}
+}
+
+func TestMosaicLayout(t *testing.T) {
+	tests := []struct {
+		name   string
+		images []image.Image
+		want   [4]color.RGBA // top left, top right, bottom left, bottom right
+	}{
+		{"single", []image.Image{solid(red, 10, 10)}, [4]color.RGBA{red, red, red, red}},
+		{"two", []image.Image{solid(red, 10, 10), solid(green, 10, 10)}, [4]color.RGBA{red, green, green, red}},
+		{"three", []image.Image{solid(red, 10, 10), solid(green, 10, 10), solid(blue, 10, 10)}, [4]color.RGBA{red, green, blue, red}},
+		{"four", []image.Image{solid(red, 10, 10), solid(green, 10, 10), solid(blue, 10, 10), solid(white, 10, 10)}, [4]color.RGBA{red, green, blue, white}},
+		{"extra images are ignored", []image.Image{solid(red, 10, 10), solid(green, 10, 10), solid(blue, 10, 10), solid(white, 10, 10), solid(color.Black, 10, 10)}, [4]color.RGBA{red, green, blue, white}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mosaic, err := Mosaic(tt.images, 101)
+			if err != nil {
+				t.Fatalf("Mosaic() error = %v", err)
+			}
+			if got := mosaic.Bounds(); got != image.Rect(0, 0, 101, 101) {
+				t.Fatalf("Mosaic() bounds = %v, want 101x101", got)
+			}
+			assertColor(t, mosaic, 0, 0, tt.want[0])
+			assertColor(t, mosaic, 100, 0, tt.want[1])
+			assertColor(t, mosaic, 0, 100, tt.want[2])
+			assertColor(t, mosaic, 100, 100, tt.want[3])
+		})
+	}
+}
+
+func TestMosaicWithoutImages(t *testing.T) {
+	if _, err := Mosaic(nil, 100); err != ErrNoImages {
+		t.Errorf("Mosaic(nil) error = %v, want ErrNoImages", err)
+	}
+}
+
+func TestSquareCropsTheCentre(t *testing.T) {
+	// A wide image with red bands on both sides of a green centre square
+	src := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 300; x++ {
+			if x >= 100 && x < 200 {
+				src.Set(x, y, green)
+			} else {
+				src.Set(x, y, red)
+			}
+		}
+	}
+
+	square := Square(src, 50)
+	if got := square.Bounds(); got != image.Rect(0, 0, 50, 50) {
+		t.Fatalf("Square() bounds = %v, want 50x50", got)
+	}
+	assertColor(t, square, 0, 0, green)
+	assertColor(t, square, 49, 49, green)
+}
+
+func TestSquareAveragesPixels(t *testing.T) {
+	// Alternating black and white columns average to grey when halved
+	src := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			if x%2 == 0 {
+				src.Set(x, y, color.Black)
+			} else {
+				src.Set(x, y, white)
+			}
+		}
+	}
+
+	got := color.RGBAModel.Convert(Square(src, 2).At(0, 0)).(color.RGBA)
+	if got.R < 126 || got.R > 128 || got.A != 255 {
+		t.Errorf("Square() pixel = %v, want mid grey", got)
+	}
+}
+
+func TestClampSize(t *testing.T) {
+	tests := map[int]int{0: DefaultSize, -5: DefaultSize, 10: MinSize, 300: 300, 5000: MaxSize}
+	for size, want := range tests {
+		if got := ClampSize(size); got != want {
+			t.Errorf("ClampSize(%d) = %d, want %d", size, got, want)
+		}
+	}
+}
+
+func writePNG(t *testing.T, path string, img image.Image) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMosaicCache(t *testing.T) {
+	dir := t.TempDir()
+	coverA := filepath.Join(dir, "a.png")
+	coverB := filepath.Join(dir, "b.png")
+	writePNG(t, coverA, solid(red, 20, 20))
+	writePNG(t, coverB, solid(green, 20, 20))
+
+	cache := NewMosaicCache(filepath.Join(dir, "cache"))
+	covers := []string{coverA, filepath.Join(dir, "missing.png"), coverB}
+
+	first, err := cache.Get("pl-1", covers, 64)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	img, err := Load(first)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if img.Bounds().Dx() != 64 {
+		t.Errorf("mosaic width = %d, want 64", img.Bounds().Dx())
+	}
+
+	again, err := cache.Get("pl-1", covers, 64)
+	if err != nil || again != first {
+		t.Errorf("Get() = %s, %v, want cached %s", again, err, first)
+	}
+
+	// Replacing a cover produces a new mosaic and drops the stale one
+	writePNG(t, coverB, solid(blue, 20, 20))
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(coverB, later, later)
+
+	updated, err := cache.Get("pl-1", covers, 64)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if updated == first {
+		t.Error("Get() returned the stale mosaic after a cover changed")
+	}
+	if _, err := os.Stat(first); !os.IsNotExist(err) {
+		t.Errorf("stale mosaic %s was not removed", first)
+	}
+
+	// Other sizes are kept side by side until the playlist is removed
+	small, err := cache.Get("pl-1", covers, MinSize+1)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if _, err := os.Stat(updated); err != nil {
+		t.Errorf("mosaic of another size was removed: %v", err)
+	}
+
+	cache.Remove("pl-1")
+	for _, path := range []string{updated, small} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Remove() kept %s", path)
+		}
+	}
+
+	if _, err := cache.Get("pl-2", []string{filepath.Join(dir, "missing.png")}, 64); err != ErrNoImages {
+		t.Errorf("Get() without readable covers error = %v, want ErrNoImages", err)
+	}
+}
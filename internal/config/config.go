@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -17,6 +18,11 @@ type Config struct {
 	MaxConcurrentStreams int
 	MaxDownloadsPerDay   int
 	LastFMAPIKey         string
+	LastFMSharedSecret   string   // Necesario para firmar scrobbles y "now playing" en Last.fm
+	MetadataAgents       []string // Orden de prioridad de los agentes de metadatos
+	ListenBrainzURL      string   // API de ListenBrainz (permite instancias propias)
+	PodcastPath          string   // Directorio donde se descargan los episodios de podcasts
+	DataPath             string   // Directorio para imágenes subidas y generadas (portadas de playlists)
 }
 
 func Load() *Config {
@@ -34,6 +40,11 @@ func Load() *Config {
 		MaxConcurrentStreams: getEnvInt("MAX_CONCURRENT_STREAMS", 3),
 		MaxDownloadsPerDay:   getEnvInt("MAX_DOWNLOADS_PER_DAY", 50),
 		LastFMAPIKey:         getEnv("LASTFM_API_KEY", ""),
+		LastFMSharedSecret:   getEnv("LASTFM_SHARED_SECRET", ""),
+		MetadataAgents:       strings.Split(getEnv("METADATA_AGENTS", "lastfm,files,library"), ","),
+		ListenBrainzURL:      getEnv("LISTENBRAINZ_URL", "https://api.listenbrainz.org"),
+		PodcastPath:          getEnv("PODCAST_PATH", "./podcasts"),
+		DataPath:             getEnv("DATA_PATH", "./data"),
 	}
 }
 
@@ -0,0 +1,31 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/lib/pq"
+)
+
+// Array is a slice parameter for queries such as "id = ANY($1)"
+type Array interface {
+	driver.Valuer
+	sql.Scanner
+}
+
+// Ints and Strings pass a slice as a Postgres array. Unlike pq.Array they never send
+// NULL: pq sends a nil slice as NULL, and "x = ANY(NULL)" or "x <> ALL(NULL)" is never
+// true, so a nil slice would match nothing where an empty array matches as expected.
+func Ints(values []int) Array {
+	if values == nil {
+		values = []int{}
+	}
+	return pq.Array(values)
+}
+
+func Strings(values []string) Array {
+	if values == nil {
+		values = []string{}
+	}
+	return pq.Array(values)
+}
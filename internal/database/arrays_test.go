@@ -0,0 +1,15 @@
+package database
+
+import "testing"
+
+func TestArraysNeverNull(t *testing.T) {
+	for name, array := range map[string]Array{"Ints": Ints(nil), "Strings": Strings(nil)} {
+		value, err := array.Value()
+		if err != nil {
+			t.Fatalf("%s(nil).Value() error = %v", name, err)
+		}
+		if value != "{}" {
+			t.Errorf("%s(nil).Value() = %v, want {}", name, value)
+		}
+	}
+}
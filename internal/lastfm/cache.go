@@ -0,0 +1,159 @@
+package lastfm
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Default freshness of cached responses per Last.fm method
+var DefaultTTLs = map[string]time.Duration{
+	"artist.getinfo":      7 * 24 * time.Hour,
+	"artist.getsimilar":   7 * 24 * time.Hour,
+	"track.getsimilar":    7 * 24 * time.Hour,
+	"artist.gettoptracks": 3 * 24 * time.Hour,
+	"album.getinfo":       30 * 24 * time.Hour,
+}
+
+const (
+	// defaultTTL applies to methods without an entry in DefaultTTLs
+	defaultTTL = 24 * time.Hour
+	// notFoundTTL is how long "not found" answers are remembered
+	notFoundTTL = 24 * time.Hour
+	// staleWindow is how long an expired entry is still served while it is refreshed
+	staleWindow = 30 * 24 * time.Hour
+)
+
+// CacheEntry is a raw Last.fm response body stored in the cache
+type CacheEntry struct {
+	Method    string
+	Body      []byte
+	NotFound  bool
+	FetchedAt time.Time
+}
+
+// Cache stores Last.fm responses by normalized request key
+type Cache interface {
+	// Get returns the entry for key, or nil if there is none
+	Get(key string) (*CacheEntry, error)
+	Set(key string, entry CacheEntry) error
+	// Purge removes the entries of a method, or every entry when method is empty
+	Purge(method string) (int64, error)
+}
+
+// cacheKey builds a stable key from the request parameters, ignoring case and
+// extra whitespace so "The Beatles" and " the  beatles" share an entry
+func cacheKey(params url.Values) string {
+	normalized := url.Values{}
+	for name, values := range params {
+		if name == "api_key" || name == "format" {
+			continue
+		}
+		for _, v := range values {
+			normalized.Add(name, normalizeName(v))
+		}
+	}
+	return normalized.Get("method") + "|" + normalized.Encode()
+}
+
+func normalizeName(value string) string {
+	return strings.Join(strings.Fields(strings.ToLower(value)), " ")
+}
+
+// MemoryCache is an in-process Cache, used when no database is available and in tests
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]CacheEntry
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]CacheEntry)}
+}
+
+func (m *MemoryCache) Get(key string) (*CacheEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+func (m *MemoryCache) Set(key string, entry CacheEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.entries[key] = entry
+	return nil
+}
+
+func (m *MemoryCache) Purge(method string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for key, entry := range m.entries {
+		if method == "" || entry.Method == strings.ToLower(method) {
+			delete(m.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// PostgresCache persists responses in the lastfm_cache table so they survive restarts
+type PostgresCache struct {
+	db *sql.DB
+}
+
+func NewPostgresCache(db *sql.DB) *PostgresCache {
+	return &PostgresCache{db: db}
+}
+
+func (p *PostgresCache) Get(key string) (*CacheEntry, error) {
+	var entry CacheEntry
+	var body string
+
+	err := p.db.QueryRow(`
+		SELECT method, body, not_found, fetched_at
+		FROM lastfm_cache
+		WHERE cache_key = $1
+	`, key).Scan(&entry.Method, &body, &entry.NotFound, &entry.FetchedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Body = []byte(body)
+	return &entry, nil
+}
+
+func (p *PostgresCache) Set(key string, entry CacheEntry) error {
+	_, err := p.db.Exec(`
+		INSERT INTO lastfm_cache (cache_key, method, body, not_found, fetched_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (cache_key)
+		DO UPDATE SET body = $3, not_found = $4, fetched_at = $5
+	`, key, entry.Method, string(entry.Body), entry.NotFound, entry.FetchedAt)
+	return err
+}
+
+func (p *PostgresCache) Purge(method string) (int64, error) {
+	var result sql.Result
+	var err error
+	if method == "" {
+		result, err = p.db.Exec("DELETE FROM lastfm_cache")
+	} else {
+		result, err = p.db.Exec("DELETE FROM lastfm_cache WHERE method = $1", strings.ToLower(method))
+	}
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,214 @@
+package lastfm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testAPIKey = "0123456789abcdef"
+
+// fakeLastFM serves canned artist.getInfo responses and counts requests
+type fakeLastFM struct {
+	server *httptest.Server
+	hits   int32
+}
+
+func newFakeLastFM(t *testing.T) *fakeLastFM {
+	f := &fakeLastFM{}
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&f.hits, 1)
+
+		if r.URL.Query().Get("api_key") != testAPIKey {
+			t.Errorf("request without API key: %s", r.URL)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Query().Get("artist") {
+		case "Nobody Knows":
+			w.Write([]byte(`{"error":6,"message":"The artist you supplied could not be found"}`))
+		case "Rate Limited":
+			w.Write([]byte(`{"error":29,"message":"Rate limit exceeded"}`))
+		default:
+			w.Write([]byte(`{"artist":{"name":"` + r.URL.Query().Get("artist") + `","mbid":"abc","bio":{"summary":"Bio"}}}`))
+		}
+	}))
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeLastFM) count() int {
+	return int(atomic.LoadInt32(&f.hits))
+}
+
+func newTestService(baseURL string, cache Cache) (*Service, *time.Time) {
+	s := NewService(Config{APIKey: testAPIKey, BaseURL: baseURL, Cache: cache})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func TestCacheHit(t *testing.T) {
+	fake := newFakeLastFM(t)
+	s, _ := newTestService(fake.server.URL, NewMemoryCache())
+
+	for i := 0; i < 3; i++ {
+		info, err := s.GetArtistInfo("Radiohead")
+		if err != nil {
+			t.Fatalf("GetArtistInfo() error = %v", err)
+		}
+		if info.Artist.MBID != "abc" {
+			t.Errorf("MBID = %q, want %q", info.Artist.MBID, "abc")
+		}
+	}
+
+	// Names are normalized, so these share the cached entry
+	if _, err := s.GetArtistInfo("  radiohead "); err != nil {
+		t.Fatalf("GetArtistInfo() error = %v", err)
+	}
+
+	if fake.count() != 1 {
+		t.Errorf("Last.fm was called %d times, want 1", fake.count())
+	}
+}
+
+func TestCacheNegative(t *testing.T) {
+	fake := newFakeLastFM(t)
+	s, _ := newTestService(fake.server.URL, NewMemoryCache())
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetArtistInfo("Nobody Knows"); err == nil {
+			t.Fatal("GetArtistInfo() expected not found error")
+		}
+	}
+
+	if fake.count() != 1 {
+		t.Errorf("Last.fm was called %d times, want 1 (not found should be cached)", fake.count())
+	}
+}
+
+func TestCacheDoesNotStoreOtherErrors(t *testing.T) {
+	fake := newFakeLastFM(t)
+	s, _ := newTestService(fake.server.URL, NewMemoryCache())
+
+	for i := 0; i < 2; i++ {
+		if _, err := s.GetArtistInfo("Rate Limited"); err == nil {
+			t.Fatal("GetArtistInfo() expected rate limit error")
+		}
+	}
+
+	if fake.count() != 2 {
+		t.Errorf("Last.fm was called %d times, want 2 (transient errors must not be cached)", fake.count())
+	}
+}
+
+func TestCacheStaleWhileRevalidate(t *testing.T) {
+	fake := newFakeLastFM(t)
+	s, now := newTestService(fake.server.URL, NewMemoryCache())
+
+	if _, err := s.GetArtistInfo("Radiohead"); err != nil {
+		t.Fatalf("GetArtistInfo() error = %v", err)
+	}
+
+	// Past the TTL but inside the stale window: served from cache, refreshed in background
+	*now = now.Add(DefaultTTLs["artist.getinfo"] + time.Hour)
+	if _, err := s.GetArtistInfo("Radiohead"); err != nil {
+		t.Fatalf("GetArtistInfo() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for fake.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fake.count() != 2 {
+		t.Fatalf("Last.fm was called %d times, want 2 (background refresh)", fake.count())
+	}
+
+	// The refreshed entry is fresh again
+	for !s.idle() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := s.GetArtistInfo("Radiohead"); err != nil {
+		t.Fatalf("GetArtistInfo() error = %v", err)
+	}
+	if fake.count() != 2 {
+		t.Errorf("Last.fm was called %d times, want 2", fake.count())
+	}
+}
+
+func TestCacheServesStaleWhenOffline(t *testing.T) {
+	fake := newFakeLastFM(t)
+	cache := NewMemoryCache()
+	s, now := newTestService(fake.server.URL, cache)
+
+	if _, err := s.GetArtistInfo("Radiohead"); err != nil {
+		t.Fatalf("GetArtistInfo() error = %v", err)
+	}
+
+	// Beyond the stale window the entry would normally be refetched synchronously
+	fake.server.Close()
+	*now = now.Add(DefaultTTLs["artist.getinfo"] + staleWindow + time.Hour)
+
+	info, err := s.GetArtistInfo("Radiohead")
+	if err != nil {
+		t.Fatalf("GetArtistInfo() error = %v, want cached copy while offline", err)
+	}
+	if info.Artist.Name != "Radiohead" {
+		t.Errorf("Name = %q, want %q", info.Artist.Name, "Radiohead")
+	}
+}
+
+func TestCacheTTLOverride(t *testing.T) {
+	fake := newFakeLastFM(t)
+	s := NewService(Config{
+		APIKey:  testAPIKey,
+		BaseURL: fake.server.URL,
+		TTLs:    map[string]time.Duration{"artist.getInfo": time.Minute},
+	})
+
+	if ttl := s.ttlFor("artist.getinfo", false); ttl != time.Minute {
+		t.Errorf("ttlFor(artist.getinfo) = %v, want %v", ttl, time.Minute)
+	}
+	if ttl := s.ttlFor("track.getsimilar", false); ttl != DefaultTTLs["track.getsimilar"] {
+		t.Errorf("ttlFor(track.getsimilar) = %v, want default", ttl)
+	}
+	if ttl := s.ttlFor("artist.getinfo", true); ttl != notFoundTTL {
+		t.Errorf("ttlFor(not found) = %v, want %v", ttl, notFoundTTL)
+	}
+}
+
+func TestMemoryCachePurge(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.Set("a", CacheEntry{Method: "artist.getinfo"})
+	cache.Set("b", CacheEntry{Method: "artist.getinfo"})
+	cache.Set("c", CacheEntry{Method: "track.getsimilar"})
+
+	deleted, _ := cache.Purge("artist.getInfo")
+	if deleted != 2 {
+		t.Errorf("Purge(artist.getInfo) deleted %d, want 2", deleted)
+	}
+	if entry, _ := cache.Get("c"); entry == nil {
+		t.Error("entries of other methods should be kept")
+	}
+
+	deleted, _ = cache.Purge("")
+	if deleted != 1 {
+		t.Errorf("Purge(\"\") deleted %d, want 1", deleted)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	a := url.Values{"method": {"artist.getInfo"}, "artist": {"The  Beatles"}, "api_key": {"x"}}
+	b := url.Values{"method": {"ARTIST.GETINFO"}, "artist": {" the beatles"}, "api_key": {"y"}}
+	c := url.Values{"method": {"artist.getInfo"}, "artist": {"The Rolling Stones"}}
+
+	if cacheKey(a) != cacheKey(b) {
+		t.Errorf("cacheKey mismatch: %q vs %q", cacheKey(a), cacheKey(b))
+	}
+	if cacheKey(a) == cacheKey(c) {
+		t.Errorf("different artists share key %q", cacheKey(a))
+	}
+}
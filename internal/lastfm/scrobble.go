@@ -0,0 +1,238 @@
+package lastfm
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AuthURL is where users authorize a desktop-flow token
+	AuthURL = "https://www.last.fm/api/auth/"
+
+	// MaxScrobbleBatch is the maximum number of scrobbles per track.scrobble request
+	MaxScrobbleBatch = 50
+
+	errorInvalidSession    = 9
+	errorServiceOffline    = 11
+	errorTemporaryError    = 16
+	errorRateLimitExceeded = 29
+)
+
+var (
+	// ErrInvalidSession means the user revoked access and must link the account again
+	ErrInvalidSession = errors.New("last.fm session is invalid")
+	// ErrNoSharedSecret means write methods are unavailable because no secret is configured
+	ErrNoSharedSecret = errors.New("last.fm shared secret is not configured")
+)
+
+// APIError is an error code returned by the Last.fm API
+type APIError struct {
+	Code    int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("last.fm API error %d: %s", e.Code, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried later
+func (e *APIError) Temporary() bool {
+	return e.Code == errorServiceOffline || e.Code == errorTemporaryError || e.Code == errorRateLimitExceeded
+}
+
+// Scrobble is a single listen submitted with track.scrobble
+type Scrobble struct {
+	Artist      string
+	Track       string
+	Album       string
+	Timestamp   time.Time
+	Duration    int // seconds
+	TrackNumber int
+}
+
+// CanScrobble reports whether the service is able to sign write requests
+func (s *Service) CanScrobble() bool {
+	return s != nil && s.sharedSecret != ""
+}
+
+// GetToken starts the desktop authentication flow and returns an unauthorized token
+func (s *Service) GetToken() (string, error) {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := s.signedCall("GET", url.Values{"method": {"auth.getToken"}}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Token, nil
+}
+
+// AuthorizeURL returns the page where the user grants access to a token
+func (s *Service) AuthorizeURL(token string) string {
+	params := url.Values{}
+	params.Set("api_key", s.apiKey)
+	params.Set("token", token)
+	return AuthURL + "?" + params.Encode()
+}
+
+// GetSession exchanges an authorized token for a session key and the Last.fm username
+func (s *Service) GetSession(token string) (string, string, error) {
+	var resp struct {
+		Session struct {
+			Name string `json:"name"`
+			Key  string `json:"key"`
+		} `json:"session"`
+	}
+	params := url.Values{"method": {"auth.getSession"}, "token": {token}}
+	if err := s.signedCall("GET", params, &resp); err != nil {
+		return "", "", err
+	}
+	return resp.Session.Key, resp.Session.Name, nil
+}
+
+// UpdateNowPlaying notifies Last.fm that the user started listening to a track
+func (s *Service) UpdateNowPlaying(sessionKey string, track Scrobble) error {
+	params := url.Values{}
+	params.Set("method", "track.updateNowPlaying")
+	params.Set("sk", sessionKey)
+	params.Set("artist", track.Artist)
+	params.Set("track", track.Track)
+	if track.Album != "" {
+		params.Set("album", track.Album)
+	}
+	if track.Duration > 0 {
+		params.Set("duration", strconv.Itoa(track.Duration))
+	}
+	if track.TrackNumber > 0 {
+		params.Set("trackNumber", strconv.Itoa(track.TrackNumber))
+	}
+
+	return s.signedCall("POST", params, nil)
+}
+
+// Scrobble submits up to MaxScrobbleBatch listens in a single request
+func (s *Service) Scrobble(sessionKey string, scrobbles []Scrobble) error {
+	if len(scrobbles) == 0 {
+		return nil
+	}
+	if len(scrobbles) > MaxScrobbleBatch {
+		return fmt.Errorf("too many scrobbles in one batch: %d (max %d)", len(scrobbles), MaxScrobbleBatch)
+	}
+
+	params := url.Values{}
+	params.Set("method", "track.scrobble")
+	params.Set("sk", sessionKey)
+	for i, scrobble := range scrobbles {
+		idx := fmt.Sprintf("[%d]", i)
+		params.Set("artist"+idx, scrobble.Artist)
+		params.Set("track"+idx, scrobble.Track)
+		params.Set("timestamp"+idx, strconv.FormatInt(scrobble.Timestamp.Unix(), 10))
+		if scrobble.Album != "" {
+			params.Set("album"+idx, scrobble.Album)
+		}
+		if scrobble.Duration > 0 {
+			params.Set("duration"+idx, strconv.Itoa(scrobble.Duration))
+		}
+		if scrobble.TrackNumber > 0 {
+			params.Set("trackNumber"+idx, strconv.Itoa(scrobble.TrackNumber))
+		}
+	}
+
+	var resp struct {
+		Scrobbles struct {
+			Attr struct {
+				Accepted int `json:"accepted"`
+				Ignored  int `json:"ignored"`
+			} `json:"@attr"`
+		} `json:"scrobbles"`
+	}
+	if err := s.signedCall("POST", params, &resp); err != nil {
+		return err
+	}
+
+	log.Printf("[LastFM] Scrobbled %d tracks (%d accepted, %d ignored)",
+		len(scrobbles), resp.Scrobbles.Attr.Accepted, resp.Scrobbles.Attr.Ignored)
+	return nil
+}
+
+// signedCall performs an authenticated, uncached API call and decodes the JSON response into out
+func (s *Service) signedCall(httpMethod string, params url.Values, out interface{}) error {
+	if !s.CanScrobble() {
+		return ErrNoSharedSecret
+	}
+
+	params.Set("api_key", s.apiKey)
+	params.Set("api_sig", signature(params, s.sharedSecret))
+	params.Set("format", "json")
+
+	var resp *http.Response
+	var err error
+	if httpMethod == "POST" {
+		resp, err = s.client.PostForm(s.baseURL, params)
+	} else {
+		resp, err = s.client.Get(s.baseURL + "?" + params.Encode())
+	}
+	if err != nil {
+		return fmt.Errorf("error making request to last.fm: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	var apiError struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &apiError); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	if apiError.Error == errorInvalidSession {
+		return ErrInvalidSession
+	}
+	if apiError.Error != 0 {
+		return &APIError{Code: apiError.Error, Message: apiError.Message}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(body, out); err != nil {
+			return fmt.Errorf("error parsing response: %w", err)
+		}
+	}
+	return nil
+}
+
+// signature computes api_sig: the md5 of all parameters (except format and callback)
+// sorted by name and concatenated as name+value, followed by the shared secret
+func signature(params url.Values, secret string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		if name == "format" || name == "callback" || name == "api_sig" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteString(params.Get(name))
+	}
+	b.WriteString(secret)
+
+	sum := md5.Sum([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
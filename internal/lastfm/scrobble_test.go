@@ -0,0 +1,154 @@
+package lastfm
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testSharedSecret = "s3cr3t"
+
+// newScrobbleServer answers signed calls with body after checking the signature
+func newScrobbleServer(t *testing.T, body string, check func(url.Values)) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatalf("ParseForm() error = %v", err)
+		}
+		if got, want := r.Form.Get("api_sig"), signature(r.Form, testSharedSecret); got != want {
+			t.Errorf("api_sig = %q, want %q", got, want)
+		}
+		if check != nil {
+			check(r.Form)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newScrobbleService(baseURL string) *Service {
+	return NewService(Config{APIKey: testAPIKey, SharedSecret: testSharedSecret, BaseURL: baseURL})
+}
+
+func TestSignature(t *testing.T) {
+	params := url.Values{
+		"method":  {"auth.getSession"},
+		"token":   {"tok"},
+		"api_key": {testAPIKey},
+		"format":  {"json"},
+	}
+
+	raw := "api_key" + testAPIKey + "methodauth.getSession" + "tokentok" + testSharedSecret
+	sum := md5.Sum([]byte(raw))
+	want := hex.EncodeToString(sum[:])
+
+	if got := signature(params, testSharedSecret); got != want {
+		t.Errorf("signature() = %q, want %q", got, want)
+	}
+}
+
+func TestGetSession(t *testing.T) {
+	server := newScrobbleServer(t, `{"session":{"name":"alice","key":"sk-123","subscriber":0}}`, func(form url.Values) {
+		if form.Get("method") != "auth.getSession" || form.Get("token") != "tok" {
+			t.Errorf("unexpected request: %v", form)
+		}
+	})
+
+	key, name, err := newScrobbleService(server.URL).GetSession("tok")
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if key != "sk-123" || name != "alice" {
+		t.Errorf("GetSession() = %q, %q, want %q, %q", key, name, "sk-123", "alice")
+	}
+}
+
+func TestScrobbleBatch(t *testing.T) {
+	playedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	server := newScrobbleServer(t, `{"scrobbles":{"@attr":{"accepted":2,"ignored":0}}}`, func(form url.Values) {
+		want := map[string]string{
+			"method":         "track.scrobble",
+			"sk":             "sk-123",
+			"artist[0]":      "Radiohead",
+			"track[0]":       "Airbag",
+			"album[0]":       "OK Computer",
+			"timestamp[0]":   "1709287200",
+			"trackNumber[0]": "1",
+			"artist[1]":      "Portishead",
+			"track[1]":       "Roads",
+			"duration[1]":    "305",
+		}
+		for name, value := range want {
+			if got := form.Get(name); got != value {
+				t.Errorf("%s = %q, want %q", name, got, value)
+			}
+		}
+		if _, ok := form["album[1]"]; ok {
+			t.Errorf("album[1] should be omitted when empty")
+		}
+	})
+
+	err := newScrobbleService(server.URL).Scrobble("sk-123", []Scrobble{
+		{Artist: "Radiohead", Track: "Airbag", Album: "OK Computer", Timestamp: playedAt, TrackNumber: 1},
+		{Artist: "Portishead", Track: "Roads", Timestamp: playedAt.Add(5 * time.Minute), Duration: 305},
+	})
+	if err != nil {
+		t.Fatalf("Scrobble() error = %v", err)
+	}
+}
+
+func TestScrobbleBatchTooLarge(t *testing.T) {
+	s := newScrobbleService("http://127.0.0.1:0")
+	if err := s.Scrobble("sk", make([]Scrobble, MaxScrobbleBatch+1)); err == nil {
+		t.Error("Scrobble() with more than MaxScrobbleBatch scrobbles should fail")
+	}
+}
+
+func TestScrobbleErrors(t *testing.T) {
+	server := newScrobbleServer(t, `{"error":9,"message":"Invalid session key"}`, nil)
+	err := newScrobbleService(server.URL).UpdateNowPlaying("sk", Scrobble{Artist: "a", Track: "b"})
+	if err != ErrInvalidSession {
+		t.Errorf("error = %v, want ErrInvalidSession", err)
+	}
+
+	server = newScrobbleServer(t, `{"error":29,"message":"Rate limit exceeded"}`, nil)
+	err = newScrobbleService(server.URL).UpdateNowPlaying("sk", Scrobble{Artist: "a", Track: "b"})
+	apiErr, ok := err.(*APIError)
+	if !ok || !apiErr.Temporary() {
+		t.Errorf("error = %v, want a temporary APIError", err)
+	}
+}
+
+func TestScrobbleWithoutSecret(t *testing.T) {
+	s := NewService(Config{APIKey: testAPIKey})
+	if s.CanScrobble() {
+		t.Error("CanScrobble() = true without a shared secret")
+	}
+	if _, err := s.GetToken(); err != ErrNoSharedSecret {
+		t.Errorf("GetToken() error = %v, want ErrNoSharedSecret", err)
+	}
+}
+
+func TestTemporary(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&APIError{Code: errorServiceOffline}, true},
+		{&APIError{Code: errorRateLimitExceeded}, true},
+		// Invalid parameters fail the same way on every retry
+		{&APIError{Code: 6, Message: "Invalid parameters"}, false},
+		{errors.New("connection refused"), true},
+	}
+	for _, tt := range tests {
+		if got := temporary(tt.err); got != tt.want {
+			t.Errorf("temporary(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
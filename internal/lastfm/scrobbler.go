@@ -0,0 +1,236 @@
+package lastfm
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"castafiore-backend/internal/scrobblequeue"
+)
+
+// Scrobbler links users to Last.fm and forwards their listens. Scrobbles are stored
+// in lastfm_scrobble_queue first, so listens made while Last.fm is unreachable are
+// submitted later in batches.
+type Scrobbler struct {
+	db      *sql.DB
+	service *Service
+	queue   *scrobblequeue.Queue[Scrobble]
+}
+
+func NewScrobbler(db *sql.DB, service *Service) *Scrobbler {
+	s := &Scrobbler{db: db, service: service}
+	s.queue = scrobblequeue.New(db, scrobblequeue.Config[Scrobble]{
+		Name:         "LastFM",
+		Table:        "lastfm_scrobble_queue",
+		BatchSize:    MaxScrobbleBatch,
+		Accounts:     s.pendingAccounts,
+		Load:         s.loadScrobbles,
+		Submit:       service.Scrobble,
+		Temporary:    temporary,
+		Unauthorized: func(err error) bool { return err == ErrInvalidSession },
+		// Keep the scrobbles until the user links the account again
+		Revoke: s.invalidateSession,
+	})
+	return s
+}
+
+// Start runs the queue worker, flushing every interval and whenever a scrobble is queued
+func (s *Scrobbler) Start(interval time.Duration) {
+	s.queue.Start(interval)
+}
+
+// Enabled reports whether scrobbling is possible at all
+func (s *Scrobbler) Enabled() bool {
+	return s != nil && s.service.CanScrobble()
+}
+
+// SessionKey returns the Last.fm session of a user, if linked
+func (s *Scrobbler) SessionKey(userID int) (string, string, bool) {
+	var sessionKey, username sql.NullString
+	err := s.db.QueryRow(`
+		SELECT session_key, lastfm_username FROM user_lastfm_sessions
+		WHERE user_id = $1 AND session_key IS NOT NULL
+	`, userID).Scan(&sessionKey, &username)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[LastFM] Error loading session for user %d: %v", userID, err)
+		}
+		return "", "", false
+	}
+	return sessionKey.String, username.String, true
+}
+
+// StartLink requests a new token for the user and returns the URL where it must be authorized
+func (s *Scrobbler) StartLink(userID int) (string, error) {
+	token, err := s.service.GetToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO user_lastfm_sessions (user_id, pending_token, updated_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id)
+		DO UPDATE SET pending_token = $2, updated_at = NOW()
+	`, userID, token)
+	if err != nil {
+		return "", err
+	}
+
+	return s.service.AuthorizeURL(token), nil
+}
+
+// CompleteLink exchanges the authorized pending token for a session key
+func (s *Scrobbler) CompleteLink(userID int) (string, error) {
+	var token sql.NullString
+	err := s.db.QueryRow(`
+		SELECT pending_token FROM user_lastfm_sessions WHERE user_id = $1
+	`, userID).Scan(&token)
+	if err == sql.ErrNoRows || (err == nil && token.String == "") {
+		return "", errors.New("no pending Last.fm authorization, start the link first")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sessionKey, username, err := s.service.GetSession(token.String)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE user_lastfm_sessions
+		SET session_key = $1, lastfm_username = $2, pending_token = NULL, updated_at = NOW()
+		WHERE user_id = $3
+	`, sessionKey, username, userID)
+	if err != nil {
+		return "", err
+	}
+
+	// Scrobbles kept while the account was unlinked can now be submitted
+	s.queue.Notify()
+	return username, nil
+}
+
+// Unlink removes the Last.fm session and any pending scrobbles of the user
+func (s *Scrobbler) Unlink(userID int) error {
+	if _, err := s.db.Exec("DELETE FROM lastfm_scrobble_queue WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("DELETE FROM user_lastfm_sessions WHERE user_id = $1", userID)
+	return err
+}
+
+// NowPlaying forwards track.updateNowPlaying for a linked user. Failures are only logged,
+// now playing notifications are not worth retrying.
+func (s *Scrobbler) NowPlaying(userID int, track Scrobble) {
+	sessionKey, _, ok := s.SessionKey(userID)
+	if !ok {
+		return
+	}
+
+	if err := s.service.UpdateNowPlaying(sessionKey, track); err != nil {
+		log.Printf("[LastFM] Error updating now playing for user %d: %v", userID, err)
+		if err == ErrInvalidSession {
+			s.invalidateSession(userID)
+		}
+	}
+}
+
+// Enqueue stores a scrobble for a linked user and wakes the worker
+func (s *Scrobbler) Enqueue(userID int, songID string, track Scrobble) error {
+	if _, _, ok := s.SessionKey(userID); !ok {
+		return nil
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO lastfm_scrobble_queue (user_id, song_id, artist, track, album, duration,
+		                                   track_number, played_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+	`, userID, songID, track.Artist, track.Track, track.Album, track.Duration, track.TrackNumber, track.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	s.queue.Notify()
+	return nil
+}
+
+// Flush submits every due scrobble, in batches of MaxScrobbleBatch per user
+func (s *Scrobbler) Flush() {
+	s.queue.Flush()
+}
+
+// pendingAccounts lists the linked users with due scrobbles
+func (s *Scrobbler) pendingAccounts() ([]scrobblequeue.Account, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT q.user_id, ls.session_key
+		FROM lastfm_scrobble_queue q
+		JOIN user_lastfm_sessions ls ON ls.user_id = q.user_id
+		WHERE ls.session_key IS NOT NULL AND q.next_attempt_at <= NOW()
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []scrobblequeue.Account
+	for rows.Next() {
+		var account scrobblequeue.Account
+		if err := rows.Scan(&account.UserID, &account.Credential); err == nil {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, rows.Err()
+}
+
+// loadScrobbles returns the oldest due scrobbles of a user
+func (s *Scrobbler) loadScrobbles(userID, limit int) ([]int64, []Scrobble, error) {
+	rows, err := s.db.Query(`
+		SELECT id, artist, track, album, duration, track_number, played_at
+		FROM lastfm_scrobble_queue
+		WHERE user_id = $1 AND next_attempt_at <= NOW()
+		ORDER BY played_at
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	var batch []Scrobble
+	for rows.Next() {
+		var id int64
+		var track Scrobble
+		var album sql.NullString
+		var duration, trackNumber sql.NullInt32
+		if err := rows.Scan(&id, &track.Artist, &track.Track, &album, &duration, &trackNumber, &track.Timestamp); err != nil {
+			continue
+		}
+		track.Album = album.String
+		track.Duration = int(duration.Int32)
+		track.TrackNumber = int(trackNumber.Int32)
+		ids = append(ids, id)
+		batch = append(batch, track)
+	}
+	return ids, batch, rows.Err()
+}
+
+// temporary reports whether a failed submission is worth retrying: network errors are,
+// Last.fm errors only when APIError.Temporary says so
+func temporary(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return !ok || apiErr.Temporary()
+}
+
+func (s *Scrobbler) invalidateSession(userID int) {
+	_, err := s.db.Exec(`
+		UPDATE user_lastfm_sessions SET session_key = NULL, updated_at = NOW()
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		log.Printf("[LastFM] Error invalidating session for user %d: %v", userID, err)
+	}
+}
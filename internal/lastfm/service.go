@@ -8,22 +8,43 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
 	LastFMAPIURL = "https://ws.audioscrobbler.com/2.0/"
+
+	// errorNotFound is the Last.fm error code for unknown artists, tracks and albums
+	errorNotFound = 6
 )
 
 // Config holds Last.fm configuration
 type Config struct {
 	APIKey string
+	// SharedSecret signs write requests (auth, now playing and scrobbles)
+	SharedSecret string
+	// BaseURL overrides the Last.fm endpoint (used by tests)
+	BaseURL string
+	// Cache stores responses; an in-memory cache is used when nil
+	Cache Cache
+	// TTLs overrides DefaultTTLs per lowercase method name
+	TTLs map[string]time.Duration
 }
 
 type Service struct {
-	client  *http.Client
-	apiKey  string
-	baseURL string
+	client       *http.Client
+	apiKey       string
+	sharedSecret string
+	baseURL      string
+	cache        Cache
+	ttls         map[string]time.Duration
+	now          func() time.Time
+
+	// refreshing tracks keys being revalidated in the background
+	mu         sync.Mutex
+	refreshing map[string]bool
 }
 
 // Streamable represents the Last.fm streamable object which can be either a string or an object
@@ -145,19 +166,191 @@ type ArtistInfoResponse struct {
 }
 
 func NewService(config Config) *Service {
-	log.Printf("Initializing Last.fm service with API key: %s...", config.APIKey[:12])
 	if config.APIKey == "" {
 		log.Printf("Warning: Last.fm API key is empty")
 		return nil
 	}
+	log.Printf("Initializing Last.fm service with API key: %s...", maskKey(config.APIKey))
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = LastFMAPIURL
+	}
+
+	cache := config.Cache
+	if cache == nil {
+		cache = NewMemoryCache()
+	}
+
+	ttls := make(map[string]time.Duration)
+	for method, ttl := range DefaultTTLs {
+		ttls[method] = ttl
+	}
+	for method, ttl := range config.TTLs {
+		ttls[strings.ToLower(method)] = ttl
+	}
 
 	return &Service{
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		apiKey:  config.APIKey,
-		baseURL: "http://ws.audioscrobbler.com/2.0/",
+		apiKey:       config.APIKey,
+		sharedSecret: config.SharedSecret,
+		baseURL:      baseURL,
+		cache:        cache,
+		ttls:         ttls,
+		now:          time.Now,
+		refreshing:   make(map[string]bool),
+	}
+}
+
+// maskKey keeps only the beginning of a key for logging
+func maskKey(key string) string {
+	if len(key) > 6 {
+		return key[:6]
+	}
+	return key
+}
+
+// PurgeCache removes cached responses of a method, or all of them when method is empty
+func (s *Service) PurgeCache(method string) (int64, error) {
+	return s.cache.Purge(method)
+}
+
+// get returns the response body for a Last.fm call, going through the cache.
+// Fresh entries are served directly; expired entries inside the stale window are
+// served immediately while being refreshed in the background; when Last.fm cannot
+// be reached any cached copy is preferred over an error.
+func (s *Service) get(params url.Values) ([]byte, error) {
+	key := cacheKey(params)
+	method := strings.ToLower(params.Get("method"))
+
+	entry, err := s.cache.Get(key)
+	if err != nil {
+		log.Printf("[LastFM] Cache read error for %s: %v", key, err)
+		entry = nil
+	}
+
+	if entry != nil {
+		age := s.now().Sub(entry.FetchedAt)
+		ttl := s.ttlFor(method, entry.NotFound)
+		if age < ttl {
+			log.Printf("[LastFM] Cache hit for %s", key)
+			return entry.Body, nil
+		}
+		if age < ttl+staleWindow {
+			log.Printf("[LastFM] Serving stale cache for %s while refreshing", key)
+			s.refreshAsync(key, params)
+			return entry.Body, nil
+		}
+	}
+
+	body, notFound, err := s.fetch(params)
+	if err != nil {
+		if entry != nil {
+			log.Printf("[LastFM] Request failed, serving cached copy of %s: %v", key, err)
+			return entry.Body, nil
+		}
+		return nil, err
+	}
+
+	s.store(key, method, body, notFound)
+	return body, nil
+}
+
+// fetch performs the HTTP request. Last.fm "not found" errors are returned as a
+// normal body with notFound set, so they can be cached; other API errors are not cached.
+func (s *Service) fetch(params url.Values) ([]byte, bool, error) {
+	query := url.Values{}
+	for name, values := range params {
+		query[name] = values
+	}
+	query.Set("api_key", s.apiKey)
+	query.Set("format", "json")
+
+	resp, err := s.client.Get(fmt.Sprintf("%s?%s", s.baseURL, query.Encode()))
+	if err != nil {
+		return nil, false, fmt.Errorf("error making request to last.fm: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading response: %w", err)
+	}
+
+	var apiError struct {
+		Error   int    `json:"error"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(body, &apiError); err != nil {
+		return nil, false, fmt.Errorf("last.fm API returned status %d with invalid body: %w", resp.StatusCode, err)
+	}
+
+	switch {
+	case apiError.Error == errorNotFound:
+		return body, true, nil
+	case apiError.Error != 0:
+		return nil, false, fmt.Errorf("last.fm API error %d: %s", apiError.Error, apiError.Message)
+	case resp.StatusCode != http.StatusOK:
+		return nil, false, fmt.Errorf("last.fm API returned status %d", resp.StatusCode)
+	}
+
+	return body, false, nil
+}
+
+func (s *Service) store(key, method string, body []byte, notFound bool) {
+	err := s.cache.Set(key, CacheEntry{
+		Method:    method,
+		Body:      body,
+		NotFound:  notFound,
+		FetchedAt: s.now(),
+	})
+	if err != nil {
+		log.Printf("[LastFM] Cache write error for %s: %v", key, err)
+	}
+}
+
+func (s *Service) refreshAsync(key string, params url.Values) {
+	s.mu.Lock()
+	if s.refreshing[key] {
+		s.mu.Unlock()
+		return
+	}
+	s.refreshing[key] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.refreshing, key)
+			s.mu.Unlock()
+		}()
+
+		body, notFound, err := s.fetch(params)
+		if err != nil {
+			log.Printf("[LastFM] Background refresh of %s failed: %v", key, err)
+			return
+		}
+		s.store(key, strings.ToLower(params.Get("method")), body, notFound)
+	}()
+}
+
+// idle reports whether no background refresh is running
+func (s *Service) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.refreshing) == 0
+}
+
+func (s *Service) ttlFor(method string, notFound bool) time.Duration {
+	if notFound {
+		return notFoundTTL
+	}
+	if ttl, ok := s.ttls[method]; ok {
+		return ttl
 	}
+	return defaultTTL
 }
 
 // GetSimilarTracks fetches similar tracks for the given track from Last.fm
@@ -167,21 +360,12 @@ func (s *Service) GetSimilarTracks(artistName, trackName string) (*SimilarTracks
 	params.Add("method", "track.getSimilar")
 	params.Add("artist", artistName)
 	params.Add("track", trackName)
-	params.Add("api_key", s.apiKey)
-	params.Add("format", "json")
 
-	resp, err := s.client.Get(fmt.Sprintf("%s?%s", s.baseURL, params.Encode()))
+	body, err := s.get(params)
 	if err != nil {
 		log.Printf("[LastFM] Error fetching similar tracks for %s - %s: %v", artistName, trackName, err)
 		return nil, fmt.Errorf("error fetching similar tracks: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("[LastFM] Error reading response body: %v", err)
-		return nil, fmt.Errorf("error reading response: %w", err)
-	}
 
 	// Log raw response for debugging
 	log.Printf("[LastFM] Raw response: %s", string(body))
@@ -227,20 +411,11 @@ func (s *Service) GetSimilarTracksByArtist(artist string, limit int) (*SimilarTr
 	params := url.Values{}
 	params.Set("method", "artist.getsimilar")
 	params.Set("artist", artist)
-	params.Set("api_key", s.apiKey)
-	params.Set("format", "json")
 	params.Set("limit", "10") // Obtenemos algunos artistas similares
 
-	requestURL := fmt.Sprintf("%s?%s", LastFMAPIURL, params.Encode())
-
-	resp, err := s.client.Get(requestURL)
+	body, err := s.get(params)
 	if err != nil {
-		return nil, fmt.Errorf("error making request to last.fm: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("last.fm API returned status %d", resp.StatusCode)
+		return nil, err
 	}
 
 	var response struct {
@@ -253,7 +428,7 @@ func (s *Service) GetSimilarTracksByArtist(artist string, limit int) (*SimilarTr
 		Message string `json:"message,omitempty"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("error decoding last.fm response: %w", err)
 	}
 
@@ -295,21 +470,12 @@ func (s *Service) GetTopTracks(artistName string) ([]TopTrack, error) {
 	params := url.Values{}
 	params.Add("method", "artist.getTopTracks")
 	params.Add("artist", artistName)
-	params.Add("api_key", s.apiKey)
-	params.Add("format", "json")
 
-	resp, err := s.client.Get(fmt.Sprintf("%s?%s", s.baseURL, params.Encode()))
+	body, err := s.get(params)
 	if err != nil {
 		log.Printf("[LastFM] Error fetching top tracks for artist %s: %v", artistName, err)
 		return nil, fmt.Errorf("error fetching top tracks: %w", err)
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("[LastFM] Error reading response body: %v", err)
-		return nil, fmt.Errorf("error reading response: %w", err)
-	}
 
 	var topTracksResp TopTracksResponse
 	if err := json.Unmarshal(body, &topTracksResp); err != nil {
@@ -335,30 +501,14 @@ func (s *Service) GetArtistInfo(artistName string) (*ArtistInfoResponse, error)
 	params := url.Values{}
 	params.Add("method", "artist.getInfo")
 	params.Add("artist", artistName)
-	params.Add("api_key", s.apiKey)
-	params.Add("format", "json")
 	params.Add("autocorrect", "1") // Enable name correction
 	params.Add("limit", "20")      // Request more similar artists
 
-	requestURL := fmt.Sprintf("%s?%s", s.baseURL, params.Encode())
-	log.Printf("[LastFM] Making request to URL: %s", requestURL)
-
-	resp, err := s.client.Get(requestURL)
+	body, err := s.get(params)
 	if err != nil {
 		log.Printf("[LastFM] Error fetching artist info for %s: %v", artistName, err)
 		return nil, fmt.Errorf("error fetching artist info: %w", err)
 	}
-	defer resp.Body.Close()
-
-	log.Printf("[LastFM] Got response with status code: %d", resp.StatusCode)
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("[LastFM] Error reading response body: %v", err)
-		return nil, fmt.Errorf("error reading response: %w", err)
-	}
-
-	log.Printf("[LastFM] Raw response from Last.fm: %s", string(body))
 
 	var artistInfo ArtistInfoResponse
 	if err := json.Unmarshal(body, &artistInfo); err != nil {
@@ -384,6 +534,58 @@ func (s *Service) GetArtistInfo(artistName string) (*ArtistInfoResponse, error)
 	return &artistInfo, nil
 }
 
+// AlbumInfoResponse represents the response from Last.fm's album.getInfo method
+type AlbumInfoResponse struct {
+	Error   int    `json:"error,omitempty"`
+	Message string `json:"message,omitempty"`
+	Album   struct {
+		Name   string `json:"name"`
+		Artist string `json:"artist"`
+		MBID   string `json:"mbid"`
+		URL    string `json:"url"`
+		Images []struct {
+			Size string `json:"size"`
+			Text string `json:"#text"`
+		} `json:"image"`
+		Wiki struct {
+			Summary   string `json:"summary"`
+			Content   string `json:"content"`
+			Published string `json:"published"`
+		} `json:"wiki"`
+	} `json:"album"`
+}
+
+// GetAlbumInfo fetches album information (notes, images, MBID) from Last.fm
+func (s *Service) GetAlbumInfo(artistName, albumName string) (*AlbumInfoResponse, error) {
+	log.Printf("[LastFM] Requesting album info for: %s - %s", artistName, albumName)
+
+	params := url.Values{}
+	params.Add("method", "album.getInfo")
+	params.Add("artist", artistName)
+	params.Add("album", albumName)
+	params.Add("autocorrect", "1")
+
+	body, err := s.get(params)
+	if err != nil {
+		log.Printf("[LastFM] Error fetching album info for %s - %s: %v", artistName, albumName, err)
+		return nil, fmt.Errorf("error fetching album info: %w", err)
+	}
+
+	var albumInfo AlbumInfoResponse
+	if err := json.Unmarshal(body, &albumInfo); err != nil {
+		log.Printf("[LastFM] Error unmarshalling response: %v\nResponse body: %s", err, string(body))
+		return nil, fmt.Errorf("error parsing response: %w", err)
+	}
+
+	if albumInfo.Error != 0 {
+		log.Printf("[LastFM] API returned error %d: %s", albumInfo.Error, albumInfo.Message)
+		return nil, fmt.Errorf("last.fm API error: %s", albumInfo.Message)
+	}
+
+	log.Printf("[LastFM] Successfully fetched info for album %s - %s", artistName, albumName)
+	return &albumInfo, nil
+}
+
 // ArtistSimilarResponse represents the response from Last.fm's artist.getSimilar method
 type ArtistSimilarResponse struct {
 	Error          int    `json:"error,omitempty"`
@@ -441,30 +643,14 @@ func (s *Service) GetSimilarArtists(artistName string, limit int) (*ArtistSimila
 	params := url.Values{}
 	params.Add("method", "artist.getSimilar")
 	params.Add("artist", artistName)
-	params.Add("api_key", s.apiKey)
-	params.Add("format", "json")
 	params.Add("limit", fmt.Sprintf("%d", limit))
 	params.Add("autocorrect", "1") // Enable name correction
 
-	requestURL := fmt.Sprintf("%s?%s", s.baseURL, params.Encode())
-	log.Printf("[LastFM] Making request to URL: %s", requestURL)
-
-	resp, err := s.client.Get(requestURL)
+	body, err := s.get(params)
 	if err != nil {
 		log.Printf("[LastFM] Error fetching similar artists for %s: %v", artistName, err)
 		return nil, fmt.Errorf("error fetching similar artists: %w", err)
 	}
-	defer resp.Body.Close()
-
-	log.Printf("[LastFM] Got response with status code: %d", resp.StatusCode)
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("[LastFM] Error reading response body: %v", err)
-		return nil, fmt.Errorf("error reading response: %w", err)
-	}
-
-	log.Printf("[LastFM] Raw response from Last.fm: %s", string(body))
 
 	var similarArtists ArtistSimilarResponse
 	if err := json.Unmarshal(body, &similarArtists); err != nil {
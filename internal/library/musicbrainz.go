@@ -0,0 +1,61 @@
+package library
+
+import (
+	"strings"
+
+	"github.com/dhowden/tag"
+	"github.com/dhowden/tag/mbz"
+)
+
+// MusicBrainzIDs are the identifiers MusicBrainz Picard writes into the tags
+type MusicBrainzIDs struct {
+	Recording string
+	Release   string
+	Artist    string
+}
+
+// extractMusicBrainzIDs reads the MusicBrainz identifiers of a file, ignoring malformed values
+func extractMusicBrainzIDs(metadata tag.Metadata) MusicBrainzIDs {
+	info := mbz.Extract(metadata)
+
+	recording := info.Get(mbz.Recording)
+	if recording == "" && metadata.Format() == tag.VORBIS {
+		// Picard stores the recording as MUSICBRAINZ_TRACKID in Vorbis comments
+		recording = info.Get(mbz.Track)
+	}
+
+	return MusicBrainzIDs{
+		Recording: validMBID(recording),
+		Release:   validMBID(info.Get(mbz.Album)),
+		Artist:    validMBID(firstMBID(info.Get(mbz.Artist))),
+	}
+}
+
+// firstMBID keeps the first identifier of multi-valued tags such as "id1; id2"
+func firstMBID(value string) string {
+	if i := strings.IndexAny(value, ";/"); i >= 0 {
+		return value[:i]
+	}
+	return value
+}
+
+// validMBID returns the lowercased identifier if it looks like a UUID, or an empty string
+func validMBID(value string) string {
+	value = strings.ToLower(strings.TrimSpace(strings.Trim(value, "\x00")))
+	if len(value) != 36 {
+		return ""
+	}
+	for i, r := range value {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return ""
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdef", r) {
+				return ""
+			}
+		}
+	}
+	return value
+}
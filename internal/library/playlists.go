@@ -0,0 +1,412 @@
+package library
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"castafiore-backend/internal/database"
+	"castafiore-backend/internal/playlistfile"
+	"castafiore-backend/internal/smartplaylist"
+)
+
+// PlaylistImportStats summarizes a playlist import run
+type PlaylistImportStats struct {
+	Files      int
+	Imported   int
+	Unchanged  int
+	Removed    int
+	Unresolved int
+}
+
+// PlaylistImporter keeps playlists in sync with the .m3u, .m3u8 and .pls files found
+// in the music folders. A file is imported again when it changes or when some of its
+// entries could not be resolved the last time, since the songs may have been added since.
+// The file is the source of the songs, so the API keeps imported playlists read-only.
+type PlaylistImporter struct {
+	db *sql.DB
+}
+
+func NewPlaylistImporter(db *sql.DB) *PlaylistImporter {
+	return &PlaylistImporter{db: db}
+}
+
+type storedPlaylistFile struct {
+	id         int
+	modified   time.Time
+	unresolved int
+}
+
+// ImportPlaylists imports new and changed playlist files under each music root and
+// removes the playlists whose file is gone. A root that cannot be read fails the import
+// before anything is removed, and a root holding no files at all (such as an unmounted
+// disk) keeps its playlists.
+func (p *PlaylistImporter) ImportPlaylists(roots ...string) (PlaylistImportStats, error) {
+	var stats PlaylistImportStats
+
+	var ownerID int
+	err := p.db.QueryRow("SELECT id FROM users WHERE is_admin = true ORDER BY id LIMIT 1").Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		log.Println("Skipping playlist import: there is no admin user to own the playlists")
+		return stats, nil
+	}
+	if err != nil {
+		return stats, err
+	}
+
+	stored, err := p.loadStoredPlaylists()
+	if err != nil {
+		return stats, err
+	}
+
+	var index *songIndex
+	var found, walked []string
+	for _, root := range roots {
+		seen, err := p.importRoot(root, ownerID, stored, &index, &found, &stats)
+		if err != nil {
+			return stats, fmt.Errorf("error reading music folder %s: %w", root, err)
+		}
+		if seen {
+			walked = append(walked, filepath.Clean(root))
+		} else {
+			log.Printf("Music folder %s is empty, keeping its imported playlists", root)
+		}
+	}
+
+	if len(walked) > 0 {
+		result, err := p.db.Exec(`
+			DELETE FROM playlists p
+			WHERE p.source_path IS NOT NULL AND NOT (p.source_path = ANY($1::text[]))
+			  AND EXISTS (
+				SELECT 1 FROM unnest($2::text[]) AS root
+				WHERE p.source_path LIKE replace(replace(replace(root, '\', '\\'), '%', '\%'), '_', '\_') || '/%'
+			  )
+		`, database.Strings(found), database.Strings(walked))
+		if err != nil {
+			return stats, err
+		}
+		removed, _ := result.RowsAffected()
+		stats.Removed = int(removed)
+	}
+
+	log.Printf("Playlist import: %d files, %d imported, %d unchanged, %d removed, %d unresolved entries",
+		stats.Files, stats.Imported, stats.Unchanged, stats.Removed, stats.Unresolved)
+	return stats, nil
+}
+
+// importRoot imports the playlist files under root, adding their paths to found. It
+// reports whether the walk saw any file. The song index is loaded on the first file
+// that needs importing.
+func (p *PlaylistImporter) importRoot(root string, ownerID int, stored map[string]storedPlaylistFile,
+	index **songIndex, found *[]string, stats *PlaylistImportStats) (bool, error) {
+	seen := false
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Unreadable subfolders are skipped, but the root itself must be readable
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		seen = true
+		if !playlistfile.IsPlaylist(path) {
+			return nil
+		}
+		stats.Files++
+		*found = append(*found, path)
+
+		modified := info.ModTime().Truncate(time.Microsecond)
+		if existing, ok := stored[path]; ok && existing.modified.Equal(modified) && existing.unresolved == 0 {
+			stats.Unchanged++
+			return nil
+		}
+
+		if *index == nil {
+			if *index, err = p.loadSongIndex(); err != nil {
+				return err
+			}
+		}
+
+		unresolved, err := p.importFile(path, modified, root, ownerID, *index)
+		if err != nil {
+			log.Printf("Error importing playlist %s: %v", path, err)
+			return nil
+		}
+		stats.Imported++
+		stats.Unresolved += unresolved
+		return nil
+	})
+	return seen, err
+}
+
+func (p *PlaylistImporter) loadStoredPlaylists() (map[string]storedPlaylistFile, error) {
+	rows, err := p.db.Query(`
+		SELECT p.id, p.source_path, p.source_modified, COUNT(u.playlist_id)
+		FROM playlists p
+		LEFT JOIN playlist_unresolved_entries u ON u.playlist_id = p.id
+		WHERE p.source_path IS NOT NULL
+		GROUP BY p.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]storedPlaylistFile)
+	for rows.Next() {
+		var path string
+		var playlist storedPlaylistFile
+		var modified sql.NullTime
+		if err := rows.Scan(&playlist.id, &path, &modified, &playlist.unresolved); err != nil {
+			return nil, err
+		}
+		playlist.modified = modified.Time
+		stored[path] = playlist
+	}
+	return stored, rows.Err()
+}
+
+// importFile replaces the songs of the playlist backed by path and returns how many
+// entries could not be resolved
+func (p *PlaylistImporter) importFile(path string, modified time.Time, musicPath string, ownerID int, index *songIndex) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	entries, err := playlistfile.Parse(path, file)
+	file.Close()
+	if err != nil {
+		return 0, fmt.Errorf("error parsing playlist: %w", err)
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	var playlistID int
+	err = tx.QueryRow(`
+		INSERT INTO playlists (user_id, name, is_public, source_path, source_modified, created_at, updated_at)
+		VALUES ($1, $2, true, $3, $4, NOW(), NOW())
+		ON CONFLICT (source_path)
+		DO UPDATE SET source_modified = $4, updated_at = NOW()
+		RETURNING id
+	`, ownerID, name, path, modified).Scan(&playlistID)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM playlist_songs WHERE playlist_id = $1", playlistID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM playlist_unresolved_entries WHERE playlist_id = $1", playlistID); err != nil {
+		return 0, err
+	}
+
+	dir := filepath.Dir(path)
+	position, unresolved := 0, 0
+	for i, entry := range entries {
+		songID, ok := index.resolve(playlistfile.Candidates(entry.Path, dir, musicPath))
+		if !ok {
+			unresolved++
+			_, err := tx.Exec(`
+				INSERT INTO playlist_unresolved_entries (playlist_id, position, entry)
+				VALUES ($1, $2, $3)
+			`, playlistID, i+1, entry.Path)
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		_, err := tx.Exec(`
+			INSERT INTO playlist_songs (playlist_id, song_id, position, added_at, added_by)
+			VALUES ($1, $2, $3, NOW(), $4)
+		`, playlistID, songID, position, ownerID)
+		if err != nil {
+			return 0, err
+		}
+		position++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if unresolved > 0 {
+		log.Printf("Playlist %s: %d of %d entries could not be found in the library", path, unresolved, len(entries))
+	}
+	return unresolved, nil
+}
+
+// songIndex looks songs up by file path: exactly, then ignoring case, then by the
+// last path components when a playlist was written on another machine
+type songIndex struct {
+	exact    map[string]int
+	folded   map[string]int
+	suffixes map[string]int
+}
+
+// suffixLength is how many trailing path components identify a file ("Artist/Album/Track.mp3")
+const suffixLength = 3
+
+func (p *PlaylistImporter) loadSongIndex() (*songIndex, error) {
+	rows, err := p.db.Query("SELECT id, file_path FROM songs")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index := &songIndex{
+		exact:    make(map[string]int),
+		folded:   make(map[string]int),
+		suffixes: make(map[string]int),
+	}
+	for rows.Next() {
+		var id int
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			return nil, err
+		}
+		index.add(id, path)
+	}
+	return index, rows.Err()
+}
+
+func (idx *songIndex) add(id int, path string) {
+	path = filepath.Clean(path)
+	idx.exact[path] = id
+	idx.folded[strings.ToLower(path)] = id
+
+	// Ambiguous suffixes are marked with 0 and never used
+	key := pathSuffix(path)
+	if _, exists := idx.suffixes[key]; exists {
+		idx.suffixes[key] = 0
+	} else {
+		idx.suffixes[key] = id
+	}
+}
+
+func (idx *songIndex) resolve(candidates []string) (int, bool) {
+	for _, candidate := range candidates {
+		if id, ok := idx.exact[filepath.Clean(candidate)]; ok {
+			return id, true
+		}
+	}
+	for _, candidate := range candidates {
+		if id, ok := idx.folded[strings.ToLower(filepath.Clean(candidate))]; ok {
+			return id, true
+		}
+	}
+	for _, candidate := range candidates {
+		if id := idx.suffixes[pathSuffix(candidate)]; id != 0 {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func pathSuffix(path string) string {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+	if len(parts) > suffixLength {
+		parts = parts[len(parts)-suffixLength:]
+	}
+	return strings.ToLower(strings.Join(parts, "/"))
+}
+
+// ExportPlaylist loads a stored playlist as file entries. Paths are relative to
+// musicPath so the file works when placed at the root of the music folder; smart
+// playlists are evaluated for their owner.
+func ExportPlaylist(db *sql.DB, playlistID int, musicPath string) (string, []playlistfile.Entry, error) {
+	var name string
+	var ownerID int
+	var rules sql.NullString
+	err := db.QueryRow("SELECT name, user_id, rules FROM playlists WHERE id = $1", playlistID).Scan(&name, &ownerID, &rules)
+	if err != nil {
+		return "", nil, err
+	}
+
+	const columns = "s.file_path, s.title, ar.name, s.duration"
+	var rows *sql.Rows
+	if rules.Valid {
+		criteria, parseErr := smartplaylist.Parse([]byte(rules.String))
+		if parseErr != nil {
+			return "", nil, parseErr
+		}
+		query, args, queryErr := criteria.Query(columns, ownerID)
+		if queryErr != nil {
+			return "", nil, queryErr
+		}
+		rows, err = db.Query(query, args...)
+	} else {
+		rows, err = db.Query(`
+			SELECT `+columns+`
+			FROM playlist_songs ps
+			JOIN songs s ON ps.song_id = s.id
+			JOIN artists ar ON s.artist_id = ar.id
+			WHERE ps.playlist_id = $1
+			ORDER BY ps.position, ps.added_at
+		`, playlistID)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	defer rows.Close()
+
+	var entries []playlistfile.Entry
+	for rows.Next() {
+		var path, title, artist string
+		var duration sql.NullInt32
+		if err := rows.Scan(&path, &title, &artist, &duration); err != nil {
+			return "", nil, err
+		}
+
+		if rel, err := filepath.Rel(musicPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = filepath.ToSlash(rel)
+		}
+		entries = append(entries, playlistfile.Entry{
+			Path:     path,
+			Title:    artist + " - " + title,
+			Duration: int(duration.Int32),
+		})
+	}
+	return name, entries, rows.Err()
+}
+
+// UnresolvedEntry is a playlist file entry that matched no song in the library
+type UnresolvedEntry struct {
+	Position int
+	Entry    string
+}
+
+// UnresolvedEntries returns the entries of an imported playlist file that were not found
+func UnresolvedEntries(db *sql.DB, playlistID int) ([]UnresolvedEntry, error) {
+	rows, err := db.Query(`
+		SELECT position, entry FROM playlist_unresolved_entries
+		WHERE playlist_id = $1
+		ORDER BY position
+	`, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []UnresolvedEntry
+	for rows.Next() {
+		var entry UnresolvedEntry
+		if err := rows.Scan(&entry.Position, &entry.Entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
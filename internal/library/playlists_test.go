@@ -0,0 +1,47 @@
+package library
+
+import (
+	"testing"
+
+	"castafiore-backend/internal/playlistfile"
+)
+
+func TestSongIndexResolve(t *testing.T) {
+	index := &songIndex{
+		exact:    make(map[string]int),
+		folded:   make(map[string]int),
+		suffixes: make(map[string]int),
+	}
+	index.add(1, "/music/Queen/A Night at the Opera/11 Bohemian Rhapsody.flac")
+	index.add(2, "/music/Björk/Homogenic/01 Hunter.mp3")
+	index.add(3, "/music/Rock/Various/Disc 1/01 Intro.mp3")
+	index.add(4, "/music/Jazz/Various/Disc 1/01 Intro.mp3")
+
+	tests := []struct {
+		entry string
+		want  int
+	}{
+		// relative to the playlist directory
+		{"../Queen/A Night at the Opera/11 Bohemian Rhapsody.flac", 1},
+		// relative to the music root
+		{"Björk/Homogenic/01 Hunter.mp3", 2},
+		// absolute
+		{"/music/Björk/Homogenic/01 Hunter.mp3", 2},
+		// different case
+		{"björk/homogenic/01 hunter.MP3", 2},
+		// written on another machine
+		{`D:\Users\me\Music\Queen\A Night at the Opera\11 Bohemian Rhapsody.flac`, 1},
+		// ambiguous suffix
+		{"/elsewhere/Various/Disc 1/01 Intro.mp3", 0},
+		{"/elsewhere/Rock/Various/Disc 1/01 Intro.mp3", 0},
+		// missing
+		{"Queen/Innuendo/01 Innuendo.flac", 0},
+	}
+
+	for _, tt := range tests {
+		got, ok := index.resolve(playlistfile.Candidates(tt.entry, "/music/Playlists", "/music"))
+		if got != tt.want || ok != (tt.want != 0) {
+			t.Errorf("resolve(%q) = %d, %v, want %d", tt.entry, got, ok, tt.want)
+		}
+	}
+}
@@ -11,6 +11,8 @@ import (
 	"strings"
 	"time"
 
+	"castafiore-backend/internal/matching"
+
 	"github.com/dhowden/tag"
 )
 
@@ -36,6 +38,7 @@ type AudioFile struct {
 	Format      string
 	Bitrate     int
 	CoverArt    []byte // Cover art image data
+	MBIDs       MusicBrainzIDs
 }
 
 func NewScanner(db *sql.DB) *Scanner {
@@ -128,6 +131,10 @@ func (s *Scanner) ScanLibrary(musicPath string) error {
 		return fmt.Errorf(s.LastError)
 	}
 
+	if _, err := NewPlaylistImporter(s.db).ImportPlaylists(musicPath); err != nil {
+		log.Printf("Error importing playlist files: %v", err)
+	}
+
 	s.IsScanning = false
 	log.Println("Library scan completed")
 	return nil
@@ -208,6 +215,7 @@ func (s *Scanner) processAudioFile(path string, info os.FileInfo) error {
 		Format:      format,
 		Bitrate:     bitrate,
 		CoverArt:    coverArt,
+		MBIDs:       extractMusicBrainzIDs(metadata),
 	}
 
 	// Add to database
@@ -651,8 +659,8 @@ func (s *Scanner) getOrCreateArtist(tx *sql.Tx, name string) (int, error) {
 	if err == sql.ErrNoRows {
 		// Create new artist
 		err = tx.QueryRow(
-			"INSERT INTO artists (name, created_at, updated_at) VALUES ($1, NOW(), NOW()) RETURNING id",
-			cleanName,
+			"INSERT INTO artists (name, match_name, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) RETURNING id",
+			cleanName, matching.NormalizeArtist(cleanName),
 		).Scan(&id)
 		if err != nil {
 			return 0, fmt.Errorf("failed to insert artist '%s': %v", cleanName, err)
@@ -802,8 +810,9 @@ func (s *Scanner) insertOrUpdateSong(tx *sql.Tx, file AudioFile, artistID, album
 	}
 
 	_, err := tx.Exec(`
-		INSERT INTO songs (title, artist_id, album_id, track_number, duration, file_path, file_size, bitrate, format, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+		INSERT INTO songs (title, artist_id, album_id, track_number, duration, file_path, file_size, bitrate, format,
+		                   mbz_recording_id, mbz_release_id, mbz_artist_id, match_title, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), $13, NOW(), NOW())
 		ON CONFLICT (file_path) 
 		DO UPDATE SET 
 			title = EXCLUDED.title,
@@ -814,8 +823,13 @@ func (s *Scanner) insertOrUpdateSong(tx *sql.Tx, file AudioFile, artistID, album
 			file_size = EXCLUDED.file_size,
 			bitrate = EXCLUDED.bitrate,
 			format = EXCLUDED.format,
+			mbz_recording_id = EXCLUDED.mbz_recording_id,
+			mbz_release_id = EXCLUDED.mbz_release_id,
+			mbz_artist_id = EXCLUDED.mbz_artist_id,
+			match_title = EXCLUDED.match_title,
 			updated_at = NOW()
-	`, cleanTitle, artistID, albumID, trackNumber, durationSeconds, file.Path, file.Size, bitrate, file.Format)
+	`, cleanTitle, artistID, albumID, trackNumber, durationSeconds, file.Path, file.Size, bitrate, file.Format,
+		file.MBIDs.Recording, file.MBIDs.Release, file.MBIDs.Artist, matching.NormalizeTitle(cleanTitle))
 
 	if err != nil {
 		return fmt.Errorf("failed to insert/update song '%s': %v", cleanTitle, err)
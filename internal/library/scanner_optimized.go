@@ -10,6 +10,8 @@ import (
 	"sync"
 	"time"
 
+	"castafiore-backend/internal/matching"
+
 	"github.com/dhowden/tag"
 )
 
@@ -139,6 +141,7 @@ func (s *OptimizedScanner) ScanLibraryOptimized(musicPath string) error {
 
 	if len(filesToProcess) == 0 {
 		log.Println("No files need processing (incremental mode)")
+		s.finishScan(musicPath)
 		s.mutex.Lock()
 		s.IsScanning = false
 		s.mutex.Unlock()
@@ -146,7 +149,16 @@ func (s *OptimizedScanner) ScanLibraryOptimized(musicPath string) error {
 	}
 
 	// Process files in batches using worker pool
-	return s.processBatches(filesToProcess)
+	err = s.processBatches(filesToProcess)
+	s.finishScan(musicPath)
+	return err
+}
+
+// finishScan syncs the playlist files once the songs are stored
+func (s *OptimizedScanner) finishScan(musicPath string) {
+	if _, err := NewPlaylistImporter(s.db).ImportPlaylists(musicPath); err != nil {
+		log.Printf("Error importing playlist files: %v", err)
+	}
 }
 
 // processBatches handles batch processing with worker pool
@@ -300,8 +312,8 @@ func (s *OptimizedScanner) getOrCreateArtistOptimized(tx *sql.Tx, name string) (
 	if err == sql.ErrNoRows {
 		// Create new artist
 		err = tx.QueryRow(
-			"INSERT INTO artists (name, created_at, updated_at) VALUES ($1, NOW(), NOW()) RETURNING id",
-			cleanName,
+			"INSERT INTO artists (name, match_name, created_at, updated_at) VALUES ($1, $2, NOW(), NOW()) RETURNING id",
+			cleanName, matching.NormalizeArtist(cleanName),
 		).Scan(&id)
 		if err != nil {
 			return 0, fmt.Errorf("failed to insert artist '%s': %v", cleanName, err)
@@ -380,8 +392,9 @@ func (s *OptimizedScanner) insertOrUpdateSongOptimized(tx *sql.Tx, file AudioFil
 	}
 
 	_, err := tx.Exec(`
-		INSERT INTO songs (title, artist_id, album_id, track_number, duration, file_path, file_size, bitrate, format, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW(), NOW())
+		INSERT INTO songs (title, artist_id, album_id, track_number, duration, file_path, file_size, bitrate, format,
+		                   mbz_recording_id, mbz_release_id, mbz_artist_id, match_title, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), NULLIF($11, ''), NULLIF($12, ''), $13, NOW(), NOW())
 		ON CONFLICT (file_path) 
 		DO UPDATE SET 
 			title = EXCLUDED.title,
@@ -392,8 +405,13 @@ func (s *OptimizedScanner) insertOrUpdateSongOptimized(tx *sql.Tx, file AudioFil
 			file_size = EXCLUDED.file_size,
 			bitrate = EXCLUDED.bitrate,
 			format = EXCLUDED.format,
+			mbz_recording_id = EXCLUDED.mbz_recording_id,
+			mbz_release_id = EXCLUDED.mbz_release_id,
+			mbz_artist_id = EXCLUDED.mbz_artist_id,
+			match_title = EXCLUDED.match_title,
 			updated_at = NOW()
-	`, cleanTitle, artistID, albumID, trackNumber, durationSeconds, file.Path, file.Size, bitrate, file.Format)
+	`, cleanTitle, artistID, albumID, trackNumber, durationSeconds, file.Path, file.Size, bitrate, file.Format,
+		file.MBIDs.Recording, file.MBIDs.Release, file.MBIDs.Artist, matching.NormalizeTitle(cleanTitle))
 
 	if err != nil {
 		return fmt.Errorf("failed to insert/update song '%s': %v", cleanTitle, err)
@@ -479,6 +497,7 @@ func (s *OptimizedScanner) extractMetadataFast(path string, fileSize int64) (*Au
 		Size:        fileSize,
 		Format:      format,
 		Bitrate:     bitrate,
+		MBIDs:       extractMusicBrainzIDs(metadata),
 	}
 
 	// Skip cover art extraction for large libraries or if disabled
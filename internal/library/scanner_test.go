@@ -3,6 +3,8 @@ package library
 import (
 	"testing"
 	"time"
+
+	"github.com/dhowden/tag"
 )
 
 func TestExtractAudioProperties(t *testing.T) {
@@ -131,3 +133,67 @@ func TestDurationCalculation(t *testing.T) {
 
 	t.Logf("5MB file at 128kbps = %v (%v seconds)", expectedDuration, expectedSeconds)
 }
+
+// rawMetadata exposes raw tags the way dhowden/tag reports them for a given format
+type rawMetadata struct {
+	basicMetadata
+	format tag.Format
+	raw    map[string]interface{}
+}
+
+func (m *rawMetadata) Format() tag.Format          { return m.format }
+func (m *rawMetadata) Raw() map[string]interface{} { return m.raw }
+
+func TestExtractMusicBrainzIDs(t *testing.T) {
+	const (
+		recording = "8e4ffb1c-3b4f-4b6c-a5c8-1b4a4c9f1f11"
+		release   = "b1392450-e666-3926-a536-22c65f834433"
+		artist    = "a74b1b7f-71a5-4011-9441-d0b5e4122711"
+	)
+
+	tests := []struct {
+		name     string
+		metadata tag.Metadata
+		want     MusicBrainzIDs
+	}{
+		{
+			name: "ID3v2 TXXX and UFID frames",
+			metadata: &rawMetadata{format: tag.ID3v2_4, raw: map[string]interface{}{
+				"UFID":   &tag.UFID{Provider: "http://musicbrainz.org", Identifier: []byte(recording)},
+				"TXXX":   &tag.Comm{Description: "MusicBrainz Album Id", Text: release},
+				"TXXX_0": &tag.Comm{Description: "MusicBrainz Artist Id", Text: artist},
+			}},
+			want: MusicBrainzIDs{Recording: recording, Release: release, Artist: artist},
+		},
+		{
+			name: "Vorbis comments",
+			metadata: &rawMetadata{format: tag.VORBIS, raw: map[string]interface{}{
+				"musicbrainz_trackid":  recording,
+				"musicbrainz_albumid":  release,
+				"musicbrainz_artistid": artist + "; 0383dadf-2a4e-4d10-a46a-e9e041da8eb3",
+			}},
+			want: MusicBrainzIDs{Recording: recording, Release: release, Artist: artist},
+		},
+		{
+			name: "Malformed identifiers are ignored",
+			metadata: &rawMetadata{format: tag.VORBIS, raw: map[string]interface{}{
+				"musicbrainz_trackid": "not-an-mbid",
+				"musicbrainz_albumid": "B1392450-E666-3926-A536-22C65F834433",
+			}},
+			want: MusicBrainzIDs{Release: release},
+		},
+		{
+			name:     "No tags",
+			metadata: &basicMetadata{},
+			want:     MusicBrainzIDs{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := extractMusicBrainzIDs(tt.metadata); got != tt.want {
+				t.Errorf("extractMusicBrainzIDs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package listenbrainz
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultBaseURL is the public ListenBrainz API
+	DefaultBaseURL = "https://api.listenbrainz.org"
+
+	// MaxListensPerRequest is the maximum payload size accepted by submit-listens
+	MaxListensPerRequest = 1000
+
+	ListenTypeSingle     = "single"
+	ListenTypePlayingNow = "playing_now"
+	ListenTypeImport     = "import"
+
+	clientName = "Castafiore"
+)
+
+// ErrInvalidToken means the user token was rejected and must be replaced
+var ErrInvalidToken = errors.New("listenbrainz token is invalid")
+
+// APIError is an error response of the ListenBrainz API
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("listenbrainz API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Temporary reports whether the request may succeed if retried later
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Listen is a single listen, optionally enriched with MusicBrainz identifiers
+type Listen struct {
+	ListenedAt    time.Time `json:"listened_at"`
+	Artist        string    `json:"artist"`
+	Track         string    `json:"track"`
+	Release       string    `json:"release,omitempty"`
+	Duration      int       `json:"duration,omitempty"` // seconds
+	TrackNumber   int       `json:"track_number,omitempty"`
+	RecordingMBID string    `json:"recording_mbid,omitempty"`
+	ReleaseMBID   string    `json:"release_mbid,omitempty"`
+	ArtistMBID    string    `json:"artist_mbid,omitempty"`
+}
+
+type submission struct {
+	ListenType string          `json:"listen_type"`
+	Payload    []listenPayload `json:"payload"`
+}
+
+type listenPayload struct {
+	ListenedAt    int64         `json:"listened_at,omitempty"`
+	TrackMetadata trackMetadata `json:"track_metadata"`
+}
+
+type trackMetadata struct {
+	ArtistName     string         `json:"artist_name"`
+	TrackName      string         `json:"track_name"`
+	ReleaseName    string         `json:"release_name,omitempty"`
+	AdditionalInfo additionalInfo `json:"additional_info"`
+}
+
+type additionalInfo struct {
+	MediaPlayer      string   `json:"media_player"`
+	SubmissionClient string   `json:"submission_client"`
+	DurationMs       int      `json:"duration_ms,omitempty"`
+	TrackNumber      int      `json:"tracknumber,omitempty"`
+	RecordingMBID    string   `json:"recording_mbid,omitempty"`
+	ReleaseMBID      string   `json:"release_mbid,omitempty"`
+	ArtistMBIDs      []string `json:"artist_mbids,omitempty"`
+}
+
+// Client talks to the ListenBrainz API
+type Client struct {
+	client  *http.Client
+	baseURL string
+}
+
+func NewClient(baseURL string) *Client {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return &Client{
+		client:  &http.Client{Timeout: 15 * time.Second},
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+// ValidateToken checks a user token and returns the ListenBrainz username it belongs to
+func (c *Client) ValidateToken(token string) (string, error) {
+	var resp struct {
+		Valid    bool   `json:"valid"`
+		UserName string `json:"user_name"`
+	}
+	if err := c.do("GET", "/1/validate-token", token, nil, &resp); err != nil {
+		return "", err
+	}
+	if !resp.Valid {
+		return "", ErrInvalidToken
+	}
+	return resp.UserName, nil
+}
+
+// SubmitListens sends listens of the given type. playing_now and single accept exactly one listen.
+func (c *Client) SubmitListens(token, listenType string, listens []Listen) error {
+	if len(listens) == 0 {
+		return nil
+	}
+	if listenType != ListenTypeImport && len(listens) != 1 {
+		return fmt.Errorf("listen type %s accepts a single listen, got %d", listenType, len(listens))
+	}
+	if len(listens) > MaxListensPerRequest {
+		return fmt.Errorf("too many listens in one request: %d (max %d)", len(listens), MaxListensPerRequest)
+	}
+
+	body := submission{ListenType: listenType}
+	for _, listen := range listens {
+		payload := listenPayload{
+			TrackMetadata: trackMetadata{
+				ArtistName:  listen.Artist,
+				TrackName:   listen.Track,
+				ReleaseName: listen.Release,
+				AdditionalInfo: additionalInfo{
+					MediaPlayer:      clientName,
+					SubmissionClient: clientName,
+					DurationMs:       listen.Duration * 1000,
+					TrackNumber:      listen.TrackNumber,
+					RecordingMBID:    listen.RecordingMBID,
+					ReleaseMBID:      listen.ReleaseMBID,
+				},
+			},
+		}
+		if listen.ArtistMBID != "" {
+			payload.TrackMetadata.AdditionalInfo.ArtistMBIDs = []string{listen.ArtistMBID}
+		}
+		// playing_now must not carry a timestamp
+		if listenType != ListenTypePlayingNow {
+			payload.ListenedAt = listen.ListenedAt.Unix()
+		}
+		body.Payload = append(body.Payload, payload)
+	}
+
+	return c.do("POST", "/1/submit-listens", token, body, nil)
+}
+
+// do performs an authenticated request, decoding the JSON response into out
+func (c *Client) do(method, path, token string, in, out interface{}) error {
+	var reqBody io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("error encoding request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request to listenbrainz: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response: %w", err)
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return ErrInvalidToken
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiError struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(data, &apiError)
+		if apiError.Error == "" {
+			apiError.Error = http.StatusText(resp.StatusCode)
+		}
+		return &APIError{StatusCode: resp.StatusCode, Message: apiError.Error}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("error parsing response: %w", err)
+		}
+	}
+	return nil
+}
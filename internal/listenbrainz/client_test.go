@@ -0,0 +1,148 @@
+package listenbrainz
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testToken = "token-123"
+
+// newTestServer stands in for ListenBrainz, recording the submitted payloads
+func newTestServer(t *testing.T, status int, body string) (*httptest.Server, *[]submission) {
+	var received []submission
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Token "+testToken {
+			t.Errorf("Authorization = %q, want %q", got, "Token "+testToken)
+		}
+
+		if r.URL.Path == "/1/submit-listens" {
+			var sub submission
+			if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+				t.Fatalf("invalid submission: %v", err)
+			}
+			received = append(received, sub)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &received
+}
+
+func TestSubmitSingleListen(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK, `{"status":"ok"}`)
+	listenedAt := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	err := NewClient(server.URL).SubmitListens(testToken, ListenTypeSingle, []Listen{{
+		ListenedAt:    listenedAt,
+		Artist:        "Radiohead",
+		Track:         "Airbag",
+		Release:       "OK Computer",
+		Duration:      284,
+		TrackNumber:   1,
+		RecordingMBID: "8e4ffb1c-3b4f-4b6c-a5c8-1b4a4c9f1f11",
+		ArtistMBID:    "a74b1b7f-71a5-4011-9441-d0b5e4122711",
+	}})
+	if err != nil {
+		t.Fatalf("SubmitListens() error = %v", err)
+	}
+
+	if len(*received) != 1 {
+		t.Fatalf("received %d submissions, want 1", len(*received))
+	}
+	sub := (*received)[0]
+	if sub.ListenType != ListenTypeSingle || len(sub.Payload) != 1 {
+		t.Fatalf("submission = %+v", sub)
+	}
+
+	payload := sub.Payload[0]
+	if payload.ListenedAt != listenedAt.Unix() {
+		t.Errorf("listened_at = %d, want %d", payload.ListenedAt, listenedAt.Unix())
+	}
+	meta := payload.TrackMetadata
+	if meta.ArtistName != "Radiohead" || meta.TrackName != "Airbag" || meta.ReleaseName != "OK Computer" {
+		t.Errorf("track_metadata = %+v", meta)
+	}
+	info := meta.AdditionalInfo
+	if info.DurationMs != 284000 || info.TrackNumber != 1 {
+		t.Errorf("duration_ms = %d, tracknumber = %d", info.DurationMs, info.TrackNumber)
+	}
+	if info.RecordingMBID != "8e4ffb1c-3b4f-4b6c-a5c8-1b4a4c9f1f11" {
+		t.Errorf("recording_mbid = %q", info.RecordingMBID)
+	}
+	if len(info.ArtistMBIDs) != 1 || info.ArtistMBIDs[0] != "a74b1b7f-71a5-4011-9441-d0b5e4122711" {
+		t.Errorf("artist_mbids = %v", info.ArtistMBIDs)
+	}
+	if info.ReleaseMBID != "" {
+		t.Errorf("release_mbid = %q, want it omitted", info.ReleaseMBID)
+	}
+}
+
+func TestSubmitPlayingNow(t *testing.T) {
+	server, received := newTestServer(t, http.StatusOK, `{"status":"ok"}`)
+
+	err := NewClient(server.URL).SubmitListens(testToken, ListenTypePlayingNow, []Listen{{
+		ListenedAt: time.Now(),
+		Artist:     "Portishead",
+		Track:      "Roads",
+	}})
+	if err != nil {
+		t.Fatalf("SubmitListens() error = %v", err)
+	}
+
+	sub := (*received)[0]
+	if sub.ListenType != ListenTypePlayingNow {
+		t.Errorf("listen_type = %q, want %q", sub.ListenType, ListenTypePlayingNow)
+	}
+	if sub.Payload[0].ListenedAt != 0 {
+		t.Errorf("playing_now must not include listened_at, got %d", sub.Payload[0].ListenedAt)
+	}
+}
+
+func TestSubmitRejectsBatchForSingle(t *testing.T) {
+	client := NewClient("http://127.0.0.1:0")
+	if err := client.SubmitListens(testToken, ListenTypeSingle, make([]Listen, 2)); err == nil {
+		t.Error("SubmitListens(single) with two listens should fail")
+	}
+}
+
+func TestSubmitErrors(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusUnauthorized, `{"code":401,"error":"Invalid authorization token."}`)
+	err := NewClient(server.URL).SubmitListens(testToken, ListenTypeSingle, []Listen{{Artist: "a", Track: "b"}})
+	if err != ErrInvalidToken {
+		t.Errorf("error = %v, want ErrInvalidToken", err)
+	}
+
+	server, _ = newTestServer(t, http.StatusTooManyRequests, `{"code":429,"error":"Ratelimit exceeded"}`)
+	err = NewClient(server.URL).SubmitListens(testToken, ListenTypeSingle, []Listen{{Artist: "a", Track: "b"}})
+	if apiErr, ok := err.(*APIError); !ok || !apiErr.Temporary() {
+		t.Errorf("error = %v, want a temporary APIError", err)
+	}
+
+	server, _ = newTestServer(t, http.StatusBadRequest, `{"code":400,"error":"JSON document is invalid."}`)
+	err = NewClient(server.URL).SubmitListens(testToken, ListenTypeSingle, []Listen{{Artist: "a", Track: "b"}})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.Temporary() || apiErr.Message != "JSON document is invalid." {
+		t.Errorf("error = %v, want a permanent APIError", err)
+	}
+}
+
+func TestValidateToken(t *testing.T) {
+	server, _ := newTestServer(t, http.StatusOK, `{"code":200,"message":"Token valid.","valid":true,"user_name":"alice"}`)
+	username, err := NewClient(server.URL).ValidateToken(testToken)
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+	if username != "alice" {
+		t.Errorf("username = %q, want %q", username, "alice")
+	}
+
+	server, _ = newTestServer(t, http.StatusOK, `{"code":200,"message":"Token invalid.","valid":false}`)
+	if _, err := NewClient(server.URL).ValidateToken(testToken); err != ErrInvalidToken {
+		t.Errorf("ValidateToken() error = %v, want ErrInvalidToken", err)
+	}
+}
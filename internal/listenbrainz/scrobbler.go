@@ -0,0 +1,215 @@
+package listenbrainz
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+	"time"
+
+	"castafiore-backend/internal/scrobblequeue"
+)
+
+// flushBatchSize is how many queued listens are submitted per request
+const flushBatchSize = 100
+
+// Scrobbler forwards listens of users that stored a ListenBrainz token. Listens are
+// kept in listenbrainz_queue until ListenBrainz accepts them, so nothing is lost while
+// the service is unreachable.
+type Scrobbler struct {
+	db     *sql.DB
+	client *Client
+	queue  *scrobblequeue.Queue[Listen]
+}
+
+func NewScrobbler(db *sql.DB, client *Client) *Scrobbler {
+	s := &Scrobbler{db: db, client: client}
+	s.queue = scrobblequeue.New(db, scrobblequeue.Config[Listen]{
+		Name:         "ListenBrainz",
+		Table:        "listenbrainz_queue",
+		BatchSize:    flushBatchSize,
+		Accounts:     s.pendingAccounts,
+		Load:         s.loadListens,
+		Submit:       s.submit,
+		Temporary:    temporary,
+		Unauthorized: func(err error) bool { return err == ErrInvalidToken },
+		// Keep the listens until the user stores a new token
+		Revoke: s.clearToken,
+	})
+	return s
+}
+
+// Start runs the queue worker, flushing every interval and whenever a listen is queued
+func (s *Scrobbler) Start(interval time.Duration) {
+	s.queue.Start(interval)
+}
+
+// Token returns the ListenBrainz token and username stored for a user
+func (s *Scrobbler) Token(userID int) (string, string, bool) {
+	var token, username sql.NullString
+	err := s.db.QueryRow(`
+		SELECT listenbrainz_token, listenbrainz_username FROM users WHERE id = $1
+	`, userID).Scan(&token, &username)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("[ListenBrainz] Error loading token for user %d: %v", userID, err)
+		}
+		return "", "", false
+	}
+	if token.String == "" {
+		return "", "", false
+	}
+	return token.String, username.String, true
+}
+
+// SetToken validates and stores a user token. An empty token unlinks the account
+// and discards the listens still queued.
+func (s *Scrobbler) SetToken(userID int, token string) (string, error) {
+	if token == "" {
+		if _, err := s.db.Exec("DELETE FROM listenbrainz_queue WHERE user_id = $1", userID); err != nil {
+			return "", err
+		}
+		_, err := s.db.Exec(`
+			UPDATE users SET listenbrainz_token = NULL, listenbrainz_username = NULL WHERE id = $1
+		`, userID)
+		return "", err
+	}
+
+	username, err := s.client.ValidateToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE users SET listenbrainz_token = $1, listenbrainz_username = $2 WHERE id = $3
+	`, token, username, userID)
+	if err != nil {
+		return "", err
+	}
+
+	s.queue.Notify()
+	return username, nil
+}
+
+// NowPlaying submits a playing_now listen. Failures are only logged.
+func (s *Scrobbler) NowPlaying(userID int, listen Listen) {
+	token, _, ok := s.Token(userID)
+	if !ok {
+		return
+	}
+
+	if err := s.client.SubmitListens(token, ListenTypePlayingNow, []Listen{listen}); err != nil {
+		log.Printf("[ListenBrainz] Error submitting playing now for user %d: %v", userID, err)
+	}
+}
+
+// Enqueue stores a listen for a linked user and wakes the worker
+func (s *Scrobbler) Enqueue(userID int, listen Listen) error {
+	if _, _, ok := s.Token(userID); !ok {
+		return nil
+	}
+
+	data, err := json.Marshal(listen)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO listenbrainz_queue (user_id, listen, listened_at, next_attempt_at)
+		VALUES ($1, $2, $3, NOW())
+	`, userID, string(data), listen.ListenedAt)
+	if err != nil {
+		return err
+	}
+
+	s.queue.Notify()
+	return nil
+}
+
+// Flush submits every due listen, oldest first
+func (s *Scrobbler) Flush() {
+	s.queue.Flush()
+}
+
+// pendingAccounts lists the users with a token and due listens
+func (s *Scrobbler) pendingAccounts() ([]scrobblequeue.Account, error) {
+	rows, err := s.db.Query(`
+		SELECT DISTINCT q.user_id, u.listenbrainz_token
+		FROM listenbrainz_queue q
+		JOIN users u ON u.id = q.user_id
+		WHERE u.listenbrainz_token IS NOT NULL AND q.next_attempt_at <= NOW()
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var accounts []scrobblequeue.Account
+	for rows.Next() {
+		var account scrobblequeue.Account
+		if err := rows.Scan(&account.UserID, &account.Credential); err == nil {
+			accounts = append(accounts, account)
+		}
+	}
+	return accounts, rows.Err()
+}
+
+// loadListens returns the oldest due listens of a user, dropping unreadable ones
+func (s *Scrobbler) loadListens(userID, limit int) ([]int64, []Listen, error) {
+	rows, err := s.db.Query(`
+		SELECT id, listen FROM listenbrainz_queue
+		WHERE user_id = $1 AND next_attempt_at <= NOW()
+		ORDER BY listened_at
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ids, unreadable []int64
+	var listens []Listen
+	for rows.Next() {
+		var id int64
+		var data string
+		if err := rows.Scan(&id, &data); err != nil {
+			continue
+		}
+		var listen Listen
+		if err := json.Unmarshal([]byte(data), &listen); err != nil {
+			log.Printf("[ListenBrainz] Dropping unreadable listen %d: %v", id, err)
+			unreadable = append(unreadable, id)
+			continue
+		}
+		ids = append(ids, id)
+		listens = append(listens, listen)
+	}
+	err = rows.Err()
+	rows.Close()
+
+	for _, id := range unreadable {
+		s.db.Exec("DELETE FROM listenbrainz_queue WHERE id = $1", id)
+	}
+	return ids, listens, err
+}
+
+// submit sends a single listen as "single" and a backlog as "import"
+func (s *Scrobbler) submit(token string, listens []Listen) error {
+	listenType := ListenTypeSingle
+	if len(listens) > 1 {
+		listenType = ListenTypeImport
+	}
+	return s.client.SubmitListens(token, listenType, listens)
+}
+
+// temporary reports whether a failed submission is worth retrying: network errors are,
+// listens rejected as invalid are not
+func temporary(err error) bool {
+	apiErr, ok := err.(*APIError)
+	return !ok || apiErr.Temporary()
+}
+
+func (s *Scrobbler) clearToken(userID int) {
+	_, err := s.db.Exec("UPDATE users SET listenbrainz_token = NULL WHERE id = $1", userID)
+	if err != nil {
+		log.Printf("[ListenBrainz] Error clearing token for user %d: %v", userID, err)
+	}
+}
@@ -0,0 +1,50 @@
+package matching
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NormalizeArtist reduces an artist name to a comparison key, so that spellings such as
+// "The Beatles", "Beatles, The" and "beatles" or "Sigur Rós" and "Sigur Ros" are equal.
+// Diacritics and punctuation are removed, "&" and "+" read as "and" and a leading or
+// trailing "The" is dropped.
+func NormalizeArtist(name string) string {
+	name = removeDiacritics(strings.ToLower(name))
+
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r == '&' || r == '+':
+			b.WriteString(" and ")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '’' || r == '.':
+			// "Guns N' Roses" and "R.E.M." keep their words together
+		default:
+			b.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(b.String())
+	// "The The" stays as it is
+	if len(words) > 1 && words[0] == "the" && words[1] != "the" {
+		words = words[1:]
+	} else if len(words) > 1 && words[len(words)-1] == "the" && strings.Contains(name, ",") {
+		words = words[:len(words)-1]
+	}
+	return strings.Join(words, " ")
+}
+
+func removeDiacritics(value string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	result, _, err := transform.String(t, value)
+	if err != nil {
+		return value
+	}
+	return result
+}
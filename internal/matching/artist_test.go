@@ -0,0 +1,46 @@
+package matching
+
+import "testing"
+
+func TestNormalizeArtist(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"The Beatles", "Beatles"},
+		{"Beatles, The", "the beatles"},
+		{"Sigur Rós", "Sigur Ros"},
+		{"Björk", "bjork"},
+		{"Simon & Garfunkel", "Simon and Garfunkel"},
+		{"Guns N' Roses", "Guns N Roses"},
+		{"R.E.M.", "REM"},
+		{"AC/DC", "AC DC"},
+		{"  Massive   Attack ", "massive attack"},
+		{"Mötley Crüe", "Motley Crue"},
+	}
+
+	for _, tt := range tests {
+		if got, want := NormalizeArtist(tt.a), NormalizeArtist(tt.b); got != want {
+			t.Errorf("NormalizeArtist(%q) = %q, NormalizeArtist(%q) = %q, want equal", tt.a, got, tt.b, want)
+		}
+	}
+}
+
+func TestNormalizeArtistKeepsDistinctNames(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"The The", "The"},
+		{"Portishead", "Radiohead"},
+		{"Simon & Garfunkel", "Simon"},
+	}
+
+	for _, tt := range tests {
+		if NormalizeArtist(tt.a) == NormalizeArtist(tt.b) {
+			t.Errorf("NormalizeArtist(%q) == NormalizeArtist(%q) = %q", tt.a, tt.b, NormalizeArtist(tt.a))
+		}
+	}
+
+	if got := NormalizeArtist("The"); got != "the" {
+		t.Errorf("NormalizeArtist(%q) = %q, want %q", "The", got, "the")
+	}
+}
@@ -0,0 +1,150 @@
+package matching
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/lib/pq"
+)
+
+// candidatesPerQuery is how many trigram candidates are scored for each track
+const candidatesPerQuery = 10
+
+// Query is a track to find in the library
+type Query struct {
+	Title  string
+	Artist string
+}
+
+// Match is the best library song for a Query. SongID is 0 when nothing reached the minimum confidence.
+type Match struct {
+	SongID     int
+	Title      string
+	Artist     string
+	Confidence float64
+}
+
+// Resolver finds library songs for external tracks using the pg_trgm indexes on
+// songs.match_title and artists.match_name
+type Resolver struct {
+	db            *sql.DB
+	MinConfidence float64
+}
+
+func NewResolver(db *sql.DB) *Resolver {
+	return &Resolver{db: db, MinConfidence: DefaultMinConfidence}
+}
+
+// Resolve matches a whole batch with a single query. The result has one Match per query, in order.
+func (r *Resolver) Resolve(queries []Query) ([]Match, error) {
+	matches := make([]Match, len(queries))
+	if len(queries) == 0 {
+		return matches, nil
+	}
+
+	titles := make([]string, len(queries))
+	artists := make([]string, len(queries))
+	for i, q := range queries {
+		titles[i] = NormalizeTitle(q.Title)
+		artists[i] = NormalizeArtist(q.Artist)
+	}
+
+	// The % operator uses the trigram index to find titles above pg_trgm.similarity_threshold;
+	// candidates are then ranked by title and artist similarity and scored in Go
+	rows, err := r.db.Query(`
+		SELECT q.idx, c.id, c.title, c.name
+		FROM unnest($1::text[], $2::text[]) WITH ORDINALITY AS q(title, artist, idx)
+		CROSS JOIN LATERAL (
+			SELECT s.id, s.title, ar.name
+			FROM songs s
+			JOIN artists ar ON s.artist_id = ar.id
+			WHERE s.match_title % q.title
+			ORDER BY similarity(s.match_title, q.title) + COALESCE(similarity(ar.match_name, q.artist), 0) DESC
+			LIMIT $3
+		) c
+	`, pq.Array(titles), pq.Array(artists), candidatesPerQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idx, songID int
+		var title, artist string
+		if err := rows.Scan(&idx, &songID, &title, &artist); err != nil {
+			return nil, err
+		}
+
+		i := idx - 1
+		confidence := Score(queries[i].Title, queries[i].Artist, title, artist)
+		if confidence >= r.MinConfidence && confidence > matches[i].Confidence {
+			matches[i] = Match{SongID: songID, Title: title, Artist: artist, Confidence: confidence}
+		}
+	}
+
+	return matches, rows.Err()
+}
+
+// Backfill computes the match keys of songs and artists that do not have them yet,
+// such as rows added before the keys existed
+func (r *Resolver) Backfill() error {
+	songs, err := r.backfillTable(
+		"SELECT id, title FROM songs WHERE match_title IS NULL LIMIT 1000",
+		"UPDATE songs SET match_title = $1 WHERE id = $2",
+		NormalizeTitle,
+	)
+	if err != nil {
+		return err
+	}
+
+	artists, err := r.backfillTable(
+		"SELECT id, name FROM artists WHERE match_name IS NULL LIMIT 1000",
+		"UPDATE artists SET match_name = $1 WHERE id = $2",
+		NormalizeArtist,
+	)
+	if err != nil {
+		return err
+	}
+
+	if songs > 0 || artists > 0 {
+		log.Printf("[Matching] Computed match keys for %d songs and %d artists", songs, artists)
+	}
+	return nil
+}
+
+// backfillTable updates rows in batches until the select returns nothing
+func (r *Resolver) backfillTable(selectQuery, updateQuery string, normalize func(string) string) (int, error) {
+	total := 0
+	for {
+		rows, err := r.db.Query(selectQuery)
+		if err != nil {
+			return total, err
+		}
+
+		type row struct {
+			id    int
+			value string
+		}
+		var batch []row
+		for rows.Next() {
+			var rw row
+			if err := rows.Scan(&rw.id, &rw.value); err != nil {
+				rows.Close()
+				return total, err
+			}
+			batch = append(batch, rw)
+		}
+		rows.Close()
+
+		if len(batch) == 0 {
+			return total, nil
+		}
+
+		for _, rw := range batch {
+			if _, err := r.db.Exec(updateQuery, normalize(rw.value), rw.id); err != nil {
+				return total, err
+			}
+		}
+		total += len(batch)
+	}
+}
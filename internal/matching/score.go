@@ -0,0 +1,26 @@
+package matching
+
+const (
+	// DefaultMinConfidence is the confidence below which a candidate is not considered the same track
+	DefaultMinConfidence = 0.75
+
+	titleWeight  = 0.65
+	artistWeight = 0.35
+)
+
+// Score returns how confident we are that a local track is the requested one, between 0 and 1.
+// Titles are compared after NormalizeTitle and artists by their best matching credit.
+func Score(title, artist, candidateTitle, candidateArtist string) float64 {
+	titleScore := Similarity(NormalizeTitle(title), NormalizeTitle(candidateTitle))
+
+	artistScore := 0.0
+	for _, credit := range ArtistCredits(artist) {
+		for _, candidateCredit := range ArtistCredits(candidateArtist) {
+			if s := Similarity(credit, candidateCredit); s > artistScore {
+				artistScore = s
+			}
+		}
+	}
+
+	return titleWeight*titleScore + artistWeight*artistScore
+}
@@ -0,0 +1,99 @@
+package matching
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	// bracketed matches "(...)", "[...]" and "{...}" segments of a title
+	bracketed = regexp.MustCompile(`\s*[\(\[\{]([^\)\]\}]*)[\)\]\}]`)
+	// trailingFeat matches an unbracketed "feat. X" credit at the end of a title
+	trailingFeat = regexp.MustCompile(`\s+(feat\.?|ft\.?|featuring)\s.*$`)
+	// versionWords mark a segment that only describes the release of a recording
+	versionWords = regexp.MustCompile(`\b(re-?master(ed)?|mono|stereo|version|edit|explicit|clean|bonus( track)?|deluxe|anniversary|feat\.?|ft\.?|featuring|with)\b`)
+	// recordingWords mark a segment that describes a different recording, which is kept
+	recordingWords = regexp.MustCompile(`\b(live|remix|mix|acoustic|instrumental|demo|unplugged|karaoke|reprise)\b`)
+	// creditSeparators split an artist credit into the credited artists
+	creditSeparators = regexp.MustCompile(`\s*(,|;|&|\bfeat\.?|\bft\.?|\bfeaturing\b|\bwith\b|\bvs\.?)\s*`)
+)
+
+// NormalizeTitle reduces a track title to a comparison key. Unicode is folded,
+// release decorations such as "(Remastered 2011)", "- Radio Edit" or "feat. X"
+// are removed, and punctuation is ignored. Segments that name a different
+// recording ("Live", "Remix", "Acoustic", ...) are kept so they do not match the
+// studio version exactly.
+func NormalizeTitle(title string) string {
+	title = foldQuotes(removeDiacritics(strings.ToLower(title)))
+
+	title = bracketed.ReplaceAllStringFunc(title, func(segment string) string {
+		content := bracketed.FindStringSubmatch(segment)[1]
+		if isVersionSuffix(content) {
+			return ""
+		}
+		return " " + content
+	})
+
+	if i := strings.LastIndex(title, " - "); i > 0 && isVersionSuffix(title[i+3:]) {
+		title = title[:i]
+	}
+	title = trailingFeat.ReplaceAllString(title, "")
+
+	return normalizeWords(title)
+}
+
+// ArtistCredits splits an artist credit such as "Daft Punk feat. Pharrell Williams"
+// into normalized artist keys. The whole credit comes first.
+func ArtistCredits(credit string) []string {
+	full := NormalizeArtist(credit)
+	credits := []string{}
+	if full != "" {
+		credits = append(credits, full)
+	}
+
+	lowered := foldQuotes(removeDiacritics(strings.ToLower(credit)))
+	for _, part := range creditSeparators.Split(lowered, -1) {
+		key := NormalizeArtist(part)
+		if key == "" || containsString(credits, key) {
+			continue
+		}
+		credits = append(credits, key)
+	}
+	return credits
+}
+
+func isVersionSuffix(segment string) bool {
+	return versionWords.MatchString(segment) && !recordingWords.MatchString(segment)
+}
+
+// normalizeWords keeps letters and digits, reading "&" as "and"
+func normalizeWords(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '&' || r == '+':
+			b.WriteString(" and ")
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case r == '\'' || r == '.':
+			// "Don't" and "don t" should not differ
+		default:
+			b.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+func foldQuotes(value string) string {
+	return strings.NewReplacer("’", "'", "‘", "'", "`", "'", "“", "\"", "”", "\"", "–", "-", "—", "-").Replace(value)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
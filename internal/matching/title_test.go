@@ -0,0 +1,113 @@
+package matching
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeTitle(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Here Comes the Sun (Remastered 2009)", "Here Comes the Sun"},
+		{"Wish You Were Here - 2011 Remaster", "Wish You Were Here"},
+		{"Get Lucky (feat. Pharrell Williams)", "Get Lucky"},
+		{"Get Lucky feat. Pharrell Williams", "Get Lucky"},
+		{"Lose Yourself [Radio Edit]", "Lose Yourself"},
+		{"Hey Jude - Mono Version", "Hey Jude"},
+		{"Café del Mar", "Cafe Del Mar"},
+		{"Don’t Stop Me Now", "Don't Stop Me Now"},
+		{"Rock & Roll", "Rock and Roll"},
+		{"  Paranoid   Android ", "paranoid android"},
+	}
+
+	for _, tt := range tests {
+		if got, want := NormalizeTitle(tt.a), NormalizeTitle(tt.b); got != want {
+			t.Errorf("NormalizeTitle(%q) = %q, NormalizeTitle(%q) = %q, want equal", tt.a, got, tt.b, want)
+		}
+	}
+}
+
+func TestNormalizeTitleKeepsOtherRecordings(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{"Creep (Live)", "Creep"},
+		{"Creep - Acoustic", "Creep"},
+		{"Around the World (Daft Punk Remix)", "Around the World"},
+		{"Hurt (Demo Version)", "Hurt"},
+	}
+
+	for _, tt := range tests {
+		if NormalizeTitle(tt.a) == NormalizeTitle(tt.b) {
+			t.Errorf("NormalizeTitle(%q) == NormalizeTitle(%q) = %q", tt.a, tt.b, NormalizeTitle(tt.a))
+		}
+	}
+}
+
+func TestArtistCredits(t *testing.T) {
+	tests := []struct {
+		credit string
+		want   []string
+	}{
+		{"Radiohead", []string{"radiohead"}},
+		{"Daft Punk feat. Pharrell Williams", []string{"daft punk feat pharrell williams", "daft punk", "pharrell williams"}},
+		{"Jay-Z & Kanye West", []string{"jay z and kanye west", "jay z", "kanye west"}},
+		{"Santana, Rob Thomas", []string{"santana rob thomas", "santana", "rob thomas"}},
+		{"", []string{}},
+	}
+
+	for _, tt := range tests {
+		if got := ArtistCredits(tt.credit); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ArtistCredits(%q) = %q, want %q", tt.credit, got, tt.want)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	if got := Similarity("word", "word"); got != 1 {
+		t.Errorf("Similarity of equal strings = %v, want 1", got)
+	}
+	if got := Similarity("", ""); got != 0 {
+		t.Errorf("Similarity of empty strings = %v, want 0", got)
+	}
+	if got := Similarity("abc", "xyz"); got != 0 {
+		t.Errorf("Similarity of unrelated strings = %v, want 0", got)
+	}
+
+	// pg_trgm: similarity('word', 'two words') = 4 / 11
+	if got, want := Similarity("word", "two words"), 4.0/11.0; got != want {
+		t.Errorf("Similarity(word, two words) = %v, want %v", got, want)
+	}
+}
+
+// TestScoreCorpus checks the match decision on titles seen in Last.fm results
+func TestScoreCorpus(t *testing.T) {
+	tests := []struct {
+		title, artist     string
+		localTitle, local string
+		match             bool
+	}{
+		{"Here Comes the Sun - Remastered 2009", "The Beatles", "Here Comes The Sun", "Beatles", true},
+		{"Wish You Were Here", "Pink Floyd", "Wish You Were Here (2011 Remaster)", "Pink Floyd", true},
+		{"Get Lucky (feat. Pharrell Williams & Nile Rodgers)", "Daft Punk", "Get Lucky", "Daft Punk", true},
+		{"Get Lucky", "Daft Punk feat. Pharrell Williams", "Get Lucky", "Daft Punk", true},
+		{"Hoppípolla", "Sigur Rós", "Hoppipolla", "Sigur Ros", true},
+		{"Smooth", "Santana", "Smooth", "Santana, Rob Thomas", true},
+		{"Lose Yourself", "Eminem", "Lose Yourself [Radio Edit]", "Eminem", true},
+		{"Dont Look Back in Anger", "Oasis", "Don't Look Back In Anger", "Oasis", true},
+		{"Creep", "Radiohead", "Creep", "TLC", false},
+		{"Creep", "Radiohead", "Creep (Live)", "Radiohead", false},
+		{"Around the World", "Daft Punk", "Around the World (Daft Punk Remix)", "Daft Punk", false},
+		{"Hurt", "Nine Inch Nails", "Hurt", "Johnny Cash", false},
+		{"Yesterday", "The Beatles", "Yesterdays", "Guns N' Roses", false},
+	}
+
+	for _, tt := range tests {
+		score := Score(tt.title, tt.artist, tt.localTitle, tt.local)
+		if got := score >= DefaultMinConfidence; got != tt.match {
+			t.Errorf("Score(%q by %q, %q by %q) = %.2f, match = %v, want %v",
+				tt.title, tt.artist, tt.localTitle, tt.local, score, got, tt.match)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package matching
+
+import "strings"
+
+// Similarity returns the trigram similarity of two strings, between 0 and 1.
+// It follows pg_trgm: every word is padded with two spaces before and one after,
+// and the result is the number of shared trigrams divided by the number of
+// distinct trigrams of both strings.
+func Similarity(a, b string) float64 {
+	if a == b {
+		if a == "" {
+			return 0
+		}
+		return 1
+	}
+
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+func trigrams(value string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(value) {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,73 @@
+package metadata
+
+import "errors"
+
+// ErrNotFound is returned by agents that have no data for the request,
+// including requests they do not support at all
+var ErrNotFound = errors.New("metadata not found")
+
+// Artist identifies an artist for agent lookups
+type Artist struct {
+	Name string
+	MBID string
+	// Folders are the directories that may hold artist files (usually the parents of its album folders)
+	Folders []string
+}
+
+// Album identifies an album for agent lookups
+type Album struct {
+	Name   string
+	Artist string
+	MBID   string
+	// Folder is the directory holding the album files
+	Folder string
+}
+
+// ArtistInfo is the biography and related data of an artist
+type ArtistInfo struct {
+	Name           string
+	MBID           string
+	Biography      string
+	URL            string
+	SmallImageURL  string
+	MediumImageURL string
+	LargeImageURL  string
+}
+
+// AlbumInfo is the description and related data of an album
+type AlbumInfo struct {
+	Name           string
+	MBID           string
+	Notes          string
+	URL            string
+	SmallImageURL  string
+	MediumImageURL string
+	LargeImageURL  string
+}
+
+// SimilarArtist is an artist related to another one, with a match score between 0 and 1
+type SimilarArtist struct {
+	Name  string
+	MBID  string
+	Match float64
+}
+
+// Track is a track suggested by an agent (top or similar tracks)
+type Track struct {
+	Title  string
+	Artist string
+	MBID   string
+	Match  float64
+}
+
+// Agent is a source of external metadata. Agents return ErrNotFound when they have
+// nothing for a request, so the Chain can ask the next one.
+type Agent interface {
+	// Name identifies the agent in the METADATA_AGENTS priority list
+	Name() string
+	ArtistInfo(artist Artist) (*ArtistInfo, error)
+	SimilarArtists(artist Artist, limit int) ([]SimilarArtist, error)
+	TopTracks(artist Artist, limit int) ([]Track, error)
+	SimilarTracks(artist Artist, title string, limit int) ([]Track, error)
+	AlbumInfo(album Album) (*AlbumInfo, error)
+}
@@ -0,0 +1,187 @@
+package metadata
+
+import (
+	"log"
+	"strings"
+)
+
+// Chain asks its agents in priority order and merges what they return. Single
+// values (biography, images, ...) come from the first agent that has them; lists
+// are concatenated without duplicates until the limit is reached.
+type Chain struct {
+	agents []Agent
+}
+
+// NewChain orders the available agents by the given names. Unknown names are
+// logged and skipped, and agents not listed are not used.
+func NewChain(order []string, available ...Agent) *Chain {
+	byName := make(map[string]Agent)
+	for _, agent := range available {
+		if agent != nil {
+			byName[agent.Name()] = agent
+		}
+	}
+
+	chain := &Chain{}
+	for _, name := range order {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		agent, ok := byName[name]
+		if !ok {
+			log.Printf("[Metadata] Agent %q is not available, skipping", name)
+			continue
+		}
+		chain.agents = append(chain.agents, agent)
+		delete(byName, name)
+	}
+	return chain
+}
+
+// Agents returns the names of the agents in priority order
+func (c *Chain) Agents() []string {
+	names := make([]string, len(c.agents))
+	for i, agent := range c.agents {
+		names[i] = agent.Name()
+	}
+	return names
+}
+
+func (c *Chain) ArtistInfo(artist Artist) (*ArtistInfo, error) {
+	var merged ArtistInfo
+	found := false
+
+	for _, agent := range c.agents {
+		info, err := agent.ArtistInfo(artist)
+		if err != nil {
+			logAgentError(agent, "artist info", artist.Name, err)
+			continue
+		}
+		found = true
+		fillString(&merged.Name, info.Name)
+		fillString(&merged.MBID, info.MBID)
+		fillString(&merged.Biography, info.Biography)
+		fillString(&merged.URL, info.URL)
+		fillString(&merged.SmallImageURL, info.SmallImageURL)
+		fillString(&merged.MediumImageURL, info.MediumImageURL)
+		fillString(&merged.LargeImageURL, info.LargeImageURL)
+	}
+
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &merged, nil
+}
+
+func (c *Chain) AlbumInfo(album Album) (*AlbumInfo, error) {
+	var merged AlbumInfo
+	found := false
+
+	for _, agent := range c.agents {
+		info, err := agent.AlbumInfo(album)
+		if err != nil {
+			logAgentError(agent, "album info", album.Name, err)
+			continue
+		}
+		found = true
+		fillString(&merged.Name, info.Name)
+		fillString(&merged.MBID, info.MBID)
+		fillString(&merged.Notes, info.Notes)
+		fillString(&merged.URL, info.URL)
+		fillString(&merged.SmallImageURL, info.SmallImageURL)
+		fillString(&merged.MediumImageURL, info.MediumImageURL)
+		fillString(&merged.LargeImageURL, info.LargeImageURL)
+	}
+
+	if !found {
+		return nil, ErrNotFound
+	}
+	return &merged, nil
+}
+
+func (c *Chain) SimilarArtists(artist Artist, limit int) ([]SimilarArtist, error) {
+	var result []SimilarArtist
+	seen := make(map[string]bool)
+
+	for _, agent := range c.agents {
+		if len(result) >= limit {
+			break
+		}
+		artists, err := agent.SimilarArtists(artist, limit)
+		if err != nil {
+			logAgentError(agent, "similar artists", artist.Name, err)
+			continue
+		}
+		for _, similar := range artists {
+			key := normalize(similar.Name)
+			if seen[key] || len(result) >= limit {
+				continue
+			}
+			seen[key] = true
+			result = append(result, similar)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, ErrNotFound
+	}
+	return result, nil
+}
+
+func (c *Chain) TopTracks(artist Artist, limit int) ([]Track, error) {
+	return c.mergeTracks("top tracks", artist.Name, limit, func(agent Agent) ([]Track, error) {
+		return agent.TopTracks(artist, limit)
+	})
+}
+
+func (c *Chain) SimilarTracks(artist Artist, title string, limit int) ([]Track, error) {
+	return c.mergeTracks("similar tracks", artist.Name+" - "+title, limit, func(agent Agent) ([]Track, error) {
+		return agent.SimilarTracks(artist, title, limit)
+	})
+}
+
+func (c *Chain) mergeTracks(what, subject string, limit int, fetch func(Agent) ([]Track, error)) ([]Track, error) {
+	var result []Track
+	seen := make(map[string]bool)
+
+	for _, agent := range c.agents {
+		if len(result) >= limit {
+			break
+		}
+		tracks, err := fetch(agent)
+		if err != nil {
+			logAgentError(agent, what, subject, err)
+			continue
+		}
+		for _, track := range tracks {
+			key := normalize(track.Artist) + "|" + normalize(track.Title)
+			if seen[key] || len(result) >= limit {
+				continue
+			}
+			seen[key] = true
+			result = append(result, track)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, ErrNotFound
+	}
+	return result, nil
+}
+
+func logAgentError(agent Agent, what, subject string, err error) {
+	if err != ErrNotFound {
+		log.Printf("[Metadata] %s agent failed to get %s for %s: %v", agent.Name(), what, subject, err)
+	}
+}
+
+func fillString(dst *string, value string) {
+	if *dst == "" {
+		*dst = strings.TrimSpace(value)
+	}
+}
+
+func normalize(value string) string {
+	return strings.Join(strings.Fields(strings.ToLower(value)), " ")
+}
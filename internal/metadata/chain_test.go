@@ -0,0 +1,149 @@
+package metadata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// fakeAgent returns canned data; nil fields answer ErrNotFound
+type fakeAgent struct {
+	name    string
+	info    *ArtistInfo
+	similar []SimilarArtist
+	top     []Track
+	err     error
+}
+
+func (f *fakeAgent) Name() string { return f.name }
+
+func (f *fakeAgent) ArtistInfo(artist Artist) (*ArtistInfo, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.info == nil {
+		return nil, ErrNotFound
+	}
+	return f.info, nil
+}
+
+func (f *fakeAgent) SimilarArtists(artist Artist, limit int) ([]SimilarArtist, error) {
+	if f.similar == nil {
+		return nil, ErrNotFound
+	}
+	return f.similar, nil
+}
+
+func (f *fakeAgent) TopTracks(artist Artist, limit int) ([]Track, error) {
+	if f.top == nil {
+		return nil, ErrNotFound
+	}
+	return f.top, nil
+}
+
+func (f *fakeAgent) SimilarTracks(artist Artist, title string, limit int) ([]Track, error) {
+	return nil, ErrNotFound
+}
+
+func (f *fakeAgent) AlbumInfo(album Album) (*AlbumInfo, error) {
+	return nil, ErrNotFound
+}
+
+func TestChainOrder(t *testing.T) {
+	a := &fakeAgent{name: "a"}
+	b := &fakeAgent{name: "b"}
+	c := &fakeAgent{name: "c"}
+
+	chain := NewChain([]string{" B ", "missing", "a"}, a, b, c)
+	if got, want := chain.Agents(), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Agents() = %v, want %v", got, want)
+	}
+}
+
+func TestChainMergesArtistInfo(t *testing.T) {
+	failing := &fakeAgent{name: "failing", err: errors.New("connection refused")}
+	files := &fakeAgent{name: "files", info: &ArtistInfo{Biography: "Local biography"}}
+	online := &fakeAgent{name: "online", info: &ArtistInfo{
+		Biography:     "Online biography",
+		MBID:          "mbid",
+		LargeImageURL: "https://example.com/large.jpg",
+	}}
+
+	chain := NewChain([]string{"failing", "files", "online"}, failing, files, online)
+	info, err := chain.ArtistInfo(Artist{Name: "Radiohead"})
+	if err != nil {
+		t.Fatalf("ArtistInfo() error = %v", err)
+	}
+
+	want := ArtistInfo{Biography: "Local biography", MBID: "mbid", LargeImageURL: "https://example.com/large.jpg"}
+	if *info != want {
+		t.Errorf("ArtistInfo() = %+v, want %+v", *info, want)
+	}
+}
+
+func TestChainNotFound(t *testing.T) {
+	chain := NewChain([]string{"a"}, &fakeAgent{name: "a"})
+	if _, err := chain.ArtistInfo(Artist{Name: "Nobody"}); err != ErrNotFound {
+		t.Errorf("ArtistInfo() error = %v, want ErrNotFound", err)
+	}
+	if _, err := chain.TopTracks(Artist{Name: "Nobody"}, 10); err != ErrNotFound {
+		t.Errorf("TopTracks() error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestChainMergesLists(t *testing.T) {
+	first := &fakeAgent{name: "first", similar: []SimilarArtist{{Name: "Portishead"}, {Name: "Massive Attack"}}}
+	second := &fakeAgent{name: "second", similar: []SimilarArtist{{Name: "massive  attack"}, {Name: "Tricky"}, {Name: "Björk"}}}
+
+	chain := NewChain([]string{"first", "second"}, first, second)
+	artists, err := chain.SimilarArtists(Artist{Name: "Radiohead"}, 3)
+	if err != nil {
+		t.Fatalf("SimilarArtists() error = %v", err)
+	}
+
+	var names []string
+	for _, artist := range artists {
+		names = append(names, artist.Name)
+	}
+	if want := []string{"Portishead", "Massive Attack", "Tricky"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("SimilarArtists() = %v, want %v", names, want)
+	}
+}
+
+func TestFilesAgent(t *testing.T) {
+	artistDir := t.TempDir()
+	albumDir := filepath.Join(artistDir, "OK Computer")
+	if err := os.Mkdir(albumDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(artistDir, "Biography.TXT"), []byte("\ufeffBand from Abingdon.\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(albumDir, "notes.txt"), []byte("Released in 1997."), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	agent := NewFilesAgent()
+
+	info, err := agent.ArtistInfo(Artist{Name: "Radiohead", Folders: []string{albumDir, artistDir}})
+	if err != nil {
+		t.Fatalf("ArtistInfo() error = %v", err)
+	}
+	if info.Biography != "Band from Abingdon." {
+		t.Errorf("Biography = %q", info.Biography)
+	}
+
+	album, err := agent.AlbumInfo(Album{Name: "OK Computer", Folder: albumDir})
+	if err != nil {
+		t.Fatalf("AlbumInfo() error = %v", err)
+	}
+	if album.Notes != "Released in 1997." {
+		t.Errorf("Notes = %q", album.Notes)
+	}
+
+	if _, err := agent.ArtistInfo(Artist{Name: "Nobody", Folders: []string{t.TempDir()}}); err != ErrNotFound {
+		t.Errorf("ArtistInfo() without files error = %v, want ErrNotFound", err)
+	}
+}
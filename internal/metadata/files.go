@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	// artistBioFiles are looked up in the artist folders, in this order
+	artistBioFiles = []string{"artist.txt", "biography.txt", "bio.txt"}
+	// albumNotesFiles are looked up in the album folder, in this order
+	albumNotesFiles = []string{"album.txt", "notes.txt", "info.txt"}
+)
+
+// maxTextFileSize keeps stray large files from being loaded as a biography
+const maxTextFileSize = 64 * 1024
+
+// FilesAgent reads biographies and album notes from text files stored next to the music
+type FilesAgent struct{}
+
+func NewFilesAgent() *FilesAgent {
+	return &FilesAgent{}
+}
+
+func (a *FilesAgent) Name() string { return "files" }
+
+func (a *FilesAgent) ArtistInfo(artist Artist) (*ArtistInfo, error) {
+	for _, folder := range artist.Folders {
+		if bio := readFirstTextFile(folder, artistBioFiles); bio != "" {
+			return &ArtistInfo{Name: artist.Name, Biography: bio}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (a *FilesAgent) AlbumInfo(album Album) (*AlbumInfo, error) {
+	if album.Folder == "" {
+		return nil, ErrNotFound
+	}
+	if notes := readFirstTextFile(album.Folder, albumNotesFiles); notes != "" {
+		return &AlbumInfo{Name: album.Name, Notes: notes}, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (a *FilesAgent) SimilarArtists(artist Artist, limit int) ([]SimilarArtist, error) {
+	return nil, ErrNotFound
+}
+
+func (a *FilesAgent) TopTracks(artist Artist, limit int) ([]Track, error) {
+	return nil, ErrNotFound
+}
+
+func (a *FilesAgent) SimilarTracks(artist Artist, title string, limit int) ([]Track, error) {
+	return nil, ErrNotFound
+}
+
+// readFirstTextFile returns the content of the first candidate found in dir.
+// File names are matched case-insensitively ("Artist.txt" works too).
+func readFirstTextFile(dir string, candidates []string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+
+	names := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names[strings.ToLower(entry.Name())] = entry.Name()
+		}
+	}
+
+	for _, candidate := range candidates {
+		name, ok := names[candidate]
+		if !ok {
+			continue
+		}
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err != nil || info.Size() > maxTextFileSize {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if text := strings.TrimSpace(strings.TrimPrefix(string(data), "\ufeff")); text != "" {
+			return text
+		}
+	}
+	return ""
+}
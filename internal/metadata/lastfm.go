@@ -0,0 +1,128 @@
+package metadata
+
+import "castafiore-backend/internal/lastfm"
+
+// LastFMAgent reads metadata from the Last.fm API
+type LastFMAgent struct {
+	service *lastfm.Service
+}
+
+func NewLastFMAgent(service *lastfm.Service) *LastFMAgent {
+	return &LastFMAgent{service: service}
+}
+
+func (a *LastFMAgent) Name() string { return "lastfm" }
+
+func (a *LastFMAgent) ArtistInfo(artist Artist) (*ArtistInfo, error) {
+	resp, err := a.service.GetArtistInfo(artist.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ArtistInfo{
+		Name:      resp.Artist.Name,
+		MBID:      resp.Artist.MBID,
+		Biography: resp.Artist.Bio.Summary,
+		URL:       resp.Artist.URL,
+	}
+	for _, img := range resp.Artist.Images {
+		switch img.Size {
+		case "small":
+			info.SmallImageURL = img.Text
+		case "medium":
+			info.MediumImageURL = img.Text
+		case "large":
+			info.LargeImageURL = img.Text
+		}
+	}
+	return info, nil
+}
+
+func (a *LastFMAgent) SimilarArtists(artist Artist, limit int) ([]SimilarArtist, error) {
+	resp, err := a.service.GetSimilarArtists(artist.Name, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var artists []SimilarArtist
+	for _, similar := range resp.SimilarArtists.Artist {
+		artists = append(artists, SimilarArtist{
+			Name:  similar.Name,
+			MBID:  similar.MBID,
+			Match: float64(similar.Match),
+		})
+	}
+	if len(artists) == 0 {
+		return nil, ErrNotFound
+	}
+	return artists, nil
+}
+
+func (a *LastFMAgent) TopTracks(artist Artist, limit int) ([]Track, error) {
+	topTracks, err := a.service.GetTopTracks(artist.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []Track
+	for _, track := range topTracks {
+		if len(tracks) >= limit {
+			break
+		}
+		tracks = append(tracks, Track{Title: track.Name, Artist: track.Artist.Name, MBID: track.MBID})
+	}
+	if len(tracks) == 0 {
+		return nil, ErrNotFound
+	}
+	return tracks, nil
+}
+
+func (a *LastFMAgent) SimilarTracks(artist Artist, title string, limit int) ([]Track, error) {
+	resp, err := a.service.GetSimilarTracks(artist.Name, title)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []Track
+	for _, track := range resp.Track {
+		if len(tracks) >= limit {
+			break
+		}
+		tracks = append(tracks, Track{
+			Title:  track.Name,
+			Artist: track.Artist.Name,
+			MBID:   track.MBID,
+			Match:  track.Match,
+		})
+	}
+	if len(tracks) == 0 {
+		return nil, ErrNotFound
+	}
+	return tracks, nil
+}
+
+func (a *LastFMAgent) AlbumInfo(album Album) (*AlbumInfo, error) {
+	resp, err := a.service.GetAlbumInfo(album.Artist, album.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &AlbumInfo{
+		Name:  resp.Album.Name,
+		MBID:  resp.Album.MBID,
+		Notes: resp.Album.Wiki.Summary,
+		URL:   resp.Album.URL,
+	}
+	for _, img := range resp.Album.Images {
+		switch img.Size {
+		case "small":
+			info.SmallImageURL = img.Text
+		case "medium":
+			info.MediumImageURL = img.Text
+		case "large", "extralarge":
+			// Prefer the biggest image Last.fm has
+			info.LargeImageURL = img.Text
+		}
+	}
+	return info, nil
+}
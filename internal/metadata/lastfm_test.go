@@ -0,0 +1,43 @@
+package metadata
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"castafiore-backend/internal/lastfm"
+)
+
+func TestLastFMAgentAlbumInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("method") != "album.getInfo" || q.Get("artist") != "Radiohead" || q.Get("album") != "OK Computer" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"album":{"name":"OK Computer","artist":"Radiohead","mbid":"b1392450-e666-3926-a536-22c65f834433",
+			"url":"https://www.last.fm/music/Radiohead/OK+Computer",
+			"image":[{"size":"small","#text":"s.jpg"},{"size":"medium","#text":"m.jpg"},{"size":"large","#text":"l.jpg"},{"size":"extralarge","#text":"xl.jpg"}],
+			"wiki":{"summary":"Third studio album."}}}`))
+	}))
+	defer server.Close()
+
+	service := lastfm.NewService(lastfm.Config{APIKey: "0123456789abcdef", BaseURL: server.URL})
+	info, err := NewLastFMAgent(service).AlbumInfo(Album{Name: "OK Computer", Artist: "Radiohead"})
+	if err != nil {
+		t.Fatalf("AlbumInfo() error = %v", err)
+	}
+
+	want := AlbumInfo{
+		Name:           "OK Computer",
+		MBID:           "b1392450-e666-3926-a536-22c65f834433",
+		Notes:          "Third studio album.",
+		URL:            "https://www.last.fm/music/Radiohead/OK+Computer",
+		SmallImageURL:  "s.jpg",
+		MediumImageURL: "m.jpg",
+		LargeImageURL:  "xl.jpg",
+	}
+	if *info != want {
+		t.Errorf("AlbumInfo() = %+v, want %+v", *info, want)
+	}
+}
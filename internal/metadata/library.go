@@ -0,0 +1,97 @@
+package metadata
+
+import "database/sql"
+
+// LibraryAgent derives metadata from the local library and listening history.
+// It is meant as the last fallback when no online agent answers.
+type LibraryAgent struct {
+	db *sql.DB
+}
+
+func NewLibraryAgent(db *sql.DB) *LibraryAgent {
+	return &LibraryAgent{db: db}
+}
+
+func (a *LibraryAgent) Name() string { return "library" }
+
+// TopTracks returns the most played songs of the artist
+func (a *LibraryAgent) TopTracks(artist Artist, limit int) ([]Track, error) {
+	rows, err := a.db.Query(`
+		SELECT s.title, ar.name
+		FROM songs s
+		JOIN artists ar ON s.artist_id = ar.id
+		JOIN play_history ph ON ph.song_id = s.id
+		WHERE LOWER(ar.name) = LOWER($1)
+		GROUP BY s.id, s.title, ar.name
+		ORDER BY COUNT(ph.id) DESC, s.title
+		LIMIT $2
+	`, artist.Name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []Track
+	for rows.Next() {
+		var track Track
+		if err := rows.Scan(&track.Title, &track.Artist); err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+	if len(tracks) == 0 {
+		return nil, ErrNotFound
+	}
+	return tracks, rows.Err()
+}
+
+// SimilarArtists returns artists sharing the most genres with the given one.
+// The match is the share of the artist genres they have in common.
+func (a *LibraryAgent) SimilarArtists(artist Artist, limit int) ([]SimilarArtist, error) {
+	rows, err := a.db.Query(`
+		WITH artist_genres AS (
+			SELECT DISTINCT LOWER(al.genre) AS genre
+			FROM albums al
+			JOIN artists ar ON al.artist_id = ar.id
+			WHERE LOWER(ar.name) = LOWER($1) AND al.genre IS NOT NULL AND al.genre <> '' AND al.genre <> 'Unknown'
+		)
+		SELECT ar.name,
+		       COUNT(DISTINCT LOWER(al.genre))::float / GREATEST((SELECT COUNT(*) FROM artist_genres), 1) AS match
+		FROM albums al
+		JOIN artists ar ON al.artist_id = ar.id
+		WHERE LOWER(al.genre) IN (SELECT genre FROM artist_genres)
+		  AND LOWER(ar.name) <> LOWER($1)
+		GROUP BY ar.name
+		ORDER BY match DESC, ar.name
+		LIMIT $2
+	`, artist.Name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var artists []SimilarArtist
+	for rows.Next() {
+		var similar SimilarArtist
+		if err := rows.Scan(&similar.Name, &similar.Match); err != nil {
+			return nil, err
+		}
+		artists = append(artists, similar)
+	}
+	if len(artists) == 0 {
+		return nil, ErrNotFound
+	}
+	return artists, rows.Err()
+}
+
+func (a *LibraryAgent) ArtistInfo(artist Artist) (*ArtistInfo, error) {
+	return nil, ErrNotFound
+}
+
+func (a *LibraryAgent) SimilarTracks(artist Artist, title string, limit int) ([]Track, error) {
+	return nil, ErrNotFound
+}
+
+func (a *LibraryAgent) AlbumInfo(album Album) (*AlbumInfo, error) {
+	return nil, ErrNotFound
+}
@@ -0,0 +1,238 @@
+// Package playlistfile reads and writes M3U/M3U8 and PLS playlist files
+package playlistfile
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry is a track of a playlist file. Path is the location exactly as written in the
+// file; Title and Duration (seconds, -1 when unknown) come from #EXTINF or PLS keys.
+type Entry struct {
+	Path     string
+	Title    string
+	Duration int
+}
+
+// IsPlaylist reports whether the file extension is a supported playlist format
+func IsPlaylist(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".m3u", ".m3u8", ".pls":
+		return true
+	}
+	return false
+}
+
+// Parse reads a playlist file, choosing the format from the file name
+func Parse(name string, r io.Reader) ([]Entry, error) {
+	if strings.EqualFold(filepath.Ext(name), ".pls") {
+		return ParsePLS(r)
+	}
+	return ParseM3U(r)
+}
+
+// ParseM3U reads a plain or extended M3U playlist. Comments other than #EXTINF are ignored.
+func ParseM3U(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	pending := Entry{Duration: -1}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			pending.Duration, pending.Title = parseExtInf(line[len("#EXTINF:"):])
+		case strings.HasPrefix(line, "#"):
+			continue
+		default:
+			pending.Path = line
+			entries = append(entries, pending)
+			pending = Entry{Duration: -1}
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parseExtInf reads "123,Artist - Title", ignoring attributes such as tvg-id="x"
+func parseExtInf(value string) (int, string) {
+	info, title, _ := strings.Cut(value, ",")
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return -1, strings.TrimSpace(title)
+	}
+	duration, err := strconv.Atoi(fields[0])
+	if err != nil {
+		duration = -1
+	}
+	return duration, strings.TrimSpace(title)
+}
+
+// ParsePLS reads a PLS playlist. Entries are returned in FileN order.
+func ParsePLS(r io.Reader) ([]Entry, error) {
+	byIndex := make(map[int]*Entry)
+
+	scanner := bufio.NewScanner(r)
+	first := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		if first {
+			line = strings.TrimPrefix(line, "\ufeff")
+			first = false
+		}
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+
+		var field string
+		for _, name := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, name) {
+				field = name
+				break
+			}
+		}
+		if field == "" {
+			continue
+		}
+		index, err := strconv.Atoi(key[len(field):])
+		if err != nil {
+			continue
+		}
+
+		entry, ok := byIndex[index]
+		if !ok {
+			entry = &Entry{Duration: -1}
+			byIndex[index] = entry
+		}
+		value = strings.TrimSpace(value)
+		switch field {
+		case "file":
+			entry.Path = value
+		case "title":
+			entry.Title = value
+		case "length":
+			if duration, err := strconv.Atoi(value); err == nil {
+				entry.Duration = duration
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for index := range byIndex {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var entries []Entry
+	for _, index := range indexes {
+		if entry := byIndex[index]; entry.Path != "" {
+			entries = append(entries, *entry)
+		}
+	}
+	return entries, nil
+}
+
+// WriteM3U8 writes an extended M3U playlist in UTF-8
+func WriteM3U8(w io.Writer, entries []Entry) error {
+	var b bytes.Buffer
+	b.WriteString("#EXTM3U\n")
+	for _, entry := range entries {
+		fmt.Fprintf(&b, "#EXTINF:%d,%s\n", entry.Duration, oneLine(entry.Title))
+		b.WriteString(oneLine(entry.Path))
+		b.WriteString("\n")
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// WritePLS writes a PLS version 2 playlist
+func WritePLS(w io.Writer, entries []Entry) error {
+	var b bytes.Buffer
+	b.WriteString("[playlist]\n")
+	for i, entry := range entries {
+		n := i + 1
+		fmt.Fprintf(&b, "File%d=%s\n", n, oneLine(entry.Path))
+		if entry.Title != "" {
+			fmt.Fprintf(&b, "Title%d=%s\n", n, oneLine(entry.Title))
+		}
+		fmt.Fprintf(&b, "Length%d=%d\n", n, entry.Duration)
+	}
+	fmt.Fprintf(&b, "NumberOfEntries=%d\n", len(entries))
+	b.WriteString("Version=2\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// Candidates returns the file system paths an entry may refer to, most likely first:
+// the path itself when absolute, then relative to the playlist directory and to the
+// music root. file:// URLs and Windows separators are accepted.
+func Candidates(entryPath, playlistDir, musicRoot string) []string {
+	path := entryPath
+	if strings.HasPrefix(strings.ToLower(path), "file://") {
+		if parsed, err := url.Parse(path); err == nil {
+			path = parsed.Path
+		}
+	}
+	path = strings.ReplaceAll(path, `\`, "/")
+
+	// Windows drive letters ("C:/Music/...") are absolute but cannot exist here
+	if len(path) > 2 && path[1] == ':' && path[2] == '/' {
+		path = path[2:]
+	}
+
+	var candidates []string
+	if filepath.IsAbs(path) {
+		candidates = append(candidates, filepath.Clean(path))
+	} else {
+		candidates = append(candidates, filepath.Join(playlistDir, path))
+	}
+	candidates = append(candidates, filepath.Join(musicRoot, strings.TrimPrefix(path, "/")))
+	return candidates
+}
+
+func oneLine(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// Formats lists the export formats accepted by Write
+var Formats = []string{"m3u8", "pls"}
+
+// Write writes entries in the given format, "m3u8" or "pls"
+func Write(format string, w io.Writer, entries []Entry) error {
+	switch strings.ToLower(format) {
+	case "m3u8", "m3u":
+		return WriteM3U8(w, entries)
+	case "pls":
+		return WritePLS(w, entries)
+	}
+	return fmt.Errorf("unsupported playlist format %q", format)
+}
+
+// ContentType returns the MIME type of a playlist format
+func ContentType(format string) string {
+	if strings.EqualFold(format, "pls") {
+		return "audio/x-scpls"
+	}
+	return "audio/x-mpegurl; charset=utf-8"
+}
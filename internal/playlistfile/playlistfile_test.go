@@ -0,0 +1,123 @@
+package playlistfile
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseM3U(t *testing.T) {
+	input := "\ufeff#EXTM3U\r\n" +
+		"#EXTINF:354,Queen - Bohemian Rhapsody\r\n" +
+		"Queen/A Night at the Opera/11 Bohemian Rhapsody.flac\r\n" +
+		"\r\n" +
+		"# a comment\r\n" +
+		"/music/Björk/Homogenic/01 Hunter.mp3\r\n" +
+		"#EXTINF:-1 tvg-id=\"x\",Radio\n" +
+		"../Other/track.ogg\n"
+
+	entries, err := ParseM3U(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseM3U() error = %v", err)
+	}
+
+	want := []Entry{
+		{Path: "Queen/A Night at the Opera/11 Bohemian Rhapsody.flac", Title: "Queen - Bohemian Rhapsody", Duration: 354},
+		{Path: "/music/Björk/Homogenic/01 Hunter.mp3", Duration: -1},
+		{Path: "../Other/track.ogg", Title: "Radio", Duration: -1},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParseM3U() = %+v, want %+v", entries, want)
+	}
+}
+
+func TestParsePLS(t *testing.T) {
+	input := "[playlist]\n" +
+		"File2=b.mp3\n" +
+		"Title2=Second\n" +
+		"File1=a.mp3\n" +
+		"Length1=120\n" +
+		"Title3=Missing file\n" +
+		"NumberOfEntries=2\n" +
+		"Version=2\n"
+
+	entries, err := ParsePLS(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParsePLS() error = %v", err)
+	}
+
+	want := []Entry{
+		{Path: "a.mp3", Duration: 120},
+		{Path: "b.mp3", Title: "Second", Duration: -1},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("ParsePLS() = %+v, want %+v", entries, want)
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	entries := []Entry{
+		{Path: "Queen/A Night at the Opera/11 Bohemian Rhapsody.flac", Title: "Queen - Bohemian Rhapsody", Duration: 354},
+		{Path: "Björk/Homogenic/01 Hunter.mp3", Title: "Björk - Hunter", Duration: 255},
+	}
+
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := Write(format, &buf, entries); err != nil {
+			t.Fatalf("Write(%s) error = %v", format, err)
+		}
+
+		parsed, err := Parse("playlist."+format, &buf)
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v", format, err)
+		}
+		if !reflect.DeepEqual(parsed, entries) {
+			t.Errorf("%s round trip = %+v, want %+v", format, parsed, entries)
+		}
+	}
+
+	if err := Write("xspf", &bytes.Buffer{}, entries); err == nil {
+		t.Error("Write(xspf) succeeded, want error")
+	}
+}
+
+func TestWriteM3U8(t *testing.T) {
+	var buf bytes.Buffer
+	WriteM3U8(&buf, []Entry{{Path: "a.mp3", Title: "Artist - Title", Duration: 61}})
+
+	want := "#EXTM3U\n#EXTINF:61,Artist - Title\na.mp3\n"
+	if buf.String() != want {
+		t.Errorf("WriteM3U8() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCandidates(t *testing.T) {
+	tests := []struct {
+		entry string
+		want  []string
+	}{
+		{"Album/track.mp3", []string{"/music/lists/Album/track.mp3", "/music/Album/track.mp3"}},
+		{"../Artist/track.mp3", []string{"/music/Artist/track.mp3", "/Artist/track.mp3"}},
+		{"/music/Artist/track.mp3", []string{"/music/Artist/track.mp3", "/music/music/Artist/track.mp3"}},
+		{`Artist\Album\track.mp3`, []string{"/music/lists/Artist/Album/track.mp3", "/music/Artist/Album/track.mp3"}},
+		{`C:\Music\Artist\track.mp3`, []string{"/Music/Artist/track.mp3", "/music/Music/Artist/track.mp3"}},
+		{"file:///music/Sigur%20R%C3%B3s/track.mp3", []string{"/music/Sigur Rós/track.mp3", "/music/music/Sigur Rós/track.mp3"}},
+	}
+
+	for _, tt := range tests {
+		if got := Candidates(tt.entry, "/music/lists", "/music"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Candidates(%q) = %q, want %q", tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestIsPlaylist(t *testing.T) {
+	for path, want := range map[string]bool{
+		"a.m3u": true, "b.M3U8": true, "c.pls": true, "d.mp3": false, "e.m3u.bak": false,
+	} {
+		if got := IsPlaylist(path); got != want {
+			t.Errorf("IsPlaylist(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package podcast
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+const itunesNamespace = "http://www.itunes.com/dtds/podcast-1.0.dtd"
+
+// Feed is a parsed podcast RSS feed
+type Feed struct {
+	Title       string
+	Description string
+	Link        string
+	ImageURL    string
+	Episodes    []Episode
+}
+
+// Episode is a single item of a podcast feed that has an audio enclosure
+type Episode struct {
+	GUID            string
+	Title           string
+	Description     string
+	PublishDate     time.Time
+	Duration        int // seconds
+	EnclosureURL    string
+	EnclosureType   string
+	EnclosureLength int64
+}
+
+type rssDocument struct {
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string     `xml:"title"`
+	Link        string     `xml:"link"`
+	Description string     `xml:"description"`
+	Summary     string     `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	Images      []rssImage `xml:"image"`
+	Items       []rssItem  `xml:"item"`
+}
+
+// rssImage matches both <image><url>...</url></image> and <itunes:image href="..."/>
+type rssImage struct {
+	XMLName xml.Name
+	URL     string `xml:"url"`
+	Href    string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	GUID        string       `xml:"guid"`
+	Title       string       `xml:"title"`
+	Description string       `xml:"description"`
+	Summary     string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd summary"`
+	PubDate     string       `xml:"pubDate"`
+	Duration    string       `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	Enclosure   rssEnclosure `xml:"enclosure"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// ParseFeed parses a podcast RSS 2.0 document. Items without an audio enclosure are skipped.
+func ParseFeed(r io.Reader) (*Feed, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		encoding, err := htmlindex.Get(label)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported feed charset %q", label)
+		}
+		return encoding.NewDecoder().Reader(input), nil
+	}
+
+	var doc rssDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid RSS feed: %w", err)
+	}
+
+	channel := doc.Channel
+	if channel.Title == "" && len(channel.Items) == 0 {
+		return nil, fmt.Errorf("invalid RSS feed: no channel found")
+	}
+
+	feed := &Feed{
+		Title:       strings.TrimSpace(channel.Title),
+		Description: strings.TrimSpace(firstNonEmpty(channel.Description, channel.Summary)),
+		Link:        strings.TrimSpace(channel.Link),
+		ImageURL:    channelImage(channel.Images),
+	}
+
+	for _, item := range channel.Items {
+		if item.Enclosure.URL == "" {
+			continue
+		}
+
+		episode := Episode{
+			GUID:          strings.TrimSpace(firstNonEmpty(item.GUID, item.Enclosure.URL)),
+			Title:         strings.TrimSpace(item.Title),
+			Description:   strings.TrimSpace(firstNonEmpty(item.Description, item.Summary)),
+			PublishDate:   parseDate(item.PubDate),
+			Duration:      parseDuration(item.Duration),
+			EnclosureURL:  strings.TrimSpace(item.Enclosure.URL),
+			EnclosureType: item.Enclosure.Type,
+		}
+		episode.EnclosureLength, _ = strconv.ParseInt(strings.TrimSpace(item.Enclosure.Length), 10, 64)
+
+		feed.Episodes = append(feed.Episodes, episode)
+	}
+
+	return feed, nil
+}
+
+// channelImage prefers the iTunes artwork, which is usually larger than the RSS image
+func channelImage(images []rssImage) string {
+	var rssURL string
+	for _, image := range images {
+		if image.XMLName.Space == itunesNamespace && image.Href != "" {
+			return strings.TrimSpace(image.Href)
+		}
+		if image.URL != "" && rssURL == "" {
+			rssURL = strings.TrimSpace(image.URL)
+		}
+	}
+	return rssURL
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	time.RFC3339,
+}
+
+// parseDate parses the RFC 822 style dates used in feeds, returning the zero time when unknown
+func parseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// parseDuration parses itunes:duration values such as "3600", "59:30" or "1:02:03" into seconds
+func parseDuration(value string) int {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			// Some feeds use fractional seconds ("3600.5")
+			f, ferr := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if ferr != nil {
+				return 0
+			}
+			n = int(f)
+		}
+		seconds = seconds*60 + n
+	}
+	return seconds
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,113 @@
+package podcast
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd">
+  <channel>
+    <title>Castafiore Radio</title>
+    <link>https://example.com</link>
+    <description>Weekly mixes</description>
+    <image><url>https://example.com/small.jpg</url></image>
+    <itunes:image href="https://example.com/large.jpg"/>
+    <item>
+      <guid>ep-2</guid>
+      <title>Episode 2</title>
+      <description>Second mix</description>
+      <pubDate>Tue, 10 Jan 2023 08:00:00 +0000</pubDate>
+      <itunes:duration>1:02:03</itunes:duration>
+      <enclosure url="https://example.com/ep2.mp3" type="audio/mpeg" length="12345"/>
+    </item>
+    <item>
+      <title>Episode 1</title>
+      <itunes:summary>First mix</itunes:summary>
+      <pubDate>Mon, 2 Jan 2023 08:00:00 GMT</pubDate>
+      <itunes:duration>59:30</itunes:duration>
+      <enclosure url="https://example.com/ep1.m4a" type="audio/mp4" length="999"/>
+    </item>
+    <item>
+      <title>Announcement without audio</title>
+    </item>
+  </channel>
+</rss>`
+
+func TestParseFeed(t *testing.T) {
+	feed, err := ParseFeed(strings.NewReader(sampleFeed))
+	if err != nil {
+		t.Fatalf("ParseFeed() error = %v", err)
+	}
+
+	if feed.Title != "Castafiore Radio" {
+		t.Errorf("Title = %q, want %q", feed.Title, "Castafiore Radio")
+	}
+	if feed.ImageURL != "https://example.com/large.jpg" {
+		t.Errorf("ImageURL = %q, want the iTunes image", feed.ImageURL)
+	}
+	if len(feed.Episodes) != 2 {
+		t.Fatalf("got %d episodes, want 2 (items without enclosure are skipped)", len(feed.Episodes))
+	}
+
+	ep := feed.Episodes[0]
+	if ep.GUID != "ep-2" || ep.Duration != 3723 || ep.EnclosureLength != 12345 {
+		t.Errorf("unexpected first episode: %+v", ep)
+	}
+	if want := time.Date(2023, 1, 10, 8, 0, 0, 0, time.UTC); !ep.PublishDate.Equal(want) {
+		t.Errorf("PublishDate = %v, want %v", ep.PublishDate, want)
+	}
+
+	ep = feed.Episodes[1]
+	if ep.GUID != "https://example.com/ep1.m4a" {
+		t.Errorf("GUID = %q, want enclosure URL as fallback", ep.GUID)
+	}
+	if ep.Description != "First mix" {
+		t.Errorf("Description = %q, want itunes:summary as fallback", ep.Description)
+	}
+	if ep.Duration != 3570 {
+		t.Errorf("Duration = %d, want 3570", ep.Duration)
+	}
+}
+
+func TestParseFeedCharset(t *testing.T) {
+	// "Canción" encoded in ISO-8859-1
+	feed := "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><rss><channel><title>Canci\xf3n</title></channel></rss>"
+
+	parsed, err := ParseFeed(strings.NewReader(feed))
+	if err != nil {
+		t.Fatalf("ParseFeed() error = %v", err)
+	}
+	if parsed.Title != "Canción" {
+		t.Errorf("Title = %q, want %q", parsed.Title, "Canción")
+	}
+}
+
+func TestParseFeedInvalid(t *testing.T) {
+	if _, err := ParseFeed(strings.NewReader("<html><body>Not a feed</body></html>")); err == nil {
+		t.Error("ParseFeed() expected error for non-RSS document")
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"", 0},
+		{"3600", 3600},
+		{"59:30", 3570},
+		{"1:02:03", 3723},
+		{"1800.5", 1800},
+		{"unknown", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := parseDuration(tt.input); got != tt.want {
+				t.Errorf("parseDuration(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,483 @@
+package podcast
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Episode and channel statuses, as defined by the Subsonic API
+const (
+	StatusNew         = "new"
+	StatusDownloading = "downloading"
+	StatusCompleted   = "completed"
+	StatusError       = "error"
+	StatusDeleted     = "deleted"
+	StatusSkipped     = "skipped"
+)
+
+// Service manages podcast subscriptions and downloads episodes in the background
+type Service struct {
+	db          *sql.DB
+	client      *http.Client
+	podcastPath string
+	downloads   chan int
+}
+
+func NewService(db *sql.DB, podcastPath string) *Service {
+	return &Service{
+		db: db,
+		client: &http.Client{
+			// Episodes can be large, the timeout only guards against stalled servers
+			Timeout: 30 * time.Minute,
+		},
+		podcastPath: podcastPath,
+		downloads:   make(chan int, 100),
+	}
+}
+
+// Start launches the download workers
+func (s *Service) Start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go s.downloadWorker()
+	}
+	log.Printf("Podcast: Started %d download workers (path: %s)", workers, s.podcastPath)
+
+	if err := s.resumeDownloads(); err != nil {
+		log.Printf("Podcast: Error resuming interrupted downloads: %v", err)
+	}
+}
+
+// resumeDownloads queues again the episodes left downloading when the server stopped.
+// Nothing else would pick them up, and QueueDownload skips episodes already downloading.
+func (s *Service) resumeDownloads() error {
+	rows, err := s.db.Query("SELECT id FROM podcast_episodes WHERE status = $1 ORDER BY id", StatusDownloading)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var episodes []int
+	for rows.Next() {
+		var episodeID int
+		if err := rows.Scan(&episodeID); err != nil {
+			return err
+		}
+		episodes = append(episodes, episodeID)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, episodeID := range episodes {
+		s.enqueue(episodeID)
+	}
+	if len(episodes) > 0 {
+		log.Printf("Podcast: Resumed %d interrupted downloads", len(episodes))
+	}
+	return nil
+}
+
+// FetchFeed downloads and parses the RSS feed at the given URL
+func (s *Service) FetchFeed(feedURL string) (*Feed, error) {
+	resp, err := s.client.Get(feedURL)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	return ParseFeed(resp.Body)
+}
+
+// CreateChannel subscribes to a new podcast and fetches its episodes
+func (s *Service) CreateChannel(feedURL string) (int, error) {
+	parsed, err := url.Parse(feedURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return 0, fmt.Errorf("invalid podcast URL: %s", feedURL)
+	}
+
+	var channelID int
+	err = s.db.QueryRow(`
+		INSERT INTO podcast_channels (url, status, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (url) DO UPDATE SET url = EXCLUDED.url
+		RETURNING id
+	`, feedURL, StatusNew).Scan(&channelID)
+	if err != nil {
+		return 0, fmt.Errorf("error creating channel: %w", err)
+	}
+
+	// The channel is kept with an error status if the first refresh fails
+	if err := s.RefreshChannel(channelID); err != nil {
+		log.Printf("Podcast: Error refreshing new channel %d: %v", channelID, err)
+	}
+
+	return channelID, nil
+}
+
+// RefreshChannel fetches the feed of a channel and stores new or updated episodes
+func (s *Service) RefreshChannel(channelID int) error {
+	var feedURL string
+	var currentImage sql.NullString
+	err := s.db.QueryRow(`
+		SELECT url, image_url FROM podcast_channels WHERE id = $1
+	`, channelID).Scan(&feedURL, &currentImage)
+	if err != nil {
+		return err
+	}
+
+	feed, err := s.FetchFeed(feedURL)
+	if err != nil {
+		s.setChannelError(channelID, err)
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE podcast_channels
+		SET title = $1, description = $2, image_url = $3, status = $4,
+		    error_message = NULL, last_refreshed = NOW()
+		WHERE id = $5
+	`, feed.Title, feed.Description, feed.ImageURL, StatusCompleted, channelID)
+	if err != nil {
+		return err
+	}
+
+	for _, episode := range feed.Episodes {
+		var publishDate interface{}
+		if !episode.PublishDate.IsZero() {
+			publishDate = episode.PublishDate
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO podcast_episodes (channel_id, guid, title, description, publish_date,
+			                              duration, enclosure_url, enclosure_type, enclosure_length, status)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			ON CONFLICT (channel_id, guid) DO UPDATE SET
+				title = EXCLUDED.title,
+				description = EXCLUDED.description,
+				publish_date = EXCLUDED.publish_date,
+				duration = EXCLUDED.duration,
+				enclosure_url = EXCLUDED.enclosure_url,
+				enclosure_type = EXCLUDED.enclosure_type,
+				enclosure_length = EXCLUDED.enclosure_length
+		`, channelID, episode.GUID, episode.Title, episode.Description, publishDate,
+			episode.Duration, episode.EnclosureURL, episode.EnclosureType, episode.EnclosureLength, StatusNew)
+		if err != nil {
+			return fmt.Errorf("error saving episode %s: %w", episode.GUID, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if feed.ImageURL != "" && (feed.ImageURL != currentImage.String || !s.hasCover(channelID)) {
+		s.saveChannelCover(channelID, feed.ImageURL)
+	}
+
+	log.Printf("Podcast: Refreshed channel %d (%s), %d episodes in feed", channelID, feed.Title, len(feed.Episodes))
+	return nil
+}
+
+// RefreshAll refreshes every subscribed channel
+func (s *Service) RefreshAll() {
+	rows, err := s.db.Query("SELECT id FROM podcast_channels ORDER BY id")
+	if err != nil {
+		log.Printf("Podcast: Error listing channels: %v", err)
+		return
+	}
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if err := s.RefreshChannel(id); err != nil {
+			log.Printf("Podcast: Error refreshing channel %d: %v", id, err)
+		}
+	}
+}
+
+// DeleteChannel unsubscribes from a podcast and removes its downloaded files
+func (s *Service) DeleteChannel(channelID int) error {
+	result, err := s.db.Exec("DELETE FROM podcast_channels WHERE id = $1", channelID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return os.RemoveAll(s.channelDir(channelID))
+}
+
+// QueueDownload marks an episode as downloading and hands it to the workers
+func (s *Service) QueueDownload(episodeID int) error {
+	result, err := s.db.Exec(`
+		UPDATE podcast_episodes SET status = $1, error_message = NULL
+		WHERE id = $2 AND status <> $1
+	`, StatusDownloading, episodeID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		var exists bool
+		if err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM podcast_episodes WHERE id = $1)", episodeID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return sql.ErrNoRows
+		}
+		// Already downloading
+		return nil
+	}
+
+	s.enqueue(episodeID)
+	return nil
+}
+
+// enqueue hands an episode to the workers without blocking
+func (s *Service) enqueue(episodeID int) {
+	select {
+	case s.downloads <- episodeID:
+	default:
+		// Queue full, run the download in its own goroutine rather than blocking the request
+		go s.downloadEpisode(episodeID)
+	}
+}
+
+// DeleteEpisode removes the downloaded file of an episode and marks it as deleted
+func (s *Service) DeleteEpisode(episodeID int) error {
+	var filePath sql.NullString
+	err := s.db.QueryRow("SELECT file_path FROM podcast_episodes WHERE id = $1", episodeID).Scan(&filePath)
+	if err != nil {
+		return err
+	}
+
+	if filePath.String != "" {
+		if err := os.Remove(filePath.String); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE podcast_episodes SET status = $1, file_path = NULL, file_size = NULL
+		WHERE id = $2
+	`, StatusDeleted, episodeID)
+	return err
+}
+
+// EpisodeFile returns the path, format and size of a downloaded episode
+func (s *Service) EpisodeFile(episodeID int) (string, string, int64, error) {
+	var filePath, format sql.NullString
+	var fileSize sql.NullInt64
+	err := s.db.QueryRow(`
+		SELECT file_path, format, file_size FROM podcast_episodes
+		WHERE id = $1 AND status = $2
+	`, episodeID, StatusCompleted).Scan(&filePath, &format, &fileSize)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if _, err := os.Stat(filePath.String); err != nil {
+		return "", "", 0, err
+	}
+	return filePath.String, format.String, fileSize.Int64, nil
+}
+
+// ChannelCover returns the path of the downloaded artwork of a channel
+func (s *Service) ChannelCover(channelID int) (string, error) {
+	var coverPath sql.NullString
+	err := s.db.QueryRow("SELECT cover_art_path FROM podcast_channels WHERE id = $1", channelID).Scan(&coverPath)
+	if err != nil {
+		return "", err
+	}
+	if coverPath.String == "" {
+		return "", os.ErrNotExist
+	}
+	if _, err := os.Stat(coverPath.String); err != nil {
+		return "", err
+	}
+	return coverPath.String, nil
+}
+
+func (s *Service) downloadWorker() {
+	for episodeID := range s.downloads {
+		s.downloadEpisode(episodeID)
+	}
+}
+
+func (s *Service) downloadEpisode(episodeID int) {
+	var channelID int
+	var enclosureURL string
+	var enclosureType sql.NullString
+	err := s.db.QueryRow(`
+		SELECT channel_id, enclosure_url, enclosure_type FROM podcast_episodes WHERE id = $1
+	`, episodeID).Scan(&channelID, &enclosureURL, &enclosureType)
+	if err != nil {
+		log.Printf("Podcast: Error loading episode %d: %v", episodeID, err)
+		return
+	}
+
+	ext := fileExtension(enclosureURL, enclosureType.String)
+	dest := filepath.Join(s.channelDir(channelID), strconv.Itoa(episodeID)+ext)
+
+	log.Printf("Podcast: Downloading episode %d from %s", episodeID, enclosureURL)
+	size, err := downloadFile(s.client, enclosureURL, dest)
+	if err != nil {
+		log.Printf("Podcast: Error downloading episode %d: %v", episodeID, err)
+		_, dbErr := s.db.Exec(`
+			UPDATE podcast_episodes SET status = $1, error_message = $2 WHERE id = $3 AND status = $4
+		`, StatusError, err.Error(), episodeID, StatusDownloading)
+		if dbErr != nil {
+			log.Printf("Podcast: Error updating episode %d: %v", episodeID, dbErr)
+		}
+		return
+	}
+
+	// The episode, or its channel, may have been deleted during the download: it is only
+	// completed if it is still downloading, otherwise the file must not stay on disk
+	result, err := s.db.Exec(`
+		UPDATE podcast_episodes
+		SET status = $1, file_path = $2, file_size = $3, format = $4, error_message = NULL
+		WHERE id = $5 AND status = $6
+	`, StatusCompleted, dest, size, strings.TrimPrefix(ext, "."), episodeID, StatusDownloading)
+	if err != nil {
+		log.Printf("Podcast: Error updating episode %d: %v", episodeID, err)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		log.Printf("Podcast: Episode %d was deleted while downloading, removing the file", episodeID)
+		if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+			log.Printf("Podcast: Error removing episode %d: %v", episodeID, err)
+		}
+		// Only succeeds when the channel is gone and its folder is empty again
+		os.Remove(filepath.Dir(dest))
+		return
+	}
+
+	log.Printf("Podcast: Episode %d downloaded (%d bytes)", episodeID, size)
+}
+
+func (s *Service) saveChannelCover(channelID int, imageURL string) {
+	dest := filepath.Join(s.channelDir(channelID), "cover"+fileExtension(imageURL, "image/jpeg"))
+	if _, err := downloadFile(s.client, imageURL, dest); err != nil {
+		log.Printf("Podcast: Error downloading cover for channel %d: %v", channelID, err)
+		return
+	}
+
+	if _, err := s.db.Exec("UPDATE podcast_channels SET cover_art_path = $1 WHERE id = $2", dest, channelID); err != nil {
+		log.Printf("Podcast: Error saving cover for channel %d: %v", channelID, err)
+	}
+}
+
+func (s *Service) hasCover(channelID int) bool {
+	_, err := s.ChannelCover(channelID)
+	return err == nil
+}
+
+func (s *Service) setChannelError(channelID int, cause error) {
+	_, err := s.db.Exec(`
+		UPDATE podcast_channels SET status = $1, error_message = $2, last_refreshed = NOW()
+		WHERE id = $3
+	`, StatusError, cause.Error(), channelID)
+	if err != nil {
+		log.Printf("Podcast: Error updating channel %d: %v", channelID, err)
+	}
+}
+
+func (s *Service) channelDir(channelID int) string {
+	return filepath.Join(s.podcastPath, strconv.Itoa(channelID))
+}
+
+// downloadFile streams a remote file to dest through a temporary file, returning its size
+func downloadFile(client *http.Client, fileURL, dest string) (int64, error) {
+	resp, err := client.Get(fileURL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("server returned status %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, err
+	}
+
+	tmp := dest + ".part"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// fileExtension picks a file extension from the URL path, falling back to the MIME type
+func fileExtension(fileURL, mimeType string) string {
+	if parsed, err := url.Parse(fileURL); err == nil {
+		if ext := strings.ToLower(path.Ext(parsed.Path)); ext != "" && len(ext) <= 5 {
+			return ext
+		}
+	}
+
+	switch strings.ToLower(mimeType) {
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/mp4", "audio/x-m4a", "audio/aac":
+		return ".m4a"
+	case "audio/ogg":
+		return ".ogg"
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".mp3"
+}
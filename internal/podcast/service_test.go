@@ -0,0 +1,98 @@
+package podcast
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(sampleFeed))
+	})
+	mux.HandleFunc("/ep1.mp3", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("ID3 fake audio payload"))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestFetchFeed(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	s := NewService(nil, t.TempDir())
+
+	feed, err := s.FetchFeed(server.URL + "/feed.xml")
+	if err != nil {
+		t.Fatalf("FetchFeed() error = %v", err)
+	}
+	if len(feed.Episodes) != 2 {
+		t.Errorf("got %d episodes, want 2", len(feed.Episodes))
+	}
+
+	if _, err := s.FetchFeed(server.URL + "/missing.xml"); err == nil {
+		t.Error("FetchFeed() expected error for 404 response")
+	}
+}
+
+func TestDownloadFile(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "1", "10.mp3")
+
+	size, err := downloadFile(server.Client(), server.URL+"/ep1.mp3", dest)
+	if err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("downloaded file missing: %v", err)
+	}
+	if string(data) != "ID3 fake audio payload" || size != int64(len(data)) {
+		t.Errorf("unexpected download: size=%d content=%q", size, data)
+	}
+	if _, err := os.Stat(dest + ".part"); !os.IsNotExist(err) {
+		t.Error("temporary .part file was not cleaned up")
+	}
+}
+
+func TestDownloadFileError(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	dest := filepath.Join(t.TempDir(), "missing.mp3")
+	if _, err := downloadFile(server.Client(), server.URL+"/missing.mp3", dest); err == nil {
+		t.Fatal("downloadFile() expected error for 404 response")
+	}
+	if _, err := os.Stat(dest); !os.IsNotExist(err) {
+		t.Error("no file should be created for a failed download")
+	}
+}
+
+func TestFileExtension(t *testing.T) {
+	tests := []struct {
+		url      string
+		mimeType string
+		want     string
+	}{
+		{"https://example.com/show/ep1.m4a?token=abc", "audio/mpeg", ".m4a"},
+		{"https://example.com/download/12345", "audio/mpeg", ".mp3"},
+		{"https://example.com/download/12345", "audio/ogg", ".ogg"},
+		{"https://example.com/download/12345", "", ".mp3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			if got := fileExtension(tt.url, tt.mimeType); got != tt.want {
+				t.Errorf("fileExtension(%q, %q) = %q, want %q", tt.url, tt.mimeType, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+package recommend
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// SessionGap is the pause after which plays belong to a new listening session
+	SessionGap = 30 * time.Minute
+	// sessionWindow is how many songs apart two plays of a session may be to count as related
+	sessionWindow = 10
+	// maxNeighbours is how many similar songs and users are stored per item
+	maxNeighbours = 50
+	// historyWindow is how far back Refresh reads plays: recent listening describes
+	// current taste, and the model stays bounded as the history grows
+	historyWindow = 365 * 24 * time.Hour
+	// maxSongListeners bounds the users compared through a single song
+	maxSongListeners = 200
+)
+
+// Engine builds recommendations from play_history alone, so they work without any
+// outside service. Plays through public shares are left out, they are not the owner's. Similar songs come from songs played in the same sessions, similar
+// users from overlapping listening; both are precomputed by Refresh.
+type Engine struct {
+	db *sql.DB
+}
+
+func NewEngine(db *sql.DB) *Engine {
+	return &Engine{db: db}
+}
+
+// Start refreshes the model now and then every interval
+func (e *Engine) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := e.Refresh(); err != nil {
+				log.Printf("[Recommend] Error refreshing recommendations: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+	log.Printf("[Recommend] Recommendation refresh started (interval: %s)", interval)
+}
+
+// Refresh rebuilds song_similarity and user_similarity from the plays of the last
+// historyWindow
+func (e *Engine) Refresh() error {
+	start := time.Now()
+	since := start.Add(-historyWindow)
+
+	plays, err := e.loadPlays(since)
+	if err != nil {
+		return err
+	}
+	songs := Top(CoOccurrence(Sessions(plays, SessionGap), sessionWindow), maxNeighbours)
+
+	counts, err := e.loadPlayCounts(since)
+	if err != nil {
+		return err
+	}
+	users := Top(UserSimilarity(counts, maxSongListeners), maxNeighbours)
+
+	tx, err := e.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceNeighbours(tx, "song_similarity", "song_id", "similar_song_id", songs); err != nil {
+		return err
+	}
+	if err := replaceNeighbours(tx, "user_similarity", "user_id", "similar_user_id", users); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Printf("[Recommend] Built recommendations from %d plays: %d songs, %d users (%s)",
+		len(plays), len(songs), len(users), time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+// loadPlays returns the plays since a time, sorted by user and time as Sessions needs
+func (e *Engine) loadPlays(since time.Time) ([]Play, error) {
+	rows, err := e.db.Query(`
+		SELECT user_id, song_id, played_at
+		FROM play_history
+		WHERE user_id IS NOT NULL AND song_id IS NOT NULL AND played_at >= $1
+		  AND share_id IS NULL
+		ORDER BY user_id, played_at
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var plays []Play
+	for rows.Next() {
+		var play Play
+		if err := rows.Scan(&play.UserID, &play.SongID, &play.PlayedAt); err != nil {
+			return nil, err
+		}
+		plays = append(plays, play)
+	}
+	return plays, rows.Err()
+}
+
+// loadPlayCounts returns how many times each user played each song since a time
+func (e *Engine) loadPlayCounts(since time.Time) ([]PlayCount, error) {
+	rows, err := e.db.Query(`
+		SELECT user_id, song_id, COUNT(*)
+		FROM play_history
+		WHERE user_id IS NOT NULL AND song_id IS NOT NULL AND played_at >= $1
+		  AND share_id IS NULL
+		GROUP BY user_id, song_id
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []PlayCount
+	for rows.Next() {
+		var count PlayCount
+		if err := rows.Scan(&count.UserID, &count.SongID, &count.Plays); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// replaceNeighbours swaps the content of a similarity table inside tx
+func replaceNeighbours(tx *sql.Tx, table, idColumn, neighbourColumn string, neighbours map[int][]Neighbour) error {
+	if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+		return err
+	}
+
+	var ids, neighbourIDs []int64
+	var scores []float64
+	for id, list := range neighbours {
+		for _, n := range list {
+			ids = append(ids, int64(id))
+			neighbourIDs = append(neighbourIDs, int64(n.ID))
+			scores = append(scores, n.Score)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO `+table+` (`+idColumn+`, `+neighbourColumn+`, score)
+		SELECT * FROM unnest($1::int[], $2::int[], $3::float8[])
+	`, pq.Array(ids), pq.Array(neighbourIDs), pq.Array(scores))
+	return err
+}
+
+// SimilarSongs returns songs related to songID, best first. Songs played in the same
+// sessions come first; the rest is filled with what similar listeners of the song play.
+func (e *Engine) SimilarSongs(userID, songID, limit int) ([]int, error) {
+	ids, err := e.queryIDs(`
+		SELECT similar_song_id FROM song_similarity
+		WHERE song_id = $1
+		ORDER BY score DESC
+		LIMIT $2
+	`, songID, limit)
+	if err != nil || len(ids) >= limit {
+		return ids, err
+	}
+
+	more, err := e.queryIDs(`
+		SELECT ph.song_id
+		FROM user_similarity us
+		JOIN play_history ph ON ph.user_id = us.similar_user_id
+		WHERE us.user_id = $1
+		  AND us.similar_user_id IN (SELECT user_id FROM play_history WHERE song_id = $2 AND share_id IS NULL)
+		  AND ph.share_id IS NULL
+		  AND ph.song_id <> $2
+		  AND NOT (ph.song_id = ANY($3::int[]))
+		GROUP BY ph.song_id
+		ORDER BY SUM(us.score) DESC, ph.song_id
+		LIMIT $4
+	`, userID, songID, pq.Array(ids), limit-len(ids))
+	if err != nil {
+		return ids, err
+	}
+	return append(ids, more...), nil
+}
+
+// Discover returns albums the user never played that similar users listen to, best first.
+// Users without similar listeners get the most played albums they have not heard yet.
+func (e *Engine) Discover(userID, limit, offset int) ([]int, error) {
+	var hasNeighbours bool
+	err := e.db.QueryRow("SELECT EXISTS(SELECT 1 FROM user_similarity WHERE user_id = $1)", userID).Scan(&hasNeighbours)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasNeighbours {
+		return e.queryIDs(`
+			SELECT s.album_id
+			FROM user_similarity us
+			JOIN play_history ph ON ph.user_id = us.similar_user_id
+			JOIN songs s ON s.id = ph.song_id
+			WHERE us.user_id = $1
+			  AND ph.share_id IS NULL
+			  AND s.album_id IS NOT NULL
+			  AND NOT EXISTS (
+				SELECT 1 FROM play_history ph2 JOIN songs s2 ON s2.id = ph2.song_id
+				WHERE ph2.user_id = $1 AND ph2.share_id IS NULL AND s2.album_id = s.album_id
+			  )
+			GROUP BY s.album_id
+			ORDER BY SUM(us.score) DESC, s.album_id
+			LIMIT $2 OFFSET $3
+		`, userID, limit, offset)
+	}
+
+	return e.queryIDs(`
+		SELECT s.album_id
+		FROM play_history ph
+		JOIN songs s ON s.id = ph.song_id
+		WHERE ph.share_id IS NULL
+		  AND s.album_id IS NOT NULL
+		  AND NOT EXISTS (
+			SELECT 1 FROM play_history ph2 JOIN songs s2 ON s2.id = ph2.song_id
+			WHERE ph2.user_id = $1 AND ph2.share_id IS NULL AND s2.album_id = s.album_id
+		  )
+		GROUP BY s.album_id
+		ORDER BY COUNT(*) DESC, s.album_id
+		LIMIT $2 OFFSET $3
+	`, userID, limit, offset)
+}
+
+func (e *Engine) queryIDs(query string, args ...interface{}) ([]int, error) {
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
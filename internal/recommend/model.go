@@ -0,0 +1,161 @@
+package recommend
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Play is a single entry of play_history
+type Play struct {
+	UserID   int
+	SongID   int
+	PlayedAt time.Time
+}
+
+// PlayCount is how many times a user played a song
+type PlayCount struct {
+	UserID int
+	SongID int
+	Plays  int
+}
+
+// Neighbour is a similar item and how similar it is, between 0 and 1
+type Neighbour struct {
+	ID    int
+	Score float64
+}
+
+// Sessions splits plays into listening sessions: consecutive plays of a user
+// separated by less than gap. Each session lists its songs once, in play order.
+// Plays must be sorted by user and time.
+func Sessions(plays []Play, gap time.Duration) [][]int {
+	var sessions [][]int
+	var current []int
+	seen := make(map[int]bool)
+
+	for i, play := range plays {
+		if i > 0 {
+			prev := plays[i-1]
+			if play.UserID != prev.UserID || play.PlayedAt.Sub(prev.PlayedAt) > gap {
+				if len(current) > 0 {
+					sessions = append(sessions, current)
+				}
+				current = nil
+				seen = make(map[int]bool)
+			}
+		}
+		if !seen[play.SongID] {
+			seen[play.SongID] = true
+			current = append(current, play.SongID)
+		}
+	}
+	if len(current) > 0 {
+		sessions = append(sessions, current)
+	}
+	return sessions
+}
+
+// CoOccurrence scores song pairs by how often they are played in the same sessions.
+// Only songs at most window positions apart count, so long sessions do not pair
+// everything with everything. The score is the cosine of both songs' session sets:
+// together / sqrt(sessionsA * sessionsB).
+func CoOccurrence(sessions [][]int, window int) map[int][]Neighbour {
+	occurrences := make(map[int]int)
+	together := make(map[[2]int]int)
+
+	for _, session := range sessions {
+		for i, a := range session {
+			occurrences[a]++
+			for j := i + 1; j < len(session) && j-i <= window; j++ {
+				b := session[j]
+				if a < b {
+					together[[2]int{a, b}]++
+				} else {
+					together[[2]int{b, a}]++
+				}
+			}
+		}
+	}
+
+	scores := make(map[int][]Neighbour)
+	for pair, count := range together {
+		a, b := pair[0], pair[1]
+		score := float64(count) / math.Sqrt(float64(occurrences[a]*occurrences[b]))
+		scores[a] = append(scores[a], Neighbour{ID: b, Score: score})
+		scores[b] = append(scores[b], Neighbour{ID: a, Score: score})
+	}
+	return scores
+}
+
+// UserSimilarity compares the listening of every pair of users with the cosine of
+// their play count vectors. Counts are damped with log(1 + plays) so a song on
+// repeat does not dominate a profile. Pairs are found through the songs they share,
+// which is quadratic in the listeners of a song: songs with more than maxListeners
+// listeners are left out of the comparison, like stop words, as everyone plays them.
+func UserSimilarity(counts []PlayCount, maxListeners int) map[int][]Neighbour {
+	profiles := make(map[int]map[int]float64)
+	for _, count := range counts {
+		profile, ok := profiles[count.UserID]
+		if !ok {
+			profile = make(map[int]float64)
+			profiles[count.UserID] = profile
+		}
+		profile[count.SongID] += float64(count.Plays)
+	}
+
+	norms := make(map[int]float64)
+	listeners := make(map[int][]int)
+	for userID, profile := range profiles {
+		var norm float64
+		for songID, count := range profile {
+			weight := math.Log1p(count)
+			profile[songID] = weight
+			norm += weight * weight
+			listeners[songID] = append(listeners[songID], userID)
+		}
+		norms[userID] = math.Sqrt(norm)
+	}
+
+	dot := make(map[[2]int]float64)
+	for songID, users := range listeners {
+		if len(users) > maxListeners {
+			continue
+		}
+		for i, a := range users {
+			for _, b := range users[i+1:] {
+				key := [2]int{a, b}
+				if b < a {
+					key = [2]int{b, a}
+				}
+				dot[key] += profiles[a][songID] * profiles[b][songID]
+			}
+		}
+	}
+
+	scores := make(map[int][]Neighbour)
+	for pair, product := range dot {
+		a, b := pair[0], pair[1]
+		score := product / (norms[a] * norms[b])
+		scores[a] = append(scores[a], Neighbour{ID: b, Score: score})
+		scores[b] = append(scores[b], Neighbour{ID: a, Score: score})
+	}
+	return scores
+}
+
+// Top keeps the k best neighbours of every item, best first
+func Top(scores map[int][]Neighbour, k int) map[int][]Neighbour {
+	for id, neighbours := range scores {
+		sort.Slice(neighbours, func(i, j int) bool {
+			if neighbours[i].Score != neighbours[j].Score {
+				return neighbours[i].Score > neighbours[j].Score
+			}
+			return neighbours[i].ID < neighbours[j].ID
+		})
+		if len(neighbours) > k {
+			neighbours = neighbours[:k]
+		}
+		scores[id] = neighbours
+	}
+	return scores
+}
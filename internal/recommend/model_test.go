@@ -0,0 +1,150 @@
+package recommend
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func plays(userID int, start time.Time, steps ...interface{}) []Play {
+	var result []Play
+	at := start
+	for _, step := range steps {
+		switch v := step.(type) {
+		case time.Duration:
+			at = at.Add(v)
+		case int:
+			result = append(result, Play{UserID: userID, SongID: v, PlayedAt: at})
+			at = at.Add(4 * time.Minute)
+		}
+	}
+	return result
+}
+
+func TestSessions(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+
+	var history []Play
+	history = append(history, plays(1, start, 1, 2, 1, 3, 2*time.Hour, 4, 5)...)
+	history = append(history, plays(2, start, 1, 2)...)
+
+	got := Sessions(history, SessionGap)
+	want := [][]int{{1, 2, 3}, {4, 5}, {1, 2}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Sessions() = %v, want %v", got, want)
+	}
+}
+
+func TestCoOccurrence(t *testing.T) {
+	sessions := [][]int{{1, 2, 3}, {1, 2}, {3, 4}}
+	scores := CoOccurrence(sessions, 10)
+
+	score := func(a, b int) float64 {
+		for _, n := range scores[a] {
+			if n.ID == b {
+				return n.Score
+			}
+		}
+		return 0
+	}
+
+	// 1 and 2 are always played together
+	if got := score(1, 2); got != 1 {
+		t.Errorf("score(1, 2) = %v, want 1", got)
+	}
+	if score(1, 2) != score(2, 1) {
+		t.Errorf("scores are not symmetric: %v != %v", score(1, 2), score(2, 1))
+	}
+	// 1 appears in 2 sessions, 3 in 2, together once
+	if got, want := score(1, 3), 0.5; got != want {
+		t.Errorf("score(1, 3) = %v, want %v", got, want)
+	}
+	if got := score(1, 4); got != 0 {
+		t.Errorf("score(1, 4) = %v, want 0", got)
+	}
+}
+
+func TestCoOccurrenceWindow(t *testing.T) {
+	scores := CoOccurrence([][]int{{1, 2, 3, 4}}, 2)
+
+	for _, n := range scores[1] {
+		if n.ID == 4 {
+			t.Errorf("songs 3 positions apart should not be related with a window of 2")
+		}
+	}
+	if len(scores[1]) != 2 {
+		t.Errorf("song 1 has %d neighbours, want 2", len(scores[1]))
+	}
+}
+
+func TestUserSimilarity(t *testing.T) {
+	start := time.Now()
+	var history []Play
+	history = append(history, plays(1, start, 1, 2, 3)...)
+	history = append(history, plays(2, start, 1, 2, 3)...)
+	history = append(history, plays(3, start, 3, 4)...)
+	history = append(history, plays(4, start, 5)...)
+
+	scores := Top(UserSimilarity(countPlays(history), 10), 10)
+
+	if len(scores[1]) != 2 || scores[1][0].ID != 2 {
+		t.Fatalf("neighbours of user 1 = %v, want user 2 first", scores[1])
+	}
+	if math.Abs(scores[1][0].Score-1) > 1e-9 {
+		t.Errorf("identical listening scored %v, want 1", scores[1][0].Score)
+	}
+	if scores[1][1].ID != 3 || scores[1][1].Score >= scores[1][0].Score {
+		t.Errorf("user 3 should be a weaker neighbour of user 1, got %v", scores[1])
+	}
+	if len(scores[4]) != 0 {
+		t.Errorf("user 4 shares no songs but has neighbours %v", scores[4])
+	}
+}
+
+func TestUserSimilarityMaxListeners(t *testing.T) {
+	start := time.Now()
+	var history []Play
+	for user := 1; user <= 3; user++ {
+		history = append(history, plays(user, start, 1)...)
+	}
+	history = append(history, plays(1, start, 2)...)
+	history = append(history, plays(2, start, 2)...)
+
+	// Song 1 is played by everyone and ignored, song 2 still relates users 1 and 2
+	scores := UserSimilarity(countPlays(history), 2)
+	if len(scores[3]) != 0 {
+		t.Errorf("user 3 only shares an ignored song but has neighbours %v", scores[3])
+	}
+	if len(scores[1]) != 1 || scores[1][0].ID != 2 {
+		t.Errorf("neighbours of user 1 = %v, want user 2", scores[1])
+	}
+}
+
+// countPlays aggregates plays like the GROUP BY of the engine
+func countPlays(history []Play) []PlayCount {
+	var counts []PlayCount
+	index := make(map[[2]int]int)
+	for _, play := range history {
+		key := [2]int{play.UserID, play.SongID}
+		if i, ok := index[key]; ok {
+			counts[i].Plays++
+			continue
+		}
+		index[key] = len(counts)
+		counts = append(counts, PlayCount{UserID: play.UserID, SongID: play.SongID, Plays: 1})
+	}
+	return counts
+}
+
+func TestTop(t *testing.T) {
+	scores := map[int][]Neighbour{
+		1: {{ID: 2, Score: 0.1}, {ID: 3, Score: 0.9}, {ID: 4, Score: 0.5}, {ID: 5, Score: 0.5}},
+	}
+
+	got := Top(scores, 3)[1]
+	want := []Neighbour{{ID: 3, Score: 0.9}, {ID: 4, Score: 0.5}, {ID: 5, Score: 0.5}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Top() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,209 @@
+package scrobblequeue
+
+import (
+	"database/sql"
+	"log"
+	"time"
+)
+
+const (
+	// maxAttempts is how many failed submissions a queued item survives
+	maxAttempts = 20
+	// maxRetryDelay caps the exponential backoff between submissions
+	maxRetryDelay = 6 * time.Hour
+)
+
+// Account is a user with due items and the credential their items are submitted with
+type Account struct {
+	UserID     int
+	Credential string
+}
+
+// Config describes a queue table and how its items are loaded and submitted. The table
+// must have the columns id, attempts, last_error and next_attempt_at.
+type Config[T any] struct {
+	// Name prefixes the log messages, such as "LastFM"
+	Name string
+	// Table is the queue table
+	Table string
+	// BatchSize is how many items are submitted per request
+	BatchSize int
+
+	// Accounts lists the users with due items and a valid credential
+	Accounts func() ([]Account, error)
+	// Load returns the IDs and items of the oldest due items of a user, at most limit
+	Load func(userID, limit int) ([]int64, []T, error)
+	// Submit sends a batch of items with the credential of their user
+	Submit func(credential string, items []T) error
+	// Temporary reports whether a failed submission may succeed later. Items failing
+	// otherwise are dropped, retrying them would only fail again.
+	Temporary func(err error) bool
+	// Unauthorized reports whether the credential was rejected. The items are kept and
+	// Revoke is called, so they are submitted once the user links the account again.
+	Unauthorized func(err error) bool
+	Revoke       func(userID int)
+}
+
+// Queue submits stored items in the background, retrying failures with exponential
+// backoff. Items are only removed once the service accepts them.
+type Queue[T any] struct {
+	db     *sql.DB
+	config Config[T]
+	wake   chan struct{}
+}
+
+func New[T any](db *sql.DB, config Config[T]) *Queue[T] {
+	return &Queue[T]{
+		db:     db,
+		config: config,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// Start runs the worker, flushing every interval and whenever Notify is called
+func (q *Queue[T]) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			q.Flush()
+			select {
+			case <-ticker.C:
+			case <-q.wake:
+			}
+		}
+	}()
+	log.Printf("[%s] Queue worker started (interval: %s)", q.config.Name, interval)
+}
+
+// Notify wakes the worker without blocking
+func (q *Queue[T]) Notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Flush submits every due item, in batches of BatchSize per user
+func (q *Queue[T]) Flush() {
+	accounts, err := q.config.Accounts()
+	if err != nil {
+		log.Printf("[%s] Error reading queue: %v", q.config.Name, err)
+		return
+	}
+
+	for _, account := range accounts {
+		for {
+			submitted, err := q.flushBatch(account)
+			if err != nil || submitted < q.config.BatchSize {
+				break
+			}
+		}
+	}
+}
+
+// flushBatch submits the oldest due items of a user and returns how many were sent
+func (q *Queue[T]) flushBatch(account Account) (int, error) {
+	ids, items, err := q.config.Load(account.UserID, q.config.BatchSize)
+	if err != nil {
+		log.Printf("[%s] Error loading queue for user %d: %v", q.config.Name, account.UserID, err)
+		return 0, err
+	}
+	if len(items) == 0 {
+		return 0, nil
+	}
+
+	if err := q.config.Submit(account.Credential, items); err != nil {
+		log.Printf("[%s] Error submitting %d items for user %d: %v", q.config.Name, len(items), account.UserID, err)
+		switch q.classify(err) {
+		case failureRevoke:
+			q.config.Revoke(account.UserID)
+		case failureDrop:
+			log.Printf("[%s] Dropping %d items rejected by the service", q.config.Name, len(ids))
+			for _, id := range ids {
+				q.delete(id)
+			}
+		default:
+			q.recordFailure(ids, err)
+		}
+		return 0, err
+	}
+
+	for _, id := range ids {
+		q.delete(id)
+	}
+	return len(items), nil
+}
+
+// failure is what happens to a batch whose submission failed
+type failure int
+
+const (
+	// failureRetry schedules the items again with backoff
+	failureRetry failure = iota
+	// failureRevoke keeps the items and revokes the rejected credential
+	failureRevoke
+	// failureDrop removes the items, retrying them would only fail again
+	failureDrop
+)
+
+func (q *Queue[T]) classify(err error) failure {
+	switch {
+	case q.config.Unauthorized(err):
+		return failureRevoke
+	case !q.config.Temporary(err):
+		return failureDrop
+	default:
+		return failureRetry
+	}
+}
+
+// recordFailure schedules the next attempt with exponential backoff and drops items
+// failing too many times
+func (q *Queue[T]) recordFailure(ids []int64, cause error) {
+	for _, id := range ids {
+		var attempts int
+		err := q.db.QueryRow(`
+			UPDATE `+q.config.Table+`
+			SET attempts = attempts + 1, last_error = $1
+			WHERE id = $2
+			RETURNING attempts
+		`, cause.Error(), id).Scan(&attempts)
+		if err != nil {
+			log.Printf("[%s] Error updating queued item %d: %v", q.config.Name, id, err)
+			continue
+		}
+
+		if attempts >= maxAttempts {
+			log.Printf("[%s] Dropping queued item %d after %d attempts", q.config.Name, id, attempts)
+			q.delete(id)
+			continue
+		}
+
+		_, err = q.db.Exec(`
+			UPDATE `+q.config.Table+` SET next_attempt_at = NOW() + make_interval(secs => $1) WHERE id = $2
+		`, retryDelay(attempts).Seconds(), id)
+		if err != nil {
+			log.Printf("[%s] Error scheduling queued item %d: %v", q.config.Name, id, err)
+		}
+	}
+}
+
+func (q *Queue[T]) delete(id int64) {
+	if _, err := q.db.Exec("DELETE FROM "+q.config.Table+" WHERE id = $1", id); err != nil {
+		log.Printf("[%s] Error removing queued item %d: %v", q.config.Name, id, err)
+	}
+}
+
+// retryDelay doubles from one minute up to maxRetryDelay
+func retryDelay(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,48 @@
+package scrobblequeue
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{30, maxRetryDelay},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	errUnauthorized := errors.New("invalid token")
+	errRejected := errors.New("invalid listen")
+	q := New[string](nil, Config[string]{
+		Unauthorized: func(err error) bool { return err == errUnauthorized },
+		Temporary:    func(err error) bool { return err != errRejected },
+	})
+
+	tests := []struct {
+		err  error
+		want failure
+	}{
+		{errUnauthorized, failureRevoke},
+		// Errors that are not temporary are dropped instead of retried until maxAttempts
+		{errRejected, failureDrop},
+		{errors.New("connection refused"), failureRetry},
+	}
+	for _, tt := range tests {
+		if got := q.classify(tt.err); got != tt.want {
+			t.Errorf("classify(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package search
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Kind is a type of library item that can be searched
+type Kind struct {
+	table  string
+	column string // the name or title compared by trigram similarity
+	joins  string // the tables referenced by the filter fields
+	fields map[string]field
+}
+
+var (
+	Artists = Kind{table: "artists", column: "name", fields: artistFields}
+	Albums  = Kind{
+		table:  "albums",
+		column: "name",
+		joins:  "LEFT JOIN artists ar ON t.artist_id = ar.id",
+		fields: albumFields,
+	}
+	Songs = Kind{
+		table:  "songs",
+		column: "title",
+		joins:  "LEFT JOIN artists ar ON t.artist_id = ar.id LEFT JOIN albums al ON t.album_id = al.id",
+		fields: songFields,
+	}
+)
+
+// Engine finds library items matching a query and returns their IDs by relevance.
+// Callers load the rows they need in that order, so every client of the search gets
+// the same results.
+type Engine struct {
+	db *sql.DB
+}
+
+func NewEngine(db *sql.DB) *Engine {
+	return &Engine{db: db}
+}
+
+// Find returns the IDs of the items of a kind matching query, best first. The query
+// may use the syntax read by Parse; filters on ratings, stars and plays are those of
+// userID. Full-text matches come first, ranked by the field weights of their search
+// vector; items whose name is only similar to the words follow, so typos still find
+// something. A query without words lists the matching items by name, which Subsonic
+// clients use with an empty query to sync the library.
+func (e *Engine) Find(kind Kind, query string, userID, limit, offset int) ([]int, error) {
+	q := Parse(query)
+	b := &builder{userID: userID}
+
+	from := kind.table + " t " + kind.joins
+	var where []string
+	order := fmt.Sprintf("t.%s, t.id", kind.column)
+
+	if len(q.Terms) > 0 {
+		tsquery, words := b.arg(PrefixQuery(q.Terms)), b.arg(strings.Join(q.Terms, " "))
+		name := fmt.Sprintf("search_unaccent(lower(t.%s))", kind.column)
+
+		from += fmt.Sprintf(", to_tsquery('simple', search_unaccent(%s)) AS q", tsquery)
+		where = append(where, fmt.Sprintf("(t.search_vector @@ q OR %s %% search_unaccent(%s))", name, words))
+		order = fmt.Sprintf(`COALESCE(t.search_vector @@ q, false) DESC,
+			ts_rank(t.search_vector, q) DESC,
+			similarity(%s, search_unaccent(%s)) DESC, `, name, words) + order
+	}
+
+	if len(q.Excluded) > 0 {
+		where = append(where, fmt.Sprintf(
+			"NOT COALESCE(t.search_vector @@ to_tsquery('simple', search_unaccent(%s)), false)",
+			b.arg(ExcludeQuery(q.Excluded))))
+	}
+
+	for _, f := range q.Filters {
+		cond, err := b.filter(kind, f)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, "("+cond+")")
+	}
+
+	if len(where) == 0 {
+		where = append(where, "TRUE")
+	}
+	return e.ids(fmt.Sprintf(`
+		SELECT t.id
+		FROM %s
+		WHERE %s
+		ORDER BY %s
+		LIMIT %s OFFSET %s
+	`, from, strings.Join(where, " AND "), order, b.arg(limit), b.arg(offset)), b.args...)
+}
+
+func (e *Engine) ids(query string, args ...interface{}) ([]int, error) {
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// refreshQueries fill the search vectors of rows that have none. Triggers keep the
+// vectors of inserted and changed rows up to date (migration 027), so these only find
+// rows stored before the triggers existed.
+var refreshQueries = []struct {
+	table string
+	query string
+}{
+	{"artists", `
+		UPDATE artists SET search_vector = search_artist_vector(name)
+		WHERE search_vector IS NULL
+	`},
+	{"albums", `
+		UPDATE albums SET search_vector = search_album_vector(name, artist_id)
+		WHERE search_vector IS NULL
+	`},
+	{"songs", `
+		UPDATE songs SET search_vector = search_song_vector(title, artist_id, album_id)
+		WHERE search_vector IS NULL
+	`},
+}
+
+// Refresh indexes the rows without a search vector. It runs at startup.
+func (e *Engine) Refresh() error {
+	updated := make([]int64, len(refreshQueries))
+	for i, r := range refreshQueries {
+		result, err := e.db.Exec(r.query)
+		if err != nil {
+			return fmt.Errorf("refreshing search index of %s: %v", r.table, err)
+		}
+		updated[i], _ = result.RowsAffected()
+	}
+
+	if updated[0] > 0 || updated[1] > 0 || updated[2] > 0 {
+		log.Printf("[Search] Indexed %d artists, %d albums and %d songs", updated[0], updated[1], updated[2])
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// field is the SQL of a filter field for one kind of item. Columns may reference t
+// (the item), ar and al when the kind joins them, and {user}, the user searching. When
+// exists is set the column belongs to related rows: the filter matches items with
+// at least one related row satisfying it.
+type field struct {
+	column string
+	exists string
+	exact  bool // compared as a whole value instead of a substring
+}
+
+var songFields = map[string]field{
+	"artist":  {column: "ar.name"},
+	"album":   {column: "al.name"},
+	"genre":   {column: "al.genre"},
+	"format":  {column: "t.format", exact: true},
+	"year":    {column: "al.year"},
+	"bitrate": {column: "t.bitrate"},
+	"rating":  {column: "COALESCE((SELECT rating FROM ratings WHERE song_id = t.id AND user_id = {user}), 0)"},
+	"starred": {column: "EXISTS (SELECT 1 FROM starred_songs WHERE song_id = t.id AND user_id = {user})"},
+	"plays":   {column: "(SELECT COUNT(*) FROM play_history WHERE song_id = t.id AND user_id = {user} AND share_id IS NULL)"},
+}
+
+var albumFields = map[string]field{
+	"artist":  {column: "ar.name"},
+	"album":   {column: "t.name"},
+	"genre":   {column: "t.genre"},
+	"format":  {column: "s.format", exists: "SELECT 1 FROM songs s WHERE s.album_id = t.id", exact: true},
+	"year":    {column: "t.year"},
+	"bitrate": {column: "s.bitrate", exists: "SELECT 1 FROM songs s WHERE s.album_id = t.id"},
+	"rating":  {column: "COALESCE((SELECT rating FROM album_ratings WHERE album_id = t.id AND user_id = {user}), 0)"},
+	"starred": {column: "EXISTS (SELECT 1 FROM starred_albums WHERE album_id = t.id AND user_id = {user})"},
+	"plays": {column: `(SELECT COUNT(*) FROM play_history ph JOIN songs s ON s.id = ph.song_id
+		WHERE s.album_id = t.id AND ph.user_id = {user} AND ph.share_id IS NULL)`},
+}
+
+var artistFields = map[string]field{
+	"artist":  {column: "t.name"},
+	"album":   {column: "al.name", exists: "SELECT 1 FROM albums al WHERE al.artist_id = t.id"},
+	"genre":   {column: "al.genre", exists: "SELECT 1 FROM albums al WHERE al.artist_id = t.id"},
+	"format":  {column: "s.format", exists: "SELECT 1 FROM songs s WHERE s.artist_id = t.id", exact: true},
+	"year":    {column: "al.year", exists: "SELECT 1 FROM albums al WHERE al.artist_id = t.id"},
+	"bitrate": {column: "s.bitrate", exists: "SELECT 1 FROM songs s WHERE s.artist_id = t.id"},
+	"rating":  {column: "COALESCE((SELECT rating FROM artist_ratings WHERE artist_id = t.id AND user_id = {user}), 0)"},
+	"starred": {column: "EXISTS (SELECT 1 FROM starred_artists WHERE artist_id = t.id AND user_id = {user})"},
+	"plays": {column: `(SELECT COUNT(*) FROM play_history ph JOIN songs s ON s.id = ph.song_id
+		WHERE s.artist_id = t.id AND ph.user_id = {user} AND ph.share_id IS NULL)`},
+}
+
+// builder collects positional arguments while the WHERE clause is written
+type builder struct {
+	args   []interface{}
+	userID int
+	user   string // the argument holding userID, once a field needs it
+}
+
+func (b *builder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// filter returns the condition of a filter for a kind of item
+func (b *builder) filter(kind Kind, f Filter) (string, error) {
+	def, ok := kind.fields[f.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown search field %q", f.Field)
+	}
+
+	if strings.Contains(def.column, "{user}") {
+		if b.user == "" {
+			b.user = b.arg(b.userID)
+		}
+		def.column = strings.ReplaceAll(def.column, "{user}", b.user)
+	}
+
+	var cond string
+	switch fieldTypes[f.Field] {
+	case textValue:
+		if def.exact {
+			cond = fmt.Sprintf("LOWER(%s) = %s", def.column, b.arg(strings.ToLower(f.Text)))
+		} else {
+			cond = fmt.Sprintf("search_unaccent(LOWER(%s)) LIKE '%%' || search_unaccent(%s) || '%%'",
+				def.column, b.arg(escapeLike(strings.ToLower(f.Text))))
+		}
+	case rangeValue:
+		var bounds []string
+		if f.Range.HasMin {
+			bounds = append(bounds, fmt.Sprintf("%s >= %s", def.column, b.arg(f.Range.Min)))
+		}
+		if f.Range.HasMax {
+			bounds = append(bounds, fmt.Sprintf("%s <= %s", def.column, b.arg(f.Range.Max)))
+		}
+		cond = strings.Join(bounds, " AND ")
+	default:
+		cond = def.column
+		if !f.Bool {
+			cond = "NOT " + cond
+		}
+	}
+
+	if def.exists != "" {
+		cond = fmt.Sprintf("EXISTS (%s AND %s)", def.exists, cond)
+	}
+	// Items without a value, such as albums without a year, are kept by negated filters
+	if f.Negate {
+		cond = fmt.Sprintf("NOT COALESCE(%s, false)", cond)
+	}
+	return cond, nil
+}
+
+// escapeLike escapes the wildcards of LIKE patterns
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,47 @@
+// Package search implements the library search shared by the Subsonic API and the
+// web interface: PostgreSQL full-text search over unaccented, weighted tsvectors
+// with prefix matching, trigram similarity as a fallback for typos, and field
+// filters such as year:1955..1965 typed in the search box.
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maxTerms bounds the words of a query so pasted text does not build huge queries
+const maxTerms = 10
+
+// Terms splits a query into lowercase words. Anything that is not a letter or a digit
+// separates words, which also keeps tsquery operators out of the query. Accents are
+// removed by the database, with the same dictionary used for the indexed text.
+func Terms(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxTerms {
+		words = words[:maxTerms]
+	}
+	return words
+}
+
+// PrefixQuery builds a tsquery matching every term as a prefix, so "beat abb" finds
+// "The Beatles - Abbey Road" while the user is still typing. It returns "" when the
+// query has no words.
+func PrefixQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, term := range terms {
+		parts[i] = term + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+// ExcludeQuery builds a tsquery matching any of the phrases, to filter out results
+// containing them. Words of a phrase must follow each other.
+func ExcludeQuery(phrases []string) string {
+	parts := make([]string, len(phrases))
+	for i, phrase := range phrases {
+		parts[i] = strings.Join(strings.Fields(phrase), " <-> ")
+	}
+	return strings.Join(parts, " | ")
+}
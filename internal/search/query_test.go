@@ -0,0 +1,52 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"Beatles Abbey", []string{"beatles", "abbey"}},
+		{"  Maná  ", []string{"maná"}},
+		{"AC/DC", []string{"ac", "dc"}},
+		{"rock & roll | !metal:*", []string{"rock", "roll", "metal"}},
+		{`""`, []string{}},
+		{"", []string{}},
+		{"Sigur Rós 2002", []string{"sigur", "rós", "2002"}},
+	}
+
+	for _, tt := range tests {
+		if got := Terms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Terms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestTermsAreBounded(t *testing.T) {
+	query := strings.Repeat("word ", maxTerms+5)
+	if got := len(Terms(query)); got != maxTerms {
+		t.Errorf("Terms() returned %d words, want %d", got, maxTerms)
+	}
+}
+
+func TestPrefixQuery(t *testing.T) {
+	tests := []struct {
+		terms []string
+		want  string
+	}{
+		{[]string{"beat", "abb"}, "beat:* & abb:*"},
+		{[]string{"maná"}, "maná:*"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := PrefixQuery(tt.terms); got != tt.want {
+			t.Errorf("PrefixQuery(%q) = %q, want %q", tt.terms, got, tt.want)
+		}
+	}
+}
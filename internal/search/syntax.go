@@ -0,0 +1,226 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type valueType int
+
+const (
+	textValue valueType = iota
+	rangeValue
+	boolValue
+)
+
+// fieldTypes lists the fields that can filter a search, as in `artist:"Miles Davis"`,
+// `year:1955..1965` or `starred:true`
+var fieldTypes = map[string]valueType{
+	"artist":  textValue,
+	"album":   textValue,
+	"genre":   textValue,
+	"format":  textValue,
+	"year":    rangeValue,
+	"bitrate": rangeValue,
+	"rating":  rangeValue,
+	"plays":   rangeValue,
+	"starred": boolValue,
+}
+
+// fieldAliases are other names accepted for the fields, mostly those of smart playlists
+var fieldAliases = map[string]string{
+	"albumartist": "artist",
+	"filetype":    "format",
+	"playcount":   "plays",
+	"loved":       "starred",
+}
+
+// Query is a parsed search query: words for the full-text search, phrases prefixed
+// with - that results must not contain (their words separated by spaces), and field
+// filters
+type Query struct {
+	Terms    []string
+	Excluded []string
+	Filters  []Filter
+}
+
+// Filter restricts results by a field. Text holds the value of text fields, Range
+// that of numeric fields and Bool that of starred.
+type Filter struct {
+	Field  string
+	Negate bool
+	Text   string
+	Range  Range
+	Bool   bool
+}
+
+// Range is an inclusive numeric range, open on the sides without a bound
+type Range struct {
+	Min, Max       int
+	HasMin, HasMax bool
+}
+
+// Parse reads a query like `artist:"Miles Davis" year:1955..1965 genre:jazz -live
+// kind of blue`. Double quotes group words with spaces. Tokens that look like filters
+// but name an unknown field or carry an invalid value are searched as plain text,
+// so a query never fails to parse.
+func Parse(input string) Query {
+	var q Query
+	for _, tok := range tokenize(input) {
+		if tok.field != "" {
+			if filter, ok := parseFilter(tok.field, tok.value); ok {
+				filter.Negate = tok.negate
+				q.Filters = append(q.Filters, filter)
+				continue
+			}
+		}
+
+		text := tok.value
+		if tok.field != "" {
+			text = tok.field + " " + tok.value
+		}
+		if tok.negate {
+			if words := Terms(text); len(words) > 0 {
+				q.Excluded = append(q.Excluded, strings.Join(words, " "))
+			}
+		} else {
+			q.Terms = append(q.Terms, Terms(text)...)
+		}
+	}
+
+	if len(q.Terms) > maxTerms {
+		q.Terms = q.Terms[:maxTerms]
+	}
+	if len(q.Excluded) > maxTerms {
+		q.Excluded = q.Excluded[:maxTerms]
+	}
+	return q
+}
+
+// token is a word of the query, split as field:value when it has a colon outside quotes
+type token struct {
+	negate bool
+	field  string
+	value  string
+}
+
+func tokenize(input string) []token {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		var tok token
+		if runes[i] == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+			tok.negate = true
+			i++
+		}
+
+		var value strings.Builder
+		quoted, sawQuote := false, false
+		for ; i < len(runes) && (quoted || !unicode.IsSpace(runes[i])); i++ {
+			switch r := runes[i]; {
+			case r == '"':
+				quoted = !quoted
+				sawQuote = true
+			case r == ':' && !sawQuote && tok.field == "":
+				tok.field = strings.ToLower(value.String())
+				value.Reset()
+			default:
+				value.WriteRune(r)
+			}
+		}
+		tok.value = value.String()
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+func parseFilter(name, value string) (Filter, bool) {
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	kind, ok := fieldTypes[name]
+	if !ok {
+		return Filter{}, false
+	}
+
+	filter := Filter{Field: name}
+	switch kind {
+	case textValue:
+		filter.Text = strings.TrimSpace(value)
+		if name == "format" {
+			filter.Text = strings.TrimPrefix(strings.ToLower(filter.Text), ".")
+		}
+		return filter, filter.Text != ""
+	case rangeValue:
+		filter.Range, ok = parseRange(value)
+		return filter, ok
+	default:
+		filter.Bool, ok = parseBool(value)
+		return filter, ok
+	}
+}
+
+// parseRange reads "1955..1965", "1955..", "..1965", ">=3", ">3", "<=3", "<3" and "3"
+func parseRange(value string) (Range, bool) {
+	var r Range
+	var err error
+
+	switch {
+	case strings.Contains(value, ".."):
+		parts := strings.SplitN(value, "..", 2)
+		if parts[0] == "" && parts[1] == "" {
+			return r, false
+		}
+		if parts[0] != "" {
+			if r.Min, err = strconv.Atoi(parts[0]); err != nil {
+				return r, false
+			}
+			r.HasMin = true
+		}
+		if parts[1] != "" {
+			if r.Max, err = strconv.Atoi(parts[1]); err != nil {
+				return r, false
+			}
+			r.HasMax = true
+		}
+		if r.HasMin && r.HasMax && r.Min > r.Max {
+			return r, false
+		}
+	case strings.HasPrefix(value, ">="):
+		r.Min, err = strconv.Atoi(value[2:])
+		r.HasMin = true
+	case strings.HasPrefix(value, ">"):
+		r.Min, err = strconv.Atoi(value[1:])
+		r.Min++
+		r.HasMin = true
+	case strings.HasPrefix(value, "<="):
+		r.Max, err = strconv.Atoi(value[2:])
+		r.HasMax = true
+	case strings.HasPrefix(value, "<"):
+		r.Max, err = strconv.Atoi(value[1:])
+		r.Max--
+		r.HasMax = true
+	default:
+		r.Min, err = strconv.Atoi(value)
+		r.Max = r.Min
+		r.HasMin, r.HasMax = true, true
+	}
+	return r, err == nil
+}
+
+func parseBool(value string) (bool, bool) {
+	switch strings.ToLower(value) {
+	case "true", "yes", "1":
+		return true, true
+	case "false", "no", "0":
+		return false, true
+	}
+	return false, false
+}
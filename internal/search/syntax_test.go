@@ -0,0 +1,159 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	q := Parse(`artist:"Miles Davis" year:1955..1965 genre:jazz -live format:.FLAC kind of blue`)
+
+	if want := []string{"kind", "of", "blue"}; !reflect.DeepEqual(q.Terms, want) {
+		t.Errorf("Terms = %q, want %q", q.Terms, want)
+	}
+	if want := []string{"live"}; !reflect.DeepEqual(q.Excluded, want) {
+		t.Errorf("Excluded = %q, want %q", q.Excluded, want)
+	}
+
+	want := []Filter{
+		{Field: "artist", Text: "Miles Davis"},
+		{Field: "year", Range: Range{Min: 1955, Max: 1965, HasMin: true, HasMax: true}},
+		{Field: "genre", Text: "jazz"},
+		{Field: "format", Text: "flac"},
+	}
+	if !reflect.DeepEqual(q.Filters, want) {
+		t.Errorf("Filters = %+v, want %+v", q.Filters, want)
+	}
+}
+
+func TestParseFallsBackToText(t *testing.T) {
+	tests := []struct {
+		query string
+		terms []string
+	}{
+		// Unknown fields and invalid values are searched as words
+		{"mood:happy", []string{"mood", "happy"}},
+		{"year:nineties", []string{"year", "nineties"}},
+		{"starred:maybe", []string{"starred", "maybe"}},
+		{"artist:", []string{"artist"}},
+		// A colon inside quotes is part of the text
+		{`"Live: 1975"`, []string{"live", "1975"}},
+		{"jay-z", []string{"jay", "z"}},
+		{"- alone", []string{"alone"}},
+	}
+
+	for _, tt := range tests {
+		q := Parse(tt.query)
+		if len(q.Filters) != 0 || !reflect.DeepEqual(q.Terms, tt.terms) {
+			t.Errorf("Parse(%q) = terms %q, filters %+v; want terms %q", tt.query, q.Terms, q.Filters, tt.terms)
+		}
+	}
+}
+
+func TestParseNegation(t *testing.T) {
+	q := Parse(`-"live at" -starred:true -Genre:Pop`)
+
+	if want := []string{"live at"}; !reflect.DeepEqual(q.Excluded, want) {
+		t.Errorf("Excluded = %q, want %q", q.Excluded, want)
+	}
+	want := []Filter{
+		{Field: "starred", Negate: true, Bool: true},
+		{Field: "genre", Negate: true, Text: "Pop"},
+	}
+	if !reflect.DeepEqual(q.Filters, want) {
+		t.Errorf("Filters = %+v, want %+v", q.Filters, want)
+	}
+}
+
+func TestParseAliases(t *testing.T) {
+	q := Parse("playcount:>10 loved:yes filetype:mp3 albumartist:Queen")
+	var fields []string
+	for _, f := range q.Filters {
+		fields = append(fields, f.Field)
+	}
+	if want := []string{"plays", "starred", "format", "artist"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("fields = %q, want %q", fields, want)
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Range
+		ok    bool
+	}{
+		{"1955..1965", Range{Min: 1955, Max: 1965, HasMin: true, HasMax: true}, true},
+		{"1990..", Range{Min: 1990, HasMin: true}, true},
+		{"..320", Range{Max: 320, HasMax: true}, true},
+		{">=4", Range{Min: 4, HasMin: true}, true},
+		{">3", Range{Min: 4, HasMin: true}, true},
+		{"<=2", Range{Max: 2, HasMax: true}, true},
+		{"<3", Range{Max: 2, HasMax: true}, true},
+		{"5", Range{Min: 5, Max: 5, HasMin: true, HasMax: true}, true},
+		{"..", Range{}, false},
+		{"1965..1955", Range{}, false},
+		{"a..b", Range{}, false},
+		{">x", Range{}, false},
+		{"", Range{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseRange(tt.value)
+		if ok != tt.ok || (ok && got != tt.want) {
+			t.Errorf("parseRange(%q) = %+v, %v; want %+v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	b := &builder{userID: 7}
+
+	rating, err := b.filter(Songs, Filter{Field: "rating", Range: Range{Min: 4, HasMin: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	starred, err := b.filter(Songs, Filter{Field: "starred", Bool: true, Negate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The user is passed once, however many fields need it
+	if !reflect.DeepEqual(b.args, []interface{}{7, 4}) {
+		t.Errorf("args = %v, want [7 4]", b.args)
+	}
+	if !strings.Contains(rating, "user_id = $1") || !strings.HasSuffix(rating, ">= $2") {
+		t.Errorf("rating condition = %q", rating)
+	}
+	if !strings.HasPrefix(starred, "NOT COALESCE(EXISTS") || !strings.Contains(starred, "user_id = $1") {
+		t.Errorf("starred condition = %q", starred)
+	}
+
+	// Albums and artists match through their songs
+	format, err := b.filter(Artists, Filter{Field: "format", Text: "flac"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "EXISTS (SELECT 1 FROM songs s WHERE s.artist_id = t.id AND LOWER(s.format) = $3)"; format != want {
+		t.Errorf("format condition = %q, want %q", format, want)
+	}
+	if b.args[2] != "flac" {
+		t.Errorf("format argument = %v, want flac", b.args[2])
+	}
+
+	if _, err := b.filter(Songs, Filter{Field: "mood"}); err == nil {
+		t.Error("filter() accepted an unknown field")
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got := escapeLike(`100%_\`); got != `100\%\_\\` {
+		t.Errorf("escapeLike() = %q", got)
+	}
+}
+
+func TestExcludeQuery(t *testing.T) {
+	if got := ExcludeQuery([]string{"live at", "demo"}); got != "live <-> at | demo" {
+		t.Errorf("ExcludeQuery() = %q", got)
+	}
+}
@@ -0,0 +1,178 @@
+package smartplaylist
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Criteria is a smart playlist definition, stored as JSON in playlists.rules.
+// It uses the Navidrome .nsp format:
+//
+//	{"all": [{"is": {"genre": "Jazz"}}, {"lt": {"year": 1970}}, {"gt": {"rating": 3}}],
+//	 "sort": "lastplayed", "order": "desc", "limit": 100}
+type Criteria struct {
+	Expression Expression
+	// Sort is a comma separated list of fields, each optionally prefixed with + or -
+	Sort   string
+	Order  string
+	Limit  int
+	Offset int
+}
+
+// Expression is either a group of expressions joined with "all" (AND) or "any" (OR),
+// or a single condition comparing a field with a value
+type Expression struct {
+	Conjunction string
+	Children    []Expression
+
+	Operator string
+	Field    string
+	Value    interface{}
+}
+
+// ErrNoRules is returned when a definition has neither "all" nor "any"
+var ErrNoRules = errors.New(`smart playlist has no "all" or "any" rules`)
+
+var operators = map[string]bool{
+	"is": true, "isnot": true,
+	"gt": true, "lt": true,
+	"contains": true, "notcontains": true,
+	"startswith": true, "endswith": true,
+	"intherange": true,
+	"before":     true, "after": true,
+	"inthelast": true, "notinthelast": true,
+}
+
+// Parse reads a JSON definition and validates its fields and operators
+func Parse(data []byte) (*Criteria, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid smart playlist: %w", err)
+	}
+
+	criteria := &Criteria{}
+	for key, value := range raw {
+		var err error
+		switch strings.ToLower(key) {
+		case "all", "any":
+			if criteria.Expression.Conjunction != "" {
+				return nil, errors.New(`smart playlist can only have one of "all" or "any"`)
+			}
+			criteria.Expression, err = parseGroup(strings.ToLower(key), value)
+		case "sort":
+			err = json.Unmarshal(value, &criteria.Sort)
+		case "order":
+			err = json.Unmarshal(value, &criteria.Order)
+		case "limit":
+			err = json.Unmarshal(value, &criteria.Limit)
+		case "offset":
+			err = json.Unmarshal(value, &criteria.Offset)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid smart playlist %q: %w", key, err)
+		}
+	}
+
+	if criteria.Expression.Conjunction == "" {
+		return nil, ErrNoRules
+	}
+	if criteria.Limit < 0 || criteria.Offset < 0 {
+		return nil, errors.New("smart playlist limit and offset must not be negative")
+	}
+	if order := strings.ToLower(criteria.Order); order != "" && order != "asc" && order != "desc" {
+		return nil, fmt.Errorf("invalid smart playlist order %q", criteria.Order)
+	}
+	// Compiling catches values that do not suit their field, such as text for "year"
+	if _, _, err := criteria.Query("s.id", 0); err != nil {
+		return nil, err
+	}
+	return criteria, nil
+}
+
+func parseGroup(conjunction string, data json.RawMessage) (Expression, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return Expression{}, err
+	}
+
+	group := Expression{Conjunction: conjunction}
+	for _, item := range items {
+		child, err := parseExpression(item)
+		if err != nil {
+			return Expression{}, err
+		}
+		group.Children = append(group.Children, child)
+	}
+	return group, nil
+}
+
+// parseExpression reads {"operator": {"field": value}} or a nested {"all"|"any": [...]}
+func parseExpression(data json.RawMessage) (Expression, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Expression{}, err
+	}
+	if len(raw) != 1 {
+		return Expression{}, fmt.Errorf("rule must have exactly one operator, got %d", len(raw))
+	}
+
+	for key, value := range raw {
+		operator := strings.ToLower(key)
+		if operator == "all" || operator == "any" {
+			return parseGroup(operator, value)
+		}
+		if !operators[operator] {
+			return Expression{}, fmt.Errorf("unknown operator %q", key)
+		}
+
+		var condition map[string]interface{}
+		if err := json.Unmarshal(value, &condition); err != nil {
+			return Expression{}, err
+		}
+		if len(condition) != 1 {
+			return Expression{}, fmt.Errorf("operator %q must compare exactly one field", key)
+		}
+		for field, fieldValue := range condition {
+			if _, ok := fields[strings.ToLower(field)]; !ok {
+				return Expression{}, fmt.Errorf("unknown field %q", field)
+			}
+			return Expression{Operator: operator, Field: strings.ToLower(field), Value: fieldValue}, nil
+		}
+	}
+	return Expression{}, nil
+}
+
+// MarshalJSON writes the criteria back in the .nsp format
+func (c Criteria) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	group := c.Expression.toJSON()
+	for k, v := range group {
+		out[k] = v
+	}
+	if c.Sort != "" {
+		out["sort"] = c.Sort
+	}
+	if c.Order != "" {
+		out["order"] = c.Order
+	}
+	if c.Limit > 0 {
+		out["limit"] = c.Limit
+	}
+	if c.Offset > 0 {
+		out["offset"] = c.Offset
+	}
+	return json.Marshal(out)
+}
+
+func (e Expression) toJSON() map[string]interface{} {
+	if e.Conjunction != "" {
+		children := make([]map[string]interface{}, len(e.Children))
+		for i, child := range e.Children {
+			children[i] = child.toJSON()
+		}
+		return map[string]interface{}{e.Conjunction: children}
+	}
+	return map[string]interface{}{e.Operator: map[string]interface{}{e.Field: e.Value}}
+}
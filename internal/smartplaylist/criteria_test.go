@@ -0,0 +1,209 @@
+package smartplaylist
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseAndQuery(t *testing.T) {
+	criteria, err := Parse([]byte(`{
+		"all": [
+			{"is": {"genre": "Jazz"}},
+			{"lt": {"year": 1970}},
+			{"gt": {"rating": 3}}
+		],
+		"sort": "lastplayed",
+		"order": "desc",
+		"limit": 100
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	query, args, err := criteria.Query("s.id", 7)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	for _, want := range []string{
+		"(LOWER(al.genre) = LOWER($2) AND al.year < $3 AND COALESCE(r.rating, 0) > $4)",
+		"ORDER BY pc.last_played DESC NULLS LAST, s.id",
+		"LIMIT $5",
+		"r.user_id = $1",
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query does not contain %q:\n%s", want, query)
+		}
+	}
+
+	wantArgs := []interface{}{7, "Jazz", 1970.0, 3.0, 100}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestSummaryQueryKeepsLimit(t *testing.T) {
+	criteria, err := Parse([]byte(`{"all": [{"is": {"loved": true}}], "sort": "-playcount", "limit": 25}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	query, args, err := criteria.SummaryQuery(3)
+	if err != nil {
+		t.Fatalf("SummaryQuery() error = %v", err)
+	}
+
+	// The limit applies to the sorted songs before they are counted
+	if !strings.HasPrefix(query, "SELECT COUNT(*), COALESCE(SUM(matched.duration), 0) FROM (SELECT s.duration") {
+		t.Errorf("query does not count the matched songs:\n%s", query)
+	}
+	if !strings.Contains(query, "LIMIT $3) matched") {
+		t.Errorf("query does not limit the songs it counts:\n%s", query)
+	}
+	if wantArgs := []interface{}{3, true, 25}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+}
+
+func TestNestedGroups(t *testing.T) {
+	criteria, err := Parse([]byte(`{
+		"any": [
+			{"contains": {"title": "100%_love"}},
+			{"all": [{"is": {"loved": true}}, {"inTheLast": {"lastPlayed": 30}}]}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	query, args, err := criteria.Query("s.id", 1)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	want := "(s.title ILIKE $2 OR ((st.song_id IS NOT NULL) = $3 AND pc.last_played >= NOW() - ($4 * INTERVAL '1 day')))"
+	if !strings.Contains(query, want) {
+		t.Errorf("query does not contain %q:\n%s", want, query)
+	}
+	if args[1] != `%100\%\_love%` {
+		t.Errorf("LIKE wildcards are not escaped: %q", args[1])
+	}
+	if !strings.Contains(query, "ORDER BY s.title ASC NULLS LAST, s.id") {
+		t.Errorf("default sort is not by title:\n%s", query)
+	}
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		sort, order, want string
+	}{
+		{"", "", "s.title ASC NULLS LAST, s.id"},
+		{"year,-rating", "", "al.year ASC NULLS LAST, COALESCE(r.rating, 0) DESC NULLS LAST, s.id"},
+		{"playcount", "desc", "COALESCE(pc.play_count, 0) DESC NULLS LAST, s.id"},
+		{"random", "", "RANDOM(), s.id"},
+	}
+
+	for _, tt := range tests {
+		got, err := orderBy(tt.sort, tt.order)
+		if err != nil {
+			t.Errorf("orderBy(%q, %q) error = %v", tt.sort, tt.order, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("orderBy(%q, %q) = %q, want %q", tt.sort, tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidRules(t *testing.T) {
+	tests := []string{
+		`not json`,
+		`{"sort": "title"}`,
+		`{"all": [{"is": {"mood": "happy"}}]}`,
+		`{"all": [{"matches": {"title": "x"}}]}`,
+		`{"all": [{"is": {"year": "nineteen"}}]}`,
+		`{"all": [{"contains": {"year": 1970}}]}`,
+		`{"all": [{"is": {"title": "a", "album": "b"}}]}`,
+		`{"all": [], "any": []}`,
+		`{"all": [], "sort": "mood"}`,
+		`{"all": [], "order": "sideways"}`,
+		`{"all": [{"inTheRange": {"year": [1960]}}]}`,
+		`{"all": [{"before": {"dateAdded": "last week"}}]}`,
+	}
+
+	for _, rules := range tests {
+		if _, err := Parse([]byte(rules)); err == nil {
+			t.Errorf("Parse(%s) succeeded, want error", rules)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	criteria, err := Parse([]byte(`{"all": [
+		{"inTheRange": {"year": [1960, 1969]}},
+		{"inTheRange": {"dateAdded": ["2024-01-01", "2024-06-30"]}}
+	]}`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	query, args, err := criteria.Query("s.id", 1)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if !strings.Contains(query, "al.year BETWEEN $2 AND $3 AND s.created_at::date BETWEEN $4 AND $5") {
+		t.Errorf("unexpected range conditions:\n%s", query)
+	}
+	if want := []interface{}{1, 1960.0, 1969.0, "2024-01-01", "2024-06-30"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	original := `{"all":[{"is":{"genre":"Jazz"}},{"any":[{"gt":{"rating":3}},{"is":{"loved":true}}]}],"limit":50,"sort":"-year"}`
+
+	criteria, err := Parse([]byte(original))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	data, err := json.Marshal(criteria)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	var got, want interface{}
+	json.Unmarshal(data, &got)
+	json.Unmarshal([]byte(original), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %s, want %s", data, original)
+	}
+}
+
+func TestParseNSP(t *testing.T) {
+	data := []byte("\ufeff" + `{
+		"name": " Recently Played Jazz ",
+		"comment": "Jazz I listened to this month",
+		"all": [
+			{"is": {"genre": "Jazz"}},
+			{"inTheLast": {"lastPlayed": 30}}
+		],
+		"sort": "lastPlayed",
+		"order": "desc"
+	}`)
+
+	playlist, err := ParseNSP(data)
+	if err != nil {
+		t.Fatalf("ParseNSP() error = %v", err)
+	}
+	if playlist.Name != "Recently Played Jazz" {
+		t.Errorf("Name = %q", playlist.Name)
+	}
+	if playlist.Comment != "Jazz I listened to this month" {
+		t.Errorf("Comment = %q", playlist.Comment)
+	}
+	if len(playlist.Criteria.Expression.Children) != 2 || playlist.Criteria.Sort != "lastPlayed" {
+		t.Errorf("Criteria = %+v", playlist.Criteria)
+	}
+}
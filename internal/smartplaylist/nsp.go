@@ -0,0 +1,42 @@
+package smartplaylist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Playlist is a smart playlist read from a Navidrome .nsp file
+type Playlist struct {
+	Name     string
+	Comment  string
+	Public   bool
+	Criteria *Criteria
+}
+
+// ParseNSP reads a .nsp file: the rules of Parse plus the playlist name and comment
+func ParseNSP(data []byte) (*Playlist, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	var header struct {
+		Name    string `json:"name"`
+		Comment string `json:"comment"`
+		Public  bool   `json:"public"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid .nsp file: %w", err)
+	}
+
+	criteria, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Playlist{
+		Name:     strings.TrimSpace(header.Name),
+		Comment:  header.Comment,
+		Public:   header.Public,
+		Criteria: criteria,
+	}, nil
+}
@@ -0,0 +1,342 @@
+package smartplaylist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	numberField
+	dateField
+	boolField
+)
+
+type field struct {
+	column string
+	kind   fieldKind
+}
+
+// fields maps rule fields to columns of the query built by Query. Ratings, stars
+// and plays are those of the user the playlist is evaluated for.
+var fields = map[string]field{
+	"title":        {"s.title", textField},
+	"album":        {"al.name", textField},
+	"artist":       {"ar.name", textField},
+	"albumartist":  {"ar.name", textField},
+	"genre":        {"al.genre", textField},
+	"filetype":     {"s.format", textField},
+	"filepath":     {"s.file_path", textField},
+	"year":         {"al.year", numberField},
+	"tracknumber":  {"s.track_number", numberField},
+	"duration":     {"s.duration", numberField},
+	"bitrate":      {"s.bitrate", numberField},
+	"size":         {"s.file_size", numberField},
+	"rating":       {"COALESCE(r.rating, 0)", numberField},
+	"playcount":    {"COALESCE(pc.play_count, 0)", numberField},
+	"dateadded":    {"s.created_at", dateField},
+	"datemodified": {"s.updated_at", dateField},
+	"lastplayed":   {"pc.last_played", dateField},
+	"dateloved":    {"st.starred_at", dateField},
+	"loved":        {"(st.song_id IS NOT NULL)", boolField},
+	"starred":      {"(st.song_id IS NOT NULL)", boolField},
+}
+
+// builder collects positional arguments while the WHERE clause is written
+type builder struct {
+	args []interface{}
+}
+
+func (b *builder) arg(value interface{}) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+// Query returns a parameterized query selecting columns for the songs matching the
+// criteria, evaluated for userID. Columns may reference s (songs), ar (artists) and
+// al (albums).
+func (c *Criteria) Query(columns string, userID int) (string, []interface{}, error) {
+	b := &builder{}
+	user := b.arg(userID)
+
+	where, err := b.expression(c.Expression)
+	if err != nil {
+		return "", nil, err
+	}
+	order, err := orderBy(c.Sort, c.Order)
+	if err != nil {
+		return "", nil, err
+	}
+
+	query := `SELECT ` + columns + `
+		FROM songs s
+		JOIN artists ar ON s.artist_id = ar.id
+		JOIN albums al ON s.album_id = al.id
+		LEFT JOIN ratings r ON r.song_id = s.id AND r.user_id = ` + user + `
+		LEFT JOIN starred_songs st ON st.song_id = s.id AND st.user_id = ` + user + `
+		LEFT JOIN (
+			SELECT song_id, COUNT(*) AS play_count, MAX(played_at) AS last_played
+			FROM play_history
+			WHERE user_id = ` + user + ` AND share_id IS NULL
+			GROUP BY song_id
+		) pc ON pc.song_id = s.id
+		WHERE ` + where + `
+		ORDER BY ` + order
+
+	if c.Limit > 0 {
+		query += " LIMIT " + b.arg(c.Limit)
+	}
+	if c.Offset > 0 {
+		query += " OFFSET " + b.arg(c.Offset)
+	}
+	return query, b.args, nil
+}
+
+// SummaryQuery returns a parameterized query counting the songs matching the criteria
+// and summing their duration, as listed without loading the songs
+func (c *Criteria) SummaryQuery(userID int) (string, []interface{}, error) {
+	query, args, err := c.Query("s.duration", userID)
+	if err != nil {
+		return "", nil, err
+	}
+	return `SELECT COUNT(*), COALESCE(SUM(matched.duration), 0) FROM (` + query + `) matched`, args, nil
+}
+
+func (b *builder) expression(e Expression) (string, error) {
+	if e.Conjunction == "" {
+		return b.condition(e)
+	}
+	if len(e.Children) == 0 {
+		return "TRUE", nil
+	}
+
+	joiner := " AND "
+	if e.Conjunction == "any" {
+		joiner = " OR "
+	}
+
+	parts := make([]string, len(e.Children))
+	for i, child := range e.Children {
+		part, err := b.expression(child)
+		if err != nil {
+			return "", err
+		}
+		parts[i] = part
+	}
+	return "(" + strings.Join(parts, joiner) + ")", nil
+}
+
+func (b *builder) condition(e Expression) (string, error) {
+	f, ok := fields[e.Field]
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", e.Field)
+	}
+
+	switch f.kind {
+	case textField:
+		return b.textCondition(f.column, e)
+	case numberField:
+		return b.numberCondition(f.column, e)
+	case dateField:
+		return b.dateCondition(f.column, e)
+	default:
+		return b.boolCondition(f.column, e)
+	}
+}
+
+func (b *builder) textCondition(column string, e Expression) (string, error) {
+	value, ok := e.Value.(string)
+	if !ok {
+		return "", fmt.Errorf("field %q expects text, got %v", e.Field, e.Value)
+	}
+
+	switch e.Operator {
+	case "is":
+		return fmt.Sprintf("LOWER(%s) = LOWER(%s)", column, b.arg(value)), nil
+	case "isnot":
+		return fmt.Sprintf("(%s IS NULL OR LOWER(%s) <> LOWER(%s))", column, column, b.arg(value)), nil
+	case "contains":
+		return fmt.Sprintf("%s ILIKE %s", column, b.arg("%"+escapeLike(value)+"%")), nil
+	case "notcontains":
+		return fmt.Sprintf("(%s IS NULL OR %s NOT ILIKE %s)", column, column, b.arg("%"+escapeLike(value)+"%")), nil
+	case "startswith":
+		return fmt.Sprintf("%s ILIKE %s", column, b.arg(escapeLike(value)+"%")), nil
+	case "endswith":
+		return fmt.Sprintf("%s ILIKE %s", column, b.arg("%"+escapeLike(value))), nil
+	}
+	return "", unsupported(e)
+}
+
+func (b *builder) numberCondition(column string, e Expression) (string, error) {
+	if e.Operator == "intherange" {
+		from, to, err := numberRange(e)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s BETWEEN %s AND %s", column, b.arg(from), b.arg(to)), nil
+	}
+
+	value, err := number(e.Value)
+	if err != nil {
+		return "", fmt.Errorf("field %q: %w", e.Field, err)
+	}
+
+	switch e.Operator {
+	case "is":
+		return fmt.Sprintf("%s = %s", column, b.arg(value)), nil
+	case "isnot":
+		return fmt.Sprintf("(%s IS NULL OR %s <> %s)", column, column, b.arg(value)), nil
+	case "gt":
+		return fmt.Sprintf("%s > %s", column, b.arg(value)), nil
+	case "lt":
+		return fmt.Sprintf("%s < %s", column, b.arg(value)), nil
+	}
+	return "", unsupported(e)
+}
+
+func (b *builder) dateCondition(column string, e Expression) (string, error) {
+	switch e.Operator {
+	case "inthelast", "notinthelast":
+		days, err := number(e.Value)
+		if err != nil {
+			return "", fmt.Errorf("field %q expects a number of days: %w", e.Field, err)
+		}
+		since := fmt.Sprintf("NOW() - (%s * INTERVAL '1 day')", b.arg(days))
+		if e.Operator == "inthelast" {
+			return fmt.Sprintf("%s >= %s", column, since), nil
+		}
+		return fmt.Sprintf("(%s IS NULL OR %s < %s)", column, column, since), nil
+	case "intherange":
+		values, ok := e.Value.([]interface{})
+		if !ok || len(values) != 2 {
+			return "", fmt.Errorf("field %q expects a range of two dates", e.Field)
+		}
+		from, err := date(values[0])
+		if err != nil {
+			return "", err
+		}
+		to, err := date(values[1])
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s::date BETWEEN %s AND %s", column, b.arg(from), b.arg(to)), nil
+	}
+
+	value, err := date(e.Value)
+	if err != nil {
+		return "", fmt.Errorf("field %q: %w", e.Field, err)
+	}
+
+	switch e.Operator {
+	case "is":
+		return fmt.Sprintf("%s::date = %s", column, b.arg(value)), nil
+	case "isnot":
+		return fmt.Sprintf("(%s IS NULL OR %s::date <> %s)", column, column, b.arg(value)), nil
+	case "before", "lt":
+		return fmt.Sprintf("%s < %s", column, b.arg(value)), nil
+	case "after", "gt":
+		return fmt.Sprintf("%s > %s", column, b.arg(value)), nil
+	}
+	return "", unsupported(e)
+}
+
+func (b *builder) boolCondition(column string, e Expression) (string, error) {
+	value, ok := e.Value.(bool)
+	if !ok {
+		return "", fmt.Errorf("field %q expects true or false, got %v", e.Field, e.Value)
+	}
+
+	switch e.Operator {
+	case "is":
+		return fmt.Sprintf("%s = %s", column, b.arg(value)), nil
+	case "isnot":
+		return fmt.Sprintf("%s <> %s", column, b.arg(value)), nil
+	}
+	return "", unsupported(e)
+}
+
+// orderBy translates the sort fields. Fields sort ascending unless prefixed with "-"
+// or order is "desc"; NULLs (never played, unrated) always come last.
+func orderBy(sortFields, order string) (string, error) {
+	if strings.TrimSpace(sortFields) == "" {
+		sortFields = "title"
+	}
+	descending := strings.EqualFold(order, "desc")
+
+	var parts []string
+	for _, name := range strings.Split(sortFields, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		desc := descending
+		switch {
+		case strings.HasPrefix(name, "-"):
+			desc, name = !descending, name[1:]
+		case strings.HasPrefix(name, "+"):
+			name = name[1:]
+		}
+
+		if name == "random" {
+			parts = append(parts, "RANDOM()")
+			continue
+		}
+		f, ok := fields[name]
+		if !ok {
+			return "", fmt.Errorf("unknown sort field %q", name)
+		}
+		direction := "ASC"
+		if desc {
+			direction = "DESC"
+		}
+		parts = append(parts, f.column+" "+direction+" NULLS LAST")
+	}
+	return strings.Join(append(parts, "s.id"), ", "), nil
+}
+
+func number(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	}
+	return 0, fmt.Errorf("expected a number, got %v", value)
+}
+
+func numberRange(e Expression) (float64, float64, error) {
+	values, ok := e.Value.([]interface{})
+	if !ok || len(values) != 2 {
+		return 0, 0, fmt.Errorf("field %q expects a range of two numbers", e.Field)
+	}
+	from, err := number(values[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := number(values[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+func date(value interface{}) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a date, got %v", value)
+	}
+	parsed, err := time.Parse("2006-01-02", text)
+	if err != nil {
+		return "", fmt.Errorf("expected a date as YYYY-MM-DD, got %q", text)
+	}
+	return parsed.Format("2006-01-02"), nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+func unsupported(e Expression) error {
+	return fmt.Errorf("operator %q cannot be used with field %q", e.Operator, e.Field)
+}
@@ -0,0 +1,246 @@
+package stats
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// DefaultLimit and MaxLimit bound the length of the top lists of a report
+	DefaultLimit = 10
+	MaxLimit     = 100
+)
+
+// plays is the FROM clause of the plays of user $1 between $2 and $3. Plays through
+// public shares are logged against the owner but are not the owner's listening.
+const plays = `
+	FROM play_history ph
+	JOIN songs s ON s.id = ph.song_id
+	JOIN artists ar ON ar.id = s.artist_id
+	JOIN albums al ON al.id = s.album_id
+	WHERE ph.user_id = $1 AND ph.played_at >= $2 AND ph.played_at < $3 AND ph.share_id IS NULL`
+
+// playSeconds is how long a play lasted, the whole song when the client did not say
+const playSeconds = "COALESCE(ph.duration_played, s.duration, 0)"
+
+// top is the grouping of one of the top lists of a report
+type top struct {
+	columns string // id, name and artist
+	groupBy string
+	where   string
+}
+
+var (
+	topArtists = top{columns: "ar.id, ar.name, ''", groupBy: "ar.id, ar.name"}
+	topAlbums  = top{
+		columns: "al.id, al.name, COALESCE((SELECT name FROM artists WHERE id = al.artist_id), '')",
+		groupBy: "al.id, al.name, al.artist_id",
+	}
+	topSongs  = top{columns: "s.id, s.title, ar.name", groupBy: "s.id, s.title, ar.name"}
+	topGenres = top{columns: "0, al.genre, ''", groupBy: "al.genre", where: " AND al.genre <> ''"}
+)
+
+// Item is an entry of a top list
+type Item struct {
+	ID      int    `json:"id,omitempty"`
+	Name    string `json:"name"`
+	Artist  string `json:"artist,omitempty"`
+	Plays   int    `json:"plays"`
+	Seconds int    `json:"seconds"`
+}
+
+// Discoveries counts the artists, albums and songs first played in a period
+type Discoveries struct {
+	Artists int `json:"artists"`
+	Albums  int `json:"albums"`
+	Songs   int `json:"songs"`
+}
+
+// Report is the listening of a user in a period
+type Report struct {
+	Period        Period      `json:"period"`
+	Plays         int         `json:"plays"`
+	Seconds       int         `json:"seconds"`
+	TopArtists    []Item      `json:"topArtists"`
+	TopAlbums     []Item      `json:"topAlbums"`
+	TopSongs      []Item      `json:"topSongs"`
+	TopGenres     []Item      `json:"topGenres"`
+	ByHour        []int       `json:"byHour"`    // plays per hour of the day
+	ByWeekday     []int       `json:"byWeekday"` // plays per day of the week, from Sunday
+	CurrentStreak int         `json:"currentStreak"`
+	LongestStreak int         `json:"longestStreak"`
+	Discoveries   Discoveries `json:"discoveries"`
+}
+
+// PeakHour returns the hour of the day with the most plays
+func (r *Report) PeakHour() int {
+	return peak(r.ByHour)
+}
+
+// PeakWeekday returns the day of the week with the most plays, 0 being Sunday
+func (r *Report) PeakWeekday() int {
+	return peak(r.ByWeekday)
+}
+
+// peak returns the index of the largest count, the first one on ties
+func peak(counts []int) int {
+	best := 0
+	for i, count := range counts {
+		if count > counts[best] {
+			best = i
+		}
+	}
+	return best
+}
+
+// Engine computes listening statistics from play_history
+type Engine struct {
+	db *sql.DB
+}
+
+func NewEngine(db *sql.DB) *Engine {
+	return &Engine{db: db}
+}
+
+// Report returns the listening of a user in a period, with top lists of up to limit items
+func (e *Engine) Report(userID int, p Period, limit int) (*Report, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	r := &Report{Period: p, ByHour: make([]int, 24), ByWeekday: make([]int, 7)}
+	args := []interface{}{userID, p.From, p.To}
+
+	err := e.db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(`+playSeconds+`), 0)`+plays, args...).
+		Scan(&r.Plays, &r.Seconds)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, list := range []struct {
+		top  top
+		dest *[]Item
+	}{
+		{topArtists, &r.TopArtists},
+		{topAlbums, &r.TopAlbums},
+		{topSongs, &r.TopSongs},
+		{topGenres, &r.TopGenres},
+	} {
+		if *list.dest, err = e.top(list.top, limit, args); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.countBy("EXTRACT(HOUR FROM ph.played_at)", r.ByHour, 0, args); err != nil {
+		return nil, err
+	}
+	if err := e.countBy("EXTRACT(DOW FROM ph.played_at)", r.ByWeekday, 0, args); err != nil {
+		return nil, err
+	}
+
+	days, err := e.days(args)
+	if err != nil {
+		return nil, err
+	}
+	// Streaks are measured at the end of the period
+	r.CurrentStreak, r.LongestStreak = Streaks(days, p.To.Add(-time.Nanosecond))
+
+	for _, count := range []struct {
+		column string
+		dest   *int
+	}{
+		{"s.artist_id", &r.Discoveries.Artists},
+		{"s.album_id", &r.Discoveries.Albums},
+		{"s.id", &r.Discoveries.Songs},
+	} {
+		if *count.dest, err = e.discoveries(count.column, args); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+func (e *Engine) top(t top, limit int, args []interface{}) ([]Item, error) {
+	rows, err := e.db.Query(`
+		SELECT `+t.columns+`, COUNT(*), SUM(`+playSeconds+`)`+plays+t.where+`
+		GROUP BY `+t.groupBy+`
+		ORDER BY COUNT(*) DESC, SUM(`+playSeconds+`) DESC, 2
+		LIMIT $4
+	`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []Item{}
+	for rows.Next() {
+		var item Item
+		if err := rows.Scan(&item.ID, &item.Name, &item.Artist, &item.Plays, &item.Seconds); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// countBy adds the plays per value of expr, such as the hour of the day, to counts.
+// Values are shifted by offset to index counts.
+func (e *Engine) countBy(expr string, counts []int, offset int, args []interface{}) error {
+	rows, err := e.db.Query(`SELECT `+expr+`::int AS value, COUNT(*)`+plays+` GROUP BY value`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value, count int
+		if err := rows.Scan(&value, &count); err != nil {
+			return err
+		}
+		if i := value - offset; i >= 0 && i < len(counts) {
+			counts[i] += count
+		}
+	}
+	return rows.Err()
+}
+
+// days returns the days with plays in ascending order
+func (e *Engine) days(args []interface{}) ([]time.Time, error) {
+	rows, err := e.db.Query(`SELECT DISTINCT ph.played_at::date AS day`+plays+` ORDER BY day`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var day time.Time
+		if err := rows.Scan(&day); err != nil {
+			return nil, err
+		}
+		days = append(days, day)
+	}
+	return days, rows.Err()
+}
+
+// discoveries counts the items grouped by column, such as s.artist_id, whose first play
+// by the user falls in the period
+func (e *Engine) discoveries(column string, args []interface{}) (int, error) {
+	var count int
+	err := e.db.QueryRow(fmt.Sprintf(`
+		SELECT COUNT(*) FROM (
+			SELECT MIN(ph.played_at) AS first_played
+			FROM play_history ph
+			JOIN songs s ON s.id = ph.song_id
+			WHERE ph.user_id = $1 AND ph.played_at < $3 AND ph.share_id IS NULL
+			GROUP BY %s
+		) f
+		WHERE f.first_played >= $2
+	`, column), args...).Scan(&count)
+	return count, err
+}
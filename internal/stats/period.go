@@ -0,0 +1,87 @@
+package stats
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultPeriod is the period reports cover when none is given
+const DefaultPeriod = "month"
+
+// relativePeriods are the periods that end now, by their length in days
+var relativePeriods = map[string]int{
+	"week":  7,
+	"month": 30,
+	"year":  365,
+}
+
+// Period is the range of time a report counts plays in. From is inclusive and To
+// exclusive; a zero From means since the first play.
+type Period struct {
+	Name string    `json:"name"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// ParsePeriod returns the period named by value: week, month and year end now, all
+// covers every play, and a year such as 2024 or a month such as 2024-05 cover that
+// calendar year or month.
+func ParsePeriod(value string, now time.Time) (Period, error) {
+	if value == "" {
+		value = DefaultPeriod
+	}
+
+	if days, ok := relativePeriods[value]; ok {
+		return Period{Name: value, From: now.AddDate(0, 0, -days), To: now}, nil
+	}
+	if value == "all" {
+		return Period{Name: value, To: now}, nil
+	}
+	if start, err := time.ParseInLocation("2006", value, now.Location()); err == nil {
+		return Period{Name: value, From: start, To: start.AddDate(1, 0, 0)}, nil
+	}
+	if start, err := time.ParseInLocation("2006-01", value, now.Location()); err == nil {
+		return Period{Name: value, From: start, To: start.AddDate(0, 1, 0)}, nil
+	}
+	return Period{}, fmt.Errorf("unknown period %q", value)
+}
+
+// Year returns the period of a calendar year
+func Year(year int, loc *time.Location) Period {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+	return Period{Name: fmt.Sprint(year), From: start, To: start.AddDate(1, 0, 0)}
+}
+
+// Streaks returns the current and the longest runs of consecutive days with plays.
+// days are the days with plays in ascending order. The current streak is the run that
+// ends on today or yesterday, as today may still get plays.
+func Streaks(days []time.Time, today time.Time) (current, longest int) {
+	var last time.Time
+	run := 0
+	for _, day := range days {
+		day = dateOf(day)
+		switch {
+		case run > 0 && day.Equal(last):
+			continue
+		case run > 0 && day.Equal(last.AddDate(0, 0, 1)):
+			run++
+		default:
+			run = 1
+		}
+		last = day
+		if run > longest {
+			longest = run
+		}
+	}
+
+	today = dateOf(today)
+	if run > 0 && (last.Equal(today) || last.Equal(today.AddDate(0, 0, -1))) {
+		current = run
+	}
+	return current, longest
+}
+
+// dateOf drops the time of day, so days can be compared across time zones
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
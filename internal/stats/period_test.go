@@ -0,0 +1,90 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParsePeriod(t *testing.T) {
+	now := time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		value    string
+		name     string
+		from, to time.Time
+	}{
+		{"", "month", now.AddDate(0, 0, -30), now},
+		{"week", "week", now.AddDate(0, 0, -7), now},
+		{"year", "year", now.AddDate(0, 0, -365), now},
+		{"all", "all", time.Time{}, now},
+		{"2023", "2023", day(2023, 1, 1), day(2024, 1, 1)},
+		{"2023-12", "2023-12", day(2023, 12, 1), day(2024, 1, 1)},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePeriod(tt.value, now)
+		if err != nil {
+			t.Errorf("ParsePeriod(%q) error = %v", tt.value, err)
+			continue
+		}
+		if p.Name != tt.name || !p.From.Equal(tt.from) || !p.To.Equal(tt.to) {
+			t.Errorf("ParsePeriod(%q) = %+v, want %s from %v to %v", tt.value, p, tt.name, tt.from, tt.to)
+		}
+	}
+
+	for _, value := range []string{"decade", "2023-13", "05-2023", "24"} {
+		if _, err := ParsePeriod(value, now); err == nil {
+			t.Errorf("ParsePeriod(%q) accepted an invalid period", value)
+		}
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2024, 5, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name             string
+		days             []time.Time
+		today            time.Time
+		current, longest int
+	}{
+		{"no plays", nil, day(31), 0, 0},
+		{"one day", []time.Time{day(31)}, day(31), 1, 1},
+		{"alive from yesterday", []time.Time{day(28), day(29), day(30)}, day(31), 3, 3},
+		{"broken", []time.Time{day(27), day(28)}, day(31), 0, 2},
+		{"longest in the past", []time.Time{day(1), day(2), day(3), day(4), day(30), day(31)}, day(31), 2, 4},
+		{"duplicated days", []time.Time{day(30), day(30), day(31)}, day(31), 2, 2},
+		{"across months", []time.Time{time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC), day(1)}, day(1), 2, 2},
+	}
+
+	for _, tt := range tests {
+		current, longest := Streaks(tt.days, tt.today)
+		if current != tt.current || longest != tt.longest {
+			t.Errorf("%s: Streaks() = %d, %d; want %d, %d", tt.name, current, longest, tt.current, tt.longest)
+		}
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	r := &Report{ByHour: make([]int, 24), ByWeekday: []int{0, 3, 3, 1, 0, 0, 0}}
+	r.ByHour[22] = 5
+	if got := r.PeakHour(); got != 22 {
+		t.Errorf("PeakHour() = %d, want 22", got)
+	}
+	// Ties go to the first day
+	if got := r.PeakWeekday(); got != 1 {
+		t.Errorf("PeakWeekday() = %d, want 1", got)
+	}
+}
+
+func TestPeakMonth(t *testing.T) {
+	w := &Wrapped{ByMonth: []int{3, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 9}}
+	if got := w.PeakMonth(); got != 12 {
+		t.Errorf("PeakMonth() = %d, want 12", got)
+	}
+}
@@ -0,0 +1,55 @@
+package stats
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Wrapped is the yearly summary of the listening of a user
+type Wrapped struct {
+	*Report
+	Year            int    `json:"year"`
+	Username        string `json:"username"`
+	ByMonth         []int  `json:"byMonth"`              // plays per month, from January
+	BusiestDay      string `json:"busiestDay,omitempty"` // the day with the most plays, as 2024-05-31
+	BusiestDayPlays int    `json:"busiestDayPlays"`
+}
+
+// PeakMonth returns the month with the most plays, 1 being January
+func (w *Wrapped) PeakMonth() int {
+	return peak(w.ByMonth) + 1
+}
+
+// Wrapped returns the summary of a calendar year for a user
+func (e *Engine) Wrapped(userID, year, limit int) (*Wrapped, error) {
+	report, err := e.Report(userID, Year(year, time.Local), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Wrapped{Report: report, Year: year, ByMonth: make([]int, 12)}
+	if err := e.db.QueryRow("SELECT username FROM users WHERE id = $1", userID).Scan(&w.Username); err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{userID, report.Period.From, report.Period.To}
+	if err := e.countBy("EXTRACT(MONTH FROM ph.played_at)", w.ByMonth, 1, args); err != nil {
+		return nil, err
+	}
+
+	var day time.Time
+	err = e.db.QueryRow(`
+		SELECT ph.played_at::date AS day, COUNT(*)`+plays+`
+		GROUP BY day
+		ORDER BY COUNT(*) DESC, day
+		LIMIT 1
+	`, args...).Scan(&day, &w.BusiestDayPlays)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil {
+		w.BusiestDay = day.Format("2006-01-02")
+	}
+
+	return w, nil
+}
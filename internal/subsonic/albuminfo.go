@@ -0,0 +1,132 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"path/filepath"
+	"time"
+
+	"castafiore-backend/internal/metadata"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// albumInfoTTL is how long stored album info is served before asking the agents again
+	albumInfoTTL = 30 * 24 * time.Hour
+	// albumInfoMissingTTL is how long an album without any info waits before a new lookup
+	albumInfoMissingTTL = 24 * time.Hour
+)
+
+// GetAlbumInfo - Returns album notes, image URLs etc, using data from the metadata agents.
+// Serves both getAlbumInfo and getAlbumInfo2, since directories and ID3 albums share IDs.
+func (s *Service) GetAlbumInfo(c *gin.Context) {
+	id := c.Query("id")
+	if !s.isValidID(id) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	var albumName, artistName string
+	var releaseMBID, filePath sql.NullString
+	err := s.db.QueryRow(`
+		SELECT al.name, ar.name,
+		       (SELECT mbz_release_id FROM songs WHERE album_id = al.id AND mbz_release_id IS NOT NULL LIMIT 1),
+		       (SELECT file_path FROM songs WHERE album_id = al.id LIMIT 1)
+		FROM albums al
+		JOIN artists ar ON al.artist_id = ar.id
+		WHERE al.id = $1
+	`, id).Scan(&albumName, &artistName, &releaseMBID, &filePath)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			s.sendError(c, 70, "Album not found")
+		} else {
+			log.Printf("GetAlbumInfo: Database error: %v", err)
+			s.sendError(c, 0, "Database error")
+		}
+		return
+	}
+
+	if info, ok := s.loadAlbumInfo(id); ok {
+		s.sendResponse(c, info)
+		return
+	}
+
+	album := metadata.Album{
+		Name:   albumName,
+		Artist: artistName,
+		MBID:   releaseMBID.String,
+	}
+	if filePath.Valid {
+		album.Folder = filepath.Dir(s.libraryPath(filePath.String))
+	}
+
+	result := &AlbumInfo{MusicBrainzID: releaseMBID.String}
+	if info, err := s.agents.AlbumInfo(album); err == nil {
+		result.Notes = info.Notes
+		result.LastFmUrl = info.URL
+		result.SmallImageUrl = info.SmallImageURL
+		result.MediumImageUrl = info.MediumImageURL
+		result.LargeImageUrl = info.LargeImageURL
+		// The MBID from the tags identifies the exact release, keep it over the agent one
+		if result.MusicBrainzID == "" {
+			result.MusicBrainzID = info.MBID
+		}
+	} else {
+		log.Printf("GetAlbumInfo: No album info for %s - %s: %v", artistName, albumName, err)
+	}
+
+	s.storeAlbumInfo(id, result)
+	s.sendResponse(c, result)
+}
+
+// loadAlbumInfo returns the stored album info while it is still fresh
+func (s *Service) loadAlbumInfo(albumId string) (*AlbumInfo, bool) {
+	var notes, mbid, lastFmUrl, small, medium, large sql.NullString
+	var fetchedAt time.Time
+
+	err := s.db.QueryRow(`
+		SELECT notes, musicbrainz_id, lastfm_url, small_image_url, medium_image_url, large_image_url, fetched_at
+		FROM album_info
+		WHERE album_id = $1
+	`, albumId).Scan(&notes, &mbid, &lastFmUrl, &small, &medium, &large, &fetchedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error loading album info for %s: %v", albumId, err)
+		}
+		return nil, false
+	}
+
+	info := &AlbumInfo{
+		Notes:          notes.String,
+		MusicBrainzID:  mbid.String,
+		LastFmUrl:      lastFmUrl.String,
+		SmallImageUrl:  small.String,
+		MediumImageUrl: medium.String,
+		LargeImageUrl:  large.String,
+	}
+
+	ttl := albumInfoTTL
+	if info.Notes == "" && info.LastFmUrl == "" && info.LargeImageUrl == "" {
+		ttl = albumInfoMissingTTL
+	}
+	if time.Since(fetchedAt) > ttl {
+		return nil, false
+	}
+	return info, true
+}
+
+func (s *Service) storeAlbumInfo(albumId string, info *AlbumInfo) {
+	_, err := s.db.Exec(`
+		INSERT INTO album_info (album_id, notes, musicbrainz_id, lastfm_url, small_image_url,
+		                        medium_image_url, large_image_url, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())
+		ON CONFLICT (album_id)
+		DO UPDATE SET notes = $2, musicbrainz_id = $3, lastfm_url = $4, small_image_url = $5,
+		              medium_image_url = $6, large_image_url = $7, fetched_at = NOW()
+	`, albumId, info.Notes, info.MusicBrainzID, info.LastFmUrl, info.SmallImageUrl,
+		info.MediumImageUrl, info.LargeImageUrl)
+	if err != nil {
+		log.Printf("Error storing album info for %s: %v", albumId, err)
+	}
+}
@@ -0,0 +1,387 @@
+package subsonic
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// musicFolderID is the only music folder, the library itself
+	musicFolderID = 1
+	// defaultListSize and maxListSize bound the size of album lists and random songs
+	defaultListSize = 10
+	maxListSize     = 500
+	// defaultForgottenMonths is how long starred albums must go unplayed to be forgotten
+	defaultForgottenMonths = 6
+)
+
+// sqlArgs numbers the arguments of a query as they are added
+type sqlArgs []interface{}
+
+func (a *sqlArgs) add(value interface{}) string {
+	*a = append(*a, value)
+	return "$" + strconv.Itoa(len(*a))
+}
+
+// libraryFilter holds the genre, year and music folder filters shared by album lists
+// and random songs
+type libraryFilter struct {
+	genre                  string
+	fromYear, toYear       int
+	hasFromYear, hasToYear bool
+	// otherFolder is set when a music folder other than the library was requested,
+	// which matches nothing
+	otherFolder bool
+}
+
+// paramError is an invalid or missing parameter, reported with error code 10
+type paramError string
+
+func (e paramError) Error() string { return string(e) }
+
+// parseLibraryFilter reads the genre, fromYear, toYear and musicFolderId parameters
+func parseLibraryFilter(c *gin.Context) (libraryFilter, error) {
+	f := libraryFilter{genre: c.Query("genre")}
+	var err error
+
+	if value := c.Query("fromYear"); value != "" {
+		if f.fromYear, err = strconv.Atoi(value); err != nil {
+			return f, paramError("Parameter 'fromYear' is invalid")
+		}
+		f.hasFromYear = true
+	}
+	if value := c.Query("toYear"); value != "" {
+		if f.toYear, err = strconv.Atoi(value); err != nil {
+			return f, paramError("Parameter 'toYear' is invalid")
+		}
+		f.hasToYear = true
+	}
+	if value := c.Query("musicFolderId"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			return f, paramError("Parameter 'musicFolderId' is invalid")
+		}
+		f.otherFolder = id != musicFolderID
+	}
+	return f, nil
+}
+
+// conditions returns the SQL conditions of the filter over the al (albums) alias
+func (f libraryFilter) conditions(args *sqlArgs) []string {
+	var conds []string
+	if f.otherFolder {
+		conds = append(conds, "FALSE")
+	}
+	if f.genre != "" {
+		conds = append(conds, "LOWER(al.genre) = LOWER("+args.add(f.genre)+")")
+	}
+	switch {
+	case f.hasFromYear && f.hasToYear:
+		// A descending range such as 2000..1990 covers the same years
+		conds = append(conds, fmt.Sprintf("al.year BETWEEN SYMMETRIC %s AND %s", args.add(f.fromYear), args.add(f.toYear)))
+	case f.hasFromYear:
+		conds = append(conds, "al.year >= "+args.add(f.fromYear))
+	case f.hasToYear:
+		conds = append(conds, "al.year <= "+args.add(f.toYear))
+	}
+	return conds
+}
+
+// albumList is a request for one of the album list types of getAlbumList and getAlbumList2.
+// Besides the types of the specification it supports the discovery types byDecade
+// (decade), forgottenFavorites (months) and newFromPlayedArtists.
+type albumList struct {
+	listType string
+	userId   int
+	size     int
+	offset   int
+	filter   libraryFilter
+	decade   int
+	months   int
+}
+
+// errUnknownListType is returned for list types that are not in the specification
+var errUnknownListType = errors.New("unknown album list type")
+
+// query builds the album query of the list. Every order ends with the album ID so
+// pages never overlap or skip albums that sort equal.
+func (l albumList) query() (string, []interface{}, error) {
+	var args sqlArgs
+	var join, order string
+	var conds []string
+
+	switch l.listType {
+	case "random":
+		order = "RANDOM()"
+	case "newest":
+		order = "al.created_at DESC, al.id DESC"
+	case "highest":
+		join = "JOIN album_ratings r ON r.album_id = al.id AND r.user_id = " + args.add(l.userId)
+		order = "r.rating DESC, r.updated_at DESC, al.id"
+	case "frequent", "recent":
+		join = `JOIN (
+			SELECT s.album_id, COUNT(*) AS play_count, MAX(ph.played_at) AS last_played
+			FROM play_history ph
+			JOIN songs s ON s.id = ph.song_id
+			WHERE ph.user_id = ` + args.add(l.userId) + ` AND ph.share_id IS NULL
+			GROUP BY s.album_id
+		) pc ON pc.album_id = al.id`
+		if l.listType == "frequent" {
+			order = "pc.play_count DESC, pc.last_played DESC, al.id"
+		} else {
+			order = "pc.last_played DESC, al.id"
+		}
+	case "alphabeticalByName":
+		order = "al.name, al.id"
+	case "alphabeticalByArtist":
+		order = "ar.name, al.name, al.id"
+	case "starred":
+		join = "JOIN starred_albums sa ON sa.album_id = al.id AND sa.user_id = " + args.add(l.userId)
+		order = "sa.starred_at DESC, al.id"
+	case "byYear":
+		if !l.filter.hasFromYear || !l.filter.hasToYear {
+			return "", nil, paramError("Required parameters 'fromYear' and 'toYear' are missing")
+		}
+		order = "al.year, al.name, al.id"
+		if l.filter.fromYear > l.filter.toYear {
+			order = "al.year DESC, al.name, al.id"
+		}
+	case "byGenre":
+		if l.filter.genre == "" {
+			return "", nil, paramError("Required parameter 'genre' is missing")
+		}
+		order = "al.name, al.id"
+	case "byDecade":
+		if l.decade <= 0 {
+			return "", nil, paramError("Required parameter 'decade' is missing")
+		}
+		conds = append(conds, fmt.Sprintf("al.year BETWEEN %s AND %s", args.add(l.decade), args.add(l.decade+9)))
+		order = "al.year, al.name, al.id"
+	case "forgottenFavorites":
+		// Starred albums, or albums with starred songs, not played for months
+		user := args.add(l.userId)
+		join = `LEFT JOIN (
+			SELECT s.album_id, MAX(ph.played_at) AS last_played
+			FROM play_history ph
+			JOIN songs s ON s.id = ph.song_id
+			WHERE ph.user_id = ` + user + ` AND ph.share_id IS NULL
+			GROUP BY s.album_id
+		) pc ON pc.album_id = al.id`
+		conds = append(conds, `(EXISTS (SELECT 1 FROM starred_albums sa WHERE sa.album_id = al.id AND sa.user_id = `+user+`)
+			OR EXISTS (
+				SELECT 1 FROM starred_songs ss JOIN songs s ON s.id = ss.song_id
+				WHERE s.album_id = al.id AND ss.user_id = `+user+`
+			))`,
+			"(pc.last_played IS NULL OR pc.last_played < NOW() - make_interval(months => "+args.add(l.months)+"))")
+		order = "pc.last_played NULLS FIRST, al.id"
+	case "newFromPlayedArtists":
+		// The newest albums the user has not played by artists the user plays
+		user := args.add(l.userId)
+		conds = append(conds,
+			`al.artist_id IN (
+				SELECT s.artist_id FROM play_history ph JOIN songs s ON s.id = ph.song_id
+				WHERE ph.user_id = `+user+` AND ph.share_id IS NULL
+			)`,
+			`NOT EXISTS (
+				SELECT 1 FROM play_history ph JOIN songs s ON s.id = ph.song_id
+				WHERE s.album_id = al.id AND ph.user_id = `+user+` AND ph.share_id IS NULL
+			)`)
+		order = "al.created_at DESC, al.id DESC"
+	default:
+		return "", nil, errUnknownListType
+	}
+
+	conds = append(conds, l.filter.conditions(&args)...)
+	where := "TRUE"
+	if len(conds) > 0 {
+		where = strings.Join(conds, " AND ")
+	}
+
+	query := `
+		SELECT al.id, al.name, al.artist_id, al.year, al.genre, al.created_at,
+		       ar.name, COALESCE(st.song_count, 0), COALESCE(st.duration, 0), al.cover_art_path
+		FROM albums al
+		JOIN artists ar ON al.artist_id = ar.id
+		LEFT JOIN LATERAL (
+			SELECT COUNT(*) AS song_count, SUM(duration) AS duration
+			FROM songs WHERE album_id = al.id
+		) st ON TRUE
+		` + join + `
+		WHERE ` + where + `
+		ORDER BY ` + order + `
+		LIMIT ` + args.add(l.size) + ` OFFSET ` + args.add(l.offset)
+	return query, args, nil
+}
+
+// parseAlbumList reads the parameters of getAlbumList and getAlbumList2
+func (s *Service) parseAlbumList(c *gin.Context) (albumList, error) {
+	filter, err := parseLibraryFilter(c)
+	if err != nil {
+		return albumList{}, err
+	}
+
+	l := albumList{
+		listType: c.DefaultQuery("type", "newest"),
+		userId:   s.getUserID(c),
+		size:     parseIntDefault(c.Query("size"), defaultListSize),
+		offset:   parseIntDefault(c.Query("offset"), 0),
+		filter:   filter,
+		months:   parseIntDefault(c.Query("months"), defaultForgottenMonths),
+	}
+	if value := c.Query("decade"); value != "" {
+		// "1980" and "1980s" name the same decade, as does any year in it
+		decade, err := strconv.Atoi(strings.TrimSuffix(value, "s"))
+		if err != nil || decade <= 0 {
+			return l, paramError("Parameter 'decade' is invalid")
+		}
+		l.decade = decade - decade%10
+	}
+	if l.months <= 0 {
+		l.months = defaultForgottenMonths
+	}
+	if l.size <= 0 {
+		l.size = defaultListSize
+	}
+	if l.size > maxListSize {
+		l.size = maxListSize
+	}
+	if l.offset < 0 {
+		l.offset = 0
+	}
+	return l, nil
+}
+
+// hasContentFilter reports whether the filter restricts the genre or the years
+func (f libraryFilter) hasContentFilter() bool {
+	return f.genre != "" || f.hasFromYear || f.hasToYear
+}
+
+// loadAlbumList runs an album list. The discover type comes from the recommendations,
+// which are ranked and paged by the recommender and cannot be filtered by genre or year.
+func (s *Service) loadAlbumList(l albumList) ([]AlbumID3, error) {
+	if l.listType == "discover" {
+		if l.filter.hasContentFilter() {
+			return nil, paramError("Type 'discover' does not support the genre, fromYear and toYear filters")
+		}
+		if l.filter.otherFolder {
+			return nil, nil
+		}
+		return s.discoverAlbums(l.userId, l.size, l.offset)
+	}
+
+	query, args, err := l.query()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanAlbums(rows)
+}
+
+// sendAlbumListError reports invalid parameters with code 10 and anything else as a
+// database error
+func (s *Service) sendAlbumListError(c *gin.Context, endpoint string, err error) {
+	var invalid paramError
+	switch {
+	case errors.As(err, &invalid):
+		s.sendError(c, 10, invalid.Error())
+	case errors.Is(err, errUnknownListType):
+		s.sendError(c, 0, "Unknown list type: "+c.Query("type"))
+	default:
+		log.Printf("%s: Error loading album list: %v", endpoint, err)
+		s.sendError(c, 0, "Database error")
+	}
+}
+
+// GetAlbumList - Returns a list of albums as directories
+func (s *Service) GetAlbumList(c *gin.Context) {
+	l, err := s.parseAlbumList(c)
+	if err != nil {
+		s.sendAlbumListError(c, "GetAlbumList", err)
+		return
+	}
+	albums, err := s.loadAlbumList(l)
+	if err != nil {
+		s.sendAlbumListError(c, "GetAlbumList", err)
+		return
+	}
+
+	children := make([]Child, 0, len(albums))
+	for _, album := range albums {
+		children = append(children, Child{
+			ID:       album.ID,
+			Parent:   album.ArtistID,
+			IsDir:    true,
+			Title:    album.Name,
+			Album:    album.Name,
+			AlbumId:  album.ID,
+			Artist:   album.Artist,
+			Year:     album.Year,
+			Genre:    album.Genre,
+			CoverArt: album.CoverArt,
+			Duration: album.Duration,
+		})
+	}
+
+	s.annotator(l.userId).addChildren(children).load()
+	s.sendResponse(c, &AlbumList{Album: children})
+}
+
+// GetAlbumList2 - Returns a list of albums organized by ID3 tags
+func (s *Service) GetAlbumList2(c *gin.Context) {
+	l, err := s.parseAlbumList(c)
+	if err != nil {
+		s.sendAlbumListError(c, "GetAlbumList2", err)
+		return
+	}
+	albums, err := s.loadAlbumList(l)
+	if err != nil {
+		s.sendAlbumListError(c, "GetAlbumList2", err)
+		return
+	}
+	if albums == nil {
+		albums = []AlbumID3{}
+	}
+
+	s.annotator(l.userId).addAlbums(albums).load()
+	s.sendResponse(c, &AlbumList2{Album: albums})
+}
+
+// scanAlbums reads album rows selecting id, name, artist_id, year, genre, created_at,
+// artist name, song count, duration and cover_art_path
+func scanAlbums(rows *sql.Rows) ([]AlbumID3, error) {
+	var albums []AlbumID3
+	for rows.Next() {
+		var album AlbumID3
+		var createdAt time.Time
+		var year sql.NullInt32
+		var genre, coverArtPath sql.NullString
+
+		err := rows.Scan(
+			&album.ID, &album.Name, &album.ArtistID, &year, &genre, &createdAt,
+			&album.Artist, &album.SongCount, &album.Duration, &coverArtPath,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		album.Created = createdAt.Format("2006-01-02T15:04:05Z")
+		album.Year = int(year.Int32)
+		album.Genre = genre.String
+		if coverArtPath.String != "" {
+			album.CoverArt = album.ID
+		}
+		albums = append(albums, album)
+	}
+	return albums, rows.Err()
+}
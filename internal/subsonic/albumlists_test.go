@@ -0,0 +1,161 @@
+package subsonic
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAlbumListOrders(t *testing.T) {
+	tests := map[string]string{
+		"random":               "ORDER BY RANDOM()",
+		"newest":               "ORDER BY al.created_at DESC, al.id DESC",
+		"highest":              "ORDER BY r.rating DESC, r.updated_at DESC, al.id",
+		"frequent":             "ORDER BY pc.play_count DESC, pc.last_played DESC, al.id",
+		"recent":               "ORDER BY pc.last_played DESC, al.id",
+		"alphabeticalByName":   "ORDER BY al.name, al.id",
+		"alphabeticalByArtist": "ORDER BY ar.name, al.name, al.id",
+		"starred":              "ORDER BY sa.starred_at DESC, al.id",
+	}
+
+	for listType, order := range tests {
+		query, args, err := albumList{listType: listType, userId: 3, size: 20, offset: 40}.query()
+		if err != nil {
+			t.Errorf("%s: query() error = %v", listType, err)
+			continue
+		}
+		if !strings.Contains(query, order) {
+			t.Errorf("%s: query does not contain %q:\n%s", listType, order, query)
+		}
+		// Size and offset always come last
+		if got := args[len(args)-2:]; !reflect.DeepEqual(got, []interface{}{20, 40}) {
+			t.Errorf("%s: size and offset args = %v, want [20 40]", listType, got)
+		}
+		if strings.Contains(query, "user_id") && args[0] != 3 {
+			t.Errorf("%s: first arg = %v, want the user", listType, args[0])
+		}
+	}
+}
+
+func TestAlbumListByYear(t *testing.T) {
+	filter := libraryFilter{fromYear: 1990, toYear: 1999, hasFromYear: true, hasToYear: true}
+	query, args, err := albumList{listType: "byYear", size: 10, filter: filter}.query()
+	if err != nil {
+		t.Fatalf("query() error = %v", err)
+	}
+	if !strings.Contains(query, "al.year BETWEEN SYMMETRIC $1 AND $2") || !strings.Contains(query, "ORDER BY al.year, al.name") {
+		t.Errorf("ascending byYear query:\n%s", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{1990, 1999, 10, 0}) {
+		t.Errorf("args = %v", args)
+	}
+
+	// A descending range lists the same years, newest first
+	filter.fromYear, filter.toYear = 1999, 1990
+	query, _, err = albumList{listType: "byYear", size: 10, filter: filter}.query()
+	if err != nil {
+		t.Fatalf("query() error = %v", err)
+	}
+	if !strings.Contains(query, "BETWEEN SYMMETRIC") || !strings.Contains(query, "ORDER BY al.year DESC, al.name") {
+		t.Errorf("descending byYear query:\n%s", query)
+	}
+
+	_, _, err = albumList{listType: "byYear", filter: libraryFilter{fromYear: 1990, hasFromYear: true}}.query()
+	var invalid paramError
+	if !errors.As(err, &invalid) {
+		t.Errorf("byYear without toYear error = %v, want a parameter error", err)
+	}
+}
+
+func TestAlbumListByGenre(t *testing.T) {
+	query, args, err := albumList{listType: "byGenre", size: 5, filter: libraryFilter{genre: "Jazz"}}.query()
+	if err != nil {
+		t.Fatalf("query() error = %v", err)
+	}
+	if !strings.Contains(query, "LOWER(al.genre) = LOWER($1)") || args[0] != "Jazz" {
+		t.Errorf("byGenre query:\n%s\nargs %v", query, args)
+	}
+
+	_, _, err = albumList{listType: "byGenre"}.query()
+	var invalid paramError
+	if !errors.As(err, &invalid) {
+		t.Errorf("byGenre without genre error = %v, want a parameter error", err)
+	}
+}
+
+func TestAlbumListUnknownType(t *testing.T) {
+	if _, _, err := (albumList{listType: "popular"}).query(); !errors.Is(err, errUnknownListType) {
+		t.Errorf("query() error = %v, want errUnknownListType", err)
+	}
+}
+
+func TestLibraryFilterConditions(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter libraryFilter
+		want   []string
+	}{
+		{"none", libraryFilter{}, nil},
+		{"from year", libraryFilter{fromYear: 2000, hasFromYear: true}, []string{"al.year >= $1"}},
+		{"to year", libraryFilter{toYear: 2000, hasToYear: true}, []string{"al.year <= $1"}},
+		{"genre and years", libraryFilter{genre: "Rock", fromYear: 1970, toYear: 1979, hasFromYear: true, hasToYear: true},
+			[]string{"LOWER(al.genre) = LOWER($1)", "al.year BETWEEN SYMMETRIC $2 AND $3"}},
+		{"other music folder", libraryFilter{otherFolder: true}, []string{"FALSE"}},
+	}
+
+	for _, tt := range tests {
+		var args sqlArgs
+		if got := tt.filter.conditions(&args); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: conditions() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverRejectsContentFilters(t *testing.T) {
+	s := &Service{}
+	for _, filter := range []libraryFilter{{genre: "Jazz"}, {fromYear: 1990, hasFromYear: true}, {toYear: 2000, hasToYear: true}} {
+		_, err := s.loadAlbumList(albumList{listType: "discover", filter: filter})
+		var invalid paramError
+		if !errors.As(err, &invalid) {
+			t.Errorf("filter %+v: error = %v, want a parameter error", filter, err)
+		}
+	}
+}
+
+func TestDiscoveryAlbumLists(t *testing.T) {
+	query, args, err := albumList{listType: "byDecade", decade: 1980, size: 10}.query()
+	if err != nil {
+		t.Fatalf("byDecade: query() error = %v", err)
+	}
+	if !strings.Contains(query, "al.year BETWEEN $1 AND $2") || args[0] != 1980 || args[1] != 1989 {
+		t.Errorf("byDecade query:\n%s\nargs %v", query, args)
+	}
+	var invalid paramError
+	if _, _, err := (albumList{listType: "byDecade"}).query(); !errors.As(err, &invalid) {
+		t.Errorf("byDecade without decade error = %v, want a parameter error", err)
+	}
+
+	query, args, err = albumList{listType: "forgottenFavorites", userId: 4, months: 6, size: 10}.query()
+	if err != nil {
+		t.Fatalf("forgottenFavorites: query() error = %v", err)
+	}
+	if !strings.Contains(query, "make_interval(months => $2)") || !strings.Contains(query, "ORDER BY pc.last_played NULLS FIRST") {
+		t.Errorf("forgottenFavorites query:\n%s", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{4, 6, 10, 0}) {
+		t.Errorf("forgottenFavorites args = %v", args)
+	}
+
+	// The user is passed once and the library filters still apply
+	query, args, err = albumList{listType: "newFromPlayedArtists", userId: 4, size: 10, filter: libraryFilter{genre: "Rock"}}.query()
+	if err != nil {
+		t.Fatalf("newFromPlayedArtists: query() error = %v", err)
+	}
+	if strings.Count(query, "ph.user_id = $1") != 2 || !strings.Contains(query, "LOWER(al.genre) = LOWER($2)") {
+		t.Errorf("newFromPlayedArtists query:\n%s", query)
+	}
+	if !reflect.DeepEqual(args, []interface{}{4, "Rock", 10, 0}) {
+		t.Errorf("newFromPlayedArtists args = %v", args)
+	}
+}
@@ -0,0 +1,225 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// annotationTimeFormat formats the starred and played attributes
+const annotationTimeFormat = "2006-01-02T15:04:05Z"
+
+// annotation is the state of an item for one user
+type annotation struct {
+	starred   sql.NullTime
+	rating    sql.NullInt64
+	playCount sql.NullInt64
+	played    sql.NullTime
+	bookmark  sql.NullInt64
+}
+
+// annotationFields points to the fields of a response item that hold its annotations.
+// Items without some of them, like artists that have no play count, leave those nil.
+type annotationFields struct {
+	starred    *string
+	userRating *int
+	playCount  *int64
+	played     *string
+	bookmark   *int64
+}
+
+// apply copies the annotation into the fields of an item
+func (f annotationFields) apply(a annotation) {
+	if f.starred != nil && a.starred.Valid {
+		*f.starred = a.starred.Time.Format(annotationTimeFormat)
+	}
+	if f.userRating != nil && a.rating.Valid {
+		*f.userRating = int(a.rating.Int64)
+	}
+	if f.playCount != nil && a.playCount.Valid {
+		*f.playCount = a.playCount.Int64
+	}
+	if f.played != nil && a.played.Valid {
+		*f.played = a.played.Time.Format(annotationTimeFormat)
+	}
+	if f.bookmark != nil && a.bookmark.Valid {
+		*f.bookmark = a.bookmark.Int64
+	}
+}
+
+// annotationTargets collects the items of one kind by ID, keeping the order in which
+// IDs were first seen. The same ID may appear several times in a response.
+type annotationTargets struct {
+	ids    []string
+	fields map[string][]annotationFields
+}
+
+func (t *annotationTargets) add(id string, fields annotationFields) {
+	// Podcast episodes and other items with prefixed IDs have no annotations
+	if _, err := strconv.Atoi(id); err != nil {
+		return
+	}
+	if t.fields == nil {
+		t.fields = make(map[string][]annotationFields)
+	}
+	if _, seen := t.fields[id]; !seen {
+		t.ids = append(t.ids, id)
+	}
+	t.fields[id] = append(t.fields[id], fields)
+}
+
+func (t *annotationTargets) apply(id string, a annotation) {
+	for _, fields := range t.fields[id] {
+		fields.apply(a)
+	}
+}
+
+// annotator fills the stars, ratings, play counts and bookmarks of a user into the songs,
+// albums and artists of a response. Items are collected first and then loaded with one
+// query per kind of item, whatever the size of the response.
+type annotator struct {
+	db      *sql.DB
+	userId  int
+	songs   annotationTargets
+	albums  annotationTargets
+	artists annotationTargets
+}
+
+func (s *Service) annotator(userId int) *annotator {
+	return &annotator{db: s.db, userId: userId}
+}
+
+// annotateSongs fills the annotations of a list of songs
+func (s *Service) annotateSongs(userId int, songs []Child) {
+	s.annotator(userId).addChildren(songs).load()
+}
+
+// addChildren adds songs. Directory entries are albums, as listed by getStarred.
+func (a *annotator) addChildren(children []Child) *annotator {
+	for i := range children {
+		song := &children[i]
+		if song.IsDir {
+			a.albums.add(song.ID, annotationFields{
+				starred:    &song.Starred,
+				userRating: &song.UserRating,
+				playCount:  &song.PlayCount,
+				played:     &song.Played,
+			})
+			continue
+		}
+		a.songs.add(song.ID, annotationFields{
+			starred:    &song.Starred,
+			userRating: &song.UserRating,
+			playCount:  &song.PlayCount,
+			played:     &song.Played,
+			bookmark:   &song.BookmarkPosition,
+		})
+	}
+	return a
+}
+
+// addAlbums adds albums and the songs listed inside them
+func (a *annotator) addAlbums(albums []AlbumID3) *annotator {
+	for i := range albums {
+		a.addAlbum(&albums[i])
+	}
+	return a
+}
+
+func (a *annotator) addAlbum(album *AlbumID3) *annotator {
+	a.albums.add(album.ID, annotationFields{
+		starred:    &album.Starred,
+		userRating: &album.UserRating,
+		playCount:  &album.PlayCount,
+		played:     &album.Played,
+	})
+	return a.addChildren(album.Song)
+}
+
+func (a *annotator) addArtists(artists []ArtistID3) *annotator {
+	for i := range artists {
+		artist := &artists[i]
+		a.artists.add(artist.ID, annotationFields{
+			starred:    &artist.Starred,
+			userRating: &artist.UserRating,
+		})
+	}
+	return a
+}
+
+// addArtist adds an artist with its albums
+func (a *annotator) addArtist(artist *ArtistWithAlbums) *annotator {
+	a.artists.add(artist.ID, annotationFields{
+		starred:    &artist.Starred,
+		userRating: &artist.UserRating,
+	})
+	return a.addAlbums(artist.Album)
+}
+
+// load runs the queries and fills the collected items. Errors are logged: a response
+// without annotations is still better than no response.
+func (a *annotator) load() {
+	if len(a.songs.ids) > 0 {
+		a.query(&a.songs, "songs", `
+			SELECT ids.id, st.starred_at, r.rating, pc.play_count, pc.last_played, b.position
+			FROM unnest($2::int[]) AS ids(id)
+			LEFT JOIN starred_songs st ON st.song_id = ids.id AND st.user_id = $1
+			LEFT JOIN ratings r ON r.song_id = ids.id AND r.user_id = $1
+			LEFT JOIN bookmarks b ON b.song_id = ids.id AND b.user_id = $1
+			LEFT JOIN (
+				SELECT song_id, COUNT(*) AS play_count, MAX(played_at) AS last_played
+				FROM play_history
+				WHERE user_id = $1 AND song_id = ANY($2::int[]) AND share_id IS NULL
+				GROUP BY song_id
+			) pc ON pc.song_id = ids.id
+		`)
+	}
+
+	if len(a.albums.ids) > 0 {
+		a.query(&a.albums, "albums", `
+			SELECT ids.id, sa.starred_at, r.rating, pc.play_count, pc.last_played, NULL::bigint
+			FROM unnest($2::int[]) AS ids(id)
+			LEFT JOIN starred_albums sa ON sa.album_id = ids.id AND sa.user_id = $1
+			LEFT JOIN album_ratings r ON r.album_id = ids.id AND r.user_id = $1
+			LEFT JOIN (
+				SELECT s.album_id, COUNT(*) AS play_count, MAX(ph.played_at) AS last_played
+				FROM play_history ph
+				JOIN songs s ON s.id = ph.song_id
+				WHERE ph.user_id = $1 AND s.album_id = ANY($2::int[]) AND ph.share_id IS NULL
+				GROUP BY s.album_id
+			) pc ON pc.album_id = ids.id
+		`)
+	}
+
+	if len(a.artists.ids) > 0 {
+		a.query(&a.artists, "artists", `
+			SELECT ids.id, sa.starred_at, r.rating, NULL::bigint, NULL::timestamp, NULL::bigint
+			FROM unnest($2::int[]) AS ids(id)
+			LEFT JOIN starred_artists sa ON sa.artist_id = ids.id AND sa.user_id = $1
+			LEFT JOIN artist_ratings r ON r.artist_id = ids.id AND r.user_id = $1
+		`)
+	}
+}
+
+// query runs an annotation query returning id, starred, rating, play count, last played
+// and bookmark position for the IDs in $2 and the user in $1
+func (a *annotator) query(targets *annotationTargets, kind, query string) {
+	rows, err := a.db.Query(query, a.userId, pq.Array(targets.ids))
+	if err != nil {
+		log.Printf("Error loading annotations of %d %s: %v", len(targets.ids), kind, err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		var ann annotation
+		if err := rows.Scan(&id, &ann.starred, &ann.rating, &ann.playCount, &ann.played, &ann.bookmark); err != nil {
+			log.Printf("Error scanning annotations of %s: %v", kind, err)
+			continue
+		}
+		targets.apply(id, ann)
+	}
+}
@@ -0,0 +1,76 @@
+package subsonic
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestAnnotationTargetsDeduplicateIDs(t *testing.T) {
+	songs := []Child{{ID: "1"}, {ID: "2"}, {ID: "1"}, {ID: "pe-3"}}
+
+	a := &annotator{}
+	a.addChildren(songs)
+
+	if got := a.songs.ids; len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Fatalf("song IDs = %v, want [1 2]", got)
+	}
+
+	starred := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	a.songs.apply("1", annotation{
+		starred:   sql.NullTime{Time: starred, Valid: true},
+		rating:    sql.NullInt64{Int64: 4, Valid: true},
+		playCount: sql.NullInt64{Int64: 7, Valid: true},
+		bookmark:  sql.NullInt64{Int64: 90000, Valid: true},
+	})
+
+	for _, i := range []int{0, 2} {
+		song := songs[i]
+		if song.Starred != "2024-03-01T12:30:00Z" || song.UserRating != 4 || song.PlayCount != 7 || song.BookmarkPosition != 90000 {
+			t.Errorf("songs[%d] = %+v, want the annotation applied", i, song)
+		}
+	}
+	if songs[1].Starred != "" || songs[1].UserRating != 0 {
+		t.Errorf("songs[1] = %+v, want no annotation", songs[1])
+	}
+}
+
+func TestAnnotatorCollectsNestedItems(t *testing.T) {
+	artist := &ArtistWithAlbums{
+		ID: "5",
+		Album: []AlbumID3{
+			{ID: "10", Song: []Child{{ID: "100"}, {ID: "101"}}},
+			{ID: "11"},
+		},
+	}
+	starredAlbums := []Child{{ID: "12", IsDir: true}}
+
+	a := &annotator{}
+	a.addArtist(artist).addChildren(starredAlbums)
+
+	if len(a.artists.ids) != 1 || len(a.albums.ids) != 3 || len(a.songs.ids) != 2 {
+		t.Fatalf("collected %d artists, %d albums, %d songs, want 1, 3 and 2",
+			len(a.artists.ids), len(a.albums.ids), len(a.songs.ids))
+	}
+
+	rated := annotation{rating: sql.NullInt64{Int64: 5, Valid: true}}
+	a.artists.apply("5", rated)
+	a.albums.apply("10", rated)
+	a.albums.apply("12", rated)
+	a.songs.apply("101", rated)
+
+	if artist.UserRating != 5 || artist.Album[0].UserRating != 5 || artist.Album[0].Song[1].UserRating != 5 {
+		t.Errorf("nested ratings were not applied: %+v", artist)
+	}
+	if artist.Album[1].UserRating != 0 || artist.Album[0].Song[0].UserRating != 0 {
+		t.Errorf("ratings were applied to other items: %+v", artist)
+	}
+	if starredAlbums[0].UserRating != 5 {
+		t.Errorf("album directory rating = %d, want 5", starredAlbums[0].UserRating)
+	}
+}
+
+func TestAnnotatorLoadWithoutItems(t *testing.T) {
+	// Nothing to annotate must not touch the database
+	(&annotator{}).load()
+}
@@ -0,0 +1,164 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// GetBookmarks - Returns all bookmarks for the current user
+func (s *Service) GetBookmarks(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	rows, err := s.db.Query(`
+		SELECT b.song_id, b.position, b.comment, b.created_at, b.updated_at, u.username
+		FROM bookmarks b
+		JOIN users u ON b.user_id = u.id
+		WHERE b.user_id = $1
+		ORDER BY b.updated_at DESC
+	`, userId)
+	if err != nil {
+		log.Printf("GetBookmarks: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	var bookmarks []Bookmark
+	var songIds []string
+
+	for rows.Next() {
+		var bookmark Bookmark
+		var songId string
+		var comment sql.NullString
+		var createdAt, updatedAt time.Time
+
+		if err := rows.Scan(&songId, &bookmark.Position, &comment, &createdAt, &updatedAt, &bookmark.Username); err != nil {
+			log.Printf("GetBookmarks: Error scanning bookmark: %v", err)
+			continue
+		}
+
+		bookmark.Comment = comment.String
+		bookmark.Created = createdAt.Format("2006-01-02T15:04:05Z")
+		bookmark.Changed = updatedAt.Format("2006-01-02T15:04:05Z")
+		bookmark.Entry.ID = songId
+
+		bookmarks = append(bookmarks, bookmark)
+		songIds = append(songIds, songId)
+	}
+
+	songs, err := s.getSongsByIDs(songIds)
+	if err != nil {
+		log.Printf("GetBookmarks: Error loading songs: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+
+	result := Bookmarks{Bookmark: []Bookmark{}}
+	for _, bookmark := range bookmarks {
+		song, ok := songs[bookmark.Entry.ID]
+		if !ok {
+			continue
+		}
+		song.BookmarkPosition = bookmark.Position
+		bookmark.Entry = song
+		result.Bookmark = append(result.Bookmark, bookmark)
+	}
+
+	s.sendResponse(c, &result)
+}
+
+// CreateBookmark - Creates or updates a bookmark for a song
+func (s *Service) CreateBookmark(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	songId := c.Query("id")
+	if !s.isValidID(songId) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	position, err := strconv.ParseInt(c.Query("position"), 10, 64)
+	if err != nil || position < 0 {
+		s.sendError(c, 10, "Required parameter 'position' is missing or invalid")
+		return
+	}
+
+	var exists bool
+	err = s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM songs WHERE id = $1)", songId).Scan(&exists)
+	if err != nil {
+		log.Printf("CreateBookmark: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	if !exists {
+		s.sendError(c, 70, "Song not found")
+		return
+	}
+
+	_, err = s.db.Exec(`
+		INSERT INTO bookmarks (user_id, song_id, position, comment, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (user_id, song_id)
+		DO UPDATE SET position = $3, comment = $4, updated_at = NOW()
+	`, userId, songId, position, c.Query("comment"))
+	if err != nil {
+		log.Printf("CreateBookmark: Error saving bookmark: %v", err)
+		s.sendError(c, 0, "Failed to create bookmark")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// DeleteBookmark - Deletes the bookmark for a song
+func (s *Service) DeleteBookmark(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	songId := c.Query("id")
+	if !s.isValidID(songId) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	_, err := s.db.Exec("DELETE FROM bookmarks WHERE user_id = $1 AND song_id = $2", userId, songId)
+	if err != nil {
+		log.Printf("DeleteBookmark: Error deleting bookmark: %v", err)
+		s.sendError(c, 0, "Failed to delete bookmark")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// getSongsByIDs loads full song entries for the given IDs, keyed by song ID
+func (s *Service) getSongsByIDs(ids []string) (map[string]Child, error) {
+	songs := make(map[string]Child)
+	if len(ids) == 0 {
+		return songs, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT s.id, s.title, s.track_number, s.duration, s.file_path,
+		       s.file_size, s.bitrate, s.format, s.album_id,
+		       ar.name, al.name, al.year, al.genre, al.cover_art_path
+		FROM songs s
+		JOIN artists ar ON s.artist_id = ar.id
+		JOIN albums al ON s.album_id = al.id
+		WHERE s.id = ANY($1::int[])
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for _, song := range s.scanSongs(rows) {
+		songs[song.ID] = song
+	}
+
+	return songs, nil
+}
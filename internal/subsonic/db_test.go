@@ -0,0 +1,47 @@
+package subsonic
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+)
+
+// testTx opens a transaction on the database of CASTAFIORE_TEST_DATABASE_URL, which must
+// have the migrations applied. It is rolled back when the test ends, so tests can write
+// freely. Tests are skipped when the variable is not set.
+func testTx(t *testing.T) *sql.Tx {
+	t.Helper()
+
+	url := os.Getenv("CASTAFIORE_TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("CASTAFIORE_TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tx.Rollback()
+		db.Close()
+	})
+	return tx
+}
+
+// mustInsert runs an INSERT ... RETURNING id and returns the id
+func mustInsert(t *testing.T, tx *sql.Tx, query string, args ...interface{}) int {
+	t.Helper()
+
+	var id int
+	if err := tx.QueryRow(query, args...).Scan(&id); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return id
+}
@@ -0,0 +1,110 @@
+package subsonic
+
+import (
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultOnThisDayCount is how many songs getOnThisDay returns by default
+const defaultOnThisDayCount = 50
+
+// GetDecades - Lists the decades of the library with their album count, newest first,
+// to browse the byDecade album list (extension)
+func (s *Service) GetDecades(c *gin.Context) {
+	if filter, err := parseLibraryFilter(c); err != nil {
+		s.sendError(c, 10, err.Error())
+		return
+	} else if filter.otherFolder {
+		s.sendResponse(c, &Decades{Decade: []Decade{}})
+		return
+	}
+
+	rows, err := s.db.Query(`
+		SELECT year - year % 10 AS decade, COUNT(*)
+		FROM albums
+		WHERE year > 0
+		GROUP BY decade
+		ORDER BY decade DESC
+	`)
+	if err != nil {
+		log.Printf("GetDecades: Error loading decades: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	result := &Decades{Decade: []Decade{}}
+	for rows.Next() {
+		var decade Decade
+		if err := rows.Scan(&decade.Year, &decade.AlbumCount); err != nil {
+			log.Printf("GetDecades: Error scanning decade: %v", err)
+			continue
+		}
+		result.Decade = append(result.Decade, decade)
+	}
+
+	s.sendResponse(c, result)
+}
+
+// GetOnThisDay - Returns the songs the user played on this date in earlier years, most
+// played first (extension). Clients in another time zone can send their own date.
+func (s *Service) GetOnThisDay(c *gin.Context) {
+	day, err := onThisDayDate(c.Query("date"), time.Now())
+	if err != nil {
+		s.sendError(c, 10, "Parameter 'date' must be a date like 2024-05-31")
+		return
+	}
+
+	count := parseIntDefault(c.Query("count"), defaultOnThisDayCount)
+	if count <= 0 {
+		count = defaultOnThisDayCount
+	}
+	if count > maxListSize {
+		count = maxListSize
+	}
+
+	userId := s.getUserID(c)
+	rows, err := s.db.Query(`
+		SELECT s.id, s.title, s.track_number, s.duration, s.file_path,
+		       s.file_size, s.bitrate, s.format, s.album_id,
+		       ar.name, al.name, al.year, al.genre, al.cover_art_path
+		FROM (
+			SELECT song_id, COUNT(*) AS plays, MAX(played_at) AS last_played
+			FROM play_history
+			WHERE user_id = $1 AND share_id IS NULL
+			  AND EXTRACT(MONTH FROM played_at) = $2
+			  AND EXTRACT(DAY FROM played_at) = $3
+			  AND EXTRACT(YEAR FROM played_at) < $4
+			GROUP BY song_id
+		) h
+		JOIN songs s ON s.id = h.song_id
+		JOIN artists ar ON s.artist_id = ar.id
+		JOIN albums al ON s.album_id = al.id
+		ORDER BY h.plays DESC, h.last_played DESC, s.id
+		LIMIT $5
+	`, userId, int(day.Month()), day.Day(), day.Year(), count)
+	if err != nil {
+		log.Printf("GetOnThisDay: Error loading songs: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	songs := s.scanSongs(rows)
+	if songs == nil {
+		songs = []Child{}
+	}
+	s.annotateSongs(userId, songs)
+
+	s.sendResponse(c, &OnThisDay{Song: songs})
+}
+
+// onThisDayDate returns the date sent by the client, or today
+func onThisDayDate(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return now, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
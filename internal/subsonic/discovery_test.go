@@ -0,0 +1,23 @@
+package subsonic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOnThisDayDate(t *testing.T) {
+	now := time.Date(2024, 5, 31, 23, 30, 0, 0, time.UTC)
+
+	if got, err := onThisDayDate("", now); err != nil || !got.Equal(now) {
+		t.Errorf("onThisDayDate(\"\") = %v, %v; want now", got, err)
+	}
+
+	got, err := onThisDayDate("2023-02-28", now)
+	if err != nil || got.Month() != time.February || got.Day() != 28 || got.Year() != 2023 {
+		t.Errorf("onThisDayDate(\"2023-02-28\") = %v, %v", got, err)
+	}
+
+	if _, err := onThisDayDate("31/05/2024", now); err == nil {
+		t.Error("onThisDayDate() accepted a date in another format")
+	}
+}
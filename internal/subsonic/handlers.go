@@ -11,9 +11,12 @@ import (
 	"strings"
 	"time"
 
-	"castafiore-backend/internal/lastfm"
+	"castafiore-backend/internal/matching"
+	"castafiore-backend/internal/metadata"
+	"castafiore-backend/internal/search"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 // Ping - Used to test connectivity
@@ -33,7 +36,7 @@ func (s *Service) GetLicense(c *gin.Context) {
 func (s *Service) GetMusicFolders(c *gin.Context) {
 	musicFolders := &MusicFolders{
 		MusicFolder: []MusicFolder{
-			{ID: 1, Name: "Music"},
+			{ID: musicFolderID, Name: "Music"},
 		},
 	}
 	s.sendResponse(c, musicFolders)
@@ -219,6 +222,12 @@ func (s *Service) GetArtists(c *gin.Context) {
 		indexes = append(indexes, index)
 	}
 
+	ann := s.annotator(s.getUserID(c))
+	for i := range indexes {
+		ann.addArtists(indexes[i].Artist)
+	}
+	ann.load()
+
 	result := &ArtistsID3{
 		Index: indexes,
 	}
@@ -317,6 +326,7 @@ func (s *Service) GetArtist(c *gin.Context) {
 		AlbumCount: artist.AlbumCount,
 		Album:      albums,
 	}
+	s.annotator(s.getUserID(c)).addArtist(result).load()
 
 	s.sendResponse(c, result)
 }
@@ -428,6 +438,7 @@ func (s *Service) GetAlbum(c *gin.Context) {
 	album.Song = songs
 	album.SongCount = len(songs)
 	album.Duration = totalDuration
+	s.annotator(s.getUserID(c)).addAlbum(&album).load()
 
 	s.sendResponse(c, &album)
 }
@@ -485,131 +496,34 @@ func (s *Service) GetSong(c *gin.Context) {
 		song.Genre = *genre
 	}
 
-	s.sendResponse(c, &song)
-}
+	songs := []Child{song}
+	s.annotateSongs(s.getUserID(c), songs)
 
-// Placeholder implementations for other endpoints
-func (s *Service) GetAlbumList(c *gin.Context) { s.sendResponse(c, nil) }
-func (s *Service) GetAlbumList2(c *gin.Context) {
-	// Get parameters
-	listType := c.DefaultQuery("type", "newest")
-	size := 10 // default size
-	if sizeStr := c.Query("size"); sizeStr != "" {
-		if parsed, err := strconv.Atoi(sizeStr); err == nil && parsed > 0 {
-			size = parsed
-		}
-	}
+	s.sendResponse(c, &songs[0])
+}
 
-	offset := 0
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if parsed, err := strconv.Atoi(offsetStr); err == nil && parsed >= 0 {
-			offset = parsed
-		}
+// GetRandomSongs - Returns random songs, optionally filtered like the album lists
+func (s *Service) GetRandomSongs(c *gin.Context) {
+	size := parseIntDefault(c.Query("size"), defaultListSize)
+	if size <= 0 {
+		size = defaultListSize
 	}
-
-	// Build query based on type
-	var query string
-	var orderBy string
-
-	switch listType {
-	case "newest":
-		orderBy = "al.created_at DESC"
-	case "recent":
-		orderBy = "al.updated_at DESC"
-	case "frequent":
-		orderBy = "al.id DESC" // Placeholder - would need play counts
-	case "random":
-		orderBy = "RANDOM()"
-	case "alphabeticalByName":
-		orderBy = "al.name ASC"
-	case "alphabeticalByArtist":
-		orderBy = "ar.name ASC, al.name ASC"
-	case "starred":
-		orderBy = "al.created_at DESC" // Placeholder - would need starred functionality
-	default:
-		orderBy = "al.created_at DESC"
+	if size > maxListSize {
+		size = maxListSize
 	}
 
-	query = fmt.Sprintf(`
-		SELECT al.id, al.name, al.artist_id, al.year, al.genre, al.created_at,
-		       ar.name as artist_name,
-		       COUNT(s.id) as song_count,
-		       COALESCE(SUM(s.duration), 0) as total_duration,
-		       al.cover_art_path
-		FROM albums al
-		JOIN artists ar ON al.artist_id = ar.id
-		LEFT JOIN songs s ON al.id = s.album_id
-		GROUP BY al.id, al.name, al.artist_id, al.year, al.genre, al.created_at, ar.name, al.cover_art_path
-		ORDER BY %s
-		LIMIT $1 OFFSET $2
-	`, orderBy)
-
-	rows, err := s.db.Query(query, size, offset)
+	filter, err := parseLibraryFilter(c)
 	if err != nil {
-		s.sendError(c, 0, "Database error")
+		s.sendError(c, 10, err.Error())
 		return
 	}
-	defer rows.Close()
-
-	var albums []AlbumID3
-	for rows.Next() {
-		var album AlbumID3
-		var artistName string
-		var createdAt time.Time
-		var songCount, totalDuration int
-		var year *int
-		var genre *string
-		var coverArtPath *string
-
-		err := rows.Scan(
-			&album.ID, &album.Name, &album.ArtistID, &year, &genre, &createdAt,
-			&artistName, &songCount, &totalDuration, &coverArtPath,
-		)
-		if err != nil {
-			continue
-		}
-
-		album.Artist = artistName
-		album.SongCount = songCount
-		album.Duration = totalDuration
-		album.Created = createdAt.Format("2006-01-02T15:04:05Z")
-
-		if year != nil {
-			album.Year = *year
-		}
-		if genre != nil {
-			album.Genre = *genre
-		}
-
-		// Set cover art to album ID if album has cover art
-		if coverArtPath != nil && *coverArtPath != "" {
-			album.CoverArt = album.ID
-		}
-
-		albums = append(albums, album)
-	}
 
-	result := &AlbumList2{
-		Album: albums,
-	}
-
-	s.sendResponse(c, result)
-}
-func (s *Service) GetRandomSongs(c *gin.Context) {
-	// Get parameters
-	size := 10 // default size
-	if sizeStr := c.Query("size"); sizeStr != "" {
-		if parsed, err := strconv.Atoi(sizeStr); err == nil && parsed > 0 && parsed <= 500 {
-			size = parsed
-		}
+	var args sqlArgs
+	where := "TRUE"
+	if conds := filter.conditions(&args); len(conds) > 0 {
+		where = strings.Join(conds, " AND ")
 	}
 
-	genre := c.Query("genre")
-	fromYear := c.Query("fromYear")
-	toYear := c.Query("toYear")
-	musicFolderId := c.Query("musicFolderId")
-
-	// Build query
 	query := `
 		SELECT s.id, s.title, s.track_number, s.duration, s.file_path, 
 		       s.file_size, s.bitrate, s.format, s.album_id,
@@ -617,40 +531,9 @@ func (s *Service) GetRandomSongs(c *gin.Context) {
 		FROM songs s
 		JOIN artists ar ON s.artist_id = ar.id
 		JOIN albums al ON s.album_id = al.id
-		WHERE 1=1`
-
-	args := []interface{}{}
-	argCount := 0
-
-	if genre != "" {
-		argCount++
-		query += fmt.Sprintf(" AND al.genre ILIKE $%d", argCount)
-		args = append(args, "%"+genre+"%")
-	}
-
-	if fromYear != "" {
-		if year, err := strconv.Atoi(fromYear); err == nil {
-			argCount++
-			query += fmt.Sprintf(" AND al.year >= $%d", argCount)
-			args = append(args, year)
-		}
-	}
-
-	if toYear != "" {
-		if year, err := strconv.Atoi(toYear); err == nil {
-			argCount++
-			query += fmt.Sprintf(" AND al.year <= $%d", argCount)
-			args = append(args, year)
-		}
-	}
-
-	if musicFolderId != "" {
-		// For now, ignore music folder filtering since we have only one folder
-	}
-
-	argCount++
-	query += fmt.Sprintf(" ORDER BY RANDOM() LIMIT $%d", argCount)
-	args = append(args, size)
+		WHERE ` + where + `
+		ORDER BY RANDOM()
+		LIMIT ` + args.add(size)
 
 	rows, err := s.db.Query(query, args...)
 	if err != nil {
@@ -704,6 +587,8 @@ func (s *Service) GetRandomSongs(c *gin.Context) {
 		songs = append(songs, song)
 	}
 
+	s.annotateSongs(s.getUserID(c), songs)
+
 	result := &RandomSongs{
 		Song: songs,
 	}
@@ -802,6 +687,8 @@ func (s *Service) GetSongsByGenre(c *gin.Context) {
 		songs = append(songs, song)
 	}
 
+	s.annotateSongs(s.getUserID(c), songs)
+
 	result := &SongsByGenre{
 		Song: songs,
 	}
@@ -820,111 +707,30 @@ func (s *Service) GetTopSongs(c *gin.Context) {
 
 	var songs []Child
 
-	if artist != "" && s.lastfm != nil {
-		// Get top tracks from Last.fm
-		log.Printf("GetTopSongs: Requesting top tracks from Last.fm for artist: %s", artist)
-		topTracks, err := s.lastfm.GetTopTracks(artist)
+	if artist != "" {
+		// Get top tracks from the metadata agents
+		log.Printf("GetTopSongs: Requesting top tracks for artist: %s", artist)
+		// Ask for extra tracks since not all of them will be in the library
+		limit := count
+		if limit < 50 {
+			limit = 50
+		}
+		topTracks, err := s.agents.TopTracks(metadata.Artist{Name: artist}, limit)
 		if err != nil {
-			log.Printf("Error getting top tracks from Last.fm: %v", err)
+			log.Printf("GetTopSongs: No top tracks for artist '%s': %v", artist, err)
 		} else {
 			if len(topTracks) == 0 {
-				log.Printf("GetTopSongs: Last.fm returned no tracks for artist '%s'", artist)
+				log.Printf("GetTopSongs: Agents returned no tracks for artist '%s'", artist)
 			} else {
 				trackCount := len(topTracks)
-				log.Printf("GetTopSongs: Last.fm returned %d top tracks", trackCount)
-
-				// Try to find each track in our local database
-				for _, track := range topTracks {
-					// Normalize names for better matching
-					trackName := strings.ToLower(strings.TrimSpace(track.Name))
-					artistName := strings.ToLower(strings.TrimSpace(track.Artist.Name))
-
-					log.Printf("GetTopSongs: Looking for track '%s' by '%s'", track.Name, track.Artist.Name)
-
-					// Query to find the closest match in our database
-					query := `
-					SELECT s.id, s.title, s.track_number, s.duration, s.file_path, 
-						   s.file_size, s.bitrate, s.format, s.album_id,
-						   ar.name as artist_name, al.name as album_name, 
-						   al.year, al.genre, al.cover_art_path
-					FROM songs s
-					JOIN artists ar ON s.artist_id = ar.id
-					JOIN albums al ON s.album_id = al.id
-					WHERE LOWER(ar.name) LIKE $1 
-					  AND LOWER(s.title) LIKE $2
-					ORDER BY 
-						CASE 
-							WHEN LOWER(s.title) = $3 THEN 4
-							WHEN LOWER(s.title) LIKE $3 || '%' THEN 3
-							WHEN LOWER(s.title) LIKE '%' || $3 || '%' THEN 2
-							ELSE 1 
-						END DESC,
-						LENGTH(s.title) ASC
-					LIMIT 1`
-
-					rows, err := s.db.Query(query,
-						"%"+artistName+"%",
-						"%"+trackName+"%",
-						trackName)
-
-					if err != nil {
-						log.Printf("Error searching for track '%s': %v", track.Name, err)
-						continue
-					}
-
-					for rows.Next() {
-						var song Child
-						var trackNumber *int
-						var year *int
-						var genre *string
-						var coverArtPath *string
-						var localArtistName, albumName string
-
-						err := rows.Scan(
-							&song.ID, &song.Title, &trackNumber, &song.Duration,
-							&song.Path, &song.Size, &song.BitRate, &song.Suffix, &song.Parent,
-							&localArtistName, &albumName, &year, &genre, &coverArtPath,
-						)
-						if err != nil {
-							log.Printf("Error scanning song result: %v", err)
-							continue
-						}
-
-						song.Album = albumName
-						song.Artist = localArtistName
-						song.IsDir = false
-						song.AlbumId = song.Parent
-						song.ContentType = s.getContentType(song.Suffix)
-
-						if trackNumber != nil {
-							song.Track = *trackNumber
-						}
-						if year != nil {
-							song.Year = *year
-						}
-						if genre != nil {
-							song.Genre = *genre
-						}
-						if coverArtPath != nil && *coverArtPath != "" {
-							song.CoverArt = song.Parent
-						}
-
-						log.Printf("Debug GetTopSongs: Found local match - Song ID %s, Title: %s, Artist: %s",
-							song.ID, song.Title, song.Artist)
-
-						songs = append(songs, song)
-					}
-					rows.Close()
+				log.Printf("GetTopSongs: Agents returned %d top tracks", trackCount)
 
-					if len(songs) >= count {
-						break
-					}
-				}
+				songs = s.findLocalSongsFromAgents(topTracks, count)
 			}
 		}
 	}
 
-	// If no songs found from Last.fm or if no artist specified, fall back to local database
+	// If no songs found from the agents or if no artist specified, fall back to local database
 	if len(songs) == 0 {
 		log.Printf("GetTopSongs: Using fallback strategy")
 
@@ -1010,6 +816,8 @@ func (s *Service) GetTopSongs(c *gin.Context) {
 		}
 	}
 
+	s.annotateSongs(s.getUserID(c), songs)
+
 	result := &TopSongs{
 		Song: songs,
 	}
@@ -1199,6 +1007,7 @@ func (s *Service) GetStarred(c *gin.Context) {
 		defer songRows.Close()
 		result.Song = s.scanSongs(songRows)
 	}
+	s.annotator(userId).addChildren(result.Album).addChildren(result.Song).load()
 
 	log.Printf("GetStarred: Returning %d artists, %d albums, %d songs for user %d",
 		len(result.Artist), len(result.Album), len(result.Song), userId)
@@ -1314,13 +1123,107 @@ func (s *Service) GetStarred2(c *gin.Context) {
 		defer songRows.Close()
 		result.Song = s.scanSongs(songRows)
 	}
+	s.annotator(userId).addArtists(result.Artist).addAlbums(result.Album).addChildren(result.Song).load()
 
 	log.Printf("GetStarred2: Returning %d artists, %d albums, %d songs for user %d",
 		len(result.Artist), len(result.Album), len(result.Song), userId)
 	s.sendResponse(c, result)
 }
 
-func (s *Service) Search2(c *gin.Context) { s.sendResponse(c, nil) }
+// Search2 - Searches artists, albums and songs, returning albums as directories
+func (s *Service) Search2(c *gin.Context) {
+	query := c.Query("query")
+	artistCount := parseIntDefault(c.Query("artistCount"), 20)
+	albumCount := parseIntDefault(c.Query("albumCount"), 20)
+	songCount := parseIntDefault(c.Query("songCount"), 20)
+	userId := s.getUserID(c)
+
+	result := &SearchResult2{
+		Artist: []Artist{},
+		Album:  []Child{},
+		Song:   []Child{},
+	}
+
+	if artistCount > 0 {
+		rows, err := s.searchRows(search.Artists, `
+			SELECT ar.id, ar.name
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN artists ar ON ar.id = m.id
+			ORDER BY m.pos
+		`, query, userId, artistCount, parseIntDefault(c.Query("artistOffset"), 0))
+		if err != nil {
+			log.Printf("Search2: Error querying artists: %v", err)
+		} else {
+			for rows.Next() {
+				var artist Artist
+				if err := rows.Scan(&artist.ID, &artist.Name); err != nil {
+					log.Printf("Search2: Error scanning artist: %v", err)
+					continue
+				}
+				result.Artist = append(result.Artist, artist)
+			}
+			rows.Close()
+		}
+	}
+
+	if albumCount > 0 {
+		rows, err := s.searchRows(search.Albums, `
+			SELECT al.id, al.name, al.artist_id, ar.name, al.year, al.genre, al.cover_art_path
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN albums al ON al.id = m.id
+			JOIN artists ar ON al.artist_id = ar.id
+			ORDER BY m.pos
+		`, query, userId, albumCount, parseIntDefault(c.Query("albumOffset"), 0))
+		if err != nil {
+			log.Printf("Search2: Error querying albums: %v", err)
+		} else {
+			for rows.Next() {
+				var album Child
+				var year sql.NullInt32
+				var genre, coverArtPath sql.NullString
+				if err := rows.Scan(&album.ID, &album.Title, &album.Parent, &album.Artist, &year, &genre, &coverArtPath); err != nil {
+					log.Printf("Search2: Error scanning album: %v", err)
+					continue
+				}
+				album.IsDir = true
+				album.Album = album.Title
+				album.AlbumId = album.ID
+				album.Year = int(year.Int32)
+				album.Genre = genre.String
+				if coverArtPath.String != "" {
+					album.CoverArt = album.ID
+				}
+				result.Album = append(result.Album, album)
+			}
+			rows.Close()
+		}
+	}
+
+	if songCount > 0 {
+		rows, err := s.searchRows(search.Songs, `
+			SELECT s.id, s.title, s.track_number, s.duration, s.file_path,
+			       s.file_size, s.bitrate, s.format, s.album_id,
+			       ar.name, al.name, al.year, al.genre, al.cover_art_path
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN songs s ON s.id = m.id
+			JOIN artists ar ON s.artist_id = ar.id
+			JOIN albums al ON s.album_id = al.id
+			ORDER BY m.pos
+		`, query, userId, songCount, parseIntDefault(c.Query("songOffset"), 0))
+		if err != nil {
+			log.Printf("Search2: Error querying songs: %v", err)
+		} else {
+			if songs := s.scanSongs(rows); songs != nil {
+				result.Song = songs
+			}
+			rows.Close()
+		}
+	}
+
+	s.annotator(userId).addChildren(result.Album).addChildren(result.Song).load()
+	s.sendResponse(c, result)
+}
+
 func (s *Service) Search3(c *gin.Context) {
 	// Obtener parámetros de búsqueda
 	query := c.Query("query")
@@ -1338,6 +1241,7 @@ func (s *Service) Search3(c *gin.Context) {
 	// For future use when implementing multiple music folders
 	_ = c.Query("musicFolderId")
 
+	userId := s.getUserID(c)
 	log.Printf("Search3: query='%s', artistCount=%d, albumCount=%d, songCount=%d",
 		query, artistCount, albumCount, songCount)
 
@@ -1347,28 +1251,20 @@ func (s *Service) Search3(c *gin.Context) {
 		Song:   []Child{},
 	}
 
-	// Si no hay query, usar búsqueda amplia para mostrar todo el contenido
-	var searchTerm string
-	if query == "" {
-		searchTerm = "%"
-	} else {
-		searchTerm = "%" + strings.ToLower(query) + "%"
-	}
-
-	log.Printf("Search3: searchTerm='%s'", searchTerm)
+	// El motor de búsqueda devuelve los IDs por relevancia; una query vacía lista todo
+	// el contenido. Cada consulta carga las filas en ese orden.
 
 	// Search artists - solo buscar si se solicitan artistas
 	if artistCount > 0 {
 		artistQuery := `
 			SELECT ar.id, ar.name, COUNT(al.id) as album_count
-			FROM artists ar
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN artists ar ON ar.id = m.id
 			LEFT JOIN albums al ON ar.id = al.artist_id
-			WHERE LOWER(ar.name) LIKE $1
-			GROUP BY ar.id, ar.name
-			ORDER BY ar.name
-			LIMIT $2 OFFSET $3`
+			GROUP BY ar.id, ar.name, m.pos
+			ORDER BY m.pos`
 
-		rows, err := s.db.Query(artistQuery, searchTerm, artistCount, artistOffset)
+		rows, err := s.searchRows(search.Artists, artistQuery, query, userId, artistCount, artistOffset)
 		if err != nil {
 			log.Printf("Search3: Error querying artists: %v", err)
 		} else {
@@ -1397,15 +1293,14 @@ func (s *Service) Search3(c *gin.Context) {
 			       COUNT(s.id) as song_count,
 			       COALESCE(SUM(s.duration), 0) as total_duration,
 			       al.cover_art_path
-			FROM albums al
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN albums al ON al.id = m.id
 			JOIN artists ar ON al.artist_id = ar.id
 			LEFT JOIN songs s ON al.id = s.album_id
-			WHERE LOWER(al.name) LIKE $1 OR LOWER(ar.name) LIKE $1
-			GROUP BY al.id, al.name, al.artist_id, al.year, al.genre, al.created_at, ar.name, al.cover_art_path
-			ORDER BY ar.name, al.name
-			LIMIT $2 OFFSET $3`
+			GROUP BY al.id, al.name, al.artist_id, al.year, al.genre, al.created_at, ar.name, al.cover_art_path, m.pos
+			ORDER BY m.pos`
 
-		rows, err := s.db.Query(albumQuery, searchTerm, albumCount, albumOffset)
+		rows, err := s.searchRows(search.Albums, albumQuery, query, userId, albumCount, albumOffset)
 		if err != nil {
 			log.Printf("Search3: Error querying albums: %v", err)
 		} else {
@@ -1457,14 +1352,13 @@ func (s *Service) Search3(c *gin.Context) {
 				SELECT s.id, s.title, s.track_number, s.duration, s.file_path, 
 				       s.file_size, s.bitrate, s.format, s.album_id,
 				       ar.name as artist_name, al.name as album_name, al.year, al.genre, al.cover_art_path
-				FROM songs s
+				FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+				JOIN songs s ON s.id = m.id
 				JOIN artists ar ON s.artist_id = ar.id
 				JOIN albums al ON s.album_id = al.id
-				WHERE LOWER(s.title) LIKE $1 OR LOWER(ar.name) LIKE $1 OR LOWER(al.name) LIKE $1
-				ORDER BY ar.name, al.name, s.track_number
-				LIMIT $2 OFFSET $3`
+				ORDER BY m.pos`
 
-		rows, err := s.db.Query(songQuery, searchTerm, songCount, songOffset)
+		rows, err := s.searchRows(search.Songs, songQuery, query, userId, songCount, songOffset)
 		if err != nil {
 			log.Printf("Search3: Error querying songs: %v", err)
 		} else {
@@ -1531,16 +1425,28 @@ func (s *Service) Search3(c *gin.Context) {
 		}
 	}
 
+	s.annotator(userId).addArtists(result.Artist).addAlbums(result.Album).addChildren(result.Song).load()
+
 	log.Printf("Search3: Returning %d artists, %d albums, %d songs",
 		len(result.Artist), len(result.Album), len(result.Song))
 	s.sendResponse(c, result)
 }
 
+// searchRows runs a query loading the items found by the search engine, whose IDs
+// are passed as $1 in order of relevance
+func (s *Service) searchRows(kind search.Kind, rowsQuery, query string, userId, count, offset int) (*sql.Rows, error) {
+	ids, err := s.search.Find(kind, query, userId, count, offset)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.Query(rowsQuery, pq.Array(ids))
+}
+
 // GetPlaylists - Returns all playlists a user is allowed to play
 func (s *Service) GetPlaylists(c *gin.Context) {
 	// Get user from context (would be set by auth middleware)
 	// For now, use a default user ID
-	userId := 1 // TODO: Get from authenticated user context
+	userId := s.getUserID(c)
 	username := c.Query("username")
 	if username == "" {
 		username = "admin" // TODO: Get from authenticated user
@@ -1548,15 +1454,16 @@ func (s *Service) GetPlaylists(c *gin.Context) {
 
 	rows, err := s.db.Query(`
 		SELECT p.id, p.name, p.comment, p.is_public, p.created_at, p.updated_at,
-		       u.username as owner,
+		       u.username as owner, p.user_id, p.rules, p.source_path IS NOT NULL, pc.role,
 		       COUNT(ps.id) as song_count,
 		       COALESCE(SUM(s.duration), 0) as total_duration
 		FROM playlists p
 		JOIN users u ON p.user_id = u.id
+		LEFT JOIN playlist_collaborators pc ON pc.playlist_id = p.id AND pc.user_id = $1
 		LEFT JOIN playlist_songs ps ON p.id = ps.playlist_id
 		LEFT JOIN songs s ON ps.song_id = s.id
-		WHERE p.user_id = $1 OR p.is_public = true
-		GROUP BY p.id, p.name, p.comment, p.is_public, p.created_at, p.updated_at, u.username
+		WHERE p.user_id = $1 OR p.is_public = true OR pc.role IS NOT NULL
+		GROUP BY p.id, p.name, p.comment, p.is_public, p.created_at, p.updated_at, u.username, p.user_id, p.rules, p.source_path, pc.role
 		ORDER BY p.name
 	`, userId)
 
@@ -1572,10 +1479,13 @@ func (s *Service) GetPlaylists(c *gin.Context) {
 		var playlist PlaylistID3
 		var comment *string
 		var createdAt, updatedAt time.Time
+		var ownerId int
+		var rules, role sql.NullString
+		var imported bool
 
 		err := rows.Scan(
 			&playlist.ID, &playlist.Name, &comment, &playlist.Public,
-			&createdAt, &updatedAt, &playlist.Owner,
+			&createdAt, &updatedAt, &playlist.Owner, &ownerId, &rules, &imported, &role,
 			&playlist.SongCount, &playlist.Duration,
 		)
 
@@ -1583,11 +1493,25 @@ func (s *Service) GetPlaylists(c *gin.Context) {
 			continue
 		}
 
+		// Public playlists and viewers cannot change the songs, and the songs of
+		// imported playlists come from their file
+		playlist.Readonly = (ownerId != userId && role.String != roleEditor) || imported
+
+		// Smart playlists are evaluated now, their songs are not stored
+		if rules.Valid {
+			playlist.Readonly = true
+			playlist.SongCount, playlist.Duration, err = s.smartPlaylistSummary(rules.String, ownerId)
+			if err != nil {
+				log.Printf("Error evaluating smart playlist %s: %v", playlist.ID, err)
+			}
+		}
+
 		if comment != nil {
 			playlist.Comment = *comment
 		}
 		playlist.Created = createdAt.Format("2006-01-02T15:04:05Z")
-		playlist.Changed = updatedAt.Format("2006-01-02T15:04:05Z")
+		playlist.Changed = updatedAt.Format(playlistChangedFormat)
+		playlist.CoverArt = playlistCoverArt(playlist.ID, updatedAt)
 
 		playlists = append(playlists, playlist)
 	}
@@ -1612,15 +1536,19 @@ func (s *Service) GetPlaylist(c *gin.Context) {
 	var comment *string
 	var createdAt, updatedAt time.Time
 	var userId int
+	var rules, role sql.NullString
+	var imported bool
+	currentUserId := s.getUserID(c)
 
 	err := s.db.QueryRow(`
 		SELECT p.id, p.name, p.comment, p.is_public, p.created_at, p.updated_at,
-		       u.username as owner, p.user_id
+		       u.username as owner, p.user_id, p.rules, p.source_path IS NOT NULL, pc.role
 		FROM playlists p
 		JOIN users u ON p.user_id = u.id
+		LEFT JOIN playlist_collaborators pc ON pc.playlist_id = p.id AND pc.user_id = $2
 		WHERE p.id = $1
-	`, id).Scan(&playlist.ID, &playlist.Name, &comment, &playlist.Public,
-		&createdAt, &updatedAt, &playlist.Owner, &userId)
+	`, id, currentUserId).Scan(&playlist.ID, &playlist.Name, &comment, &playlist.Public,
+		&createdAt, &updatedAt, &playlist.Owner, &userId, &rules, &imported, &role)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1631,31 +1559,58 @@ func (s *Service) GetPlaylist(c *gin.Context) {
 		return
 	}
 
+	// Private playlists are visible to their owner and collaborators only
+	if userId != currentUserId && !playlist.Public && !role.Valid {
+		s.sendError(c, 50, "User is not authorized to access this playlist")
+		return
+	}
+	playlist.Readonly = (userId != currentUserId && role.String != roleEditor) || imported
+
+	playlist.AllowedUser, err = s.playlistCollaborators(id)
+	if err != nil {
+		log.Printf("Error getting collaborators of playlist %s: %v", id, err)
+	}
+
 	if comment != nil {
 		playlist.Comment = *comment
 	}
 	playlist.Created = createdAt.Format("2006-01-02T15:04:05Z")
-	playlist.Changed = updatedAt.Format("2006-01-02T15:04:05Z")
+	playlist.Changed = updatedAt.Format(playlistChangedFormat)
+	playlist.CoverArt = playlistCoverArt(playlist.ID, updatedAt)
 
-	// Get songs in playlist
-	rows, err := s.db.Query(`
-		SELECT s.id, s.title, s.track_number, s.duration, s.file_path, 
-		       s.file_size, s.bitrate, s.format, s.album_id,
-		       ar.name as artist_name, al.name as album_name, al.year, al.genre, al.cover_art_path
-		FROM playlist_songs ps
-		JOIN songs s ON ps.song_id = s.id
-		JOIN artists ar ON s.artist_id = ar.id
-		JOIN albums al ON s.album_id = al.id
-		WHERE ps.playlist_id = $1
-		ORDER BY ps.position, ps.added_at
-	`, id)
-
-	if err != nil {
-		log.Printf("Error getting playlist songs: %v", err)
-		playlist.Entry = []Child{}
+	if rules.Valid {
+		playlist.Readonly = true
+		playlist.Entry, err = s.smartPlaylistSongs(rules.String, userId)
+		if err != nil {
+			log.Printf("Error evaluating smart playlist %s: %v", id, err)
+			playlist.Entry = []Child{}
+		}
+		s.annotateSongs(currentUserId, playlist.Entry)
 	} else {
-		defer rows.Close()
-		playlist.Entry = s.scanSongs(rows)
+		// Get songs in playlist
+		rows, err := s.db.Query(`
+			SELECT s.id, s.title, s.track_number, s.duration, s.file_path, 
+			       s.file_size, s.bitrate, s.format, s.album_id,
+			       ar.name as artist_name, al.name as album_name, al.year, al.genre, al.cover_art_path
+			FROM playlist_songs ps
+			JOIN songs s ON ps.song_id = s.id
+			JOIN artists ar ON s.artist_id = ar.id
+			JOIN albums al ON s.album_id = al.id
+			WHERE ps.playlist_id = $1
+			ORDER BY ps.position, ps.added_at, ps.id
+		`, id)
+
+		if err != nil {
+			log.Printf("Error getting playlist songs: %v", err)
+			playlist.Entry = []Child{}
+		} else {
+			defer rows.Close()
+			playlist.Entry = s.scanSongs(rows)
+			s.annotateSongs(currentUserId, playlist.Entry)
+			if err := s.fillPlaylistAdders(id, playlist.Entry); err != nil {
+				log.Printf("Error getting who added the songs of playlist %s: %v", id, err)
+			}
+		}
 	}
 
 	playlist.SongCount = len(playlist.Entry)
@@ -1668,140 +1623,192 @@ func (s *Service) GetPlaylist(c *gin.Context) {
 	s.sendResponse(c, &playlist)
 }
 
-// CreatePlaylist - Creates a new playlist
+// CreatePlaylist - Creates a new playlist, or replaces the songs of an existing one
+// when playlistId is given
 func (s *Service) CreatePlaylist(c *gin.Context) {
 	name := c.Query("name")
-	if name == "" {
-		s.sendError(c, 10, "Required parameter 'name' is missing")
+	playlistIdStr := c.Query("playlistId")
+	if name == "" && !s.isValidID(playlistIdStr) {
+		s.sendError(c, 10, "Required parameter 'name' or 'playlistId' is missing")
 		return
 	}
 
-	// Get user from context
-	userId := 1 // TODO: Get from authenticated user context
-
-	comment := c.Query("comment")
-
-	// Get song IDs to add
-	songIds := c.QueryArray("songId")
+	songIds, err := queryInts(c, "songId")
+	if err != nil {
+		s.sendError(c, 10, err.Error())
+		return
+	}
 
-	// Create playlist
-	var playlistId int
-	err := s.db.QueryRow(`
-		INSERT INTO playlists (user_id, name, comment, is_public, created_at, updated_at)
-		VALUES ($1, $2, $3, false, NOW(), NOW())
-		RETURNING id
-	`, userId, name, comment).Scan(&playlistId)
+	userId := s.getUserID(c)
 
+	tx, err := s.db.Begin()
 	if err != nil {
-		log.Printf("Error creating playlist: %v", err)
-		s.sendError(c, 0, "Failed to create playlist")
+		s.sendError(c, 0, "Database error")
 		return
 	}
+	defer tx.Rollback()
 
-	// Add songs to playlist
-	for i, songId := range songIds {
-		_, err := s.db.Exec(`
-			INSERT INTO playlist_songs (playlist_id, song_id, position, added_at)
-			VALUES ($1, $2, $3, NOW())
-		`, playlistId, songId, i)
+	if err := checkSongsExist(tx, songIds); err != nil {
+		s.sendPlaylistEditError(c, "Failed to create playlist", err)
+		return
+	}
 
+	var playlistId int
+	if s.isValidID(playlistIdStr) {
+		if playlistId, err = strconv.Atoi(playlistIdStr); err != nil {
+			s.sendError(c, 10, "Parameter 'playlistId' is invalid")
+			return
+		}
+		access, err := lockPlaylist(tx, playlistId, userId)
+		if err == nil {
+			err = access.checkEditSongs()
+		}
+		if err == nil && name != "" {
+			err = access.checkOwner()
+		}
+		if err == nil {
+			err = access.checkChanged(c.Query("changed"))
+		}
 		if err != nil {
-			log.Printf("Error adding song %s to playlist: %v", songId, err)
+			s.sendPlaylistEditError(c, "Failed to update playlist", err)
+			return
+		}
+		if name != "" {
+			if _, err := tx.Exec("UPDATE playlists SET name = $1 WHERE id = $2", name, playlistId); err != nil {
+				s.sendPlaylistEditError(c, "Failed to update playlist", err)
+				return
+			}
 		}
+	} else {
+		err = tx.QueryRow(`
+			INSERT INTO playlists (user_id, name, comment, is_public, created_at, updated_at)
+			VALUES ($1, $2, $3, false, NOW(), NOW())
+			RETURNING id
+		`, userId, name, c.Query("comment")).Scan(&playlistId)
+		if err != nil {
+			s.sendPlaylistEditError(c, "Failed to create playlist", err)
+			return
+		}
+	}
+
+	// The song list replaces whatever the playlist contained
+	err = writePlaylistEntries(tx, playlistId, userId, newEntries(songIds))
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to create playlist", err)
+		return
 	}
 
 	s.sendResponse(c, nil)
 }
 
-// UpdatePlaylist - Updates a playlist
+// UpdatePlaylist - Updates a playlist. songIndexToRemove and songIdToAdd may be repeated;
+// indexes refer to the order before the update, removals are applied before additions and
+// the remaining songs are renumbered, all in a single transaction. Editors may change the
+// songs; clients can send the "changed" value they read to reject concurrent edits.
 func (s *Service) UpdatePlaylist(c *gin.Context) {
-	playlistId := c.Query("playlistId")
-	if !s.isValidID(playlistId) {
+	playlistId, err := strconv.Atoi(c.Query("playlistId"))
+	if err != nil {
 		s.sendError(c, 10, "Required parameter 'playlistId' is missing or invalid")
 		return
 	}
 
-	// Get user from context
-	userId := 1 // TODO: Get from authenticated user context
-
-	// Check if user owns the playlist
-	var ownerId int
-	err := s.db.QueryRow("SELECT user_id FROM playlists WHERE id = $1", playlistId).Scan(&ownerId)
+	songIdsToAdd, err := queryInts(c, "songIdToAdd")
 	if err != nil {
-		if err == sql.ErrNoRows {
-			s.sendError(c, 70, "Playlist not found")
-		} else {
-			s.sendError(c, 0, "Database error")
-		}
+		s.sendError(c, 10, err.Error())
 		return
 	}
-
-	if ownerId != userId {
-		s.sendError(c, 50, "User is not authorized to update this playlist")
+	indexesToRemove, err := queryInts(c, "songIndexToRemove")
+	if err != nil {
+		s.sendError(c, 10, err.Error())
 		return
 	}
+	editSongs := len(songIdsToAdd) > 0 || len(indexesToRemove) > 0
 
 	// Update playlist metadata
 	name := c.Query("name")
 	comment := c.Query("comment")
 	isPublic := c.Query("public")
+	editMetadata := name != "" || comment != "" || isPublic != "" || c.Query("rules") != ""
+
+	userId := s.getUserID(c)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Editors may change the songs, only the owner the rest. Smart playlists accept
+	// metadata and rules but no song edits.
+	access, err := lockPlaylist(tx, playlistId, userId)
+	if err == nil && editSongs {
+		err = access.checkEditSongs()
+	}
+	if err == nil && (editMetadata || !editSongs) {
+		err = access.checkOwner()
+	}
+	if err == nil {
+		err = access.checkChanged(c.Query("changed"))
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist", err)
+		return
+	}
+
+	if !s.updatePlaylistRules(c, tx, playlistId, access.isSmart) {
+		return
+	}
 
 	if name != "" {
-		_, err := s.db.Exec("UPDATE playlists SET name = $1, updated_at = NOW() WHERE id = $2", name, playlistId)
-		if err != nil {
-			log.Printf("Error updating playlist name: %v", err)
+		if _, err := tx.Exec("UPDATE playlists SET name = $1, updated_at = NOW() WHERE id = $2", name, playlistId); err != nil {
+			s.sendPlaylistEditError(c, "Failed to update playlist", err)
+			return
 		}
 	}
 
 	if comment != "" {
-		_, err := s.db.Exec("UPDATE playlists SET comment = $1, updated_at = NOW() WHERE id = $2", comment, playlistId)
-		if err != nil {
-			log.Printf("Error updating playlist comment: %v", err)
+		if _, err := tx.Exec("UPDATE playlists SET comment = $1, updated_at = NOW() WHERE id = $2", comment, playlistId); err != nil {
+			s.sendPlaylistEditError(c, "Failed to update playlist", err)
+			return
 		}
 	}
 
 	if isPublic != "" {
 		publicBool := isPublic == "true"
-		_, err := s.db.Exec("UPDATE playlists SET is_public = $1, updated_at = NOW() WHERE id = $2", publicBool, playlistId)
-		if err != nil {
-			log.Printf("Error updating playlist visibility: %v", err)
+		if _, err := tx.Exec("UPDATE playlists SET is_public = $1, updated_at = NOW() WHERE id = $2", publicBool, playlistId); err != nil {
+			s.sendPlaylistEditError(c, "Failed to update playlist", err)
+			return
 		}
 	}
 
-	// Add song to playlist
-	songIdToAdd := c.Query("songIdToAdd")
-	if songIdToAdd != "" {
-		// Get max position
-		var maxPosition int
-		s.db.QueryRow("SELECT COALESCE(MAX(position), -1) FROM playlist_songs WHERE playlist_id = $1", playlistId).Scan(&maxPosition)
-
-		_, err := s.db.Exec(`
-			INSERT INTO playlist_songs (playlist_id, song_id, position, added_at)
-			VALUES ($1, $2, $3, NOW())
-		`, playlistId, songIdToAdd, maxPosition+1)
-
-		if err != nil {
-			log.Printf("Error adding song to playlist: %v", err)
+	if editSongs {
+		if err := checkSongsExist(tx, songIdsToAdd); err != nil {
+			s.sendPlaylistEditError(c, "Failed to update playlist", err)
+			return
 		}
-	}
-
-	// Remove song from playlist
-	songIndexToRemove := c.Query("songIndexToRemove")
-	if songIndexToRemove != "" {
-		index, err := strconv.Atoi(songIndexToRemove)
-		if err == nil {
-			// Delete song at position
-			_, err := s.db.Exec(`
-				DELETE FROM playlist_songs 
-				WHERE playlist_id = $1 AND position = $2
-			`, playlistId, index)
 
+		err := editPlaylistSongs(tx, playlistId, userId, func(entries []playlistEntry) ([]playlistEntry, error) {
+			entries, err := removeEntries(entries, indexesToRemove)
 			if err != nil {
-				log.Printf("Error removing song from playlist: %v", err)
+				return nil, err
 			}
+			return append(entries, newEntries(songIdsToAdd)...), nil
+		})
+		if err != nil {
+			s.sendPlaylistEditError(c, "Failed to update playlist", err)
+			return
 		}
 	}
 
+	if err := tx.Commit(); err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist", err)
+		return
+	}
+
 	s.sendResponse(c, nil)
 }
 
@@ -1813,33 +1820,41 @@ func (s *Service) DeletePlaylist(c *gin.Context) {
 		return
 	}
 
-	// Get user from context
-	userId := 1 // TODO: Get from authenticated user context
+	playlistId, err := strconv.Atoi(id)
+	if err != nil {
+		s.sendError(c, 10, "Parameter 'id' is invalid")
+		return
+	}
 
-	// Check if user owns the playlist
-	var ownerId int
-	err := s.db.QueryRow("SELECT user_id FROM playlists WHERE id = $1", id).Scan(&ownerId)
+	tx, err := s.db.Begin()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			s.sendError(c, 70, "Playlist not found")
-		} else {
-			s.sendError(c, 0, "Database error")
-		}
+		s.sendError(c, 0, "Database error")
 		return
 	}
+	defer tx.Rollback()
 
-	if ownerId != userId {
-		s.sendError(c, 50, "User is not authorized to delete this playlist")
+	// Only the owner may delete a playlist, not its editors
+	access, err := lockPlaylist(tx, playlistId, s.getUserID(c))
+	if err == nil {
+		err = access.checkOwner()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to delete playlist", err)
 		return
 	}
 
 	// Delete playlist (cascade will delete playlist_songs)
-	_, err = s.db.Exec("DELETE FROM playlists WHERE id = $1", id)
+	var customCover sql.NullString
+	err = tx.QueryRow("DELETE FROM playlists WHERE id = $1 RETURNING cover_art_path", playlistId).Scan(&customCover)
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("Error deleting playlist: %v", err)
 		s.sendError(c, 0, "Failed to delete playlist")
 		return
 	}
+	s.removePlaylistCovers(id, customCover.String)
 
 	s.sendResponse(c, nil)
 }
@@ -1854,6 +1869,28 @@ func (s *Service) GetCoverArt(c *gin.Context) {
 		return
 	}
 
+	// Podcast channel artwork uses "pc-<id>" IDs
+	if strings.HasPrefix(id, "pc-") {
+		channelID, err := strconv.Atoi(strings.TrimPrefix(id, "pc-"))
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		coverPath, err := s.podcast.ChannelCover(channelID)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		s.serveCoverFile(c, coverPath)
+		return
+	}
+
+	// Playlist covers use "pl-<id>-<version>" IDs
+	if strings.HasPrefix(id, "pl-") {
+		s.servePlaylistCover(c, strings.TrimPrefix(id, "pl-"))
+		return
+	}
+
 	// The ID can be either an album ID or a song ID
 	// First, try to get cover art from album
 	var coverArtPath sql.NullString
@@ -1877,13 +1914,7 @@ func (s *Service) GetCoverArt(c *gin.Context) {
 	}
 
 	// Ensure the cover art path is absolute or relative to working directory
-	coverPath := coverArtPath.String
-	if !filepath.IsAbs(coverPath) {
-		// Normalize path separators for the current OS
-		coverPath = filepath.FromSlash(coverPath)
-		// Make relative paths relative to current working directory
-		coverPath = filepath.Join(".", coverPath)
-	}
+	coverPath := coverFilePath(coverArtPath.String)
 
 	// Read the cover art file
 	coverData, err := os.ReadFile(coverPath)
@@ -1940,10 +1971,10 @@ func (s *Service) GetUser(c *gin.Context) {
 	var isAdmin bool
 
 	err := s.db.QueryRow(`
-		SELECT username, email, is_admin
+		SELECT username, email, is_admin, share_role
 		FROM users
 		WHERE username = $1
-	`, username).Scan(&user.Username, &email, &isAdmin)
+	`, username).Scan(&user.Username, &email, &isAdmin, &user.ShareRole)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1963,10 +1994,9 @@ func (s *Service) GetUser(c *gin.Context) {
 	user.PlaylistRole = true
 	user.CoverArtRole = true
 	user.CommentRole = true
-	user.PodcastRole = true
+	user.PodcastRole = isAdmin
 	user.StreamRole = true
 	user.JukeboxRole = false
-	user.ShareRole = true
 	user.VideoConversionRole = false
 
 	s.sendResponse(c, &user)
@@ -2182,7 +2212,76 @@ func (s *Service) Unstar(c *gin.Context) {
 	s.sendResponse(c, nil)
 }
 
-func (s *Service) SetRating(c *gin.Context) { s.sendResponse(c, nil) }
+// ratingTables maps the kinds of items that can be rated to their table and ID column
+var ratingTables = map[string][2]string{
+	"song":   {"ratings", "song_id"},
+	"album":  {"album_ratings", "album_id"},
+	"artist": {"artist_ratings", "artist_id"},
+}
+
+// SetRating - Sets the rating of a song, album or artist, 0 removing it. Songs, albums
+// and artists share ID ranges, so "id" is rated only when it names a single item and
+// rejected as ambiguous otherwise; albumId and artistId select the kind explicitly.
+func (s *Service) SetRating(c *gin.Context) {
+	rating, err := strconv.Atoi(c.Query("rating"))
+	if err != nil || rating < 0 || rating > 5 {
+		s.sendError(c, 10, "Required parameter 'rating' is missing or invalid")
+		return
+	}
+
+	candidates, id := []string{"song", "album", "artist"}, c.Query("id")
+	if albumId := c.Query("albumId"); albumId != "" {
+		candidates, id = []string{"album"}, albumId
+	} else if artistId := c.Query("artistId"); artistId != "" {
+		candidates, id = []string{"artist"}, artistId
+	}
+	if !s.isValidID(id) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		var exists bool
+		err := s.db.QueryRow("SELECT EXISTS(SELECT 1 FROM "+candidate+"s WHERE id = $1)", id).Scan(&exists)
+		if err != nil {
+			s.sendError(c, 0, "Database error")
+			return
+		}
+		if exists {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		s.sendError(c, 70, "Item not found")
+		return
+	}
+	if len(matches) > 1 {
+		s.sendError(c, 10, "Parameter 'id' is ambiguous, it matches a "+strings.Join(matches, ", ")+
+			"; use albumId or artistId to rate an album or artist")
+		return
+	}
+	kind := matches[0]
+
+	table, column := ratingTables[kind][0], ratingTables[kind][1]
+	userId := s.getUserID(c)
+	if rating == 0 {
+		_, err = s.db.Exec("DELETE FROM "+table+" WHERE user_id = $1 AND "+column+" = $2", userId, id)
+	} else {
+		_, err = s.db.Exec(`
+			INSERT INTO `+table+` (user_id, `+column+`, rating, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (user_id, `+column+`) DO UPDATE SET rating = EXCLUDED.rating, updated_at = NOW()
+		`, userId, id, rating)
+	}
+	if err != nil {
+		log.Printf("Error rating %s %s: %v", kind, id, err)
+		s.sendError(c, 0, "Failed to set rating")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
 
 // Scrobble - Registers the local playback of one or more media files
 func (s *Service) Scrobble(c *gin.Context) {
@@ -2229,8 +2328,13 @@ func (s *Service) Scrobble(c *gin.Context) {
 				if err != nil {
 					log.Printf("Error scrobbling song %s: %v", songId, err)
 				}
+
+				s.forwardScrobble(userId, songId, playedAt)
 			}
 		}
+	} else if len(songIds) > 0 && s.isValidID(songIds[0]) {
+		// A non-submission scrobble is a "now playing" notification
+		s.forwardNowPlaying(userId, songIds[0])
 	}
 
 	s.sendResponse(c, nil)
@@ -2264,6 +2368,8 @@ func (s *Service) SetNowPlaying(c *gin.Context) {
 		return
 	}
 
+	s.forwardNowPlaying(userId, songId)
+
 	s.sendResponse(c, nil)
 }
 
@@ -2301,68 +2407,61 @@ func (s *Service) GetArtistInfo2(c *gin.Context) {
 		}
 	}
 
-	// Try to get artist info and similar artists from Last.fm if available
-	if s.lastfm != nil {
-		log.Printf("GetArtistInfo2: Requesting artist info from Last.fm for: %s", artistName)
+	// Ask the metadata agents for the biography, images and similar artists
+	artistRef := s.artistRef(id, artistName)
 
-		// Get basic artist info (biography, images, etc)
-		if lastfmInfo, err := s.lastfm.GetArtistInfo(artistName); err == nil {
-			// Set biography
-			if lastfmInfo.Artist.Bio.Summary != "" {
-				artistInfo.Biography = lastfmInfo.Artist.Bio.Summary
-			}
+	if info, err := s.agents.ArtistInfo(artistRef); err == nil {
+		if info.Biography != "" {
+			artistInfo.Biography = info.Biography
+		}
+		artistInfo.MusicBrainzID = info.MBID
+		artistInfo.LastFmUrl = info.URL
+		artistInfo.SmallImageUrl = info.SmallImageURL
+		artistInfo.MediumImageUrl = info.MediumImageURL
+		artistInfo.LargeImageUrl = info.LargeImageURL
+	} else {
+		log.Printf("GetArtistInfo2: No artist info for %s: %v", artistName, err)
+	}
 
-			// Set MusicBrainz ID if available
-			if lastfmInfo.Artist.MBID != "" {
-				artistInfo.MusicBrainzID = lastfmInfo.Artist.MBID
-			}
+	// Only artists in the library are returned unless includeNotPresent is set,
+	// so ask for more candidates than needed
+	includeNotPresent := c.Query("includeNotPresent") == "true"
+	limit := count
+	if !includeNotPresent {
+		limit = 100
+	}
 
-			// Set Last.fm URL
-			if lastfmInfo.Artist.URL != "" {
-				artistInfo.LastFmUrl = lastfmInfo.Artist.URL
-			}
+	if similarArtists, err := s.agents.SimilarArtists(artistRef, limit); err == nil {
+		log.Printf("GetArtistInfo2: Found %d similar artists before matching", len(similarArtists))
 
-			// Set images if available
-			for _, img := range lastfmInfo.Artist.Images {
-				switch img.Size {
-				case "small":
-					artistInfo.SmallImageUrl = img.Text
-				case "medium":
-					artistInfo.MediumImageUrl = img.Text
-				case "large":
-					artistInfo.LargeImageUrl = img.Text
-				}
-			}
-		} else {
-			log.Printf("GetArtistInfo2: Error getting artist info from Last.fm: %v", err)
+		names := make([]string, len(similarArtists))
+		for i, artist := range similarArtists {
+			names[i] = artist.Name
+		}
+		localArtists, err := s.loadLocalArtists(names)
+		if err != nil {
+			log.Printf("GetArtistInfo2: Error loading library artists: %v", err)
 		}
 
-		// Get similar artists with the new method
-		if similarArtists, err := s.lastfm.GetSimilarArtists(artistName, count); err == nil {
-			log.Printf("GetArtistInfo2: Found %d similar artists from Last.fm before filtering",
-				len(similarArtists.SimilarArtists.Artist))
-
-			// Convert Last.fm similar artists directly to ArtistID3 objects
-			// Filter out artists with "&" or "," in their names
-			for _, artist := range similarArtists.SimilarArtists.Artist {
-				// Skip artists with multiple names
-				if strings.Contains(artist.Name, "&") || strings.Contains(artist.Name, ",") {
-					log.Printf("GetArtistInfo2: Skipping multiple artist name: %s", artist.Name)
-					continue
-				}
+		seen := map[string]bool{id: true}
+		for _, artist := range similarArtists {
+			if len(artistInfo.SimilarArtist) >= count {
+				break
+			}
 
-				similarArtist := ArtistID3{
-					Name:       artist.Name,
-					AlbumCount: 0, // Since we're not checking local database
+			if local, ok := localArtists[matching.NormalizeArtist(artist.Name)]; ok {
+				if !seen[local.ID] {
+					seen[local.ID] = true
+					artistInfo.SimilarArtist = append(artistInfo.SimilarArtist, local)
 				}
-				artistInfo.SimilarArtist = append(artistInfo.SimilarArtist, similarArtist)
+			} else if includeNotPresent {
+				artistInfo.SimilarArtist = append(artistInfo.SimilarArtist, ArtistID3{Name: artist.Name})
 			}
-
-			log.Printf("GetArtistInfo2: Added %d similar artists from Last.fm after filtering",
-				len(artistInfo.SimilarArtist))
-		} else {
-			log.Printf("GetArtistInfo2: Error getting similar artists from Last.fm: %v", err)
 		}
+
+		log.Printf("GetArtistInfo2: Returning %d similar artists", len(artistInfo.SimilarArtist))
+	} else {
+		log.Printf("GetArtistInfo2: No similar artists for %s: %v", artistName, err)
 	}
 
 	s.sendResponse(c, artistInfo)
@@ -2397,8 +2496,28 @@ func parseIntDefault(s string, defaultValue int) int {
 	return defaultValue
 }
 
-// GetSimilarSongs2 returns songs similar to the given song using Last.fm API
+// GetSimilarSongs returns songs similar to the given song (Subsonic API v1)
+func (s *Service) GetSimilarSongs(c *gin.Context) {
+	songs, ok := s.similarSongs(c)
+	if !ok {
+		return
+	}
+	s.sendResponse(c, &SimilarSongs{Song: songs})
+}
+
+// GetSimilarSongs2 returns songs similar to the given song
 func (s *Service) GetSimilarSongs2(c *gin.Context) {
+	songs, ok := s.similarSongs(c)
+	if !ok {
+		return
+	}
+	s.sendResponse(c, &SimilarSongs2{Song: songs})
+}
+
+// similarSongs builds the list shared by getSimilarSongs and getSimilarSongs2: tracks suggested
+// by the metadata agents, then local recommendations from play history, then songs of the same
+// artist, album or genre. It sends the error response itself when it returns false.
+func (s *Service) similarSongs(c *gin.Context) ([]Child, bool) {
 	// Get user ID for logging
 	userID := s.getUserID(c)
 	username := c.GetString("username")
@@ -2409,7 +2528,7 @@ func (s *Service) GetSimilarSongs2(c *gin.Context) {
 	if id == "" {
 		log.Printf("GetSimilarSongs2: Missing ID parameter")
 		s.sendError(c, 10, "Required parameter 'id' is missing")
-		return
+		return nil, false
 	}
 
 	size := 50 // default size
@@ -2434,11 +2553,11 @@ func (s *Service) GetSimilarSongs2(c *gin.Context) {
 		if err == sql.ErrNoRows {
 			log.Printf("GetSimilarSongs2: Song ID %s not found in database", id)
 			s.sendError(c, 70, "Song not found")
-			return
+			return nil, false
 		}
 		log.Printf("GetSimilarSongs2: Database error: %v", err)
 		s.sendError(c, 0, "Database error")
-		return
+		return nil, false
 	}
 
 	var songs []Child
@@ -2446,28 +2565,29 @@ func (s *Service) GetSimilarSongs2(c *gin.Context) {
 	// Debug logging
 	log.Printf("GetSimilarSongs2: Found song in database: %s - %s (Album ID: %d)", artistName, songTitle, albumId)
 
-	// Try to get similar songs from Last.fm
-	if s.lastfm != nil {
-		log.Printf("GetSimilarSongs2: Requesting similar tracks from Last.fm for %s - %s", artistName, songTitle)
-		similarTracks, err := s.lastfm.GetSimilarTracks(artistName, songTitle)
-		if err == nil && len(similarTracks.Track) > 0 {
-			log.Printf("GetSimilarSongs2: Last.fm returned %d similar tracks", len(similarTracks.Track))
-			// Convert Last.fm tracks to local songs
-			songs = s.findLocalSongsFromLastFM(similarTracks.Track, size)
-			log.Printf("GetSimilarSongs2: Found %d local matches from Last.fm recommendations", len(songs))
-		} else {
-			log.Printf("GetSimilarSongs2: Last.fm error or no results: %v", err)
-		}
+	// Try to get similar songs from the metadata agents
+	log.Printf("GetSimilarSongs2: Requesting similar tracks for %s - %s", artistName, songTitle)
+	similarTracks, err := s.agents.SimilarTracks(metadata.Artist{Name: artistName}, songTitle, size*2)
+	if err == nil {
+		log.Printf("GetSimilarSongs2: Agents returned %d similar tracks", len(similarTracks))
+		// Convert suggested tracks to local songs
+		songs = s.findLocalSongsFromAgents(similarTracks, size)
+		log.Printf("GetSimilarSongs2: Found %d local matches from recommendations", len(songs))
 	} else {
-		log.Printf("GetSimilarSongs2: Last.fm service not initialized")
+		log.Printf("GetSimilarSongs2: No similar tracks: %v", err)
 	}
 
-	// If we don't have enough songs from Last.fm, use fallback strategy
+	// If we don't have enough songs from the agents, use fallback strategy
 	minSongs := size / 4 // Al menos 25% de las canciones solicitadas
 	if minSongs < 1 {
 		minSongs = 1 // Asegurar al menos 1 canción como mínimo
 	}
 
+	if len(songs) < minSongs {
+		songs = s.appendRecommendedSongs(songs, userID, id, size)
+		log.Printf("GetSimilarSongs2: %d songs after local recommendations", len(songs))
+	}
+
 	if len(songs) < minSongs {
 		log.Printf("GetSimilarSongs2: Using fallback strategy (current: %d, min needed: %d)", len(songs), minSongs)
 		fallbackSongs := s.getFallbackSimilarSongs(artistName, albumId, size-len(songs), id)
@@ -2482,81 +2602,56 @@ func (s *Service) GetSimilarSongs2(c *gin.Context) {
 		songs = songs[:size]
 	}
 
-	result := &SimilarSongs2{
-		Song: songs,
-	}
-
-	s.sendResponse(c, result)
+	s.annotateSongs(userID, songs)
+	return songs, true
 }
 
-// findLocalSongsFromLastFM tries to find local songs that match Last.fm recommendations
-func (s *Service) findLocalSongsFromLastFM(lastfmTracks interface{}, limit int) []Child {
-	var songs []Child
-	processedTracks := make(map[string]bool) // To avoid duplicates
-
-	// Process tracks based on their type
-	switch tracks := lastfmTracks.(type) {
-	case []lastfm.TopTrack:
-		for _, track := range tracks {
-			if len(songs) >= limit {
-				break
-			}
-
-			// Normalize title and artist for better matching
-			normalizedTitle := strings.ToLower(strings.TrimSpace(track.Name))
-			normalizedArtist := strings.ToLower(strings.TrimSpace(track.Artist.Name))
-
-			// Generate a unique key for this combination
-			trackKey := fmt.Sprintf("%s-%s", normalizedArtist, normalizedTitle)
+// findLocalSongsFromAgents resolves the tracks suggested by the metadata agents to library songs,
+// keeping the agent order and skipping tracks that resolve to an already added song
+func (s *Service) findLocalSongsFromAgents(tracks []metadata.Track, limit int) []Child {
+	queries := make([]matching.Query, len(tracks))
+	for i, track := range tracks {
+		queries[i] = matching.Query{Title: track.Title, Artist: track.Artist}
+	}
 
-			// Avoid processing the same song more than once
-			if processedTracks[trackKey] {
-				continue
-			}
-			processedTracks[trackKey] = true
+	matches, err := s.matcher.Resolve(queries)
+	if err != nil {
+		log.Printf("Error matching %d agent tracks to the library: %v", len(tracks), err)
+		return nil
+	}
 
-			song := s.findLocalSong(track.Name, track.Artist.Name)
-			if song != nil {
-				log.Printf("Found local match for '%s - %s'", track.Artist.Name, track.Name)
-				songs = append(songs, *song)
-			} else {
-				log.Printf("No local match found for '%s - %s'", track.Artist.Name, track.Name)
-			}
+	var ids []string
+	seen := make(map[int]bool)
+	for i, match := range matches {
+		if match.SongID == 0 {
+			log.Printf("No local match found for '%s - %s'", tracks[i].Artist, tracks[i].Title)
+			continue
 		}
+		if seen[match.SongID] {
+			continue
+		}
+		seen[match.SongID] = true
+		log.Printf("Matched '%s - %s' to '%s - %s' (confidence %.2f)",
+			tracks[i].Artist, tracks[i].Title, match.Artist, match.Title, match.Confidence)
 
-	case []lastfm.SimilarTrack:
-		for _, track := range tracks {
-			if len(songs) >= limit {
-				break
-			}
-
-			// Normalize title and artist for better matching
-			normalizedTitle := strings.ToLower(strings.TrimSpace(track.Name))
-			normalizedArtist := strings.ToLower(strings.TrimSpace(track.Artist.Name))
-
-			// Generate a unique key for this combination
-			trackKey := fmt.Sprintf("%s-%s", normalizedArtist, normalizedTitle)
-
-			// Avoid processing the same song more than once
-			if processedTracks[trackKey] {
-				continue
-			}
-			processedTracks[trackKey] = true
-
-			song := s.findLocalSong(track.Name, track.Artist.Name)
-			if song != nil {
-				log.Printf("Found local match for '%s - %s'", track.Artist.Name, track.Name)
-				songs = append(songs, *song)
-			} else {
-				log.Printf("No local match found for '%s - %s'", track.Artist.Name, track.Name)
-			}
+		ids = append(ids, strconv.Itoa(match.SongID))
+		if len(ids) >= limit {
+			break
 		}
+	}
 
-	default:
-		log.Printf("findLocalSongsFromLastFM: Unsupported track type: %T", lastfmTracks)
-		return songs
+	songsByID, err := s.getSongsByIDs(ids)
+	if err != nil {
+		log.Printf("Error loading matched songs: %v", err)
+		return nil
 	}
 
+	songs := make([]Child, 0, len(ids))
+	for _, id := range ids {
+		if song, ok := songsByID[id]; ok {
+			songs = append(songs, song)
+		}
+	}
 	return songs
 }
 
@@ -2626,35 +2721,50 @@ func (s *Service) getFallbackSimilarSongs(artistName string, albumId int, limit
 	return songs
 }
 
-// scanSongs is a helper function to scan song results
+// scanSongs is a helper function to scan song results.
+// Rows must follow the standard song column order:
+// s.id, s.title, s.track_number, s.duration, s.file_path, s.file_size, s.bitrate, s.format,
+// s.album_id, ar.name, al.name, al.year, al.genre, al.cover_art_path
 func (s *Service) scanSongs(rows *sql.Rows) []Child {
 	var songs []Child
 
 	for rows.Next() {
 		var song Child
+		var trackNumber sql.NullInt32
+		var duration, bitrate sql.NullInt32
+		var fileSize sql.NullInt64
 		var coverArtPath sql.NullString
 		var year sql.NullInt32
 		var genre sql.NullString
 
 		err := rows.Scan(
-			&song.ID, &song.Title, &song.Track, &song.Duration, &song.Path,
-			&song.Size, &song.BitRate, &song.ContentType, &song.Parent,
-			&song.Album, &song.Artist, &genre, &year)
+			&song.ID, &song.Title, &trackNumber, &duration, &song.Path,
+			&fileSize, &bitrate, &song.Suffix, &song.Parent,
+			&song.Artist, &song.Album, &year, &genre, &coverArtPath)
 
-		if err == nil {
-			song.IsDir = false
-			if year.Valid {
-				song.Year = int(year.Int32)
-			}
-			if genre.Valid {
-				song.Genre = genre.String
-			}
-			if coverArtPath.Valid {
-				song.CoverArt = song.AlbumId
-			}
+		if err != nil {
+			log.Printf("scanSongs: Error scanning song row: %v", err)
+			continue
+		}
 
-			songs = append(songs, song)
+		song.IsDir = false
+		song.AlbumId = song.Parent
+		song.ContentType = s.getContentType("." + song.Suffix)
+		song.Track = int(trackNumber.Int32)
+		song.Duration = int(duration.Int32)
+		song.BitRate = int(bitrate.Int32)
+		song.Size = fileSize.Int64
+		if year.Valid {
+			song.Year = int(year.Int32)
 		}
+		if genre.Valid {
+			song.Genre = genre.String
+		}
+		if coverArtPath.Valid && coverArtPath.String != "" {
+			song.CoverArt = song.AlbumId
+		}
+
+		songs = append(songs, song)
 	}
 
 	return songs
@@ -2668,20 +2778,19 @@ func (s *Service) Stream(c *gin.Context) {
 		return
 	}
 
-	// Get song information from database
-	var filePath string
-	var contentType string
-	var fileSize int64
-
-	err := s.db.QueryRow(`
-		SELECT file_path, format, file_size
-		FROM songs
-		WHERE id = $1
-	`, id).Scan(&filePath, &contentType, &fileSize)
+	// Podcast episodes use "pe-<id>" stream IDs
+	if strings.HasPrefix(id, "pe-") {
+		s.streamPodcastEpisode(c, id)
+		return
+	}
 
+	// Get song information from database
+	fullPath, format, fileSize, err := s.resolveSongFile(id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			s.sendError(c, 70, "Song not found")
+		} else if os.IsNotExist(err) {
+			s.sendError(c, 70, "Media file not found")
 		} else {
 			log.Printf("Database error: %v", err)
 			s.sendError(c, 0, "Database error")
@@ -2689,6 +2798,27 @@ func (s *Service) Stream(c *gin.Context) {
 		return
 	}
 
+	s.serveAudioFile(c, fullPath, format, fileSize)
+
+	log.Printf("Streaming song ID %s to user %s", id, c.GetString("username"))
+}
+
+// resolveSongFile looks up a song and returns the absolute path of its file, its format and size.
+// It returns sql.ErrNoRows for unknown songs and an os.IsNotExist error when the file is missing.
+func (s *Service) resolveSongFile(id string) (string, string, int64, error) {
+	var filePath string
+	var format string
+	var fileSize int64
+
+	err := s.db.QueryRow(`
+		SELECT file_path, format, file_size
+		FROM songs
+		WHERE id = $1
+	`, id).Scan(&filePath, &format, &fileSize)
+	if err != nil {
+		return "", "", 0, err
+	}
+
 	// Handle file path
 	var fullPath string
 	if filepath.IsAbs(filePath) {
@@ -2707,15 +2837,19 @@ func (s *Service) Stream(c *gin.Context) {
 	log.Printf("Full Path: %s", fullPath)
 
 	// Check if file exists
-	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+	if _, err := os.Stat(fullPath); err != nil {
 		log.Printf("File not found: %s", fullPath)
-		s.sendError(c, 70, "Media file not found")
-		return
+		return "", "", 0, err
 	}
 
+	return fullPath, format, fileSize, nil
+}
+
+// serveAudioFile writes the streaming headers for the given format and serves the file
+func (s *Service) serveAudioFile(c *gin.Context, fullPath, format string, fileSize int64) {
 	// Determine content type based on format
 	mimeType := "audio/mpeg"
-	switch strings.ToLower(contentType) {
+	switch strings.ToLower(format) {
 	case "mp3":
 		mimeType = "audio/mpeg"
 	case "flac":
@@ -2737,8 +2871,6 @@ func (s *Service) Stream(c *gin.Context) {
 
 	// Serve the file
 	c.File(fullPath)
-
-	log.Printf("Streaming song ID %s to user %s", id, c.GetString("username"))
 }
 
 // Download - Downloads a given media file
@@ -2869,62 +3001,3 @@ func (s *Service) Download(c *gin.Context) {
 	log.Printf("User %s downloaded song ID %s (%d/%d downloads today)",
 		c.GetString("username"), id, downloadCount+1, maxDownloads)
 }
-
-// findLocalSong tries to find a local song that matches the given title and artist
-func (s *Service) findLocalSong(title, artistName string) *Child {
-	// Normalize input
-	normalizedTitle := strings.ToLower(strings.TrimSpace(title))
-	normalizedArtist := strings.ToLower(strings.TrimSpace(artistName))
-
-	// Variables for extra fields we need to scan but don't use in the response
-	var artistID int
-
-	// Query to find the closest match in our database
-	query := `
-		SELECT s.id, s.title, s.track_number, s.duration, s.file_path, 
-			s.file_size, s.bitrate, s.format, s.album_id,
-			al.name as album_name, ar.name as artist_name,
-			al.year, al.genre, al.cover_art_path,
-			s.artist_id, s.track_number, s.duration, s.album_id
-		FROM songs s
-		JOIN artists ar ON s.artist_id = ar.id
-		JOIN albums al ON s.album_id = al.id
-		WHERE LOWER(s.title) LIKE $1
-		AND LOWER(ar.name) LIKE $2
-		ORDER BY 
-			CASE 
-				WHEN LOWER(s.title) = $1 AND LOWER(ar.name) = $2 THEN 1
-				WHEN LOWER(s.title) = $1 THEN 2
-				WHEN LOWER(ar.name) = $2 THEN 3
-				ELSE 4
-			END,
-			s.id DESC
-		LIMIT 1`
-
-	// Add wildcards for flexible matching
-	titlePattern := "%" + normalizedTitle + "%"
-	artistPattern := "%" + normalizedArtist + "%"
-
-	var song Child
-	var coverArtPath sql.NullString
-	err := s.db.QueryRow(query, titlePattern, artistPattern).Scan(
-		&song.ID, &song.Title, &song.Track, &song.Duration, &song.Path,
-		&song.Size, &song.BitRate, &song.ContentType, &song.Parent,
-		&song.Album, &song.Artist,
-		&song.Year, &song.Genre, &coverArtPath,
-		&artistID, &song.Track, &song.Duration, &song.AlbumId,
-	)
-
-	if err != nil {
-		if err != sql.ErrNoRows {
-			log.Printf("Error searching for song '%s - %s': %v", artistName, title, err)
-		}
-		return nil
-	}
-
-	// Fill in additional fields
-	song.IsDir = false
-	song.CoverArt = song.AlbumId // Use album ID as cover art ID
-
-	return &song
-}
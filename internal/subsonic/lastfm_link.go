@@ -0,0 +1,70 @@
+package subsonic
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLastFMLink - Returns the Last.fm account linked to the current user (Castafiore extension)
+func (s *Service) GetLastFMLink(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	result := &LastFMLink{Enabled: s.scrobbler.Enabled()}
+	if result.Enabled {
+		_, username, linked := s.scrobbler.SessionKey(userId)
+		result.Linked = linked
+		result.Username = username
+	}
+
+	s.sendResponse(c, result)
+}
+
+// StartLastFMLink - Requests a Last.fm token and returns the URL where the user must authorize it.
+// Once authorized the client calls completeLastfmLink.
+func (s *Service) StartLastFMLink(c *gin.Context) {
+	if !s.scrobbler.Enabled() {
+		s.sendError(c, 0, "Last.fm scrobbling is not configured on this server")
+		return
+	}
+
+	userId := s.getUserID(c)
+	authURL, err := s.scrobbler.StartLink(userId)
+	if err != nil {
+		log.Printf("StartLastFMLink: Error requesting token: %v", err)
+		s.sendError(c, 0, "Failed to contact Last.fm")
+		return
+	}
+
+	s.sendResponse(c, &LastFMLink{Enabled: true, AuthURL: authURL})
+}
+
+// CompleteLastFMLink - Exchanges the authorized token for a Last.fm session
+func (s *Service) CompleteLastFMLink(c *gin.Context) {
+	if !s.scrobbler.Enabled() {
+		s.sendError(c, 0, "Last.fm scrobbling is not configured on this server")
+		return
+	}
+
+	userId := s.getUserID(c)
+	username, err := s.scrobbler.CompleteLink(userId)
+	if err != nil {
+		log.Printf("CompleteLastFMLink: Error getting session: %v", err)
+		s.sendError(c, 0, "Failed to link Last.fm account: "+err.Error())
+		return
+	}
+
+	s.sendResponse(c, &LastFMLink{Enabled: true, Linked: true, Username: username})
+}
+
+// UnlinkLastFM - Removes the Last.fm session of the current user
+func (s *Service) UnlinkLastFM(c *gin.Context) {
+	userId := s.getUserID(c)
+	if err := s.scrobbler.Unlink(userId); err != nil {
+		log.Printf("UnlinkLastFM: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+
+	s.sendResponse(c, &LastFMLink{Enabled: s.scrobbler.Enabled()})
+}
@@ -0,0 +1,37 @@
+package subsonic
+
+import (
+	"log"
+
+	"castafiore-backend/internal/listenbrainz"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetListenBrainzLink - Returns the ListenBrainz account of the current user (Castafiore extension)
+func (s *Service) GetListenBrainzLink(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	_, username, linked := s.listenbrainz.Token(userId)
+	s.sendResponse(c, &ListenBrainzLink{Linked: linked, Username: username})
+}
+
+// SetListenBrainzToken - Validates and stores the ListenBrainz user token of the current user.
+// An empty token unlinks the account.
+func (s *Service) SetListenBrainzToken(c *gin.Context) {
+	userId := s.getUserID(c)
+	token := c.Query("token")
+
+	username, err := s.listenbrainz.SetToken(userId, token)
+	if err == listenbrainz.ErrInvalidToken {
+		s.sendError(c, 10, "Invalid ListenBrainz token")
+		return
+	}
+	if err != nil {
+		log.Printf("SetListenBrainzToken: Error storing token: %v", err)
+		s.sendError(c, 0, "Failed to link ListenBrainz account")
+		return
+	}
+
+	s.sendResponse(c, &ListenBrainzLink{Linked: token != "", Username: username})
+}
@@ -0,0 +1,125 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"path/filepath"
+	"strconv"
+
+	"castafiore-backend/internal/database"
+	"castafiore-backend/internal/matching"
+	"castafiore-backend/internal/metadata"
+)
+
+// artistRef builds the metadata agent lookup for a library artist, including the
+// folders where artist files such as artist.txt may live
+func (s *Service) artistRef(artistId, name string) metadata.Artist {
+	artist := metadata.Artist{Name: name}
+
+	rows, err := s.db.Query(`
+		SELECT DISTINCT ON (album_id) file_path
+		FROM songs
+		WHERE artist_id = $1
+		ORDER BY album_id
+		LIMIT 50
+	`, artistId)
+	if err != nil {
+		log.Printf("Error loading folders for artist %s: %v", artistId, err)
+		return artist
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var filePath string
+		if err := rows.Scan(&filePath); err != nil {
+			continue
+		}
+		// Songs live in <artist>/<album>/, so check the album parent first
+		albumDir := filepath.Dir(s.libraryPath(filePath))
+		for _, dir := range []string{filepath.Dir(albumDir), albumDir} {
+			if !seen[dir] {
+				seen[dir] = true
+				artist.Folders = append(artist.Folders, dir)
+			}
+		}
+	}
+
+	return artist
+}
+
+// libraryPath resolves a song path stored in the database against the music folder
+func (s *Service) libraryPath(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+	return filepath.Join(s.musicPath, filePath)
+}
+
+// loadLocalArtists resolves artist names returned by the metadata agents to library
+// artists, indexed by matching.NormalizeArtist. Only the given names are looked up, by
+// their key in artists.match_name; artists whose key the startup backfill has not
+// computed yet are normalized here. When two artists normalize to the same key, the one
+// with more albums wins.
+func (s *Service) loadLocalArtists(names []string) (map[string]ArtistID3, error) {
+	artists := make(map[string]ArtistID3)
+
+	var keys []string
+	wanted := make(map[string]bool)
+	for _, name := range names {
+		key := matching.NormalizeArtist(name)
+		if key != "" && !wanted[key] {
+			wanted[key] = true
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return artists, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT ar.match_name, ar.id, ar.name,
+		       (SELECT COUNT(*) FROM albums al WHERE al.artist_id = ar.id),
+		       (SELECT MIN(al.id) FROM albums al
+		        WHERE al.artist_id = ar.id AND al.cover_art_path IS NOT NULL AND al.cover_art_path <> '')
+		FROM artists ar
+		WHERE ar.match_name = ANY($1) OR ar.match_name IS NULL
+	`, database.Strings(keys))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, albumCount int
+		var matchName sql.NullString
+		var name string
+		var coverAlbumId *int
+		if err := rows.Scan(&matchName, &id, &name, &albumCount, &coverAlbumId); err != nil {
+			return nil, err
+		}
+
+		key := matchName.String
+		if !matchName.Valid {
+			key = matching.NormalizeArtist(name)
+		}
+		if !wanted[key] {
+			continue
+		}
+
+		artist := ArtistID3{
+			ID:         strconv.Itoa(id),
+			Name:       name,
+			AlbumCount: albumCount,
+		}
+		if coverAlbumId != nil {
+			artist.CoverArt = strconv.Itoa(*coverAlbumId)
+		}
+
+		if existing, ok := artists[key]; !ok || artist.AlbumCount > existing.AlbumCount {
+			artists[key] = artist
+		}
+	}
+
+	return artists, rows.Err()
+}
@@ -0,0 +1,229 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// playlistCollaborators returns the usernames a playlist is shared with, for allowedUser
+func (s *Service) playlistCollaborators(playlistId string) ([]string, error) {
+	collaborators, err := loadPlaylistCollaborators(s.db, playlistId)
+	if err != nil {
+		return nil, err
+	}
+
+	usernames := make([]string, len(collaborators))
+	for i, collaborator := range collaborators {
+		usernames[i] = collaborator.Username
+	}
+	return usernames, nil
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadPlaylistCollaborators lists the collaborators of a playlist ordered by username
+func loadPlaylistCollaborators(db queryer, playlistId interface{}) ([]PlaylistCollaborator, error) {
+	rows, err := db.Query(`
+		SELECT u.username, pc.role
+		FROM playlist_collaborators pc
+		JOIN users u ON pc.user_id = u.id
+		WHERE pc.playlist_id = $1
+		ORDER BY u.username
+	`, playlistId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collaborators := []PlaylistCollaborator{}
+	for rows.Next() {
+		var collaborator PlaylistCollaborator
+		if err := rows.Scan(&collaborator.Username, &collaborator.Role); err != nil {
+			return nil, err
+		}
+		collaborators = append(collaborators, collaborator)
+	}
+	return collaborators, rows.Err()
+}
+
+// canViewPlaylist reports whether a user may see a playlist: its owner and collaborators
+// always can, everybody else only when it is public
+func (s *Service) canViewPlaylist(playlistId, userId int) (bool, error) {
+	var visible bool
+	err := s.db.QueryRow(`
+		SELECT p.user_id = $2 OR COALESCE(p.is_public, false) OR EXISTS (
+			SELECT 1 FROM playlist_collaborators pc WHERE pc.playlist_id = p.id AND pc.user_id = $2
+		)
+		FROM playlists p
+		WHERE p.id = $1
+	`, playlistId, userId).Scan(&visible)
+	if err == sql.ErrNoRows {
+		return false, errPlaylistNotFound
+	}
+	return visible, err
+}
+
+// GetPlaylistCollaborators - Returns the collaborators of a playlist and their roles
+func (s *Service) GetPlaylistCollaborators(c *gin.Context) {
+	playlistId, err := strconv.Atoi(c.Query("playlistId"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'playlistId' is missing or invalid")
+		return
+	}
+
+	visible, err := s.canViewPlaylist(playlistId, s.getUserID(c))
+	if err != nil {
+		if err == errPlaylistNotFound {
+			s.sendError(c, 70, "Playlist not found")
+		} else {
+			s.sendError(c, 0, "Database error")
+		}
+		return
+	}
+	if !visible {
+		s.sendError(c, 50, "User is not authorized to access this playlist")
+		return
+	}
+
+	collaborators, err := loadPlaylistCollaborators(s.db, playlistId)
+	if err != nil {
+		log.Printf("GetPlaylistCollaborators: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+
+	s.sendResponse(c, &PlaylistCollaborators{
+		PlaylistID:   strconv.Itoa(playlistId),
+		Collaborator: collaborators,
+	})
+}
+
+// SetPlaylistCollaborator - Shares a playlist with a user as "viewer" or "editor", or stops
+// sharing it when role is "none". Only the owner manages collaborators.
+func (s *Service) SetPlaylistCollaborator(c *gin.Context) {
+	playlistId, err := strconv.Atoi(c.Query("playlistId"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'playlistId' is missing or invalid")
+		return
+	}
+	username := c.Query("username")
+	if username == "" {
+		s.sendError(c, 10, "Required parameter 'username' is missing")
+		return
+	}
+	role := c.DefaultQuery("role", roleEditor)
+	if role != roleViewer && role != roleEditor && role != "none" {
+		s.sendError(c, 0, "Parameter 'role' must be viewer, editor or none")
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	userId := s.getUserID(c)
+	access, err := lockPlaylist(tx, playlistId, userId)
+	if err == nil {
+		err = access.checkOwner()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist collaborators", err)
+		return
+	}
+
+	var collaboratorId int
+	err = tx.QueryRow("SELECT id FROM users WHERE username = $1", username).Scan(&collaboratorId)
+	if err == sql.ErrNoRows {
+		s.sendError(c, 70, "User not found")
+		return
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist collaborators", err)
+		return
+	}
+	if collaboratorId == userId {
+		s.sendError(c, 0, "The owner of a playlist cannot be a collaborator")
+		return
+	}
+
+	if role == "none" {
+		_, err = tx.Exec(`
+			DELETE FROM playlist_collaborators WHERE playlist_id = $1 AND user_id = $2
+		`, playlistId, collaboratorId)
+	} else {
+		_, err = tx.Exec(`
+			INSERT INTO playlist_collaborators (playlist_id, user_id, role, added_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (playlist_id, user_id)
+			DO UPDATE SET role = $3
+		`, playlistId, collaboratorId, role)
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist collaborators", err)
+		return
+	}
+
+	collaborators, err := loadPlaylistCollaborators(tx, playlistId)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist collaborators", err)
+		return
+	}
+
+	log.Printf("SetPlaylistCollaborator: Role of %s on playlist %d set to %s", username, playlistId, role)
+	s.sendResponse(c, &PlaylistCollaborators{
+		PlaylistID:   strconv.Itoa(playlistId),
+		Collaborator: collaborators,
+	})
+}
+
+// fillPlaylistAdders sets AddedBy on the entries of a playlist, listed in playlist order
+// as getPlaylist does
+func (s *Service) fillPlaylistAdders(playlistId string, entries []Child) error {
+	rows, err := s.db.Query(`
+		SELECT COALESCE(u.username, '')
+		FROM playlist_songs ps
+		JOIN songs s ON ps.song_id = s.id
+		JOIN artists ar ON s.artist_id = ar.id
+		JOIN albums al ON s.album_id = al.id
+		LEFT JOIN users u ON u.id = ps.added_by
+		WHERE ps.playlist_id = $1
+		ORDER BY ps.position, ps.added_at, ps.id
+	`, playlistId)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var adders []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return err
+		}
+		adders = append(adders, username)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// The songs may have changed between both queries
+	if len(adders) != len(entries) {
+		return nil
+	}
+	for i := range entries {
+		entries[i].AddedBy = adders[i]
+	}
+	return nil
+}
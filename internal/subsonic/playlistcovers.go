@@ -0,0 +1,368 @@
+package subsonic
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"castafiore-backend/internal/artwork"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	// maxPlaylistCoverSize limits the size of an uploaded playlist image
+	maxPlaylistCoverSize = 10 << 20
+	// maxPlaylistCoverPixels limits the decoded size of an uploaded image, a small file
+	// may declare huge dimensions that would take gigabytes to decode
+	maxPlaylistCoverPixels = 6000 * 6000
+	// playlistCoverMaxAge is how long clients may cache a versioned playlist cover
+	playlistCoverMaxAge = 30 * 24 * 60 * 60
+	// mosaicCandidates is how many album covers are considered for a mosaic, so covers
+	// that cannot be decoded can be replaced by the next ones
+	mosaicCandidates = 2 * artwork.MosaicTiles
+)
+
+// playlistCoverID names the cached mosaics of a playlist
+func playlistCoverID(playlistId string) string {
+	return "pl-" + playlistId
+}
+
+// playlistCoverArt is the cover art ID sent to clients. It carries the time the playlist
+// changed, so a new image or new songs give a new ID and cached covers are not reused.
+func playlistCoverArt(playlistId string, changed time.Time) string {
+	return playlistCoverID(playlistId) + "-" + strconv.FormatInt(changed.Unix(), 10)
+}
+
+// coverFilePath resolves a cover path stored in the database, relative paths being
+// relative to the working directory
+func coverFilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(".", filepath.FromSlash(path))
+}
+
+// servePlaylistCover sends the uploaded image of a playlist or the mosaic of its first
+// album covers. id may carry the version added by playlistCoverArt.
+func (s *Service) servePlaylistCover(c *gin.Context, id string) {
+	id, version, versioned := strings.Cut(id, "-")
+	playlistId, err := strconv.Atoi(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// Private playlists keep their cover private too
+	if visible, err := s.canViewPlaylist(playlistId, s.getUserID(c)); err != nil || !visible {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	var ownerId int
+	var rules, customCover sql.NullString
+	err = s.db.QueryRow(`
+		SELECT user_id, rules, cover_art_path FROM playlists WHERE id = $1
+	`, playlistId).Scan(&ownerId, &rules, &customCover)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	// Covers of private playlists must not be kept by shared caches. A versioned ID
+	// names a single image, so it can be cached for long.
+	if versioned && version != "" {
+		c.Header("Cache-Control", "private, max-age="+strconv.Itoa(playlistCoverMaxAge))
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+
+	if customCover.String != "" {
+		if _, err := os.Stat(customCover.String); err == nil {
+			c.File(customCover.String)
+			return
+		}
+		log.Printf("Playlist %d: Uploaded cover %s is missing, generating one", playlistId, customCover.String)
+	}
+
+	var covers []string
+	if rules.Valid {
+		covers, err = s.smartPlaylistCovers(rules.String, ownerId)
+	} else {
+		covers, err = s.playlistCovers(playlistId)
+	}
+	if err != nil {
+		log.Printf("Playlist %d: Error loading album covers: %v", playlistId, err)
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	size, _ := strconv.Atoi(c.Query("size"))
+	path, err := s.mosaics.Get(playlistCoverID(id), covers, artwork.ClampSize(size))
+	if err != nil {
+		if !errors.Is(err, artwork.ErrNoImages) {
+			log.Printf("Playlist %d: Error generating cover: %v", playlistId, err)
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.File(path)
+}
+
+// playlistCovers returns the cover files of the first distinct albums of a playlist
+func (s *Service) playlistCovers(playlistId int) ([]string, error) {
+	rows, err := s.db.Query(`
+		SELECT al.cover_art_path
+		FROM playlist_songs ps
+		JOIN songs s ON ps.song_id = s.id
+		JOIN albums al ON s.album_id = al.id
+		WHERE ps.playlist_id = $1 AND COALESCE(al.cover_art_path, '') <> ''
+		GROUP BY al.id, al.cover_art_path
+		ORDER BY MIN(ps.position), al.id
+		LIMIT $2
+	`, playlistId, mosaicCandidates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var covers []string
+	for rows.Next() {
+		var cover string
+		if err := rows.Scan(&cover); err != nil {
+			return nil, err
+		}
+		covers = append(covers, coverFilePath(cover))
+	}
+	return covers, rows.Err()
+}
+
+// smartPlaylistCovers returns the cover files of the first distinct albums matched by
+// the rules of a smart playlist
+func (s *Service) smartPlaylistCovers(rules string, ownerId int) ([]string, error) {
+	songs, err := s.smartPlaylistSongs(rules, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	var albumIds []string
+	seen := make(map[string]bool)
+	for _, song := range songs {
+		if song.AlbumId != "" && !seen[song.AlbumId] {
+			seen[song.AlbumId] = true
+			albumIds = append(albumIds, song.AlbumId)
+		}
+	}
+	if len(albumIds) == 0 {
+		return nil, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT id, cover_art_path FROM albums
+		WHERE id = ANY($1::int[]) AND COALESCE(cover_art_path, '') <> ''
+	`, pq.Array(albumIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	coverByAlbum := make(map[string]string)
+	for rows.Next() {
+		var id, cover string
+		if err := rows.Scan(&id, &cover); err != nil {
+			return nil, err
+		}
+		coverByAlbum[id] = cover
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var covers []string
+	for _, id := range albumIds {
+		if cover, ok := coverByAlbum[id]; ok {
+			covers = append(covers, coverFilePath(cover))
+			if len(covers) == mosaicCandidates {
+				break
+			}
+		}
+	}
+	return covers, nil
+}
+
+// SetPlaylistCover - Uploads a custom image for a playlist, as the "file" form field or
+// as the request body. Only the owner can change the image.
+func (s *Service) SetPlaylistCover(c *gin.Context) {
+	playlistId, err := strconv.Atoi(c.Query("playlistId"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'playlistId' is missing or invalid")
+		return
+	}
+
+	var reader io.Reader = c.Request.Body
+	if file, _, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxPlaylistCoverSize+1))
+	if err != nil || len(data) == 0 {
+		s.sendError(c, 10, "Required image is missing")
+		return
+	}
+	if len(data) > maxPlaylistCoverSize {
+		s.sendError(c, 0, "Image is too large")
+		return
+	}
+
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		s.sendError(c, 0, "Image must be a JPEG, PNG or GIF file")
+		return
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPlaylistCoverPixels {
+		s.sendError(c, 0, "Image dimensions are too large")
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	access, err := lockPlaylist(tx, playlistId, s.getUserID(c))
+	if err == nil {
+		err = access.checkOwner()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist image", err)
+		return
+	}
+
+	var previous sql.NullString
+	if err := tx.QueryRow("SELECT cover_art_path FROM playlists WHERE id = $1", playlistId).Scan(&previous); err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist image", err)
+		return
+	}
+
+	// The image only replaces the current file once the change is committed
+	path := filepath.Join(s.dataPath, "playlists", strconv.Itoa(playlistId)+"."+format)
+	tmp, err := writeTempFile(path, data)
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist image", err)
+		return
+	}
+	defer os.Remove(tmp)
+
+	_, err = tx.Exec(`
+		UPDATE playlists SET cover_art_path = $1, updated_at = NOW() WHERE id = $2
+	`, path, playlistId)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist image", err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		s.sendPlaylistEditError(c, "Failed to update playlist image", err)
+		return
+	}
+
+	if previous.String != "" && previous.String != path {
+		os.Remove(previous.String)
+	}
+	s.mosaics.Remove(playlistCoverID(strconv.Itoa(playlistId)))
+
+	s.sendResponse(c, nil)
+}
+
+// DeletePlaylistCover - Removes the custom image of a playlist, which goes back to the
+// generated mosaic
+func (s *Service) DeletePlaylistCover(c *gin.Context) {
+	playlistId, err := strconv.Atoi(c.Query("playlistId"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'playlistId' is missing or invalid")
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	access, err := lockPlaylist(tx, playlistId, s.getUserID(c))
+	if err == nil {
+		err = access.checkOwner()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to remove playlist image", err)
+		return
+	}
+
+	var previous sql.NullString
+	err = tx.QueryRow("SELECT cover_art_path FROM playlists WHERE id = $1", playlistId).Scan(&previous)
+	if err == nil {
+		_, err = tx.Exec("UPDATE playlists SET cover_art_path = NULL, updated_at = NOW() WHERE id = $1", playlistId)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to remove playlist image", err)
+		return
+	}
+
+	if previous.String != "" {
+		os.Remove(previous.String)
+	}
+	s.sendResponse(c, nil)
+}
+
+// removePlaylistCovers deletes the uploaded image and the cached mosaics of a deleted playlist
+func (s *Service) removePlaylistCovers(playlistId, customCover string) {
+	if customCover != "" {
+		if err := os.Remove(customCover); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing image of playlist %s: %v", playlistId, err)
+		}
+	}
+	s.mosaics.Remove(playlistCoverID(playlistId))
+}
+
+// writeTempFile writes data to a temporary file next to path, so it can be renamed into
+// place, and returns its name
+func writeTempFile(path string, data []byte) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+"-*")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
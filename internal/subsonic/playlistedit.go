@@ -0,0 +1,386 @@
+package subsonic
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"castafiore-backend/internal/database"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+var (
+	errPlaylistNotFound  = errors.New("playlist not found")
+	errPlaylistForbidden = errors.New("user is not allowed to edit the playlist")
+	errPlaylistReadonly  = errors.New("smart playlists are read-only")
+	errPlaylistImported  = errors.New("playlists imported from a file are read-only")
+	errPlaylistChanged   = errors.New("playlist was changed by another user")
+	errSongNotFound      = errors.New("song not found")
+	errInvalidIndex      = errors.New("index out of range")
+)
+
+// Roles of a user on a playlist. Owners manage everything, editors change the songs
+// and viewers may only see a private playlist.
+const (
+	roleOwner  = "owner"
+	roleEditor = "editor"
+	roleViewer = "viewer"
+)
+
+// playlistChangedFormat formats the changed attribute of playlists. Clients send it back
+// as the version they edited, so it keeps millisecond precision.
+const playlistChangedFormat = "2006-01-02T15:04:05.000Z"
+
+// playlistAccess is what a user may do with a locked playlist
+type playlistAccess struct {
+	role       string // empty when the user is not owner or collaborator
+	isSmart    bool
+	isImported bool // the songs come from a playlist file in the music folder
+	changed    time.Time
+}
+
+// checkEditSongs fails unless the songs of the playlist may be changed by the user
+func (a playlistAccess) checkEditSongs() error {
+	if a.role != roleOwner && a.role != roleEditor {
+		return errPlaylistForbidden
+	}
+	if a.isSmart {
+		return errPlaylistReadonly
+	}
+	if a.isImported {
+		return errPlaylistImported
+	}
+	return nil
+}
+
+// checkOwner fails unless the user owns the playlist
+func (a playlistAccess) checkOwner() error {
+	if a.role != roleOwner {
+		return errPlaylistForbidden
+	}
+	return nil
+}
+
+// checkChanged implements optimistic concurrency: when the client sends the changed
+// value it read, the edit is rejected if the playlist was modified since
+func (a playlistAccess) checkChanged(sent string) error {
+	if sent == "" {
+		return nil
+	}
+	matches, err := changedMatches(a.changed, sent)
+	if err != nil {
+		return fmt.Errorf("%w: invalid changed value %q", errPlaylistChanged, sent)
+	}
+	if !matches {
+		return errPlaylistChanged
+	}
+	return nil
+}
+
+// changedMatches compares a stored modification time with the value sent by a client,
+// at the precision the client used: clients that drop the milliseconds still match
+func changedMatches(changed time.Time, sent string) (bool, error) {
+	parsed, err := time.Parse(time.RFC3339Nano, sent)
+	if err != nil {
+		return false, err
+	}
+
+	precision := time.Millisecond
+	if !strings.Contains(sent, ".") {
+		precision = time.Second
+	}
+	return changed.Truncate(precision).Equal(parsed.Truncate(precision)), nil
+}
+
+// playlistEntry is a row of playlist_songs. id is zero for songs that are not stored yet.
+type playlistEntry struct {
+	id     int
+	songID int
+}
+
+// removeEntries drops the entries at the given indexes of the current order. Every
+// index refers to the list before any removal, so a client can send the indexes it
+// sees without adjusting them; repeated indexes remove a single entry.
+func removeEntries(entries []playlistEntry, indexes []int) ([]playlistEntry, error) {
+	if len(indexes) == 0 {
+		return entries, nil
+	}
+
+	remove := make(map[int]bool, len(indexes))
+	for _, index := range indexes {
+		if index < 0 || index >= len(entries) {
+			return nil, fmt.Errorf("%w: %d", errInvalidIndex, index)
+		}
+		remove[index] = true
+	}
+
+	result := make([]playlistEntry, 0, len(entries)-len(remove))
+	for i, entry := range entries {
+		if !remove[i] {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// moveEntries moves count entries starting at from so that the first of them ends up
+// at index to of the resulting list
+func moveEntries(entries []playlistEntry, from, to, count int) ([]playlistEntry, error) {
+	if count < 1 || from < 0 || from+count > len(entries) {
+		return nil, fmt.Errorf("%w: %d", errInvalidIndex, from)
+	}
+	if to < 0 || to+count > len(entries) {
+		return nil, fmt.Errorf("%w: %d", errInvalidIndex, to)
+	}
+
+	moved := append([]playlistEntry(nil), entries[from:from+count]...)
+	rest := make([]playlistEntry, 0, len(entries)-count)
+	rest = append(rest, entries[:from]...)
+	rest = append(rest, entries[from+count:]...)
+
+	result := make([]playlistEntry, 0, len(entries))
+	result = append(result, rest[:to]...)
+	result = append(result, moved...)
+	result = append(result, rest[to:]...)
+	return result, nil
+}
+
+// newEntries wraps song IDs that are about to be added to a playlist
+func newEntries(songIDs []int) []playlistEntry {
+	entries := make([]playlistEntry, len(songIDs))
+	for i, songID := range songIDs {
+		entries[i] = playlistEntry{songID: songID}
+	}
+	return entries
+}
+
+// queryInts parses every value of a repeated integer parameter
+func queryInts(c *gin.Context, name string) ([]int, error) {
+	values := c.QueryArray(name)
+	ints := make([]int, 0, len(values))
+	for _, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("parameter '%s' is invalid: %s", name, value)
+		}
+		ints = append(ints, n)
+	}
+	return ints, nil
+}
+
+// lockPlaylist locks a playlist row for the rest of tx and returns what userId may do with it
+func lockPlaylist(tx *sql.Tx, playlistId, userId int) (playlistAccess, error) {
+	var access playlistAccess
+	var ownerId int
+	var collaboratorRole sql.NullString
+	err := tx.QueryRow(`
+		SELECT p.user_id, p.rules IS NOT NULL, p.source_path IS NOT NULL, p.updated_at, pc.role
+		FROM playlists p
+		LEFT JOIN playlist_collaborators pc ON pc.playlist_id = p.id AND pc.user_id = $2
+		WHERE p.id = $1
+		FOR UPDATE OF p
+	`, playlistId, userId).Scan(&ownerId, &access.isSmart, &access.isImported, &access.changed, &collaboratorRole)
+	if err == sql.ErrNoRows {
+		return access, errPlaylistNotFound
+	}
+	if err != nil {
+		return access, err
+	}
+
+	if ownerId == userId {
+		access.role = roleOwner
+	} else {
+		access.role = collaboratorRole.String
+	}
+	return access, nil
+}
+
+// checkSongsExist fails with errSongNotFound if any of the songs is not in the library
+func checkSongsExist(tx *sql.Tx, songIDs []int) error {
+	if len(songIDs) == 0 {
+		return nil
+	}
+
+	unique := make(map[int]bool, len(songIDs))
+	for _, id := range songIDs {
+		unique[id] = true
+	}
+
+	var found int
+	err := tx.QueryRow("SELECT COUNT(*) FROM songs WHERE id = ANY($1::int[])", pq.Array(songIDs)).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if found != len(unique) {
+		return errSongNotFound
+	}
+	return nil
+}
+
+// editPlaylistSongs loads the songs of a locked playlist in their current order, lets
+// edit rewrite the list and stores the result with contiguous positions 0..n-1
+func editPlaylistSongs(tx *sql.Tx, playlistId, userId int, edit func([]playlistEntry) ([]playlistEntry, error)) error {
+	rows, err := tx.Query(`
+		SELECT id, song_id FROM playlist_songs
+		WHERE playlist_id = $1
+		ORDER BY position, added_at, id
+	`, playlistId)
+	if err != nil {
+		return err
+	}
+
+	var entries []playlistEntry
+	for rows.Next() {
+		var entry playlistEntry
+		if err := rows.Scan(&entry.id, &entry.songID); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	entries, err = edit(entries)
+	if err != nil {
+		return err
+	}
+	return writePlaylistEntries(tx, playlistId, userId, entries)
+}
+
+// writePlaylistEntries makes playlist_songs match entries: stored rows that are no longer
+// listed are deleted, the others renumbered, and new songs inserted at their position.
+// Existing rows keep their added_at and added_by, new ones are credited to userId.
+func writePlaylistEntries(tx *sql.Tx, playlistId, userId int, entries []playlistEntry) error {
+	var keptIDs, keptPositions, newSongs, newPositions []int
+	for position, entry := range entries {
+		if entry.id != 0 {
+			keptIDs = append(keptIDs, entry.id)
+			keptPositions = append(keptPositions, position)
+		} else {
+			newSongs = append(newSongs, entry.songID)
+			newPositions = append(newPositions, position)
+		}
+	}
+
+	_, err := tx.Exec(`
+		DELETE FROM playlist_songs
+		WHERE playlist_id = $1 AND id <> ALL($2::int[])
+	`, playlistId, database.Ints(keptIDs))
+	if err != nil {
+		return err
+	}
+
+	if len(keptIDs) > 0 {
+		_, err = tx.Exec(`
+			UPDATE playlist_songs ps SET position = v.position
+			FROM unnest($2::int[], $3::int[]) AS v(id, position)
+			WHERE ps.id = v.id AND ps.playlist_id = $1 AND ps.position IS DISTINCT FROM v.position
+		`, playlistId, pq.Array(keptIDs), pq.Array(keptPositions))
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(newSongs) > 0 {
+		_, err = tx.Exec(`
+			INSERT INTO playlist_songs (playlist_id, song_id, position, added_at, added_by)
+			SELECT $1, v.song_id, v.position, NOW(), $4
+			FROM unnest($2::int[], $3::int[]) AS v(song_id, position)
+		`, playlistId, pq.Array(newSongs), pq.Array(newPositions), userId)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE playlists SET updated_at = NOW() WHERE id = $1", playlistId)
+	return err
+}
+
+// sendPlaylistEditError maps the errors of a playlist edit to a Subsonic error response
+func (s *Service) sendPlaylistEditError(c *gin.Context, failure string, err error) {
+	switch {
+	case errors.Is(err, errPlaylistNotFound):
+		s.sendError(c, 70, "Playlist not found")
+	case errors.Is(err, errPlaylistForbidden):
+		s.sendError(c, 50, "User is not authorized to update this playlist")
+	case errors.Is(err, errPlaylistReadonly):
+		s.sendError(c, 50, "Smart playlists are read-only, update their rules instead")
+	case errors.Is(err, errPlaylistImported):
+		s.sendError(c, 50, "Playlists imported from a file are read-only, edit the file instead")
+	case errors.Is(err, errPlaylistChanged):
+		s.sendError(c, 0, "Playlist was changed by another user, reload it and try again")
+	case errors.Is(err, errSongNotFound):
+		s.sendError(c, 70, "Song not found")
+	case errors.Is(err, errInvalidIndex):
+		s.sendError(c, 0, "Playlist "+err.Error())
+	default:
+		log.Printf("%s: %v", failure, err)
+		s.sendError(c, 0, failure)
+	}
+}
+
+// MovePlaylistEntry - Moves entries of a playlist to another position, for drag and drop
+// reordering. "count" entries (default 1) starting at index "from" are moved so that the
+// first of them ends up at index "to". Like updatePlaylist it accepts "changed".
+func (s *Service) MovePlaylistEntry(c *gin.Context) {
+	playlistId, err := strconv.Atoi(c.Query("playlistId"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'playlistId' is missing or invalid")
+		return
+	}
+	from, errFrom := strconv.Atoi(c.Query("from"))
+	to, errTo := strconv.Atoi(c.Query("to"))
+	if errFrom != nil || errTo != nil {
+		s.sendError(c, 10, "Required parameters 'from' and 'to' are missing or invalid")
+		return
+	}
+	count := 1
+	if countStr := c.Query("count"); countStr != "" {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			s.sendError(c, 10, "Parameter 'count' is invalid")
+			return
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	userId := s.getUserID(c)
+	access, err := lockPlaylist(tx, playlistId, userId)
+	if err == nil {
+		err = access.checkEditSongs()
+	}
+	if err == nil {
+		err = access.checkChanged(c.Query("changed"))
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to move playlist entry", err)
+		return
+	}
+
+	err = editPlaylistSongs(tx, playlistId, userId, func(entries []playlistEntry) ([]playlistEntry, error) {
+		return moveEntries(entries, from, to, count)
+	})
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		s.sendPlaylistEditError(c, "Failed to move playlist entry", err)
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
@@ -0,0 +1,79 @@
+package subsonic
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testPlaylist creates a user, songs and an empty playlist of that user
+func testPlaylist(t *testing.T, tx *sql.Tx, songs int) (playlistId, userId int, songIds []int) {
+	t.Helper()
+
+	userId = mustInsert(t, tx, `
+		INSERT INTO users (username, email, password_hash)
+		VALUES ('playlist-test', 'playlist-test@example.com', 'x') RETURNING id`)
+	artistId := mustInsert(t, tx, "INSERT INTO artists (name) VALUES ('Test Artist') RETURNING id")
+	albumId := mustInsert(t, tx, "INSERT INTO albums (name, artist_id) VALUES ('Test Album', $1) RETURNING id", artistId)
+	for i := 0; i < songs; i++ {
+		songIds = append(songIds, mustInsert(t, tx, `
+			INSERT INTO songs (title, artist_id, album_id, file_path)
+			VALUES ($1, $2, $3, $4) RETURNING id`,
+			fmt.Sprintf("Song %d", i), artistId, albumId, fmt.Sprintf("/playlist-test/%d.mp3", i)))
+	}
+	playlistId = mustInsert(t, tx, "INSERT INTO playlists (user_id, name) VALUES ($1, 'Test') RETURNING id", userId)
+	return playlistId, userId, songIds
+}
+
+// storedSongs returns the songs of a playlist in position order
+func storedSongs(t *testing.T, tx *sql.Tx, playlistId int) []int {
+	t.Helper()
+
+	rows, err := tx.Query("SELECT song_id FROM playlist_songs WHERE playlist_id = $1 ORDER BY position", playlistId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	songs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		songs = append(songs, id)
+	}
+	return songs
+}
+
+func TestWritePlaylistEntriesReplaces(t *testing.T) {
+	tx := testTx(t)
+	playlistId, userId, songs := testPlaylist(t, tx, 3)
+
+	if err := writePlaylistEntries(tx, playlistId, userId, newEntries(songs)); err != nil {
+		t.Fatal(err)
+	}
+	// createPlaylist with playlistId passes only new entries: they replace the old ones
+	if err := writePlaylistEntries(tx, playlistId, userId, newEntries([]int{songs[2], songs[0]})); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := storedSongs(t, tx, playlistId), []int{songs[2], songs[0]}; !reflect.DeepEqual(got, want) {
+		t.Errorf("songs after replacing = %v, want %v", got, want)
+	}
+}
+
+func TestWritePlaylistEntriesRemovesAll(t *testing.T) {
+	tx := testTx(t)
+	playlistId, userId, songs := testPlaylist(t, tx, 2)
+
+	if err := writePlaylistEntries(tx, playlistId, userId, newEntries(songs)); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePlaylistEntries(tx, playlistId, userId, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := storedSongs(t, tx, playlistId); len(got) != 0 {
+		t.Errorf("songs after removing all = %v, want none", got)
+	}
+}
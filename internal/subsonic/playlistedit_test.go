@@ -0,0 +1,171 @@
+package subsonic
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// entriesOf builds stored entries whose row ID equals the song ID, to keep expectations short
+func entriesOf(songIDs ...int) []playlistEntry {
+	entries := make([]playlistEntry, len(songIDs))
+	for i, id := range songIDs {
+		entries[i] = playlistEntry{id: id, songID: id}
+	}
+	return entries
+}
+
+func TestRemoveEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []int
+		want    []playlistEntry
+	}{
+		{"none", nil, entriesOf(10, 20, 30, 40, 50)},
+		{"single", []int{1}, entriesOf(10, 30, 40, 50)},
+		{"indexes refer to the original order", []int{0, 1, 4}, entriesOf(30, 40)},
+		{"order of indexes does not matter", []int{4, 0, 1}, entriesOf(30, 40)},
+		{"repeated index removes once", []int{2, 2}, entriesOf(10, 20, 40, 50)},
+		{"all", []int{0, 1, 2, 3, 4}, []playlistEntry{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := removeEntries(entriesOf(10, 20, 30, 40, 50), tt.indexes)
+			if err != nil {
+				t.Fatalf("removeEntries() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("removeEntries(%v) = %v, want %v", tt.indexes, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoveEntriesOutOfRange(t *testing.T) {
+	for _, indexes := range [][]int{{5}, {-1}, {0, 7}} {
+		if _, err := removeEntries(entriesOf(10, 20, 30, 40, 50), indexes); !errors.Is(err, errInvalidIndex) {
+			t.Errorf("removeEntries(%v) error = %v, want errInvalidIndex", indexes, err)
+		}
+	}
+}
+
+func TestMoveEntries(t *testing.T) {
+	tests := []struct {
+		name            string
+		from, to, count int
+		want            []playlistEntry
+	}{
+		{"down", 0, 2, 1, entriesOf(20, 30, 10, 40, 50)},
+		{"up", 3, 1, 1, entriesOf(10, 40, 20, 30, 50)},
+		{"to the end", 1, 4, 1, entriesOf(10, 30, 40, 50, 20)},
+		{"in place", 2, 2, 1, entriesOf(10, 20, 30, 40, 50)},
+		{"several entries", 0, 3, 2, entriesOf(30, 40, 50, 10, 20)},
+		{"several entries up", 3, 0, 2, entriesOf(40, 50, 10, 20, 30)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := moveEntries(entriesOf(10, 20, 30, 40, 50), tt.from, tt.to, tt.count)
+			if err != nil {
+				t.Fatalf("moveEntries() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("moveEntries(%d, %d, %d) = %v, want %v", tt.from, tt.to, tt.count, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMoveEntriesOutOfRange(t *testing.T) {
+	tests := []struct{ from, to, count int }{
+		{5, 0, 1},
+		{-1, 0, 1},
+		{0, 5, 1},
+		{0, 4, 2},
+		{4, 0, 2},
+		{0, 1, 0},
+	}
+	for _, tt := range tests {
+		if _, err := moveEntries(entriesOf(10, 20, 30, 40, 50), tt.from, tt.to, tt.count); !errors.Is(err, errInvalidIndex) {
+			t.Errorf("moveEntries(%d, %d, %d) error = %v, want errInvalidIndex", tt.from, tt.to, tt.count, err)
+		}
+	}
+}
+
+func TestMoveEntriesKeepsInput(t *testing.T) {
+	entries := entriesOf(10, 20, 30)
+	if _, err := moveEntries(entries, 0, 2, 1); err != nil {
+		t.Fatalf("moveEntries() error = %v", err)
+	}
+	if !reflect.DeepEqual(entries, entriesOf(10, 20, 30)) {
+		t.Errorf("moveEntries() modified its input: %v", entries)
+	}
+}
+
+func TestChangedMatches(t *testing.T) {
+	changed := time.Date(2024, 5, 1, 10, 30, 15, 123456000, time.UTC)
+
+	tests := []struct {
+		sent string
+		want bool
+	}{
+		{changed.Format(playlistChangedFormat), true},
+		{"2024-05-01T10:30:15.123Z", true},
+		{"2024-05-01T10:30:15Z", true},
+		{"2024-05-01T12:30:15.123+02:00", true},
+		{"2024-05-01T10:30:15.124Z", false},
+		{"2024-05-01T10:30:14Z", false},
+	}
+	for _, tt := range tests {
+		got, err := changedMatches(changed, tt.sent)
+		if err != nil {
+			t.Fatalf("changedMatches(%q) error = %v", tt.sent, err)
+		}
+		if got != tt.want {
+			t.Errorf("changedMatches(%q) = %v, want %v", tt.sent, got, tt.want)
+		}
+	}
+
+	if _, err := changedMatches(changed, "yesterday"); err == nil {
+		t.Error("changedMatches() accepted an invalid date")
+	}
+}
+
+func TestPlaylistAccess(t *testing.T) {
+	tests := []struct {
+		access    playlistAccess
+		editSongs error
+		owner     error
+	}{
+		{playlistAccess{role: roleOwner}, nil, nil},
+		{playlistAccess{role: roleEditor}, nil, errPlaylistForbidden},
+		{playlistAccess{role: roleViewer}, errPlaylistForbidden, errPlaylistForbidden},
+		{playlistAccess{}, errPlaylistForbidden, errPlaylistForbidden},
+		{playlistAccess{role: roleOwner, isSmart: true}, errPlaylistReadonly, nil},
+		{playlistAccess{role: roleEditor, isSmart: true}, errPlaylistReadonly, errPlaylistForbidden},
+	}
+	for _, tt := range tests {
+		if err := tt.access.checkEditSongs(); err != tt.editSongs {
+			t.Errorf("%+v checkEditSongs() = %v, want %v", tt.access, err, tt.editSongs)
+		}
+		if err := tt.access.checkOwner(); err != tt.owner {
+			t.Errorf("%+v checkOwner() = %v, want %v", tt.access, err, tt.owner)
+		}
+	}
+}
+
+func TestCheckChanged(t *testing.T) {
+	access := playlistAccess{role: roleEditor, changed: time.Date(2024, 5, 1, 10, 30, 15, 0, time.UTC)}
+
+	if err := access.checkChanged(""); err != nil {
+		t.Errorf("checkChanged(\"\") = %v, want nil", err)
+	}
+	if err := access.checkChanged("2024-05-01T10:30:15.000Z"); err != nil {
+		t.Errorf("checkChanged(current) = %v, want nil", err)
+	}
+	if err := access.checkChanged("2024-05-01T10:29:00.000Z"); !errors.Is(err, errPlaylistChanged) {
+		t.Errorf("checkChanged(stale) = %v, want errPlaylistChanged", err)
+	}
+}
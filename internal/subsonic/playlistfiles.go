@@ -0,0 +1,60 @@
+package subsonic
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"castafiore-backend/internal/library"
+	"castafiore-backend/internal/playlistfile"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportPlaylist downloads a playlist as an M3U8 (default) or PLS file
+func (s *Service) ExportPlaylist(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "m3u8"))
+	if format != "m3u8" && format != "pls" {
+		s.sendError(c, 0, "Unsupported format, use m3u8 or pls")
+		return
+	}
+
+	visible, err := s.canViewPlaylist(id, s.getUserID(c))
+	if err != nil {
+		if err == errPlaylistNotFound {
+			s.sendError(c, 70, "Playlist not found")
+		} else {
+			s.sendError(c, 0, "Database error")
+		}
+		return
+	}
+	if !visible {
+		s.sendError(c, 50, "User is not authorized to export this playlist")
+		return
+	}
+
+	name, entries, err := library.ExportPlaylist(s.db, id, s.musicPath)
+	if err != nil {
+		log.Printf("ExportPlaylist: Error loading playlist %d: %v", id, err)
+		s.sendError(c, 0, "Failed to export playlist")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := playlistfile.Write(format, &buf, entries); err != nil {
+		s.sendError(c, 0, "Failed to export playlist")
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	c.Data(http.StatusOK, playlistfile.ContentType(format), buf.Bytes())
+}
@@ -0,0 +1,247 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// storedPlayQueue is the persisted play queue of a user
+type storedPlayQueue struct {
+	entries      []Child
+	currentIndex int // -1 when no song is current
+	position     int64
+	username     string
+	changed      time.Time
+	changedBy    string
+}
+
+// SavePlayQueue - Saves the state of the play queue for the current user
+func (s *Service) SavePlayQueue(c *gin.Context) {
+	songIds := c.QueryArray("id")
+
+	currentIndex := -1
+	if current := c.Query("current"); s.isValidID(current) {
+		for i, songId := range songIds {
+			if songId == current {
+				currentIndex = i
+				break
+			}
+		}
+		if currentIndex < 0 {
+			s.sendError(c, 10, "Parameter 'current' must be one of the queued songs")
+			return
+		}
+	}
+
+	s.savePlayQueue(c, songIds, currentIndex)
+}
+
+// SavePlayQueueByIndex - Saves the play queue using the index of the current song (OpenSubsonic)
+func (s *Service) SavePlayQueueByIndex(c *gin.Context) {
+	songIds := c.QueryArray("id")
+
+	currentIndex := -1
+	if indexStr := c.Query("currentIndex"); indexStr != "" {
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 || index >= len(songIds) {
+			s.sendError(c, 10, "Parameter 'currentIndex' is invalid")
+			return
+		}
+		currentIndex = index
+	}
+
+	s.savePlayQueue(c, songIds, currentIndex)
+}
+
+// GetPlayQueue - Returns the state of the play queue for the current user
+func (s *Service) GetPlayQueue(c *gin.Context) {
+	queue, err := s.loadPlayQueue(s.getUserID(c))
+	if err != nil {
+		log.Printf("GetPlayQueue: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	if queue == nil {
+		s.sendResponse(c, nil)
+		return
+	}
+
+	result := &PlayQueue{
+		Position:  queue.position,
+		Username:  queue.username,
+		Changed:   queue.changed.Format("2006-01-02T15:04:05Z"),
+		ChangedBy: queue.changedBy,
+		Entry:     queue.entries,
+	}
+	if queue.currentIndex >= 0 {
+		result.Current = queue.entries[queue.currentIndex].ID
+	}
+
+	s.sendResponse(c, result)
+}
+
+// GetPlayQueueByIndex - Returns the play queue with the index of the current song (OpenSubsonic)
+func (s *Service) GetPlayQueueByIndex(c *gin.Context) {
+	queue, err := s.loadPlayQueue(s.getUserID(c))
+	if err != nil {
+		log.Printf("GetPlayQueueByIndex: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	if queue == nil {
+		s.sendResponse(c, nil)
+		return
+	}
+
+	result := &PlayQueueByIndex{
+		Position:  queue.position,
+		Username:  queue.username,
+		Changed:   queue.changed.Format("2006-01-02T15:04:05Z"),
+		ChangedBy: queue.changedBy,
+		Entry:     queue.entries,
+	}
+	if queue.currentIndex >= 0 {
+		index := queue.currentIndex
+		result.CurrentIndex = &index
+	}
+
+	s.sendResponse(c, result)
+}
+
+// savePlayQueue replaces the stored queue of the current user in a single transaction.
+// An empty list of songs clears the queue.
+func (s *Service) savePlayQueue(c *gin.Context, songIds []string, currentIndex int) {
+	userId := s.getUserID(c)
+
+	position, _ := strconv.ParseInt(c.DefaultQuery("position", "0"), 10, 64)
+	changedBy := c.DefaultQuery("c", "unknown")
+
+	for _, songId := range songIds {
+		if _, err := strconv.Atoi(songId); err != nil {
+			s.sendError(c, 10, "Parameter 'id' is invalid")
+			return
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("savePlayQueue: Error starting transaction: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	// Entries are removed through ON DELETE CASCADE
+	if _, err := tx.Exec("DELETE FROM play_queues WHERE user_id = $1", userId); err != nil {
+		log.Printf("savePlayQueue: Error clearing queue: %v", err)
+		s.sendError(c, 0, "Failed to save play queue")
+		return
+	}
+
+	if len(songIds) > 0 {
+		var current interface{}
+		if currentIndex >= 0 {
+			current = currentIndex
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO play_queues (user_id, current_index, position, changed_by, updated_at)
+			VALUES ($1, $2, $3, $4, NOW())
+		`, userId, current, position, changedBy)
+		if err != nil {
+			log.Printf("savePlayQueue: Error saving queue: %v", err)
+			s.sendError(c, 0, "Failed to save play queue")
+			return
+		}
+
+		for i, songId := range songIds {
+			_, err = tx.Exec(`
+				INSERT INTO play_queue_entries (user_id, song_id, position)
+				VALUES ($1, $2, $3)
+			`, userId, songId, i)
+			if err != nil {
+				log.Printf("savePlayQueue: Error saving entry %s: %v", songId, err)
+				s.sendError(c, 70, "Song not found")
+				return
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("savePlayQueue: Error committing transaction: %v", err)
+		s.sendError(c, 0, "Failed to save play queue")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// loadPlayQueue reads the stored queue of a user, returning nil if there is none
+func (s *Service) loadPlayQueue(userId int) (*storedPlayQueue, error) {
+	queue := &storedPlayQueue{currentIndex: -1}
+	var currentIndex sql.NullInt32
+	var changedBy sql.NullString
+
+	err := s.db.QueryRow(`
+		SELECT pq.current_index, pq.position, pq.changed_by, pq.updated_at, u.username
+		FROM play_queues pq
+		JOIN users u ON pq.user_id = u.id
+		WHERE pq.user_id = $1
+	`, userId).Scan(&currentIndex, &queue.position, &changedBy, &queue.changed, &queue.username)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	queue.changedBy = changedBy.String
+
+	rows, err := s.db.Query(`
+		SELECT song_id, position FROM play_queue_entries
+		WHERE user_id = $1
+		ORDER BY position
+	`, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var songIds []string
+	var positions []int
+	for rows.Next() {
+		var songId string
+		var position int
+		if err := rows.Scan(&songId, &position); err != nil {
+			return nil, err
+		}
+		songIds = append(songIds, songId)
+		positions = append(positions, position)
+	}
+
+	songs, err := s.getSongsByIDs(songIds)
+	if err != nil {
+		return nil, err
+	}
+
+	// Songs removed from the library since the queue was saved are skipped,
+	// so the current index is resolved against the stored entry positions
+	queue.entries = []Child{}
+	for i, songId := range songIds {
+		song, ok := songs[songId]
+		if !ok {
+			continue
+		}
+		if currentIndex.Valid && positions[i] == int(currentIndex.Int32) {
+			queue.currentIndex = len(queue.entries)
+		}
+		queue.entries = append(queue.entries, song)
+	}
+
+	s.annotateSongs(userId, queue.entries)
+
+	return queue, nil
+}
@@ -0,0 +1,303 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// episodeColumns is the column order expected by scanPodcastEpisodes
+const episodeColumns = `
+	e.id, e.channel_id, e.title, e.description, e.publish_date, e.duration,
+	e.file_size, e.format, e.enclosure_type, e.status, ch.title, ch.cover_art_path`
+
+// GetPodcasts - Returns all podcast channels, optionally with their episodes
+func (s *Service) GetPodcasts(c *gin.Context) {
+	includeEpisodes := c.DefaultQuery("includeEpisodes", "true") != "false"
+	id := c.Query("id")
+
+	query := `
+		SELECT id, url, title, description, image_url, cover_art_path, status, error_message
+		FROM podcast_channels`
+	var args []interface{}
+	if s.isValidID(id) {
+		query += " WHERE id = $1"
+		args = append(args, id)
+	}
+	query += " ORDER BY title"
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		log.Printf("GetPodcasts: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	result := &Podcasts{Channel: []PodcastChannel{}}
+	for rows.Next() {
+		var channel PodcastChannel
+		var title, description, imageURL, coverArtPath, errorMessage sql.NullString
+
+		err := rows.Scan(&channel.ID, &channel.URL, &title, &description, &imageURL,
+			&coverArtPath, &channel.Status, &errorMessage)
+		if err != nil {
+			log.Printf("GetPodcasts: Error scanning channel: %v", err)
+			continue
+		}
+
+		channel.Title = title.String
+		channel.Description = description.String
+		channel.OriginalImageURL = imageURL.String
+		channel.ErrorMessage = errorMessage.String
+		if coverArtPath.String != "" {
+			channel.CoverArt = "pc-" + channel.ID
+		}
+
+		result.Channel = append(result.Channel, channel)
+	}
+
+	if s.isValidID(id) && len(result.Channel) == 0 {
+		s.sendError(c, 70, "Podcast channel not found")
+		return
+	}
+
+	if includeEpisodes {
+		for i := range result.Channel {
+			episodeRows, err := s.db.Query(`
+				SELECT `+episodeColumns+`
+				FROM podcast_episodes e
+				JOIN podcast_channels ch ON e.channel_id = ch.id
+				WHERE e.channel_id = $1
+				ORDER BY e.publish_date DESC NULLS LAST, e.id DESC
+			`, result.Channel[i].ID)
+			if err != nil {
+				log.Printf("GetPodcasts: Error loading episodes: %v", err)
+				continue
+			}
+			result.Channel[i].Episode = s.scanPodcastEpisodes(episodeRows)
+			episodeRows.Close()
+		}
+	}
+
+	s.sendResponse(c, result)
+}
+
+// GetNewestPodcasts - Returns the most recently published podcast episodes
+func (s *Service) GetNewestPodcasts(c *gin.Context) {
+	count := parseIntDefault(c.Query("count"), 20)
+	if count < 1 {
+		count = 20
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+episodeColumns+`
+		FROM podcast_episodes e
+		JOIN podcast_channels ch ON e.channel_id = ch.id
+		WHERE e.status <> 'deleted'
+		ORDER BY e.publish_date DESC NULLS LAST, e.id DESC
+		LIMIT $1
+	`, count)
+	if err != nil {
+		log.Printf("GetNewestPodcasts: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	s.sendResponse(c, &NewestPodcasts{Episode: s.scanPodcastEpisodes(rows)})
+}
+
+// RefreshPodcasts - Checks all channels for new episodes in the background (admin only)
+func (s *Service) RefreshPodcasts(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage podcasts")
+		return
+	}
+	go s.podcast.RefreshAll()
+	s.sendResponse(c, nil)
+}
+
+// CreatePodcastChannel - Subscribes to a new podcast (admin only)
+func (s *Service) CreatePodcastChannel(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage podcasts")
+		return
+	}
+	url := c.Query("url")
+	if url == "" {
+		s.sendError(c, 10, "Required parameter 'url' is missing")
+		return
+	}
+
+	channelID, err := s.podcast.CreateChannel(url)
+	if err != nil {
+		log.Printf("CreatePodcastChannel: %v", err)
+		s.sendError(c, 0, "Failed to create podcast channel")
+		return
+	}
+
+	log.Printf("CreatePodcastChannel: Subscribed to %s (channel %d)", url, channelID)
+	s.sendResponse(c, nil)
+}
+
+// DeletePodcastChannel - Unsubscribes from a podcast and deletes its episodes (admin only)
+func (s *Service) DeletePodcastChannel(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage podcasts")
+		return
+	}
+	channelID, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	if err := s.podcast.DeleteChannel(channelID); err != nil {
+		if err == sql.ErrNoRows {
+			s.sendError(c, 70, "Podcast channel not found")
+		} else {
+			log.Printf("DeletePodcastChannel: %v", err)
+			s.sendError(c, 0, "Failed to delete podcast channel")
+		}
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// DownloadPodcastEpisode - Queues a podcast episode for download (admin only)
+func (s *Service) DownloadPodcastEpisode(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage podcasts")
+		return
+	}
+	episodeID, err := parsePodcastEpisodeID(c.Query("id"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	if err := s.podcast.QueueDownload(episodeID); err != nil {
+		if err == sql.ErrNoRows {
+			s.sendError(c, 70, "Podcast episode not found")
+		} else {
+			log.Printf("DownloadPodcastEpisode: %v", err)
+			s.sendError(c, 0, "Failed to download podcast episode")
+		}
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// DeletePodcastEpisode - Deletes a downloaded podcast episode (admin only)
+func (s *Service) DeletePodcastEpisode(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage podcasts")
+		return
+	}
+	episodeID, err := parsePodcastEpisodeID(c.Query("id"))
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	if err := s.podcast.DeleteEpisode(episodeID); err != nil {
+		if err == sql.ErrNoRows {
+			s.sendError(c, 70, "Podcast episode not found")
+		} else {
+			log.Printf("DeletePodcastEpisode: %v", err)
+			s.sendError(c, 0, "Failed to delete podcast episode")
+		}
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// streamPodcastEpisode serves a downloaded episode for a "pe-<id>" stream ID
+func (s *Service) streamPodcastEpisode(c *gin.Context, streamID string) {
+	episodeID, err := parsePodcastEpisodeID(streamID)
+	if err != nil {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	filePath, format, fileSize, err := s.podcast.EpisodeFile(episodeID)
+	if err != nil {
+		if err == sql.ErrNoRows || os.IsNotExist(err) {
+			s.sendError(c, 70, "Podcast episode not found or not downloaded")
+		} else {
+			log.Printf("Database error: %v", err)
+			s.sendError(c, 0, "Database error")
+		}
+		return
+	}
+
+	s.serveAudioFile(c, filePath, format, fileSize)
+
+	log.Printf("Streaming podcast episode %d to user %s", episodeID, c.GetString("username"))
+}
+
+// scanPodcastEpisodes scans rows following episodeColumns
+func (s *Service) scanPodcastEpisodes(rows *sql.Rows) []PodcastEpisode {
+	episodes := []PodcastEpisode{}
+
+	for rows.Next() {
+		var episode PodcastEpisode
+		var title, description, format, enclosureType, channelTitle, coverArtPath sql.NullString
+		var publishDate sql.NullTime
+		var duration sql.NullInt32
+		var fileSize sql.NullInt64
+
+		err := rows.Scan(&episode.ID, &episode.ChannelID, &title, &description, &publishDate,
+			&duration, &fileSize, &format, &enclosureType, &episode.Status, &channelTitle, &coverArtPath)
+		if err != nil {
+			log.Printf("scanPodcastEpisodes: Error scanning episode row: %v", err)
+			continue
+		}
+
+		episode.IsDir = false
+		episode.Parent = episode.ChannelID
+		episode.Title = title.String
+		episode.Album = channelTitle.String
+		episode.Artist = channelTitle.String
+		episode.Genre = "Podcast"
+		episode.Description = description.String
+		episode.Duration = int(duration.Int32)
+		episode.Size = fileSize.Int64
+		episode.Suffix = format.String
+		episode.ContentType = enclosureType.String
+		if publishDate.Valid {
+			episode.PublishDate = publishDate.Time.Format("2006-01-02T15:04:05Z")
+			episode.Year = publishDate.Time.Year()
+		}
+		if coverArtPath.String != "" {
+			episode.CoverArt = "pc-" + episode.ChannelID
+		}
+		if episode.Status == "completed" {
+			episode.StreamID = "pe-" + episode.ID
+			episode.ContentType = s.getContentType("." + format.String)
+		}
+
+		episodes = append(episodes, episode)
+	}
+
+	return episodes
+}
+
+// serveCoverFile sends an image file from disk, used for non-album artwork
+func (s *Service) serveCoverFile(c *gin.Context, coverPath string) {
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.File(coverPath)
+}
+
+// parsePodcastEpisodeID accepts both plain episode IDs and "pe-<id>" stream IDs
+func parsePodcastEpisodeID(id string) (int, error) {
+	return strconv.Atoi(strings.TrimPrefix(id, "pe-"))
+}
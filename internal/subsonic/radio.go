@@ -0,0 +1,135 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetInternetRadioStations - Returns all internet radio stations
+func (s *Service) GetInternetRadioStations(c *gin.Context) {
+	rows, err := s.db.Query(`
+		SELECT id, name, stream_url, homepage_url
+		FROM internet_radio_stations
+		ORDER BY name
+	`)
+	if err != nil {
+		log.Printf("GetInternetRadioStations: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	result := &InternetRadioStations{InternetRadioStation: []InternetRadioStation{}}
+	for rows.Next() {
+		var station InternetRadioStation
+		var homepageURL sql.NullString
+		if err := rows.Scan(&station.ID, &station.Name, &station.StreamURL, &homepageURL); err != nil {
+			log.Printf("GetInternetRadioStations: Error scanning station: %v", err)
+			continue
+		}
+		station.HomePageURL = homepageURL.String
+		result.InternetRadioStation = append(result.InternetRadioStation, station)
+	}
+
+	s.sendResponse(c, result)
+}
+
+// CreateInternetRadioStation - Adds a new internet radio station (admin only)
+func (s *Service) CreateInternetRadioStation(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage radio stations")
+		return
+	}
+
+	streamURL := c.Query("streamUrl")
+	name := c.Query("name")
+	if streamURL == "" || name == "" {
+		s.sendError(c, 10, "Required parameters 'streamUrl' and 'name' are missing")
+		return
+	}
+
+	_, err := s.db.Exec(`
+		INSERT INTO internet_radio_stations (name, stream_url, homepage_url)
+		VALUES ($1, $2, $3)
+	`, name, streamURL, c.Query("homepageUrl"))
+	if err != nil {
+		log.Printf("CreateInternetRadioStation: Error creating station: %v", err)
+		s.sendError(c, 0, "Failed to create radio station")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// UpdateInternetRadioStation - Updates an existing internet radio station (admin only)
+func (s *Service) UpdateInternetRadioStation(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage radio stations")
+		return
+	}
+
+	id := c.Query("id")
+	streamURL := c.Query("streamUrl")
+	name := c.Query("name")
+	if !s.isValidID(id) || streamURL == "" || name == "" {
+		s.sendError(c, 10, "Required parameters 'id', 'streamUrl' and 'name' are missing")
+		return
+	}
+
+	result, err := s.db.Exec(`
+		UPDATE internet_radio_stations
+		SET name = $1, stream_url = $2, homepage_url = $3, updated_at = NOW()
+		WHERE id = $4
+	`, name, streamURL, c.Query("homepageUrl"), id)
+	if err != nil {
+		log.Printf("UpdateInternetRadioStation: Error updating station: %v", err)
+		s.sendError(c, 0, "Failed to update radio station")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		s.sendError(c, 70, "Radio station not found")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// DeleteInternetRadioStation - Deletes an internet radio station (admin only)
+func (s *Service) DeleteInternetRadioStation(c *gin.Context) {
+	if !s.isAdmin(c) {
+		s.sendError(c, 50, "User is not authorized to manage radio stations")
+		return
+	}
+
+	id := c.Query("id")
+	if !s.isValidID(id) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	result, err := s.db.Exec("DELETE FROM internet_radio_stations WHERE id = $1", id)
+	if err != nil {
+		log.Printf("DeleteInternetRadioStation: Error deleting station: %v", err)
+		s.sendError(c, 0, "Failed to delete radio station")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		s.sendError(c, 70, "Radio station not found")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// isAdmin reports whether the current user has the admin role
+func (s *Service) isAdmin(c *gin.Context) bool {
+	var isAdmin bool
+	err := s.db.QueryRow("SELECT is_admin FROM users WHERE id = $1", s.getUserID(c)).Scan(&isAdmin)
+	if err != nil {
+		log.Printf("isAdmin: Database error: %v", err)
+		return false
+	}
+	return isAdmin
+}
@@ -0,0 +1,108 @@
+package subsonic
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/lib/pq"
+)
+
+// appendRecommendedSongs adds songs recommended from play history for the seed song,
+// skipping songs already in the list, until there are limit songs
+func (s *Service) appendRecommendedSongs(songs []Child, userId int, songId string, limit int) []Child {
+	if len(songs) >= limit {
+		return songs
+	}
+
+	seed, err := strconv.Atoi(songId)
+	if err != nil {
+		return songs
+	}
+
+	recommended, err := s.recommender.SimilarSongs(userId, seed, limit)
+	if err != nil {
+		log.Printf("Error loading recommendations for song %s: %v", songId, err)
+		return songs
+	}
+
+	present := make(map[string]bool, len(songs))
+	for _, song := range songs {
+		present[song.ID] = true
+	}
+
+	var ids []string
+	for _, id := range recommended {
+		if key := strconv.Itoa(id); !present[key] {
+			ids = append(ids, key)
+		}
+	}
+
+	songsByID, err := s.getSongsByIDs(ids)
+	if err != nil {
+		log.Printf("Error loading recommended songs: %v", err)
+		return songs
+	}
+
+	for _, id := range ids {
+		if len(songs) >= limit {
+			break
+		}
+		if song, ok := songsByID[id]; ok {
+			songs = append(songs, song)
+		}
+	}
+	return songs
+}
+
+// discoverAlbums returns the "discover" album list: albums the user has not played yet,
+// chosen from what listeners with similar taste play
+func (s *Service) discoverAlbums(userId, size, offset int) ([]AlbumID3, error) {
+	ids, err := s.recommender.Discover(userId, size, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	albumsByID, err := s.getAlbumsByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	albums := make([]AlbumID3, 0, len(ids))
+	for _, id := range ids {
+		if album, ok := albumsByID[strconv.Itoa(id)]; ok {
+			albums = append(albums, album)
+		}
+	}
+	return albums, nil
+}
+
+// getAlbumsByIDs loads album entries for the given IDs, keyed by album ID
+func (s *Service) getAlbumsByIDs(ids []int) (map[string]AlbumID3, error) {
+	albums := make(map[string]AlbumID3)
+	if len(ids) == 0 {
+		return albums, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT al.id, al.name, al.artist_id, al.year, al.genre, al.created_at,
+		       ar.name, COUNT(s.id), COALESCE(SUM(s.duration), 0), al.cover_art_path
+		FROM albums al
+		JOIN artists ar ON al.artist_id = ar.id
+		LEFT JOIN songs s ON al.id = s.album_id
+		WHERE al.id = ANY($1::int[])
+		GROUP BY al.id, ar.name
+	`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list, err := scanAlbums(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, album := range list {
+		albums[album.ID] = album
+	}
+	return albums, nil
+}
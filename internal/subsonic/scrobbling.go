@@ -0,0 +1,108 @@
+package subsonic
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"castafiore-backend/internal/lastfm"
+	"castafiore-backend/internal/listenbrainz"
+)
+
+// scrobbledSong is the song metadata forwarded to external scrobbling services
+type scrobbledSong struct {
+	Title         string
+	Artist        string
+	Album         string
+	Duration      int
+	TrackNumber   int
+	RecordingMBID string
+	ReleaseMBID   string
+	ArtistMBID    string
+}
+
+func (s *Service) loadScrobbledSong(songId string) (scrobbledSong, error) {
+	var song scrobbledSong
+	var album, recordingMBID, releaseMBID, artistMBID sql.NullString
+	var trackNumber sql.NullInt32
+
+	err := s.db.QueryRow(`
+		SELECT s.title, ar.name, al.name, s.duration, s.track_number,
+		       s.mbz_recording_id, s.mbz_release_id, s.mbz_artist_id
+		FROM songs s
+		JOIN artists ar ON s.artist_id = ar.id
+		LEFT JOIN albums al ON s.album_id = al.id
+		WHERE s.id = $1
+	`, songId).Scan(&song.Title, &song.Artist, &album, &song.Duration, &trackNumber,
+		&recordingMBID, &releaseMBID, &artistMBID)
+	if err != nil {
+		return song, err
+	}
+
+	song.Album = album.String
+	song.TrackNumber = int(trackNumber.Int32)
+	song.RecordingMBID = recordingMBID.String
+	song.ReleaseMBID = releaseMBID.String
+	song.ArtistMBID = artistMBID.String
+	return song, nil
+}
+
+func (song scrobbledSong) lastFM(playedAt time.Time) lastfm.Scrobble {
+	return lastfm.Scrobble{
+		Artist:      song.Artist,
+		Track:       song.Title,
+		Album:       song.Album,
+		Timestamp:   playedAt,
+		Duration:    song.Duration,
+		TrackNumber: song.TrackNumber,
+	}
+}
+
+func (song scrobbledSong) listenBrainz(playedAt time.Time) listenbrainz.Listen {
+	return listenbrainz.Listen{
+		ListenedAt:    playedAt,
+		Artist:        song.Artist,
+		Track:         song.Title,
+		Release:       song.Album,
+		Duration:      song.Duration,
+		TrackNumber:   song.TrackNumber,
+		RecordingMBID: song.RecordingMBID,
+		ReleaseMBID:   song.ReleaseMBID,
+		ArtistMBID:    song.ArtistMBID,
+	}
+}
+
+// forwardScrobble queues a listen for the Last.fm and ListenBrainz accounts linked by the user
+func (s *Service) forwardScrobble(userId int, songId string, playedAt time.Time) {
+	song, err := s.loadScrobbledSong(songId)
+	if err != nil {
+		log.Printf("Error loading song %s for scrobbling: %v", songId, err)
+		return
+	}
+
+	if s.scrobbler.Enabled() {
+		if err := s.scrobbler.Enqueue(userId, songId, song.lastFM(playedAt)); err != nil {
+			log.Printf("Error queueing Last.fm scrobble for song %s: %v", songId, err)
+		}
+	}
+	if err := s.listenbrainz.Enqueue(userId, song.listenBrainz(playedAt)); err != nil {
+		log.Printf("Error queueing ListenBrainz listen for song %s: %v", songId, err)
+	}
+}
+
+// forwardNowPlaying notifies the linked services in the background so clients are not delayed
+func (s *Service) forwardNowPlaying(userId int, songId string) {
+	song, err := s.loadScrobbledSong(songId)
+	if err != nil {
+		log.Printf("Error loading song %s for now playing: %v", songId, err)
+		return
+	}
+
+	now := time.Now()
+	go func() {
+		if s.scrobbler.Enabled() {
+			s.scrobbler.NowPlaying(userId, song.lastFM(now))
+		}
+		s.listenbrainz.NowPlaying(userId, song.listenBrainz(now))
+	}()
+}
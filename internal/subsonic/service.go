@@ -2,53 +2,92 @@ package subsonic
 
 import (
 	"database/sql"
+	"encoding/hex"
 	"encoding/xml"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"castafiore-backend/internal/artwork"
 	"castafiore-backend/internal/auth"
 	"castafiore-backend/internal/config"
 	"castafiore-backend/internal/lastfm"
+	"castafiore-backend/internal/listenbrainz"
+	"castafiore-backend/internal/matching"
+	"castafiore-backend/internal/metadata"
+	"castafiore-backend/internal/podcast"
+	"castafiore-backend/internal/recommend"
+	"castafiore-backend/internal/search"
+	"castafiore-backend/internal/stats"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Service struct {
-	db        *sql.DB
-	auth      *auth.Service
-	lastfm    *lastfm.Service
-	musicPath string
+	db           *sql.DB
+	auth         *auth.Service
+	agents       *metadata.Chain
+	matcher      *matching.Resolver
+	recommender  *recommend.Engine
+	search       *search.Engine
+	stats        *stats.Engine
+	scrobbler    *lastfm.Scrobbler
+	listenbrainz *listenbrainz.Scrobbler
+	podcast      *podcast.Service
+	mosaics      *artwork.MosaicCache
+	musicPath    string
+	dataPath     string
 }
 
 // Response structures for Subsonic API
 type SubsonicResponse struct {
-	XMLName       xml.Name           `xml:"subsonic-response" json:"-"`
-	Status        string             `xml:"status,attr" json:"status"`
-	Version       string             `xml:"version,attr" json:"version"`
-	Type          string             `xml:"type,attr" json:"type"`
-	Error         *Error             `xml:"error,omitempty" json:"error,omitempty"`
-	License       *License           `xml:"license,omitempty" json:"license,omitempty"`
-	MusicFolders  *MusicFolders      `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
-	Indexes       *Indexes           `xml:"indexes,omitempty" json:"indexes,omitempty"`
-	Directory     *Directory         `xml:"directory,omitempty" json:"directory,omitempty"`
-	Genres        *Genres            `xml:"genres,omitempty" json:"genres,omitempty"`
-	Artists       *ArtistsID3        `xml:"artists,omitempty" json:"artists,omitempty"`
-	Artist        *ArtistWithAlbums  `xml:"artist,omitempty" json:"artist,omitempty"`
-	Album         *AlbumID3          `xml:"album,omitempty" json:"album,omitempty"`
-	Song          *Child             `xml:"song,omitempty" json:"song,omitempty"`
-	SearchResult3 *SearchResult3     `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
-	TopSongs      *TopSongs          `xml:"topSongs,omitempty" json:"topSongs,omitempty"`
-	AlbumList2    *AlbumList2        `xml:"albumList2,omitempty" json:"albumList2,omitempty"`
-	RandomSongs   *RandomSongs       `xml:"randomSongs,omitempty" json:"randomSongs,omitempty"`
-	SongsByGenre  *SongsByGenre      `xml:"songsByGenre,omitempty" json:"songsByGenre,omitempty"`
-	SimilarSongs2 *SimilarSongs2     `xml:"similarSongs2,omitempty" json:"similarSongs2,omitempty"`
-	NowPlaying    *NowPlaying        `xml:"nowPlaying,omitempty" json:"nowPlaying,omitempty"`
-	Starred       *Starred           `xml:"starred,omitempty" json:"starred,omitempty"`
-	Starred2      *Starred2          `xml:"starred2,omitempty" json:"starred2,omitempty"`
-	ArtistInfo2   *ArtistInfo2       `xml:"artistInfo2,omitempty" json:"artistInfo2,omitempty"`
-	Playlists     *Playlists         `xml:"playlists,omitempty" json:"playlists,omitempty"`
-	Playlist      *PlaylistWithSongs `xml:"playlist,omitempty" json:"playlist,omitempty"`
-	User          *User              `xml:"user,omitempty" json:"user,omitempty"`
+	XMLName               xml.Name               `xml:"subsonic-response" json:"-"`
+	Status                string                 `xml:"status,attr" json:"status"`
+	Version               string                 `xml:"version,attr" json:"version"`
+	Type                  string                 `xml:"type,attr" json:"type"`
+	Error                 *Error                 `xml:"error,omitempty" json:"error,omitempty"`
+	License               *License               `xml:"license,omitempty" json:"license,omitempty"`
+	MusicFolders          *MusicFolders          `xml:"musicFolders,omitempty" json:"musicFolders,omitempty"`
+	Indexes               *Indexes               `xml:"indexes,omitempty" json:"indexes,omitempty"`
+	Directory             *Directory             `xml:"directory,omitempty" json:"directory,omitempty"`
+	Genres                *Genres                `xml:"genres,omitempty" json:"genres,omitempty"`
+	Artists               *ArtistsID3            `xml:"artists,omitempty" json:"artists,omitempty"`
+	Artist                *ArtistWithAlbums      `xml:"artist,omitempty" json:"artist,omitempty"`
+	Album                 *AlbumID3              `xml:"album,omitempty" json:"album,omitempty"`
+	Song                  *Child                 `xml:"song,omitempty" json:"song,omitempty"`
+	SearchResult2         *SearchResult2         `xml:"searchResult2,omitempty" json:"searchResult2,omitempty"`
+	SearchResult3         *SearchResult3         `xml:"searchResult3,omitempty" json:"searchResult3,omitempty"`
+	TopSongs              *TopSongs              `xml:"topSongs,omitempty" json:"topSongs,omitempty"`
+	AlbumList             *AlbumList             `xml:"albumList,omitempty" json:"albumList,omitempty"`
+	AlbumList2            *AlbumList2            `xml:"albumList2,omitempty" json:"albumList2,omitempty"`
+	Decades               *Decades               `xml:"decades,omitempty" json:"decades,omitempty"`
+	OnThisDay             *OnThisDay             `xml:"onThisDay,omitempty" json:"onThisDay,omitempty"`
+	ListeningStats        *ListeningStats        `xml:"listeningStats,omitempty" json:"listeningStats,omitempty"`
+	YearlyReport          *YearlyReport          `xml:"yearlyReport,omitempty" json:"yearlyReport,omitempty"`
+	RandomSongs           *RandomSongs           `xml:"randomSongs,omitempty" json:"randomSongs,omitempty"`
+	SongsByGenre          *SongsByGenre          `xml:"songsByGenre,omitempty" json:"songsByGenre,omitempty"`
+	SimilarSongs          *SimilarSongs          `xml:"similarSongs,omitempty" json:"similarSongs,omitempty"`
+	SimilarSongs2         *SimilarSongs2         `xml:"similarSongs2,omitempty" json:"similarSongs2,omitempty"`
+	NowPlaying            *NowPlaying            `xml:"nowPlaying,omitempty" json:"nowPlaying,omitempty"`
+	Starred               *Starred               `xml:"starred,omitempty" json:"starred,omitempty"`
+	Starred2              *Starred2              `xml:"starred2,omitempty" json:"starred2,omitempty"`
+	ArtistInfo2           *ArtistInfo2           `xml:"artistInfo2,omitempty" json:"artistInfo2,omitempty"`
+	AlbumInfo             *AlbumInfo             `xml:"albumInfo,omitempty" json:"albumInfo,omitempty"`
+	Playlists             *Playlists             `xml:"playlists,omitempty" json:"playlists,omitempty"`
+	Playlist              *PlaylistWithSongs     `xml:"playlist,omitempty" json:"playlist,omitempty"`
+	User                  *User                  `xml:"user,omitempty" json:"user,omitempty"`
+	Bookmarks             *Bookmarks             `xml:"bookmarks,omitempty" json:"bookmarks,omitempty"`
+	PlayQueue             *PlayQueue             `xml:"playQueue,omitempty" json:"playQueue,omitempty"`
+	PlayQueueByIndex      *PlayQueueByIndex      `xml:"playQueueByIndex,omitempty" json:"playQueueByIndex,omitempty"`
+	Shares                *Shares                `xml:"shares,omitempty" json:"shares,omitempty"`
+	InternetRadioStations *InternetRadioStations `xml:"internetRadioStations,omitempty" json:"internetRadioStations,omitempty"`
+	Podcasts              *Podcasts              `xml:"podcasts,omitempty" json:"podcasts,omitempty"`
+	NewestPodcasts        *NewestPodcasts        `xml:"newestPodcasts,omitempty" json:"newestPodcasts,omitempty"`
+	LastFMLink            *LastFMLink            `xml:"lastfmLink,omitempty" json:"lastfmLink,omitempty"`
+	ListenBrainzLink      *ListenBrainzLink      `xml:"listenBrainzLink,omitempty" json:"listenBrainzLink,omitempty"`
+	PlaylistCollaborators *PlaylistCollaborators `xml:"playlistCollaborators,omitempty" json:"playlistCollaborators,omitempty"`
 }
 
 type Error struct {
@@ -108,6 +147,15 @@ type Child struct {
 	Duration    int    `xml:"duration,attr" json:"duration"`
 	BitRate     int    `xml:"bitRate,attr" json:"bitRate"`
 	Path        string `xml:"path,attr,omitempty" json:"path,omitempty"`
+	// Annotations of the current user
+	Starred    string `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+	UserRating int    `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
+	PlayCount  int64  `xml:"playCount,attr,omitempty" json:"playCount,omitempty"`
+	Played     string `xml:"played,attr,omitempty" json:"played,omitempty"`
+	// BookmarkPosition is the saved playback position in milliseconds for the current user
+	BookmarkPosition int64 `xml:"bookmarkPosition,attr,omitempty" json:"bookmarkPosition,omitempty"`
+	// AddedBy is the user who added a playlist entry, set by getPlaylist only
+	AddedBy string `xml:"addedBy,attr,omitempty" json:"addedBy,omitempty"`
 }
 
 type Genres struct {
@@ -135,20 +183,31 @@ type ArtistID3 struct {
 	CoverArt   string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
 	AlbumCount int    `xml:"albumCount,attr" json:"albumCount"`
 	Starred    string `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+	UserRating int    `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
 }
 
 type AlbumID3 struct {
-	ID        string  `xml:"id,attr" json:"id"`
-	Name      string  `xml:"name,attr" json:"name"`
-	Artist    string  `xml:"artist,attr" json:"artist"`
-	ArtistID  string  `xml:"artistId,attr" json:"artistId"`
-	CoverArt  string  `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
-	SongCount int     `xml:"songCount,attr" json:"songCount"`
-	Duration  int     `xml:"duration,attr" json:"duration"`
-	Created   string  `xml:"created,attr" json:"created"`
-	Year      int     `xml:"year,attr,omitempty" json:"year,omitempty"`
-	Genre     string  `xml:"genre,attr,omitempty" json:"genre,omitempty"`
-	Song      []Child `xml:"song,omitempty" json:"song,omitempty"`
+	ID         string  `xml:"id,attr" json:"id"`
+	Name       string  `xml:"name,attr" json:"name"`
+	Artist     string  `xml:"artist,attr" json:"artist"`
+	ArtistID   string  `xml:"artistId,attr" json:"artistId"`
+	CoverArt   string  `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	SongCount  int     `xml:"songCount,attr" json:"songCount"`
+	Duration   int     `xml:"duration,attr" json:"duration"`
+	Created    string  `xml:"created,attr" json:"created"`
+	Year       int     `xml:"year,attr,omitempty" json:"year,omitempty"`
+	Genre      string  `xml:"genre,attr,omitempty" json:"genre,omitempty"`
+	Starred    string  `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+	UserRating int     `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
+	PlayCount  int64   `xml:"playCount,attr,omitempty" json:"playCount,omitempty"`
+	Played     string  `xml:"played,attr,omitempty" json:"played,omitempty"`
+	Song       []Child `xml:"song,omitempty" json:"song,omitempty"`
+}
+
+type SearchResult2 struct {
+	Artist []Artist `xml:"artist" json:"artist"`
+	Album  []Child  `xml:"album" json:"album"`
+	Song   []Child  `xml:"song" json:"song"`
 }
 
 type SearchResult3 struct {
@@ -169,6 +228,10 @@ type SongsByGenre struct {
 	Song []Child `xml:"song" json:"song"`
 }
 
+type SimilarSongs struct {
+	Song []Child `xml:"song" json:"song"`
+}
+
 type SimilarSongs2 struct {
 	Song []Child `xml:"song" json:"song"`
 }
@@ -196,14 +259,79 @@ type Starred2 struct {
 	Song   []Child     `xml:"song" json:"song"`
 }
 
+type AlbumList struct {
+	Album []Child `xml:"album" json:"album"`
+}
+
 type AlbumList2 struct {
 	Album []AlbumID3 `xml:"album" json:"album"`
 }
 
+// Decades lists the decades of the library (extension)
+type Decades struct {
+	Decade []Decade `xml:"decade" json:"decade"`
+}
+
+type Decade struct {
+	Year       int `xml:"year,attr" json:"year"`
+	AlbumCount int `xml:"albumCount,attr" json:"albumCount"`
+}
+
+// OnThisDay lists songs played on the same date in earlier years (extension)
+type OnThisDay struct {
+	Song []Child `xml:"song" json:"song"`
+}
+
+// ListeningStats summarizes the plays of the user in a period (extension).
+// Listening times are in seconds.
+type ListeningStats struct {
+	Period        string       `xml:"period,attr" json:"period"`
+	From          string       `xml:"from,attr,omitempty" json:"from,omitempty"`
+	To            string       `xml:"to,attr" json:"to"`
+	PlayCount     int          `xml:"playCount,attr" json:"playCount"`
+	ListeningTime int          `xml:"listeningTime,attr" json:"listeningTime"`
+	CurrentStreak int          `xml:"currentStreak,attr" json:"currentStreak"`
+	LongestStreak int          `xml:"longestStreak,attr" json:"longestStreak"`
+	NewArtists    int          `xml:"newArtists,attr" json:"newArtists"`
+	NewAlbums     int          `xml:"newAlbums,attr" json:"newAlbums"`
+	NewSongs      int          `xml:"newSongs,attr" json:"newSongs"`
+	Artist        []StatsEntry `xml:"artist" json:"artist"`
+	Album         []StatsEntry `xml:"album" json:"album"`
+	Song          []StatsEntry `xml:"song" json:"song"`
+	Genre         []StatsEntry `xml:"genre" json:"genre"`
+	Hour          []StatsCount `xml:"hour" json:"hour"`
+	Weekday       []StatsCount `xml:"weekday" json:"weekday"`
+}
+
+type StatsEntry struct {
+	ID            string `xml:"id,attr,omitempty" json:"id,omitempty"`
+	Name          string `xml:"name,attr" json:"name"`
+	Artist        string `xml:"artist,attr,omitempty" json:"artist,omitempty"`
+	PlayCount     int    `xml:"playCount,attr" json:"playCount"`
+	ListeningTime int    `xml:"listeningTime,attr" json:"listeningTime"`
+}
+
+// StatsCount is the play count of an hour, weekday (0 is Sunday) or month
+type StatsCount struct {
+	Value     int `xml:"value,attr" json:"value"`
+	PlayCount int `xml:"playCount,attr" json:"playCount"`
+}
+
+// YearlyReport is the yearly listening summary of the user (extension)
+type YearlyReport struct {
+	ListeningStats
+	Year                int          `xml:"year,attr" json:"year"`
+	BusiestDay          string       `xml:"busiestDay,attr,omitempty" json:"busiestDay,omitempty"`
+	BusiestDayPlayCount int          `xml:"busiestDayPlayCount,attr" json:"busiestDayPlayCount"`
+	Month               []StatsCount `xml:"month" json:"month"`
+}
+
 type ArtistWithAlbums struct {
 	ID         string     `xml:"id,attr" json:"id"`
 	Name       string     `xml:"name,attr" json:"name"`
 	AlbumCount int        `xml:"albumCount,attr" json:"albumCount"`
+	Starred    string     `xml:"starred,attr,omitempty" json:"starred,omitempty"`
+	UserRating int        `xml:"userRating,attr,omitempty" json:"userRating,omitempty"`
 	Album      []AlbumID3 `xml:"album" json:"album"`
 }
 
@@ -217,6 +345,15 @@ type ArtistInfo2 struct {
 	SimilarArtist  []ArtistID3 `xml:"similarArtist,omitempty" json:"similarArtist,omitempty"`
 }
 
+type AlbumInfo struct {
+	Notes          string `xml:"notes,omitempty" json:"notes,omitempty"`
+	MusicBrainzID  string `xml:"musicBrainzId,omitempty" json:"musicBrainzId,omitempty"`
+	LastFmUrl      string `xml:"lastFmUrl,omitempty" json:"lastFmUrl,omitempty"`
+	SmallImageUrl  string `xml:"smallImageUrl,omitempty" json:"smallImageUrl,omitempty"`
+	MediumImageUrl string `xml:"mediumImageUrl,omitempty" json:"mediumImageUrl,omitempty"`
+	LargeImageUrl  string `xml:"largeImageUrl,omitempty" json:"largeImageUrl,omitempty"`
+}
+
 type Playlists struct {
 	Playlist []PlaylistID3 `xml:"playlist" json:"playlist"`
 }
@@ -232,20 +369,23 @@ type PlaylistID3 struct {
 	Created   string `xml:"created,attr" json:"created"`
 	Changed   string `xml:"changed,attr" json:"changed"`
 	CoverArt  string `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Readonly  bool   `xml:"readonly,attr,omitempty" json:"readonly,omitempty"`
 }
 
 type PlaylistWithSongs struct {
-	ID        string  `xml:"id,attr" json:"id"`
-	Name      string  `xml:"name,attr" json:"name"`
-	Comment   string  `xml:"comment,attr,omitempty" json:"comment,omitempty"`
-	Owner     string  `xml:"owner,attr" json:"owner"`
-	Public    bool    `xml:"public,attr" json:"public"`
-	SongCount int     `xml:"songCount,attr" json:"songCount"`
-	Duration  int     `xml:"duration,attr" json:"duration"`
-	Created   string  `xml:"created,attr" json:"created"`
-	Changed   string  `xml:"changed,attr" json:"changed"`
-	CoverArt  string  `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
-	Entry     []Child `xml:"entry" json:"entry"`
+	ID          string   `xml:"id,attr" json:"id"`
+	Name        string   `xml:"name,attr" json:"name"`
+	Comment     string   `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Owner       string   `xml:"owner,attr" json:"owner"`
+	Public      bool     `xml:"public,attr" json:"public"`
+	SongCount   int      `xml:"songCount,attr" json:"songCount"`
+	Duration    int      `xml:"duration,attr" json:"duration"`
+	Created     string   `xml:"created,attr" json:"created"`
+	Changed     string   `xml:"changed,attr" json:"changed"`
+	CoverArt    string   `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	Readonly    bool     `xml:"readonly,attr,omitempty" json:"readonly,omitempty"`
+	AllowedUser []string `xml:"allowedUser,omitempty" json:"allowedUser,omitempty"`
+	Entry       []Child  `xml:"entry" json:"entry"`
 }
 
 type User struct {
@@ -267,20 +407,226 @@ type User struct {
 	MaxBitRate          int    `xml:"maxBitRate,attr,omitempty" json:"maxBitRate,omitempty"`
 }
 
+type Bookmarks struct {
+	Bookmark []Bookmark `xml:"bookmark" json:"bookmark"`
+}
+
+type Bookmark struct {
+	Position int64  `xml:"position,attr" json:"position"`
+	Username string `xml:"username,attr" json:"username"`
+	Comment  string `xml:"comment,attr,omitempty" json:"comment,omitempty"`
+	Created  string `xml:"created,attr" json:"created"`
+	Changed  string `xml:"changed,attr" json:"changed"`
+	Entry    Child  `xml:"entry" json:"entry"`
+}
+
+type PlayQueue struct {
+	Current   string  `xml:"current,attr,omitempty" json:"current,omitempty"`
+	Position  int64   `xml:"position,attr,omitempty" json:"position,omitempty"`
+	Username  string  `xml:"username,attr" json:"username"`
+	Changed   string  `xml:"changed,attr" json:"changed"`
+	ChangedBy string  `xml:"changedBy,attr" json:"changedBy"`
+	Entry     []Child `xml:"entry" json:"entry"`
+}
+
+type PlayQueueByIndex struct {
+	CurrentIndex *int    `xml:"currentIndex,attr,omitempty" json:"currentIndex,omitempty"`
+	Position     int64   `xml:"position,attr,omitempty" json:"position,omitempty"`
+	Username     string  `xml:"username,attr" json:"username"`
+	Changed      string  `xml:"changed,attr" json:"changed"`
+	ChangedBy    string  `xml:"changedBy,attr" json:"changedBy"`
+	Entry        []Child `xml:"entry" json:"entry"`
+}
+
+type Shares struct {
+	Share []Share `xml:"share" json:"share"`
+}
+
+type Share struct {
+	ID          string  `xml:"id,attr" json:"id"`
+	URL         string  `xml:"url,attr" json:"url"`
+	Description string  `xml:"description,attr,omitempty" json:"description,omitempty"`
+	Username    string  `xml:"username,attr" json:"username"`
+	Created     string  `xml:"created,attr" json:"created"`
+	Expires     string  `xml:"expires,attr,omitempty" json:"expires,omitempty"`
+	LastVisited string  `xml:"lastVisited,attr,omitempty" json:"lastVisited,omitempty"`
+	VisitCount  int     `xml:"visitCount,attr" json:"visitCount"`
+	Entry       []Child `xml:"entry" json:"entry"`
+}
+
+type InternetRadioStations struct {
+	InternetRadioStation []InternetRadioStation `xml:"internetRadioStation" json:"internetRadioStation"`
+}
+
+type InternetRadioStation struct {
+	ID          string `xml:"id,attr" json:"id"`
+	Name        string `xml:"name,attr" json:"name"`
+	StreamURL   string `xml:"streamUrl,attr" json:"streamUrl"`
+	HomePageURL string `xml:"homePageUrl,attr,omitempty" json:"homePageUrl,omitempty"`
+}
+
+type Podcasts struct {
+	Channel []PodcastChannel `xml:"channel" json:"channel"`
+}
+
+type PodcastChannel struct {
+	ID               string           `xml:"id,attr" json:"id"`
+	URL              string           `xml:"url,attr" json:"url"`
+	Title            string           `xml:"title,attr,omitempty" json:"title,omitempty"`
+	Description      string           `xml:"description,attr,omitempty" json:"description,omitempty"`
+	CoverArt         string           `xml:"coverArt,attr,omitempty" json:"coverArt,omitempty"`
+	OriginalImageURL string           `xml:"originalImageUrl,attr,omitempty" json:"originalImageUrl,omitempty"`
+	Status           string           `xml:"status,attr" json:"status"`
+	ErrorMessage     string           `xml:"errorMessage,attr,omitempty" json:"errorMessage,omitempty"`
+	Episode          []PodcastEpisode `xml:"episode,omitempty" json:"episode,omitempty"`
+}
+
+// PodcastEpisode extends Child with the podcast specific attributes
+type PodcastEpisode struct {
+	Child
+	StreamID    string `xml:"streamId,attr,omitempty" json:"streamId,omitempty"`
+	ChannelID   string `xml:"channelId,attr" json:"channelId"`
+	Description string `xml:"description,attr,omitempty" json:"description,omitempty"`
+	Status      string `xml:"status,attr" json:"status"`
+	PublishDate string `xml:"publishDate,attr,omitempty" json:"publishDate,omitempty"`
+}
+
+type NewestPodcasts struct {
+	Episode []PodcastEpisode `xml:"episode" json:"episode"`
+}
+
+// LastFMLink is a Castafiore extension describing the Last.fm account linked for scrobbling
+type LastFMLink struct {
+	Enabled  bool   `xml:"enabled,attr" json:"enabled"`
+	Linked   bool   `xml:"linked,attr" json:"linked"`
+	Username string `xml:"username,attr,omitempty" json:"username,omitempty"`
+	AuthURL  string `xml:"authUrl,attr,omitempty" json:"authUrl,omitempty"`
+}
+
+// ListenBrainzLink is a Castafiore extension describing the ListenBrainz account of a user
+type ListenBrainzLink struct {
+	Linked   bool   `xml:"linked,attr" json:"linked"`
+	Username string `xml:"username,attr,omitempty" json:"username,omitempty"`
+}
+
+// PlaylistCollaborators is a Castafiore extension listing the users a playlist is shared with
+type PlaylistCollaborators struct {
+	PlaylistID   string                 `xml:"playlistId,attr" json:"playlistId"`
+	Collaborator []PlaylistCollaborator `xml:"collaborator" json:"collaborator"`
+}
+
+type PlaylistCollaborator struct {
+	Username string `xml:"username,attr" json:"username"`
+	Role     string `xml:"role,attr" json:"role"`
+}
+
 func NewService(db *sql.DB, authService *auth.Service, cfg *config.Config) *Service {
+	podcastService := podcast.NewService(db, cfg.PodcastPath)
+
+	lastfmService := lastfm.NewService(lastfm.Config{
+		APIKey:       cfg.LastFMAPIKey,
+		SharedSecret: cfg.LastFMSharedSecret,
+		Cache:        lastfm.NewPostgresCache(db),
+	})
+
+	scrobbler := lastfm.NewScrobbler(db, lastfmService)
+
+	agents := []metadata.Agent{metadata.NewFilesAgent(), metadata.NewLibraryAgent(db)}
+	if lastfmService != nil {
+		agents = append(agents, metadata.NewLastFMAgent(lastfmService))
+	}
+
 	return &Service{
-		db:        db,
-		auth:      authService,
-		lastfm:    lastfm.NewService(lastfm.Config{APIKey: cfg.LastFMAPIKey}),
-		musicPath: cfg.MusicPath,
+		db:           db,
+		auth:         authService,
+		agents:       metadata.NewChain(cfg.MetadataAgents, agents...),
+		matcher:      matching.NewResolver(db),
+		recommender:  recommend.NewEngine(db),
+		search:       search.NewEngine(db),
+		stats:        stats.NewEngine(db),
+		scrobbler:    scrobbler,
+		listenbrainz: listenbrainz.NewScrobbler(db, listenbrainz.NewClient(cfg.ListenBrainzURL)),
+		podcast:      podcastService,
+		mosaics:      artwork.NewMosaicCache(filepath.Join(cfg.DataPath, "cache", "playlists")),
+		musicPath:    cfg.MusicPath,
+		dataPath:     cfg.DataPath,
+	}
+}
+
+// StartBackgroundJobs starts the podcast download workers, the scrobble queues, the
+// recommendation refresh and the backfill of match keys and search vectors
+func (s *Service) StartBackgroundJobs() {
+	s.podcast.Start(2)
+
+	if s.scrobbler.Enabled() {
+		s.scrobbler.Start(5 * time.Minute)
 	}
+	s.listenbrainz.Start(5 * time.Minute)
+
+	s.recommender.Start(6 * time.Hour)
+
+	go func() {
+		if err := s.matcher.Backfill(); err != nil {
+			log.Printf("Error computing track match keys: %v", err)
+		}
+	}()
+
+	go func() {
+		if err := s.search.Refresh(); err != nil {
+			log.Printf("Error updating search index: %v", err)
+		}
+	}()
 }
 
-// AuthMiddleware handles authentication for Subsonic API requests
+// AuthMiddleware authenticates Subsonic API requests with the u parameter and either
+// the password p (plain or "enc:" hex encoded) or the token t = md5(password + s). Both
+// are checked against users.subsonic_password, which is stored in plain text because
+// the token cannot be verified from a hash. Requests without u may use the session of
+// the admin interface, which calls the API from the browser. The user is set as
+// "userID" and "username".
 func (s *Service) AuthMiddleware() gin.HandlerFunc {
 	return gin.HandlerFunc(func(c *gin.Context) {
-		// Para simplificar, permitir todas las peticiones por ahora
-		// TODO: Implementar autenticación Subsonic real (u/p, t/s parameters)
+		username := formValue(c, "u")
+		if username == "" {
+			if cookie, err := c.Cookie("auth_token"); err == nil {
+				if claims, err := s.auth.ValidateJWT(cookie); err == nil {
+					c.Set("userID", claims.UserID)
+					c.Set("username", claims.Username)
+					c.Next()
+					return
+				}
+			}
+		}
+
+		password, token, salt := formValue(c, "p"), formValue(c, "t"), formValue(c, "s")
+		if username == "" || (password == "" && (token == "" || salt == "")) {
+			s.sendError(c, 10, "Required parameter 'u' and either 'p' or 't' and 's' are missing")
+			c.Abort()
+			return
+		}
+
+		if token == "" {
+			if encoded, ok := strings.CutPrefix(password, "enc:"); ok {
+				decoded, err := hex.DecodeString(encoded)
+				if err != nil {
+					s.sendError(c, 40, "Wrong username or password")
+					c.Abort()
+					return
+				}
+				password = string(decoded)
+			}
+			token, salt = password, ""
+		}
+
+		user, err := s.auth.ValidateSubsonicAuth(s.db, username, token, salt)
+		if err != nil {
+			s.sendError(c, 40, "Wrong username or password")
+			c.Abort()
+			return
+		}
+
+		c.Set("userID", user.ID)
+		c.Set("username", user.Username)
 		c.Next()
 	})
 }
@@ -316,16 +662,30 @@ func (s *Service) sendResponse(c *gin.Context, data interface{}) {
 		response.Album = v
 	case *Child:
 		response.Song = v
+	case *SearchResult2:
+		response.SearchResult2 = v
 	case *SearchResult3:
 		response.SearchResult3 = v
+	case *AlbumList:
+		response.AlbumList = v
 	case *AlbumList2:
 		response.AlbumList2 = v
+	case *Decades:
+		response.Decades = v
+	case *OnThisDay:
+		response.OnThisDay = v
+	case *ListeningStats:
+		response.ListeningStats = v
+	case *YearlyReport:
+		response.YearlyReport = v
 	case *TopSongs:
 		response.TopSongs = v
 	case *RandomSongs:
 		response.RandomSongs = v
 	case *SongsByGenre:
 		response.SongsByGenre = v
+	case *SimilarSongs:
+		response.SimilarSongs = v
 	case *SimilarSongs2:
 		log.Printf("DEBUG: Setting SimilarSongs2 with %d songs", len(v.Song))
 		response.SimilarSongs2 = v
@@ -337,12 +697,34 @@ func (s *Service) sendResponse(c *gin.Context, data interface{}) {
 		response.Starred2 = v
 	case *ArtistInfo2:
 		response.ArtistInfo2 = v
+	case *AlbumInfo:
+		response.AlbumInfo = v
 	case *Playlists:
 		response.Playlists = v
 	case *PlaylistWithSongs:
 		response.Playlist = v
 	case *User:
 		response.User = v
+	case *Bookmarks:
+		response.Bookmarks = v
+	case *PlayQueue:
+		response.PlayQueue = v
+	case *PlayQueueByIndex:
+		response.PlayQueueByIndex = v
+	case *Shares:
+		response.Shares = v
+	case *InternetRadioStations:
+		response.InternetRadioStations = v
+	case *Podcasts:
+		response.Podcasts = v
+	case *NewestPodcasts:
+		response.NewestPodcasts = v
+	case *LastFMLink:
+		response.LastFMLink = v
+	case *ListenBrainzLink:
+		response.ListenBrainzLink = v
+	case *PlaylistCollaborators:
+		response.PlaylistCollaborators = v
 	}
 
 	if format == "json" {
@@ -385,18 +767,21 @@ func (s *Service) isValidID(id string) bool {
 	return true
 }
 
-// getUserID extracts user ID from the authenticated context
-// For now returns a fallback user ID of 1, but should be updated to use proper authentication
+// getUserID returns the user authenticated by AuthMiddleware, or 0 (no user) for
+// requests that did not go through it
 func (s *Service) getUserID(c *gin.Context) int {
-	// TODO: Extract from JWT token or Subsonic authentication in the context
-	// For now, return default user ID 1
-	// This should be set by the AuthMiddleware after successful authentication
 	if userID, exists := c.Get("userID"); exists {
 		if id, ok := userID.(int); ok {
 			return id
 		}
 	}
+	return 0
+}
 
-	// Fallback to user ID 1 for now
-	return 1
+// formValue reads a parameter from the query string or, for POST requests, the form
+func formValue(c *gin.Context, name string) string {
+	if value := c.Query(name); value != "" {
+		return value
+	}
+	return c.PostForm(name)
 }
@@ -0,0 +1,525 @@
+package subsonic
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetShares - Returns all shares created by the current user
+func (s *Service) GetShares(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	rows, err := s.db.Query(`
+		SELECT sh.id, sh.token, sh.description, sh.created_at, sh.expires_at,
+		       sh.last_visited, sh.visit_count, u.username
+		FROM shares sh
+		JOIN users u ON sh.user_id = u.id
+		WHERE sh.user_id = $1
+		ORDER BY sh.created_at DESC
+	`, userId)
+	if err != nil {
+		log.Printf("GetShares: Database error: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer rows.Close()
+
+	result := &Shares{Share: []Share{}}
+	for rows.Next() {
+		share, err := s.scanShare(c, rows)
+		if err != nil {
+			log.Printf("GetShares: Error scanning share: %v", err)
+			continue
+		}
+		result.Share = append(result.Share, share)
+	}
+
+	for i := range result.Share {
+		entries, err := s.getShareEntries(result.Share[i].ID)
+		if err != nil {
+			log.Printf("GetShares: Error loading entries for share %s: %v", result.Share[i].ID, err)
+			continue
+		}
+		result.Share[i].Entry = entries
+	}
+
+	s.sendResponse(c, result)
+}
+
+// CreateShare - Creates a public share link for songs, albums or playlists.
+// Songs are given with "id", whole albums with "albumId" and playlists with "playlistId".
+func (s *Service) CreateShare(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	var shareRole bool
+	if err := s.db.QueryRow("SELECT share_role FROM users WHERE id = $1", userId).Scan(&shareRole); err != nil {
+		log.Printf("CreateShare: Error loading share role: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	if !shareRole {
+		s.sendError(c, 50, "User is not authorized to share")
+		return
+	}
+
+	songIds, err := s.resolveShareSongs(userId, c.QueryArray("id"), c.QueryArray("albumId"), c.QueryArray("playlistId"))
+	switch {
+	case errors.Is(err, errPlaylistNotFound):
+		s.sendError(c, 70, "Playlist not found")
+		return
+	case errors.Is(err, errPlaylistHidden):
+		s.sendError(c, 50, "User is not authorized to share this playlist")
+		return
+	case err != nil:
+		log.Printf("CreateShare: Error resolving songs: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	if len(songIds) == 0 {
+		s.sendError(c, 10, "Required parameter 'id' is missing or does not match any song")
+		return
+	}
+
+	expires, err := parseShareExpiry(c.Query("expires"))
+	if err != nil {
+		s.sendError(c, 10, "Parameter 'expires' is invalid")
+		return
+	}
+
+	token, err := generateShareToken()
+	if err != nil {
+		log.Printf("CreateShare: Error generating token: %v", err)
+		s.sendError(c, 0, "Failed to create share")
+		return
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Printf("CreateShare: Error starting transaction: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+	defer tx.Rollback()
+
+	var shareId int
+	err = tx.QueryRow(`
+		INSERT INTO shares (user_id, token, description, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		RETURNING id
+	`, userId, token, c.Query("description"), expires).Scan(&shareId)
+	if err != nil {
+		log.Printf("CreateShare: Error creating share: %v", err)
+		s.sendError(c, 0, "Failed to create share")
+		return
+	}
+
+	for i, songId := range songIds {
+		_, err = tx.Exec(`
+			INSERT INTO share_entries (share_id, song_id, position)
+			VALUES ($1, $2, $3)
+		`, shareId, songId, i)
+		if err != nil {
+			log.Printf("CreateShare: Error adding song %s: %v", songId, err)
+			s.sendError(c, 0, "Failed to create share")
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("CreateShare: Error committing transaction: %v", err)
+		s.sendError(c, 0, "Failed to create share")
+		return
+	}
+
+	share, err := s.getShare(c, strconv.Itoa(shareId))
+	if err != nil {
+		log.Printf("CreateShare: Error loading created share: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+
+	log.Printf("CreateShare: User %d shared %d songs (share %d)", userId, len(songIds), shareId)
+	s.sendResponse(c, &Shares{Share: []Share{share}})
+}
+
+// UpdateShare - Updates the description and/or expiration date of a share
+func (s *Service) UpdateShare(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	id := c.Query("id")
+	if !s.isValidID(id) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	if !s.shareOwnedBy(id, userId) {
+		s.sendError(c, 70, "Share not found")
+		return
+	}
+
+	if description, ok := c.GetQuery("description"); ok {
+		if _, err := s.db.Exec("UPDATE shares SET description = $1 WHERE id = $2", description, id); err != nil {
+			log.Printf("UpdateShare: Error updating description: %v", err)
+			s.sendError(c, 0, "Failed to update share")
+			return
+		}
+	}
+
+	if expiresStr, ok := c.GetQuery("expires"); ok {
+		expires, err := parseShareExpiry(expiresStr)
+		if err != nil {
+			s.sendError(c, 10, "Parameter 'expires' is invalid")
+			return
+		}
+		if _, err := s.db.Exec("UPDATE shares SET expires_at = $1 WHERE id = $2", expires, id); err != nil {
+			log.Printf("UpdateShare: Error updating expiry: %v", err)
+			s.sendError(c, 0, "Failed to update share")
+			return
+		}
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// DeleteShare - Deletes an existing share
+func (s *Service) DeleteShare(c *gin.Context) {
+	userId := s.getUserID(c)
+
+	id := c.Query("id")
+	if !s.isValidID(id) {
+		s.sendError(c, 10, "Required parameter 'id' is missing or invalid")
+		return
+	}
+
+	result, err := s.db.Exec("DELETE FROM shares WHERE id = $1 AND user_id = $2", id, userId)
+	if err != nil {
+		log.Printf("DeleteShare: Error deleting share: %v", err)
+		s.sendError(c, 0, "Failed to delete share")
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		s.sendError(c, 70, "Share not found")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// SharePage - Public page that lets anyone with the link play the shared songs
+func (s *Service) SharePage(c *gin.Context) {
+	shareId, _, status := s.lookupShareToken(c.Param("token"))
+	if status != http.StatusOK {
+		message := "Este enlace no existe"
+		if status == http.StatusGone {
+			message = "Este enlace ha caducado"
+		}
+		c.HTML(status, "share.html", gin.H{"title": "Enlace compartido", "error": message})
+		return
+	}
+
+	share, err := s.getShare(c, shareId)
+	if err != nil {
+		log.Printf("SharePage: Error loading share %s: %v", shareId, err)
+		c.HTML(http.StatusInternalServerError, "share.html", gin.H{"title": "Enlace compartido", "error": "Error al cargar el enlace"})
+		return
+	}
+
+	_, err = s.db.Exec(`
+		UPDATE shares SET visit_count = visit_count + 1, last_visited = NOW()
+		WHERE id = $1
+	`, shareId)
+	if err != nil {
+		log.Printf("SharePage: Error recording visit for share %s: %v", shareId, err)
+	}
+
+	title := share.Description
+	if title == "" {
+		title = "Música compartida por " + share.Username
+	}
+
+	c.HTML(http.StatusOK, "share.html", gin.H{
+		"title": title,
+		"share": share,
+		"token": c.Param("token"),
+	})
+}
+
+// ShareStream - Public stream endpoint limited to the songs of a share.
+// Plays are logged in play_history against the owner of the share, tagged with share_id so
+// the statistics and recommendations of the owner leave them out.
+func (s *Service) ShareStream(c *gin.Context) {
+	shareId, ownerId, status := s.lookupShareToken(c.Param("token"))
+	if status != http.StatusOK {
+		c.Status(status)
+		return
+	}
+
+	songId := c.Query("id")
+	if !s.isValidID(songId) {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	var shared bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM share_entries WHERE share_id = $1 AND song_id = $2)
+	`, shareId, songId).Scan(&shared)
+	if err != nil {
+		log.Printf("ShareStream: Database error: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if !shared {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	fullPath, format, fileSize, err := s.resolveSongFile(songId)
+	if err != nil {
+		if err == sql.ErrNoRows || os.IsNotExist(err) {
+			c.Status(http.StatusNotFound)
+		} else {
+			log.Printf("ShareStream: Database error: %v", err)
+			c.Status(http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Browsers issue several range requests per playback, only the first one counts as a play
+	if rangeHeader := c.GetHeader("Range"); rangeHeader == "" || rangeHeader == "bytes=0-" {
+		_, err = s.db.Exec(`
+			INSERT INTO play_history (user_id, song_id, played_at, duration_played, share_id)
+			SELECT $1, id, NOW(), duration, $3 FROM songs WHERE id = $2
+		`, ownerId, songId, shareId)
+		if err != nil {
+			log.Printf("ShareStream: Error logging play: %v", err)
+		}
+	}
+
+	s.serveAudioFile(c, fullPath, format, fileSize)
+
+	log.Printf("Streaming song ID %s through share %s", songId, shareId)
+}
+
+// lookupShareToken resolves a public token into the share ID and its owner.
+// The returned status is http.StatusOK for a usable share.
+func (s *Service) lookupShareToken(token string) (string, int, int) {
+	var shareId string
+	var ownerId int
+	var expiresAt sql.NullTime
+
+	err := s.db.QueryRow(`
+		SELECT id, user_id, expires_at FROM shares WHERE token = $1
+	`, token).Scan(&shareId, &ownerId, &expiresAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("lookupShareToken: Database error: %v", err)
+			return "", 0, http.StatusInternalServerError
+		}
+		return "", 0, http.StatusNotFound
+	}
+
+	if expiresAt.Valid && expiresAt.Time.Before(time.Now()) {
+		return "", 0, http.StatusGone
+	}
+
+	return shareId, ownerId, http.StatusOK
+}
+
+// getShare loads a single share with its entries
+func (s *Service) getShare(c *gin.Context, id string) (Share, error) {
+	row := s.db.QueryRow(`
+		SELECT sh.id, sh.token, sh.description, sh.created_at, sh.expires_at,
+		       sh.last_visited, sh.visit_count, u.username
+		FROM shares sh
+		JOIN users u ON sh.user_id = u.id
+		WHERE sh.id = $1
+	`, id)
+
+	share, err := s.scanShare(c, row)
+	if err != nil {
+		return Share{}, err
+	}
+
+	share.Entry, err = s.getShareEntries(share.ID)
+	return share, err
+}
+
+// scanShare scans a share row (without entries) and builds its public URL
+func (s *Service) scanShare(c *gin.Context, row interface{ Scan(...interface{}) error }) (Share, error) {
+	var share Share
+	var token string
+	var description sql.NullString
+	var createdAt time.Time
+	var expiresAt, lastVisited sql.NullTime
+
+	err := row.Scan(&share.ID, &token, &description, &createdAt, &expiresAt,
+		&lastVisited, &share.VisitCount, &share.Username)
+	if err != nil {
+		return share, err
+	}
+
+	share.URL = shareURL(c, token)
+	share.Description = description.String
+	share.Created = createdAt.Format("2006-01-02T15:04:05Z")
+	if expiresAt.Valid {
+		share.Expires = expiresAt.Time.Format("2006-01-02T15:04:05Z")
+	}
+	if lastVisited.Valid {
+		share.LastVisited = lastVisited.Time.Format("2006-01-02T15:04:05Z")
+	}
+	share.Entry = []Child{}
+
+	return share, nil
+}
+
+// getShareEntries returns the songs of a share in their shared order
+func (s *Service) getShareEntries(shareId string) ([]Child, error) {
+	rows, err := s.db.Query(`
+		SELECT s.id, s.title, s.track_number, s.duration, s.file_path,
+		       s.file_size, s.bitrate, s.format, s.album_id,
+		       ar.name, al.name, al.year, al.genre, al.cover_art_path
+		FROM share_entries se
+		JOIN songs s ON se.song_id = s.id
+		JOIN artists ar ON s.artist_id = ar.id
+		JOIN albums al ON s.album_id = al.id
+		WHERE se.share_id = $1
+		ORDER BY se.position
+	`, shareId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := s.scanSongs(rows)
+	if entries == nil {
+		entries = []Child{}
+	}
+	return entries, nil
+}
+
+// errPlaylistHidden is returned when sharing a playlist the user cannot view
+var errPlaylistHidden = errors.New("user is not allowed to view the playlist")
+
+// resolveShareSongs expands songs, albums and playlists into a de-duplicated, ordered list
+// of song IDs. Playlists must be visible to userId, as in getPlaylist.
+func (s *Service) resolveShareSongs(userId int, songIds, albumIds, playlistIds []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+
+	add := func(query string, id string) error {
+		rows, err := s.db.Query(query, id)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var songId string
+			if err := rows.Scan(&songId); err != nil {
+				return err
+			}
+			if !seen[songId] {
+				seen[songId] = true
+				result = append(result, songId)
+			}
+		}
+		return rows.Err()
+	}
+
+	for _, id := range songIds {
+		if _, err := strconv.Atoi(id); err != nil {
+			continue
+		}
+		if err := add("SELECT id FROM songs WHERE id = $1", id); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range albumIds {
+		if _, err := strconv.Atoi(id); err != nil {
+			continue
+		}
+		if err := add("SELECT id FROM songs WHERE album_id = $1 ORDER BY track_number, title", id); err != nil {
+			return nil, err
+		}
+	}
+	for _, id := range playlistIds {
+		playlistId, err := strconv.Atoi(id)
+		if err != nil {
+			continue
+		}
+		visible, err := s.canViewPlaylist(playlistId, userId)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, errPlaylistHidden
+		}
+		if err := add("SELECT song_id FROM playlist_songs WHERE playlist_id = $1 ORDER BY position, added_at", id); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// shareOwnedBy checks that a share exists and belongs to the given user
+func (s *Service) shareOwnedBy(id string, userId int) bool {
+	var owned bool
+	err := s.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM shares WHERE id = $1 AND user_id = $2)
+	`, id, userId).Scan(&owned)
+	if err != nil {
+		log.Printf("shareOwnedBy: Database error: %v", err)
+		return false
+	}
+	return owned
+}
+
+// parseShareExpiry parses an expiry given in milliseconds since epoch.
+// An empty value or 0 means the share never expires.
+func parseShareExpiry(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	ms, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || ms < 0 {
+		return nil, fmt.Errorf("invalid expiry %q", value)
+	}
+	if ms == 0 {
+		return nil, nil
+	}
+	expires := time.UnixMilli(ms)
+	return &expires, nil
+}
+
+// generateShareToken returns an unguessable, URL-safe token
+func generateShareToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// shareURL builds the public URL of a share from the incoming request
+func shareURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return fmt.Sprintf("%s://%s/share/%s", scheme, c.Request.Host, token)
+}
@@ -0,0 +1,189 @@
+package subsonic
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+
+	"castafiore-backend/internal/smartplaylist"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxNSPSize limits the size of an uploaded .nsp file
+const maxNSPSize = 1 << 20
+
+// smartPlaylistSongs evaluates the rules of a smart playlist for its owner
+func (s *Service) smartPlaylistSongs(rules string, ownerId int) ([]Child, error) {
+	criteria, err := smartplaylist.Parse([]byte(rules))
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := criteria.Query(`s.id, s.title, s.track_number, s.duration, s.file_path,
+		       s.file_size, s.bitrate, s.format, s.album_id,
+		       ar.name, al.name, al.year, al.genre, al.cover_art_path`, ownerId)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return s.scanSongs(rows), nil
+}
+
+// smartPlaylistSummary counts the songs of a smart playlist for its owner and sums their
+// duration in the database, for listings that do not need the songs
+func (s *Service) smartPlaylistSummary(rules string, ownerId int) (int, int, error) {
+	criteria, err := smartplaylist.Parse([]byte(rules))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	query, args, err := criteria.SummaryQuery(ownerId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count, duration int
+	err = s.db.QueryRow(query, args...).Scan(&count, &duration)
+	return count, duration, err
+}
+
+// CreateSmartPlaylist creates a playlist defined by rules in the .nsp format. Rules may
+// be too long for a URL, so the parameters are also accepted as a POST form.
+func (s *Service) CreateSmartPlaylist(c *gin.Context) {
+	name := formValue(c, "name")
+	rules := formValue(c, "rules")
+	if name == "" || rules == "" {
+		s.sendError(c, 10, "Required parameters 'name' and 'rules' are missing")
+		return
+	}
+
+	criteria, err := smartplaylist.Parse([]byte(rules))
+	if err != nil {
+		s.sendError(c, 0, "Invalid rules: "+err.Error())
+		return
+	}
+
+	playlist := &smartplaylist.Playlist{
+		Name:     name,
+		Comment:  formValue(c, "comment"),
+		Public:   formValue(c, "public") == "true",
+		Criteria: criteria,
+	}
+	if _, err := s.saveSmartPlaylist(s.getUserID(c), playlist); err != nil {
+		log.Printf("CreateSmartPlaylist: Error saving playlist: %v", err)
+		s.sendError(c, 0, "Failed to create playlist")
+		return
+	}
+
+	s.sendResponse(c, nil)
+}
+
+// ImportSmartPlaylist creates or replaces a smart playlist from a Navidrome .nsp file,
+// uploaded as the "file" form field or as the request body. The playlist takes the
+// name from the "name" parameter or from the file.
+func (s *Service) ImportSmartPlaylist(c *gin.Context) {
+	var reader io.Reader = c.Request.Body
+	if file, _, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		reader = file
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxNSPSize))
+	if err != nil || len(data) == 0 {
+		s.sendError(c, 10, "Required .nsp file is missing")
+		return
+	}
+
+	playlist, err := smartplaylist.ParseNSP(data)
+	if err != nil {
+		s.sendError(c, 0, "Invalid .nsp file: "+err.Error())
+		return
+	}
+	if name := c.Query("name"); name != "" {
+		playlist.Name = name
+	}
+	if playlist.Name == "" {
+		s.sendError(c, 10, "Required parameter 'name' is missing")
+		return
+	}
+
+	playlistId, err := s.saveSmartPlaylist(s.getUserID(c), playlist)
+	if err != nil {
+		log.Printf("ImportSmartPlaylist: Error saving playlist: %v", err)
+		s.sendError(c, 0, "Failed to import playlist")
+		return
+	}
+
+	log.Printf("ImportSmartPlaylist: Imported '%s' as playlist %d", playlist.Name, playlistId)
+	s.sendResponse(c, nil)
+}
+
+// updatePlaylistRules applies the "rules" parameter of updatePlaylist within tx. It sends
+// the error response itself and returns false when the rules cannot be applied.
+func (s *Service) updatePlaylistRules(c *gin.Context, tx *sql.Tx, playlistId int, isSmart bool) bool {
+	rules := c.Query("rules")
+	if rules == "" {
+		return true
+	}
+	if !isSmart {
+		s.sendError(c, 0, "Rules can only be set on smart playlists")
+		return false
+	}
+
+	criteria, err := smartplaylist.Parse([]byte(rules))
+	if err != nil {
+		s.sendError(c, 0, "Invalid rules: "+err.Error())
+		return false
+	}
+	normalized, err := json.Marshal(criteria)
+	if err != nil {
+		s.sendError(c, 0, "Invalid rules: "+err.Error())
+		return false
+	}
+
+	_, err = tx.Exec("UPDATE playlists SET rules = $1, updated_at = NOW() WHERE id = $2", string(normalized), playlistId)
+	if err != nil {
+		log.Printf("Error updating playlist rules: %v", err)
+		s.sendError(c, 0, "Failed to update playlist rules")
+		return false
+	}
+	return true
+}
+
+// saveSmartPlaylist stores a smart playlist, replacing the rules of an existing smart
+// playlist of the user with the same name so a .nsp file can be imported again
+func (s *Service) saveSmartPlaylist(userId int, playlist *smartplaylist.Playlist) (int, error) {
+	rules, err := json.Marshal(playlist.Criteria)
+	if err != nil {
+		return 0, err
+	}
+
+	var playlistId int
+	err = s.db.QueryRow(`
+		UPDATE playlists SET rules = $1, comment = $2, is_public = $3, updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM playlists
+			WHERE user_id = $4 AND name = $5 AND rules IS NOT NULL
+			ORDER BY id LIMIT 1
+		)
+		RETURNING id
+	`, string(rules), playlist.Comment, playlist.Public, userId, playlist.Name).Scan(&playlistId)
+	if err != sql.ErrNoRows {
+		return playlistId, err
+	}
+
+	err = s.db.QueryRow(`
+		INSERT INTO playlists (user_id, name, comment, is_public, rules, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
+		RETURNING id
+	`, userId, playlist.Name, playlist.Comment, playlist.Public, string(rules)).Scan(&playlistId)
+	return playlistId, err
+}
@@ -0,0 +1,124 @@
+package subsonic
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"castafiore-backend/internal/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetListeningStats - Returns the listening statistics of the user (extension). period is
+// week, month (the default), year, all, a year such as 2024 or a month such as 2024-05;
+// count is the length of the top lists.
+func (s *Service) GetListeningStats(c *gin.Context) {
+	period, err := stats.ParsePeriod(c.Query("period"), time.Now())
+	if err != nil {
+		s.sendError(c, 10, "Parameter 'period' is invalid")
+		return
+	}
+
+	report, err := s.stats.Report(s.getUserID(c), period, parseIntDefault(c.Query("count"), stats.DefaultLimit))
+	if err != nil {
+		log.Printf("GetListeningStats: Error computing statistics: %v", err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+
+	result := listeningStats(report)
+	s.sendResponse(c, &result)
+}
+
+// GetYearlyReport - Returns the yearly summary of the user for year, the current one by
+// default (extension). format=json and format=html export it as a file instead.
+func (s *Service) GetYearlyReport(c *gin.Context) {
+	year := parseIntDefault(c.Query("year"), time.Now().Year())
+	if year < 1 || year > 9999 {
+		s.sendError(c, 10, "Parameter 'year' is invalid")
+		return
+	}
+
+	format := c.Query("format")
+	if format != "" && format != "json" && format != "html" {
+		s.sendError(c, 10, "Parameter 'format' must be json or html")
+		return
+	}
+
+	wrapped, err := s.stats.Wrapped(s.getUserID(c), year, parseIntDefault(c.Query("count"), stats.DefaultLimit))
+	if err != nil {
+		log.Printf("GetYearlyReport: Error computing the %d report: %v", year, err)
+		s.sendError(c, 0, "Database error")
+		return
+	}
+
+	switch format {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%d.json", wrapped.Username, year)))
+		c.JSON(http.StatusOK, wrapped)
+	case "html":
+		c.HTML(http.StatusOK, "wrapped.html", gin.H{"title": fmt.Sprintf("Tu %d en música", year), "report": wrapped})
+	default:
+		s.sendResponse(c, &YearlyReport{
+			ListeningStats:      listeningStats(wrapped.Report),
+			Year:                year,
+			BusiestDay:          wrapped.BusiestDay,
+			BusiestDayPlayCount: wrapped.BusiestDayPlays,
+			Month:               statsCounts(wrapped.ByMonth, 1),
+		})
+	}
+}
+
+// listeningStats converts a report to its response
+func listeningStats(r *stats.Report) ListeningStats {
+	result := ListeningStats{
+		Period:        r.Period.Name,
+		To:            r.Period.To.Format(time.RFC3339),
+		PlayCount:     r.Plays,
+		ListeningTime: r.Seconds,
+		CurrentStreak: r.CurrentStreak,
+		LongestStreak: r.LongestStreak,
+		NewArtists:    r.Discoveries.Artists,
+		NewAlbums:     r.Discoveries.Albums,
+		NewSongs:      r.Discoveries.Songs,
+		Artist:        statsEntries(r.TopArtists),
+		Album:         statsEntries(r.TopAlbums),
+		Song:          statsEntries(r.TopSongs),
+		Genre:         statsEntries(r.TopGenres),
+		Hour:          statsCounts(r.ByHour, 0),
+		Weekday:       statsCounts(r.ByWeekday, 0),
+	}
+	if !r.Period.From.IsZero() {
+		result.From = r.Period.From.Format(time.RFC3339)
+	}
+	return result
+}
+
+func statsEntries(items []stats.Item) []StatsEntry {
+	entries := make([]StatsEntry, 0, len(items))
+	for _, item := range items {
+		entry := StatsEntry{
+			Name:          item.Name,
+			Artist:        item.Artist,
+			PlayCount:     item.Plays,
+			ListeningTime: item.Seconds,
+		}
+		if item.ID != 0 {
+			entry.ID = strconv.Itoa(item.ID)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// statsCounts numbers counts from first, such as hours from 0 or months from 1
+func statsCounts(counts []int, first int) []StatsCount {
+	result := make([]StatsCount, len(counts))
+	for i, count := range counts {
+		result[i] = StatsCount{Value: first + i, PlayCount: count}
+	}
+	return result
+}
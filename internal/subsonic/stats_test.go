@@ -0,0 +1,45 @@
+package subsonic
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"castafiore-backend/internal/stats"
+)
+
+func TestListeningStats(t *testing.T) {
+	to := time.Date(2024, 5, 31, 18, 0, 0, 0, time.UTC)
+	report := &stats.Report{
+		Period:     stats.Period{Name: "all", To: to},
+		Plays:      12,
+		TopArtists: []stats.Item{{ID: 3, Name: "Nina Simone", Plays: 7, Seconds: 1500}},
+		TopGenres:  []stats.Item{{Name: "Jazz", Plays: 12}},
+		ByWeekday:  []int{1, 0, 0, 0, 0, 0, 11},
+	}
+
+	result := listeningStats(report)
+	if result.From != "" || result.To != "2024-05-31T18:00:00Z" {
+		t.Errorf("range = %q to %q, want no start", result.From, result.To)
+	}
+	if want := []StatsEntry{{ID: "3", Name: "Nina Simone", PlayCount: 7, ListeningTime: 1500}}; !reflect.DeepEqual(result.Artist, want) {
+		t.Errorf("Artist = %+v, want %+v", result.Artist, want)
+	}
+	// Genres have no id
+	if result.Genre[0].ID != "" {
+		t.Errorf("Genre id = %q, want none", result.Genre[0].ID)
+	}
+	if result.Album == nil || len(result.Album) != 0 {
+		t.Errorf("Album = %#v, want an empty list", result.Album)
+	}
+	if got := result.Weekday[6]; got != (StatsCount{Value: 6, PlayCount: 11}) {
+		t.Errorf("Saturday = %+v", got)
+	}
+}
+
+func TestStatsCountsNumbering(t *testing.T) {
+	months := statsCounts([]int{4, 0, 2}, 1)
+	if want := []StatsCount{{1, 4}, {2, 0}, {3, 2}}; !reflect.DeepEqual(months, want) {
+		t.Errorf("statsCounts() = %+v, want %+v", months, want)
+	}
+}
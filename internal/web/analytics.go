@@ -0,0 +1,30 @@
+package web
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"castafiore-backend/internal/analytics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API: analíticas del servidor para las gráficas del dashboard (?days=30)
+func (w *WebController) APIAnalytics(c *gin.Context) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(analytics.DefaultDays)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Número de días no válido"})
+		return
+	}
+
+	summary, err := w.analytics.Summary(days, time.Now())
+	if err != nil {
+		log.Printf("Error obteniendo las analíticas: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error al obtener las analíticas"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
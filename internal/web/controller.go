@@ -9,12 +9,18 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"castafiore-backend/internal/analytics"
 	"castafiore-backend/internal/auth"
 	"castafiore-backend/internal/config"
+	"castafiore-backend/internal/lastfm"
 	"castafiore-backend/internal/library"
+	"castafiore-backend/internal/search"
+	"castafiore-backend/internal/stats"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 type WebController struct {
@@ -23,6 +29,9 @@ type WebController struct {
 	config           *config.Config
 	scanner          *library.Scanner
 	optimizedScanner *library.OptimizedScanner
+	search           *search.Engine
+	stats            *stats.Engine
+	analytics        *analytics.Engine
 }
 
 type DashboardData struct {
@@ -50,6 +59,7 @@ type User struct {
 	MaxDownloadsPerDay   int    `json:"max_downloads_per_day"`
 	IsAdmin              bool   `json:"is_admin"`
 	IsActive             bool   `json:"is_active"`
+	ShareRole            bool   `json:"share_role"`
 	CreatedAt            string `json:"created_at"`
 }
 
@@ -112,6 +122,9 @@ func NewWebController(db *sql.DB, authService *auth.Service, cfg *config.Config)
 		config:           cfg,
 		scanner:          scanner,
 		optimizedScanner: optimizedScanner,
+		search:           search.NewEngine(db),
+		stats:            stats.NewEngine(db),
+		analytics:        analytics.NewEngine(db),
 	}
 
 	// Cargar el directorio de música persistido si existe
@@ -123,6 +136,12 @@ func NewWebController(db *sql.DB, authService *auth.Service, cfg *config.Config)
 	return controller
 }
 
+// StartBackgroundJobs inicia los trabajos periódicos del panel: las analíticas del
+// dashboard se recalculan cada hora
+func (w *WebController) StartBackgroundJobs() {
+	w.analytics.Start(time.Hour)
+}
+
 // Login página
 func (w *WebController) LoginForm(c *gin.Context) {
 	c.HTML(http.StatusOK, "login.html", gin.H{
@@ -1108,7 +1127,7 @@ func (w *WebController) SearchMusic(c *gin.Context) {
 	pageSize := 20
 
 	// Realizar búsqueda
-	results := w.performMusicSearch(query, section, page, pageSize)
+	results := w.performMusicSearch(query, section, c.GetInt("user_id"), page, pageSize)
 
 	// Preparar datos para el template
 	var libraryData LibraryData
@@ -1144,8 +1163,9 @@ func (w *WebController) SearchMusic(c *gin.Context) {
 	})
 }
 
-// Realizar búsqueda en la música (optimizada con base de datos)
-func (w *WebController) performMusicSearch(query, section string, page, pageSize int) SearchResults {
+// Realizar búsqueda en la música con el mismo motor y la misma sintaxis que la API
+// Subsonic (artist:, year:1990..1999, -live...)
+func (w *WebController) performMusicSearch(query, section string, userID, page, pageSize int) SearchResults {
 	results := SearchResults{
 		Query:   query,
 		Artists: []Artist{},
@@ -1153,36 +1173,37 @@ func (w *WebController) performMusicSearch(query, section string, page, pageSize
 		Songs:   []Song{},
 	}
 
-	searchTerm := "%" + strings.ToLower(query) + "%"
+	// En "all" se muestran los 10 primeros de cada tipo; en una sección, la página pedida
+	limit, offset := pageSize, (page-1)*pageSize
+	if section == "all" {
+		limit, offset = 10, 0
+	}
+
+	// Cargar las filas encontradas por el motor ($1) en orden de relevancia
+	find := func(kind search.Kind, rowsQuery string) (*sql.Rows, error) {
+		ids, err := w.search.Find(kind, query, userID, limit, offset)
+		if err != nil {
+			log.Printf("Error searching library: %v", err)
+			return nil, err
+		}
+		return w.db.Query(rowsQuery, pq.Array(ids))
+	}
 
 	// Buscar artistas
 	if section == "all" || section == "artists" {
-		artistQuery := `
+		rows, err := find(search.Artists, `
 			SELECT 
 				a.id, 
 				a.name,
 				COUNT(DISTINCT al.id) as album_count,
 				COUNT(s.id) as song_count
-			FROM artists a
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN artists a ON a.id = m.id
 			LEFT JOIN albums al ON a.id = al.artist_id
 			LEFT JOIN songs s ON a.id = s.artist_id
-			WHERE LOWER(a.name) LIKE $1
-			GROUP BY a.id, a.name
-			ORDER BY a.name
-		`
-
-		limit := pageSize
-		if section == "all" {
-			limit = 10 // Limit for "all" search
-		}
-		artistQuery += fmt.Sprintf(" LIMIT %d", limit)
-
-		if section == "artists" {
-			offset := (page - 1) * pageSize
-			artistQuery += fmt.Sprintf(" OFFSET %d", offset)
-		}
-
-		rows, err := w.db.Query(artistQuery, searchTerm)
+			GROUP BY a.id, a.name, m.pos
+			ORDER BY m.pos
+		`)
 		if err == nil {
 			defer rows.Close()
 			for rows.Next() {
@@ -1197,7 +1218,7 @@ func (w *WebController) performMusicSearch(query, section string, page, pageSize
 
 	// Buscar álbumes
 	if section == "all" || section == "albums" {
-		albumQuery := `
+		rows, err := find(search.Albums, `
 			SELECT 
 				al.id, 
 				al.name, 
@@ -1206,26 +1227,13 @@ func (w *WebController) performMusicSearch(query, section string, page, pageSize
 				al.year,
 				COUNT(s.id) as song_count,
 				COALESCE(SUM(s.duration), 0) as total_duration
-			FROM albums al 
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN albums al ON al.id = m.id
 			JOIN artists ar ON al.artist_id = ar.id
 			LEFT JOIN songs s ON al.id = s.album_id
-			WHERE LOWER(al.name) LIKE $1 OR LOWER(ar.name) LIKE $1
-			GROUP BY al.id, al.name, ar.id, ar.name, al.year
-			ORDER BY ar.name, al.name
-		`
-
-		limit := pageSize
-		if section == "all" {
-			limit = 10
-		}
-		albumQuery += fmt.Sprintf(" LIMIT %d", limit)
-
-		if section == "albums" {
-			offset := (page - 1) * pageSize
-			albumQuery += fmt.Sprintf(" OFFSET %d", offset)
-		}
-
-		rows, err := w.db.Query(albumQuery, searchTerm)
+			GROUP BY al.id, al.name, ar.id, ar.name, al.year, m.pos
+			ORDER BY m.pos
+		`)
 		if err == nil {
 			defer rows.Close()
 			for rows.Next() {
@@ -1243,31 +1251,18 @@ func (w *WebController) performMusicSearch(query, section string, page, pageSize
 
 	// Buscar canciones
 	if section == "all" || section == "songs" {
-		songQuery := `
+		rows, err := find(search.Songs, `
 			SELECT 
 				s.id, s.title, s.artist_id, ar.name as artist_name,
 				s.album_id, al.name as album_name, s.track_number,
 				COALESCE(al.year, 0) as year, s.duration, s.file_size,
 				s.format, s.file_path, s.bitrate
-			FROM songs s 
+			FROM unnest($1::int[]) WITH ORDINALITY AS m(id, pos)
+			JOIN songs s ON s.id = m.id
 			JOIN artists ar ON s.artist_id = ar.id
 			JOIN albums al ON s.album_id = al.id
-			WHERE LOWER(s.title) LIKE $1 OR LOWER(ar.name) LIKE $1 OR LOWER(al.name) LIKE $1
-			ORDER BY ar.name, al.name, s.track_number, s.title
-		`
-
-		limit := pageSize
-		if section == "all" {
-			limit = 10
-		}
-		songQuery += fmt.Sprintf(" LIMIT %d", limit)
-
-		if section == "songs" {
-			offset := (page - 1) * pageSize
-			songQuery += fmt.Sprintf(" OFFSET %d", offset)
-		}
-
-		rows, err := w.db.Query(songQuery, searchTerm)
+			ORDER BY m.pos
+		`)
 		if err == nil {
 			defer rows.Close()
 			for rows.Next() {
@@ -1424,6 +1419,25 @@ func (wc *WebController) GetLibraryStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// PurgeLastFMCache deletes cached Last.fm responses, optionally only those of one method
+func (wc *WebController) PurgeLastFMCache(c *gin.Context) {
+	method := c.Query("method")
+
+	deleted, err := lastfm.NewPostgresCache(wc.db).Purge(method)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to purge Last.fm cache: " + err.Error(),
+		})
+		return
+	}
+
+	log.Printf("Last.fm cache purged (method=%q): %d entries", method, deleted)
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Last.fm cache purged",
+		"deleted": deleted,
+	})
+}
+
 // getMusicPath reads the music path from the config file
 func (wc *WebController) getMusicPath() (string, error) {
 	configFile := "config/music_path.txt"
@@ -1527,6 +1541,7 @@ func (w *WebController) EditUser(c *gin.Context) {
 	maxDownloads := c.PostForm("max_downloads_per_day")
 	isAdmin := c.PostForm("is_admin") == "on"
 	isActive := c.PostForm("is_active") == "on"
+	shareRole := c.PostForm("share_role") == "on"
 
 	if username == "" || email == "" {
 		w.renderPage(c, "Editar Usuario", "edit_user", gin.H{
@@ -1586,21 +1601,23 @@ func (w *WebController) EditUser(c *gin.Context) {
 		query = `
 			UPDATE users 
 			SET username = $1, email = $2, password_hash = $3, subsonic_password = $4, subscription_plan = $5, 
-			    max_concurrent_streams = $6, max_downloads_per_day = $7, is_admin = $8, is_active = $9
-			WHERE id = $10
+			    max_concurrent_streams = $6, max_downloads_per_day = $7, is_admin = $8, is_active = $9,
+			    share_role = $10
+			WHERE id = $11
 		`
 		args = []interface{}{username, email, hashedPassword, password, subscriptionPlan,
-			maxStreamsInt, maxDownloadsInt, isAdmin, isActive, userID}
+			maxStreamsInt, maxDownloadsInt, isAdmin, isActive, shareRole, userID}
 	} else {
 		// No password change
 		query = `
 			UPDATE users 
 			SET username = $1, email = $2, subscription_plan = $3, 
-			    max_concurrent_streams = $4, max_downloads_per_day = $5, is_admin = $6, is_active = $7
-			WHERE id = $8
+			    max_concurrent_streams = $4, max_downloads_per_day = $5, is_admin = $6, is_active = $7,
+			    share_role = $8
+			WHERE id = $9
 		`
 		args = []interface{}{username, email, subscriptionPlan,
-			maxStreamsInt, maxDownloadsInt, isAdmin, isActive, userID}
+			maxStreamsInt, maxDownloadsInt, isAdmin, isActive, shareRole, userID}
 	}
 
 	_, err = w.db.Exec(query, args...)
@@ -1673,7 +1690,7 @@ func (w *WebController) getUserByID(userID string) (User, error) {
 
 	query := `
 		SELECT id, username, email, subscription_plan, max_concurrent_streams, 
-		       max_downloads_per_day, is_admin, is_active, created_at
+		       max_downloads_per_day, is_admin, is_active, share_role, created_at
 		FROM users 
 		WHERE id = $1
 	`
@@ -1681,7 +1698,7 @@ func (w *WebController) getUserByID(userID string) (User, error) {
 	err := w.db.QueryRow(query, userID).Scan(
 		&user.ID, &user.Username, &user.Email, &user.SubscriptionPlan,
 		&user.MaxConcurrentStreams, &user.MaxDownloadsPerDay,
-		&user.IsAdmin, &user.IsActive, &user.CreatedAt,
+		&user.IsAdmin, &user.IsActive, &user.ShareRole, &user.CreatedAt,
 	)
 
 	return user, err
@@ -0,0 +1,135 @@
+package web
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"castafiore-backend/internal/library"
+	"castafiore-backend/internal/playlistfile"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PlaylistSummary es una playlist en la página de administración
+type PlaylistSummary struct {
+	ID         int
+	Name       string
+	Owner      string
+	Public     bool
+	Smart      bool
+	SongCount  int
+	SourcePath string
+	Updated    time.Time
+	Unresolved []library.UnresolvedEntry
+}
+
+// Página de playlists
+func (w *WebController) Playlists(c *gin.Context) {
+	w.renderPlaylistsPage(c, "", "")
+}
+
+// Importar los ficheros de playlist de la carpeta de música (POST)
+func (w *WebController) ImportPlaylistFiles(c *gin.Context) {
+	stats, err := library.NewPlaylistImporter(w.db).ImportPlaylists(w.config.MusicPath)
+	if err != nil {
+		w.renderPlaylistsPage(c, "", "Error al importar las playlists: "+err.Error())
+		return
+	}
+
+	message := fmt.Sprintf("%d ficheros encontrados: %d importados, %d sin cambios, %d eliminados, %d entradas sin resolver",
+		stats.Files, stats.Imported, stats.Unchanged, stats.Removed, stats.Unresolved)
+	w.renderPlaylistsPage(c, message, "")
+}
+
+// Exportar una playlist como M3U8 o PLS
+func (w *WebController) ExportPlaylist(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Playlist no válida")
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "m3u8"))
+	name, entries, err := library.ExportPlaylist(w.db, id, w.config.MusicPath)
+	if err == sql.ErrNoRows {
+		c.String(http.StatusNotFound, "Playlist no encontrada")
+		return
+	}
+	if err != nil {
+		log.Printf("Error exportando la playlist %d: %v", id, err)
+		c.String(http.StatusInternalServerError, "Error al exportar la playlist")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := playlistfile.Write(format, &buf, entries); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"."+format))
+	c.Data(http.StatusOK, playlistfile.ContentType(format), buf.Bytes())
+}
+
+func (w *WebController) renderPlaylistsPage(c *gin.Context, message, errorMessage string) {
+	data := gin.H{
+		"playlists": w.getPlaylists(),
+		"musicPath": w.config.MusicPath,
+	}
+	if message != "" {
+		data["message"] = message
+	}
+	if errorMessage != "" {
+		data["error"] = errorMessage
+	}
+	w.renderPage(c, "Playlists", "playlists", data)
+}
+
+func (w *WebController) getPlaylists() []PlaylistSummary {
+	rows, err := w.db.Query(`
+		SELECT p.id, p.name, u.username, p.is_public, p.rules IS NOT NULL,
+		       (SELECT COUNT(*) FROM playlist_songs ps WHERE ps.playlist_id = p.id),
+		       p.source_path, p.updated_at
+		FROM playlists p
+		JOIN users u ON p.user_id = u.id
+		ORDER BY p.name
+	`)
+	if err != nil {
+		log.Printf("Error obteniendo playlists: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var playlists []PlaylistSummary
+	for rows.Next() {
+		var playlist PlaylistSummary
+		var sourcePath sql.NullString
+		err := rows.Scan(&playlist.ID, &playlist.Name, &playlist.Owner, &playlist.Public, &playlist.Smart,
+			&playlist.SongCount, &sourcePath, &playlist.Updated)
+		if err != nil {
+			continue
+		}
+		playlist.SourcePath = sourcePath.String
+		playlists = append(playlists, playlist)
+	}
+	rows.Close()
+
+	for i := range playlists {
+		if playlists[i].SourcePath == "" {
+			continue
+		}
+		unresolved, err := library.UnresolvedEntries(w.db, playlists[i].ID)
+		if err != nil {
+			log.Printf("Error obteniendo entradas sin resolver de la playlist %d: %v", playlists[i].ID, err)
+		}
+		playlists[i].Unresolved = unresolved
+	}
+
+	return playlists
+}
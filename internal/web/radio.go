@@ -0,0 +1,118 @@
+package web
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RadioStation es una emisora de radio por internet
+type RadioStation struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`
+	StreamURL   string `json:"stream_url"`
+	HomePageURL string `json:"homepage_url"`
+}
+
+// Página de emisoras de radio
+func (w *WebController) RadioStations(c *gin.Context) {
+	w.renderRadioPage(c, "")
+}
+
+// Crear emisora de radio (POST)
+func (w *WebController) CreateRadioStation(c *gin.Context) {
+	name := strings.TrimSpace(c.PostForm("name"))
+	streamURL := strings.TrimSpace(c.PostForm("stream_url"))
+	homepageURL := strings.TrimSpace(c.PostForm("homepage_url"))
+
+	if name == "" || streamURL == "" {
+		w.renderRadioPage(c, "El nombre y la URL del stream son obligatorios")
+		return
+	}
+
+	_, err := w.db.Exec(`
+		INSERT INTO internet_radio_stations (name, stream_url, homepage_url)
+		VALUES ($1, $2, $3)
+	`, name, streamURL, homepageURL)
+	if err != nil {
+		w.renderRadioPage(c, "Error al crear la emisora: "+err.Error())
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/admin/radio")
+}
+
+// Editar emisora de radio (POST)
+func (w *WebController) EditRadioStation(c *gin.Context) {
+	stationID := c.Param("id")
+	name := strings.TrimSpace(c.PostForm("name"))
+	streamURL := strings.TrimSpace(c.PostForm("stream_url"))
+	homepageURL := strings.TrimSpace(c.PostForm("homepage_url"))
+
+	if name == "" || streamURL == "" {
+		w.renderRadioPage(c, "El nombre y la URL del stream son obligatorios")
+		return
+	}
+
+	_, err := w.db.Exec(`
+		UPDATE internet_radio_stations
+		SET name = $1, stream_url = $2, homepage_url = $3, updated_at = NOW()
+		WHERE id = $4
+	`, name, streamURL, homepageURL, stationID)
+	if err != nil {
+		w.renderRadioPage(c, "Error al actualizar la emisora: "+err.Error())
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/admin/radio")
+}
+
+// Eliminar emisora de radio (POST)
+func (w *WebController) DeleteRadioStation(c *gin.Context) {
+	_, err := w.db.Exec("DELETE FROM internet_radio_stations WHERE id = $1", c.Param("id"))
+	if err != nil {
+		w.renderRadioPage(c, "Error al eliminar la emisora: "+err.Error())
+		return
+	}
+
+	c.Redirect(http.StatusFound, "/admin/radio")
+}
+
+func (w *WebController) renderRadioPage(c *gin.Context, errorMessage string) {
+	data := gin.H{
+		"stations": w.getRadioStations(),
+	}
+	if errorMessage != "" {
+		data["error"] = errorMessage
+	}
+	w.renderPage(c, "Radio por Internet", "radio", data)
+}
+
+func (w *WebController) getRadioStations() []RadioStation {
+	rows, err := w.db.Query(`
+		SELECT id, name, stream_url, homepage_url
+		FROM internet_radio_stations
+		ORDER BY name
+	`)
+	if err != nil {
+		log.Printf("Error obteniendo emisoras de radio: %v", err)
+		return nil
+	}
+	defer rows.Close()
+
+	var stations []RadioStation
+	for rows.Next() {
+		var station RadioStation
+		var homepageURL sql.NullString
+		if err := rows.Scan(&station.ID, &station.Name, &station.StreamURL, &homepageURL); err != nil {
+			continue
+		}
+		station.HomePageURL = homepageURL.String
+		stations = append(stations, station)
+	}
+
+	return stations
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"castafiore-backend/internal/stats"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Periodos que se ofrecen en la página de estadísticas
+var statsPeriods = []struct{ Value, Label string }{
+	{"week", "Últimos 7 días"},
+	{"month", "Últimos 30 días"},
+	{"year", "Últimos 365 días"},
+	{"all", "Todo"},
+}
+
+// Página de estadísticas de escucha de un usuario
+func (w *WebController) ListeningStats(c *gin.Context) {
+	users := w.getAllUsers()
+	data := gin.H{
+		"users":   users,
+		"periods": statsPeriods,
+		"period":  c.DefaultQuery("period", stats.DefaultPeriod),
+		"year":    time.Now().Year(),
+	}
+
+	// Sin usuario seleccionado se muestran las estadísticas propias
+	userID, err := strconv.Atoi(c.Query("user"))
+	if err != nil {
+		userID = c.GetInt("user_id")
+	}
+	data["userID"] = userID
+
+	period, err := stats.ParsePeriod(c.Query("period"), time.Now())
+	if err != nil {
+		data["error"] = "Periodo no válido: " + c.Query("period")
+		w.renderPage(c, "Estadísticas de escucha", "stats", data)
+		return
+	}
+
+	report, err := w.stats.Report(userID, period, stats.DefaultLimit)
+	if err != nil {
+		log.Printf("Error calculando las estadísticas del usuario %d: %v", userID, err)
+		data["error"] = "Error al calcular las estadísticas"
+	}
+	data["report"] = report
+
+	w.renderPage(c, "Estadísticas de escucha", "stats", data)
+}
+
+// Exportar el resumen anual de un usuario como JSON o HTML
+func (w *WebController) ExportYearlyReport(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Query("user"))
+	if err != nil {
+		c.String(http.StatusBadRequest, "Usuario no válido")
+		return
+	}
+	year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+	if err != nil || year < 1 || year > 9999 {
+		c.String(http.StatusBadRequest, "Año no válido")
+		return
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "json" && format != "html" {
+		c.String(http.StatusBadRequest, "Formato no válido: "+format)
+		return
+	}
+
+	wrapped, err := w.stats.Wrapped(userID, year, stats.DefaultLimit)
+	if err == sql.ErrNoRows {
+		c.String(http.StatusNotFound, "Usuario no encontrado")
+		return
+	}
+	if err != nil {
+		log.Printf("Error calculando el resumen %d del usuario %d: %v", year, userID, err)
+		c.String(http.StatusInternalServerError, "Error al calcular el resumen anual")
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%d.%s", wrapped.Username, year, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	if format == "json" {
+		c.JSON(http.StatusOK, wrapped)
+		return
+	}
+	c.HTML(http.StatusOK, "wrapped.html", gin.H{"title": fmt.Sprintf("%d en música", year), "report": wrapped})
+}
@@ -0,0 +1,20 @@
+-- Crear tabla de colas de reproducción (una por usuario)
+CREATE TABLE IF NOT EXISTS play_queues (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    current_index INTEGER,
+    position BIGINT NOT NULL DEFAULT 0,
+    changed_by VARCHAR(255),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Crear tabla de entradas de la cola de reproducción
+CREATE TABLE IF NOT EXISTS play_queue_entries (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES play_queues(user_id) ON DELETE CASCADE,
+    song_id INTEGER REFERENCES songs(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    UNIQUE(user_id, position)
+);
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_play_queue_entries_user_id ON play_queue_entries(user_id);
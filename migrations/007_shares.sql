@@ -0,0 +1,28 @@
+-- Crear tabla de enlaces públicos compartidos
+CREATE TABLE IF NOT EXISTS shares (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    token VARCHAR(64) UNIQUE NOT NULL,
+    description TEXT,
+    expires_at TIMESTAMPTZ,
+    visit_count INTEGER NOT NULL DEFAULT 0,
+    last_visited TIMESTAMPTZ,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Crear tabla de canciones incluidas en cada enlace compartido
+-- (los álbumes y playlists se expanden a sus canciones al crear el enlace)
+CREATE TABLE IF NOT EXISTS share_entries (
+    id SERIAL PRIMARY KEY,
+    share_id INTEGER REFERENCES shares(id) ON DELETE CASCADE,
+    song_id INTEGER REFERENCES songs(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    UNIQUE(share_id, song_id)
+);
+
+-- Registrar desde qué enlace compartido se reprodujo una canción
+ALTER TABLE play_history ADD COLUMN IF NOT EXISTS share_id INTEGER REFERENCES shares(id) ON DELETE SET NULL;
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_shares_user_id ON shares(user_id);
+CREATE INDEX IF NOT EXISTS idx_share_entries_share_id ON share_entries(share_id);
@@ -0,0 +1,9 @@
+-- Crear tabla de emisoras de radio por internet
+CREATE TABLE IF NOT EXISTS internet_radio_stations (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    stream_url TEXT NOT NULL,
+    homepage_url TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    updated_at TIMESTAMP DEFAULT NOW()
+);
@@ -0,0 +1,39 @@
+-- Crear tabla de canales de podcast (suscripciones)
+CREATE TABLE IF NOT EXISTS podcast_channels (
+    id SERIAL PRIMARY KEY,
+    url TEXT UNIQUE NOT NULL,
+    title VARCHAR(500),
+    description TEXT,
+    image_url TEXT,
+    cover_art_path VARCHAR(1000),
+    status VARCHAR(20) NOT NULL DEFAULT 'new',
+    error_message TEXT,
+    last_refreshed TIMESTAMP,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+-- Crear tabla de episodios de podcast
+-- status: new, downloading, completed, error, deleted, skipped
+CREATE TABLE IF NOT EXISTS podcast_episodes (
+    id SERIAL PRIMARY KEY,
+    channel_id INTEGER REFERENCES podcast_channels(id) ON DELETE CASCADE,
+    guid TEXT NOT NULL,
+    title VARCHAR(500),
+    description TEXT,
+    publish_date TIMESTAMP,
+    duration INTEGER DEFAULT 0,
+    enclosure_url TEXT NOT NULL,
+    enclosure_type VARCHAR(100),
+    enclosure_length BIGINT,
+    file_path VARCHAR(1000),
+    file_size BIGINT,
+    format VARCHAR(10),
+    status VARCHAR(20) NOT NULL DEFAULT 'new',
+    error_message TEXT,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE(channel_id, guid)
+);
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_podcast_episodes_channel_id ON podcast_episodes(channel_id);
+CREATE INDEX IF NOT EXISTS idx_podcast_episodes_publish_date ON podcast_episodes(publish_date DESC);
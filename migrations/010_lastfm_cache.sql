@@ -0,0 +1,12 @@
+-- Crear tabla de caché de respuestas de Last.fm
+-- La clave se construye con el método y los parámetros normalizados (minúsculas, sin espacios extra)
+CREATE TABLE IF NOT EXISTS lastfm_cache (
+    cache_key TEXT PRIMARY KEY,
+    method VARCHAR(100) NOT NULL,
+    body TEXT NOT NULL,
+    not_found BOOLEAN NOT NULL DEFAULT FALSE,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_lastfm_cache_method ON lastfm_cache(method);
@@ -0,0 +1,29 @@
+-- Crear tabla de sesiones de Last.fm por usuario
+CREATE TABLE IF NOT EXISTS user_lastfm_sessions (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    session_key VARCHAR(64),
+    lastfm_username VARCHAR(255),
+    pending_token VARCHAR(64),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Crear cola persistente de scrobbles pendientes de enviar a Last.fm
+CREATE TABLE IF NOT EXISTS lastfm_scrobble_queue (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    song_id INTEGER REFERENCES songs(id) ON DELETE SET NULL,
+    artist VARCHAR(500) NOT NULL,
+    track VARCHAR(500) NOT NULL,
+    album VARCHAR(500),
+    duration INTEGER,
+    track_number INTEGER,
+    played_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_lastfm_scrobble_queue_user_due ON lastfm_scrobble_queue(user_id, next_attempt_at);
@@ -0,0 +1,23 @@
+-- Guardar el token de ListenBrainz de cada usuario
+ALTER TABLE users ADD COLUMN IF NOT EXISTS listenbrainz_token VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS listenbrainz_username VARCHAR(255);
+
+-- Identificadores de MusicBrainz leídos de las etiquetas por el escáner
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS mbz_recording_id VARCHAR(36);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS mbz_release_id VARCHAR(36);
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS mbz_artist_id VARCHAR(36);
+
+-- Crear cola persistente de escuchas pendientes de enviar a ListenBrainz
+CREATE TABLE IF NOT EXISTS listenbrainz_queue (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    listen JSONB NOT NULL,
+    listened_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_listenbrainz_queue_user_due ON listenbrainz_queue(user_id, next_attempt_at);
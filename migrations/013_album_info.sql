@@ -0,0 +1,12 @@
+-- Crear tabla de información de álbumes obtenida de los agentes de metadatos
+-- Se guarda de forma persistente para que las siguientes consultas sean inmediatas
+CREATE TABLE IF NOT EXISTS album_info (
+    album_id INTEGER PRIMARY KEY REFERENCES albums(id) ON DELETE CASCADE,
+    notes TEXT,
+    musicbrainz_id VARCHAR(36),
+    lastfm_url TEXT,
+    small_image_url TEXT,
+    medium_image_url TEXT,
+    large_image_url TEXT,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,11 @@
+-- Habilitar búsqueda por similitud de trigramas
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Claves normalizadas para encontrar canciones de la biblioteca a partir de resultados externos
+-- (sin versiones como "Remastered", sin acentos ni puntuación). Se calculan en Go al escanear.
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS match_title TEXT;
+ALTER TABLE artists ADD COLUMN IF NOT EXISTS match_name TEXT;
+
+-- Crear índices de trigramas para las búsquedas aproximadas
+CREATE INDEX IF NOT EXISTS idx_songs_match_title_trgm ON songs USING GIN (match_title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_artists_match_name_trgm ON artists USING GIN (match_name gin_trgm_ops);
@@ -0,0 +1,19 @@
+-- Crear tablas de recomendaciones calculadas a partir del historial de reproducción
+-- Canciones que se escuchan en las mismas sesiones
+CREATE TABLE IF NOT EXISTS song_similarity (
+    song_id INTEGER REFERENCES songs(id) ON DELETE CASCADE,
+    similar_song_id INTEGER REFERENCES songs(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (song_id, similar_song_id)
+);
+
+-- Usuarios con gustos parecidos (filtrado colaborativo)
+CREATE TABLE IF NOT EXISTS user_similarity (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    similar_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    score DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (user_id, similar_user_id)
+);
+
+-- Crear índices para mejorar el rendimiento
+CREATE INDEX IF NOT EXISTS idx_play_history_song_id ON play_history(song_id);
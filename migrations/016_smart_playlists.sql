@@ -0,0 +1,3 @@
+-- Reglas de las playlists inteligentes (formato .nsp de Navidrome)
+-- Las playlists con reglas se evalúan al consultarlas y son de solo lectura
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS rules JSONB;
@@ -0,0 +1,12 @@
+-- Playlists importadas desde ficheros .m3u, .m3u8 y .pls de la carpeta de música
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS source_path TEXT;
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS source_modified TIMESTAMPTZ;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_playlists_source_path ON playlists(source_path);
+
+-- Entradas de los ficheros que no se encontraron en la biblioteca
+CREATE TABLE IF NOT EXISTS playlist_unresolved_entries (
+    playlist_id INTEGER REFERENCES playlists(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    entry TEXT NOT NULL,
+    PRIMARY KEY (playlist_id, position)
+);
@@ -0,0 +1,19 @@
+-- Colaboradores de playlists: los viewer pueden verla aunque sea privada,
+-- los editor además pueden añadir, quitar y reordenar canciones
+CREATE TABLE IF NOT EXISTS playlist_collaborators (
+    playlist_id INTEGER REFERENCES playlists(id) ON DELETE CASCADE,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    role VARCHAR(10) NOT NULL CHECK (role IN ('viewer', 'editor')),
+    added_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (playlist_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_playlist_collaborators_user_id ON playlist_collaborators(user_id);
+
+-- Usuario que añadió cada canción
+ALTER TABLE playlist_songs ADD COLUMN IF NOT EXISTS added_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+-- Hasta ahora solo el propietario podía añadir canciones
+UPDATE playlist_songs ps SET added_by = p.user_id
+FROM playlists p
+WHERE ps.playlist_id = p.id AND ps.added_by IS NULL;
@@ -0,0 +1,3 @@
+-- Imagen subida por el usuario como portada de la playlist.
+-- Si no hay, la portada se genera como mosaico de las carátulas de sus álbumes.
+ALTER TABLE playlists ADD COLUMN IF NOT EXISTS cover_art_path TEXT;
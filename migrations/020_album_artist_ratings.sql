@@ -0,0 +1,16 @@
+-- Calificaciones de álbumes y artistas (las de canciones están en ratings)
+CREATE TABLE IF NOT EXISTS album_ratings (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    album_id INTEGER REFERENCES albums(id) ON DELETE CASCADE,
+    rating INTEGER NOT NULL CHECK (rating >= 1 AND rating <= 5),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, album_id)
+);
+
+CREATE TABLE IF NOT EXISTS artist_ratings (
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    artist_id INTEGER REFERENCES artists(id) ON DELETE CASCADE,
+    rating INTEGER NOT NULL CHECK (rating >= 1 AND rating <= 5),
+    updated_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (user_id, artist_id)
+);
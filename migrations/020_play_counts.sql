@@ -0,0 +1,2 @@
+-- Las reproducciones por usuario y canción se cuentan en cada respuesta
+CREATE INDEX IF NOT EXISTS idx_play_history_user_song ON play_history(user_id, song_id);
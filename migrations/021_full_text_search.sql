@@ -0,0 +1,24 @@
+-- Búsqueda de texto completo sin acentos ("Mana" encuentra "Maná")
+CREATE EXTENSION IF NOT EXISTS unaccent;
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- unaccent() no es IMMUTABLE porque depende del diccionario configurado; este envoltorio
+-- fija el diccionario para poder usarlo en índices por expresión
+CREATE OR REPLACE FUNCTION search_unaccent(text) RETURNS text AS $$
+    SELECT public.unaccent('public.unaccent'::regdictionary, $1)
+$$ LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT;
+
+-- Vectores de búsqueda ponderados. Los calcula el escáner al terminar cada escaneo:
+-- canciones con título (A), artista (B) y álbum (C); álbumes con nombre (A) y artista (B)
+ALTER TABLE artists ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+ALTER TABLE albums ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+ALTER TABLE songs ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+CREATE INDEX IF NOT EXISTS idx_artists_search_vector ON artists USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_albums_search_vector ON albums USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_songs_search_vector ON songs USING GIN (search_vector);
+
+-- Índices de trigramas para tolerar errores de escritura cuando no hay coincidencias exactas
+CREATE INDEX IF NOT EXISTS idx_artists_name_unaccent_trgm ON artists USING GIN (search_unaccent(lower(name)) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_albums_name_unaccent_trgm ON albums USING GIN (search_unaccent(lower(name)) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_songs_title_unaccent_trgm ON songs USING GIN (search_unaccent(lower(title)) gin_trgm_ops);
@@ -0,0 +1,2 @@
+-- Las estadísticas de escucha recorren el historial de cada usuario por fechas
+CREATE INDEX IF NOT EXISTS idx_play_history_user_played_at ON play_history(user_id, played_at);
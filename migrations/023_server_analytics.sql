@@ -0,0 +1,38 @@
+-- Tablas de analíticas del servidor, recalculadas periódicamente a partir de
+-- play_history, downloads y la biblioteca
+-- Uso diario por usuario: reproducciones, descargas y bytes servidos (estimados)
+CREATE TABLE IF NOT EXISTS usage_daily (
+    day DATE NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    streams INTEGER NOT NULL DEFAULT 0,
+    downloads INTEGER NOT NULL DEFAULT 0,
+    bytes_served BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (day, user_id)
+);
+
+-- Reproducciones diarias por canción, para el contenido más escuchado
+CREATE TABLE IF NOT EXISTS song_streams_daily (
+    day DATE NOT NULL,
+    song_id INTEGER REFERENCES songs(id) ON DELETE CASCADE,
+    streams INTEGER NOT NULL,
+    PRIMARY KEY (day, song_id)
+);
+
+-- Crecimiento de la biblioteca: tamaño total al final de cada día, una instantánea por día
+CREATE TABLE IF NOT EXISTS library_growth_daily (
+    day DATE PRIMARY KEY,
+    artists INTEGER NOT NULL DEFAULT 0,
+    albums INTEGER NOT NULL DEFAULT 0,
+    songs INTEGER NOT NULL DEFAULT 0,
+    bytes BIGINT NOT NULL DEFAULT 0
+);
+
+-- Espacio ocupado por formato de audio
+CREATE TABLE IF NOT EXISTS storage_by_format (
+    format VARCHAR(20) PRIMARY KEY,
+    songs INTEGER NOT NULL,
+    bytes BIGINT NOT NULL
+);
+
+-- Las reproducciones se agregan por fecha (downloads ya tiene idx_downloads_date)
+CREATE INDEX IF NOT EXISTS idx_play_history_played_at ON play_history(played_at);
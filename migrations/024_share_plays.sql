@@ -0,0 +1,5 @@
+-- Las reproducciones a través de enlaces compartidos siguen en play_history con su
+-- share_id, y las estadísticas y recomendaciones del propietario las excluyen. La marca
+-- debe sobrevivir al borrado del enlace, así que share_id deja de ser una clave foránea
+-- (con ON DELETE SET NULL pasarían a contar como escuchas del propietario)
+ALTER TABLE play_history DROP CONSTRAINT IF EXISTS play_history_share_id_fkey;
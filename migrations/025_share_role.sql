@@ -0,0 +1,2 @@
+-- Permiso para crear enlaces compartidos (shareRole en la API)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS share_role BOOLEAN NOT NULL DEFAULT TRUE;
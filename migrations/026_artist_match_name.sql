@@ -0,0 +1,3 @@
+-- Índice para buscar artistas por su clave normalizada exacta (artistas similares de los
+-- agentes de metadatos); el índice de trigramas solo sirve para búsquedas aproximadas
+CREATE INDEX IF NOT EXISTS idx_artists_match_name ON artists(match_name);
@@ -0,0 +1,82 @@
+-- Vectores de búsqueda mantenidos por triggers: cada fila insertada o modificada calcula
+-- su vector al guardarse, en lugar de recalcular toda la biblioteca tras cada escaneo.
+-- Las filas sin vector (search_vector IS NULL) las completa el motor de búsqueda al arrancar.
+
+CREATE OR REPLACE FUNCTION search_artist_vector(artist_name TEXT) RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector('simple', search_unaccent(COALESCE(artist_name, ''))), 'A')
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION search_album_vector(album_name TEXT, album_artist_id INTEGER) RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector('simple', search_unaccent(COALESCE(album_name, ''))), 'A') ||
+           setweight(to_tsvector('simple', search_unaccent(COALESCE(
+               (SELECT name FROM artists WHERE id = album_artist_id), ''))), 'B')
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION search_song_vector(song_title TEXT, song_artist_id INTEGER, song_album_id INTEGER) RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector('simple', search_unaccent(COALESCE(song_title, ''))), 'A') ||
+           setweight(to_tsvector('simple', search_unaccent(COALESCE(
+               (SELECT name FROM artists WHERE id = song_artist_id), ''))), 'B') ||
+           setweight(to_tsvector('simple', search_unaccent(COALESCE(
+               (SELECT name FROM albums WHERE id = song_album_id), ''))), 'C')
+$$ LANGUAGE sql STABLE;
+
+-- Vector de la propia fila al insertarla o al cambiar los campos que lo forman
+CREATE OR REPLACE FUNCTION artists_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := search_artist_vector(NEW.name);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION albums_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := search_album_vector(NEW.name, NEW.artist_id);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION songs_search_vector_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := search_song_vector(NEW.title, NEW.artist_id, NEW.album_id);
+    RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS artists_search_vector ON artists;
+CREATE TRIGGER artists_search_vector BEFORE INSERT OR UPDATE OF name ON artists
+    FOR EACH ROW EXECUTE FUNCTION artists_search_vector_trigger();
+
+DROP TRIGGER IF EXISTS albums_search_vector ON albums;
+CREATE TRIGGER albums_search_vector BEFORE INSERT OR UPDATE OF name, artist_id ON albums
+    FOR EACH ROW EXECUTE FUNCTION albums_search_vector_trigger();
+
+DROP TRIGGER IF EXISTS songs_search_vector ON songs;
+CREATE TRIGGER songs_search_vector BEFORE INSERT OR UPDATE OF title, artist_id, album_id ON songs
+    FOR EACH ROW EXECUTE FUNCTION songs_search_vector_trigger();
+
+-- Los álbumes y canciones incluyen el nombre de su artista y álbum: al renombrarlos se
+-- actualizan también los vectores que los contienen
+CREATE OR REPLACE FUNCTION artists_rename_search_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE albums SET search_vector = search_album_vector(name, artist_id) WHERE artist_id = NEW.id;
+    UPDATE songs SET search_vector = search_song_vector(title, artist_id, album_id) WHERE artist_id = NEW.id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION albums_rename_search_trigger() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE songs SET search_vector = search_song_vector(title, artist_id, album_id) WHERE album_id = NEW.id;
+    RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS artists_rename_search ON artists;
+CREATE TRIGGER artists_rename_search AFTER UPDATE OF name ON artists
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION artists_rename_search_trigger();
+
+DROP TRIGGER IF EXISTS albums_rename_search ON albums;
+CREATE TRIGGER albums_rename_search AFTER UPDATE OF name ON albums
+    FOR EACH ROW WHEN (OLD.name IS DISTINCT FROM NEW.name)
+    EXECUTE FUNCTION albums_rename_search_trigger();